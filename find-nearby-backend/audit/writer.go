@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+
+	"github.com/pkg/errors"
+)
+
+// writeTimeout bounds how long a batch may take to be written, so a slow database can't stall the writer for good
+const writeTimeout = 10 * time.Second
+
+// Stats are the counters of a Writer since the process started
+type Stats struct {
+	Written uint64 `json:"written"`
+	Dropped uint64 `json:"dropped"`
+	Failed  uint64 `json:"failed"`
+	Pending int    `json:"pending"`
+}
+
+// Writer appends audit records to the audit log in the background. Records wait in a bounded buffer and are written
+// in batches of up to batchSize, or whatever is there every flushInterval, so recording never blocks the request
+// path: when the buffer is full, as when the database is down for long enough, records are dropped and counted.
+// Batches that fail to be written are counted and not retried
+type Writer struct {
+	// counters come first to keep them 64-bit aligned for sync/atomic
+	written uint64
+	dropped uint64
+	failed  uint64
+
+	logger        logger.Logger
+	repository    repository.AuditRepository
+	records       chan model.AuditRecord
+	batchSize     int
+	flushInterval time.Duration
+	stopOnce      sync.Once
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// NewWriter is a constructor for Writer. The writer runs until it is closed
+func NewWriter(logger logger.Logger, repository repository.AuditRepository, bufferSize, batchSize int, flushInterval time.Duration) *Writer {
+	w := &Writer{
+		logger:        logger,
+		repository:    repository,
+		records:       make(chan model.AuditRecord, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Record queues record to be written, or drops it when the buffer is full
+func (w *Writer) Record(record model.AuditRecord) {
+	select {
+	case w.records <- record:
+	default:
+		// logging every drop would flood the log exactly when the database is struggling
+		if dropped := atomic.AddUint64(&w.dropped, 1); dropped%1000 == 1 {
+			w.logger.Errorf("the audit buffer is full, %d audit records dropped so far", dropped)
+		}
+	}
+}
+
+// Close writes the records still in the buffer and stops the writer. Records queued after Close are never written
+func (w *Writer) Close(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "failed to write %d pending audit records", len(w.records))
+	}
+}
+
+// Stats returns a snapshot of the writer counters
+func (w *Writer) Stats() Stats {
+	return Stats{
+		Written: atomic.LoadUint64(&w.written),
+		Dropped: atomic.LoadUint64(&w.dropped),
+		Failed:  atomic.LoadUint64(&w.failed),
+		Pending: len(w.records),
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+	batch := make([]model.AuditRecord, 0, w.batchSize)
+	add := func(record model.AuditRecord) {
+		batch = append(batch, record)
+		if len(batch) == w.batchSize {
+			batch = w.flush(batch)
+		}
+	}
+	for {
+		select {
+		case record := <-w.records:
+			add(record)
+		case <-ticker.C:
+			batch = w.flush(batch)
+		case <-w.stop:
+			for {
+				select {
+				case record := <-w.records:
+					add(record)
+				default:
+					w.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes batch and returns an empty one to collect the next records in
+func (w *Writer) flush(batch []model.AuditRecord) []model.AuditRecord {
+	if len(batch) == 0 {
+		return batch
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err := w.repository.SaveAuditRecords(ctx, batch); err != nil {
+		atomic.AddUint64(&w.failed, uint64(len(batch)))
+		w.logger.Errorf("failed to write %d audit records, err: %s", len(batch), err.Error())
+	} else {
+		atomic.AddUint64(&w.written, uint64(len(batch)))
+	}
+	return make([]model.AuditRecord, 0, w.batchSize)
+}
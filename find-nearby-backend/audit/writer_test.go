@@ -0,0 +1,53 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"find-nearby-backend/audit"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWriter_ShouldWriteInBatchesAndFlushOnClose(t *testing.T) {
+	repo := &mocks.AuditRepository{}
+	repo.On("SaveAuditRecords", mock.Anything, mock.Anything).Return(nil)
+	writer := audit.NewWriter(logger.New("debug", "plaintext"), repo, 10, 2, time.Hour)
+
+	for _, requestID := range []string{"r-1", "r-2", "r-3"} {
+		writer.Record(model.AuditRecord{RequestID: requestID})
+	}
+	assert.NoError(t, writer.Close(context.Background()))
+
+	repo.AssertNumberOfCalls(t, "SaveAuditRecords", 2)
+	assert.Equal(t, []model.AuditRecord{{RequestID: "r-1"}, {RequestID: "r-2"}}, repo.Calls[0].Arguments.Get(1))
+	assert.Equal(t, []model.AuditRecord{{RequestID: "r-3"}}, repo.Calls[1].Arguments.Get(1))
+	assert.Equal(t, audit.Stats{Written: 3}, writer.Stats())
+}
+
+func TestWriter_WhenBufferIsFull_ShouldDropRecordsInsteadOfBlocking(t *testing.T) {
+	writing, release := make(chan struct{}), make(chan struct{})
+	repo := &mocks.AuditRepository{}
+	repo.On("SaveAuditRecords", mock.Anything, mock.Anything).Return(errors.New("connection refused")).Once().Run(func(mock.Arguments) {
+		close(writing)
+		<-release
+	})
+	repo.On("SaveAuditRecords", mock.Anything, mock.Anything).Return(nil)
+	writer := audit.NewWriter(logger.New("debug", "plaintext"), repo, 1, 1, time.Hour)
+
+	writer.Record(model.AuditRecord{RequestID: "r-1"})
+	<-writing
+	writer.Record(model.AuditRecord{RequestID: "r-2"})
+	writer.Record(model.AuditRecord{RequestID: "r-3"})
+	assert.Equal(t, audit.Stats{Dropped: 1, Pending: 1}, writer.Stats())
+
+	close(release)
+	assert.NoError(t, writer.Close(context.Background()))
+	assert.Equal(t, audit.Stats{Written: 1, Dropped: 1, Failed: 1}, writer.Stats())
+}
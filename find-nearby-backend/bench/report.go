@@ -0,0 +1,61 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is a benchmark: what was run, against what, and how every stage went
+type Report struct {
+	Target    string    `json:"target"`
+	StartedAt time.Time `json:"started_at"`
+	Seed      int64     `json:"seed"`
+	Workload  Workload  `json:"workload"`
+	Results   []Result  `json:"results"`
+}
+
+// WriteJSON writes the report as indented JSON, to be kept and compared with later runs
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes the report as a table with a row per stage, and the most frequent errors of every stage below it
+func (r Report) WriteText(w io.Writer) error {
+	bounds := r.Workload.Bounds
+	fmt.Fprintf(w, "Target: %s\n", r.Target)
+	fmt.Fprintf(w, "Origins: %f,%f to %f,%f (lat,lng)\n", bounds.MinLatitude, bounds.MinLongitude, bounds.MaxLatitude, bounds.MaxLongitude)
+	fmt.Fprintf(w, "Radii: %s, limits: %s, random seed: %d\n\n", choices(r.Workload.Radii), choices(r.Workload.Limits), r.Seed)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "concurrency\trequests\terrors\tqps\tmean ms\tp50 ms\tp90 ms\tp95 ms\tp99 ms\tmax ms\tfound\t")
+	for _, result := range r.Results {
+		fmt.Fprintf(table, "%d\t%d\t%.2f%%\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.1f\t\n",
+			result.Concurrency, result.Requests, result.ErrorRate*100, result.Throughput, result.Latency.Mean,
+			result.Latency.P50, result.Latency.P90, result.Latency.P95, result.Latency.P99, result.Latency.Max, result.MeanFound)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	for _, result := range r.Results {
+		for _, e := range result.TopErrors {
+			fmt.Fprintf(w, "concurrency %d: %d failed with %s\n", result.Concurrency, e.Count, e.Error)
+		}
+	}
+	return nil
+}
+
+func choices(list []Choice) string {
+	text := ""
+	for i, choice := range list {
+		if i > 0 {
+			text += ","
+		}
+		text += fmt.Sprintf("%d:%g", choice.Value, choice.Weight)
+	}
+	return text
+}
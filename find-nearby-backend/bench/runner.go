@@ -0,0 +1,165 @@
+package bench
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxErrorKinds is how many distinct errors a result keeps, most frequent first
+const maxErrorKinds = 5
+
+// Stage is a run of a workload at one concurrency level. It ends after Duration or, when Requests is set, once that
+// many queries were sent, whichever comes first
+type Stage struct {
+	Concurrency int
+	Duration    time.Duration
+	Requests    int
+}
+
+// Result is how a stage went. Latencies are of the queries that succeeded, in milliseconds, and Throughput counts
+// them per second
+type Result struct {
+	Concurrency int          `json:"concurrency"`
+	Requests    int          `json:"requests"`
+	Errors      int          `json:"errors"`
+	ErrorRate   float64      `json:"error_rate"`
+	Seconds     float64      `json:"seconds"`
+	Throughput  float64      `json:"throughput"`
+	MeanFound   float64      `json:"mean_found"`
+	Latency     Latency      `json:"latency_ms"`
+	TopErrors   []ErrorCount `json:"top_errors,omitempty"`
+}
+
+// Latency sums up the latencies of a stage, in milliseconds
+type Latency struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// ErrorCount is an error and how many queries failed with it
+type ErrorCount struct {
+	Error string `json:"error"`
+	Count int    `json:"count"`
+}
+
+// worker is what a goroutine of a stage measured
+type worker struct {
+	latencies []time.Duration
+	found     int
+	errors    map[string]int
+}
+
+// Run runs a stage of workload against target. Every worker draws its queries from its own source, seeded from
+// seed, so that the same seed sends the same queries at the same concurrency
+func Run(ctx context.Context, target Target, workload Workload, stage Stage, seed int64) Result {
+	ctx, cancel := context.WithTimeout(ctx, stage.Duration)
+	defer cancel()
+
+	var sent int64
+	workers := make([]*worker, stage.Concurrency)
+	var wg sync.WaitGroup
+	started := time.Now()
+	for i := range workers {
+		w := &worker{errors: map[string]int{}}
+		workers[i] = w
+		wg.Add(1)
+		go func(queries *queries) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if stage.Requests > 0 && atomic.AddInt64(&sent, 1) > int64(stage.Requests) {
+					return
+				}
+				query := queries.next()
+				begun := time.Now()
+				found, err := target.Find(ctx, query)
+				if err != nil {
+					// queries cut off by the end of the stage didn't fail
+					if ctx.Err() == nil {
+						w.errors[err.Error()]++
+					}
+					continue
+				}
+				w.latencies = append(w.latencies, time.Since(begun))
+				w.found += found
+			}
+		}(newQueries(workload, seed+int64(i)))
+	}
+	wg.Wait()
+	return summarize(stage.Concurrency, time.Since(started), workers)
+}
+
+func summarize(concurrency int, elapsed time.Duration, workers []*worker) Result {
+	result := Result{Concurrency: concurrency, Seconds: elapsed.Seconds()}
+	var latencies []time.Duration
+	found := 0
+	errors := map[string]int{}
+	for _, w := range workers {
+		latencies = append(latencies, w.latencies...)
+		found += w.found
+		for err, count := range w.errors {
+			errors[err] += count
+			result.Errors += count
+		}
+	}
+	result.Requests = len(latencies) + result.Errors
+	if result.Requests > 0 {
+		result.ErrorRate = float64(result.Errors) / float64(result.Requests)
+	}
+	if result.Seconds > 0 {
+		result.Throughput = float64(len(latencies)) / result.Seconds
+	}
+	if len(latencies) > 0 {
+		result.MeanFound = float64(found) / float64(len(latencies))
+		result.Latency = summarizeLatencies(latencies)
+	}
+	for err, count := range errors {
+		result.TopErrors = append(result.TopErrors, ErrorCount{Error: err, Count: count})
+	}
+	sort.Slice(result.TopErrors, func(i, j int) bool {
+		if result.TopErrors[i].Count != result.TopErrors[j].Count {
+			return result.TopErrors[i].Count > result.TopErrors[j].Count
+		}
+		return result.TopErrors[i].Error < result.TopErrors[j].Error
+	})
+	if len(result.TopErrors) > maxErrorKinds {
+		result.TopErrors = result.TopErrors[:maxErrorKinds]
+	}
+	return result
+}
+
+func summarizeLatencies(latencies []time.Duration) Latency {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	return Latency{
+		Mean: milliseconds(total / time.Duration(len(latencies))),
+		P50:  milliseconds(percentile(latencies, 50)),
+		P90:  milliseconds(percentile(latencies, 90)),
+		P95:  milliseconds(percentile(latencies, 95)),
+		P99:  milliseconds(percentile(latencies, 99)),
+		Max:  milliseconds(latencies[len(latencies)-1]),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*1000) / 1000
+}
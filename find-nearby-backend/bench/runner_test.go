@@ -0,0 +1,93 @@
+package bench_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"find-nearby-backend/bench"
+	"find-nearby-backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTarget fails every fifth query and finds Limit vehicles for the others
+type fakeTarget struct {
+	mu      sync.Mutex
+	queries []bench.Query
+}
+
+func (f *fakeTarget) Find(_ context.Context, query bench.Query) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries = append(f.queries, query)
+	if len(f.queries)%5 == 0 {
+		return 0, errors.New("connection refused")
+	}
+	return query.Limit, nil
+}
+
+var testWorkload = bench.Workload{
+	Bounds: model.Bounds{MinLatitude: 1.3, MinLongitude: 103.9, MaxLatitude: 1.4, MaxLongitude: 104.0},
+	Radii:  []bench.Choice{{Value: 500, Weight: 1}, {Value: 1000, Weight: 1}},
+	Limits: []bench.Choice{{Value: 10, Weight: 1}, {Value: 50, Weight: 0}},
+}
+
+func TestRun_ShouldStopAfterRequestsAndCountErrors(t *testing.T) {
+	target := &fakeTarget{}
+	result := bench.Run(context.Background(), target, testWorkload, bench.Stage{Concurrency: 4, Duration: time.Minute, Requests: 100}, 42)
+
+	assert.Len(t, target.queries, 100)
+	assert.Equal(t, 4, result.Concurrency)
+	assert.Equal(t, 100, result.Requests)
+	assert.Equal(t, 20, result.Errors)
+	assert.Equal(t, 0.2, result.ErrorRate)
+	assert.Equal(t, []bench.ErrorCount{{Error: "connection refused", Count: 20}}, result.TopErrors)
+	assert.Equal(t, 10.0, result.MeanFound)
+	assert.True(t, result.Throughput > 0)
+	latency := result.Latency
+	assert.True(t, latency.P50 <= latency.P90 && latency.P90 <= latency.P95 && latency.P95 <= latency.P99 && latency.P99 <= latency.Max)
+
+	for _, query := range target.queries {
+		assert.True(t, query.Latitude >= 1.3 && query.Latitude <= 1.4)
+		assert.True(t, query.Longitude >= 103.9 && query.Longitude <= 104.0)
+		assert.Contains(t, []int{500, 1000}, query.Radius)
+		assert.Equal(t, 10, query.Limit)
+	}
+}
+
+func TestRun_ShouldSendTheSameQueriesForTheSameSeed(t *testing.T) {
+	first, second := &fakeTarget{}, &fakeTarget{}
+	bench.Run(context.Background(), first, testWorkload, bench.Stage{Concurrency: 1, Duration: time.Minute, Requests: 20}, 7)
+	bench.Run(context.Background(), second, testWorkload, bench.Stage{Concurrency: 1, Duration: time.Minute, Requests: 20}, 7)
+	assert.Equal(t, first.queries, second.queries)
+}
+
+func TestRun_ShouldStopAfterDuration(t *testing.T) {
+	result := bench.Run(context.Background(), &fakeTarget{}, testWorkload, bench.Stage{Concurrency: 2, Duration: 50 * time.Millisecond}, 1)
+	assert.True(t, result.Requests > 0)
+	assert.InDelta(t, 0.05, result.Seconds, 0.04)
+}
+
+func TestReport_ShouldWriteTextAndJSON(t *testing.T) {
+	result := bench.Run(context.Background(), &fakeTarget{}, testWorkload, bench.Stage{Concurrency: 2, Duration: time.Minute, Requests: 10}, 1)
+	report := bench.Report{Target: "repository", Seed: 1, Workload: testWorkload, Results: []bench.Result{result}}
+
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), "Radii: 500:1,1000:1, limits: 10:1,50:0, random seed: 1")
+	assert.Contains(t, text.String(), "p99 ms")
+	assert.Contains(t, text.String(), "concurrency 2: 2 failed with connection refused")
+
+	var decoded bench.Report
+	var encoded bytes.Buffer
+	require.NoError(t, report.WriteJSON(&encoded))
+	require.NoError(t, json.Unmarshal(encoded.Bytes(), &decoded))
+	assert.Equal(t, report.Results, decoded.Results)
+	assert.Equal(t, report.Workload, decoded.Workload)
+}
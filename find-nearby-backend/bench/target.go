@@ -0,0 +1,83 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"find-nearby-backend/repository"
+)
+
+// maxErrorBody is how much of the body of a failed response ends up in its error
+const maxErrorBody = 200
+
+// Target runs the queries of a benchmark and returns how many vehicles each found
+type Target interface {
+	Find(ctx context.Context, query Query) (int, error)
+}
+
+type httpTarget struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+}
+
+// NewHTTPTarget returns a target that searches GET /locations/find of the server at baseURL, sending apiKey as
+// X-API-Key when it is set
+func NewHTTPTarget(client *http.Client, baseURL, apiKey string) Target {
+	return httpTarget{client: client, endpoint: strings.TrimRight(baseURL, "/") + "/locations/find", apiKey: apiKey}
+}
+
+func (h httpTarget) Find(ctx context.Context, query Query) (int, error) {
+	params := url.Values{}
+	params.Set("latitude", strconv.FormatFloat(query.Latitude, 'f', -1, 64))
+	params.Set("longitude", strconv.FormatFloat(query.Longitude, 'f', -1, 64))
+	params.Set("radius", strconv.Itoa(query.Radius))
+	params.Set("limit", strconv.Itoa(query.Limit))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	if h.apiKey != "" {
+		req.Header.Set("X-API-Key", h.apiKey)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return 0, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var found struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		return 0, fmt.Errorf("failed to decode the response: %v", err)
+	}
+	return len(found.Data), nil
+}
+
+type repositoryTarget struct {
+	repo repository.LocationRepository
+}
+
+// NewRepositoryTarget returns a target that searches repo directly, leaving HTTP and the usecase out of the picture
+func NewRepositoryTarget(repo repository.LocationRepository) Target {
+	return repositoryTarget{repo: repo}
+}
+
+func (r repositoryTarget) Find(ctx context.Context, query Query) (int, error) {
+	nearby, err := r.repo.FindVehicleLocations(ctx, query.Latitude, query.Longitude, query.Radius, query.Limit)
+	if err != nil {
+		return 0, err
+	}
+	return len(nearby.Locations), nil
+}
@@ -0,0 +1,115 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"find-nearby-backend/model"
+)
+
+// Choice is a value and how often it comes up relative to the other choices
+type Choice struct {
+	Value  int     `json:"value"`
+	Weight float64 `json:"weight"`
+}
+
+// ParseChoices reads choices from a list like 500:60,1000:30,5000:10
+func ParseChoices(list string) ([]Choice, error) {
+	var choices []Choice
+	total := 0.0
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid choice %q; choices must look like value:weight", entry)
+		}
+		value, err := strconv.Atoi(parts[0])
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid choice %q; the value must be a positive integer", entry)
+		}
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || weight < 0 || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("invalid choice %q; the weight must be a non-negative number", entry)
+		}
+		choices = append(choices, Choice{Value: value, Weight: weight})
+		total += weight
+	}
+	if len(choices) == 0 {
+		return nil, errors.New("no choices given")
+	}
+	if total <= 0 {
+		return nil, errors.New("the weights add up to 0")
+	}
+	return choices, nil
+}
+
+// Query is one nearby search of a workload. Radius is in meters
+type Query struct {
+	Latitude  float64
+	Longitude float64
+	Radius    int
+	Limit     int
+}
+
+// Workload describes the nearby searches of a benchmark: origins spread uniformly over Bounds, and radii and limits
+// drawn from their choices
+type Workload struct {
+	Bounds model.Bounds `json:"bounds"`
+	Radii  []Choice     `json:"radii"`
+	Limits []Choice     `json:"limits"`
+}
+
+// queries draws the queries of a workload. It isn't safe for concurrent use; every worker has its own
+type queries struct {
+	workload Workload
+	rnd      *rand.Rand
+	radii    []float64
+	limits   []float64
+}
+
+func newQueries(workload Workload, seed int64) *queries {
+	return &queries{
+		workload: workload,
+		rnd:      rand.New(rand.NewSource(seed)),
+		radii:    accumulate(workload.Radii),
+		limits:   accumulate(workload.Limits),
+	}
+}
+
+func (q *queries) next() Query {
+	bounds := q.workload.Bounds
+	return Query{
+		Latitude:  bounds.MinLatitude + q.rnd.Float64()*(bounds.MaxLatitude-bounds.MinLatitude),
+		Longitude: bounds.MinLongitude + q.rnd.Float64()*(bounds.MaxLongitude-bounds.MinLongitude),
+		Radius:    q.workload.Radii[q.pick(q.radii)].Value,
+		Limit:     q.workload.Limits[q.pick(q.limits)].Value,
+	}
+}
+
+// pick returns the index of a weight drawn from accumulated weights
+func (q *queries) pick(accumulated []float64) int {
+	i := sort.SearchFloat64s(accumulated, q.rnd.Float64()*accumulated[len(accumulated)-1])
+	if i == len(accumulated) {
+		i--
+	}
+	return i
+}
+
+// accumulate returns the sum of the weights up to every choice
+func accumulate(choices []Choice) []float64 {
+	accumulated := make([]float64, len(choices))
+	sum := 0.0
+	for i, choice := range choices {
+		sum += choice.Weight
+		accumulated[i] = sum
+	}
+	return accumulated
+}
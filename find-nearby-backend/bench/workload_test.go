@@ -0,0 +1,23 @@
+package bench_test
+
+import (
+	"testing"
+
+	"find-nearby-backend/bench"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChoices_ShouldReadValuesAndWeights(t *testing.T) {
+	choices, err := bench.ParseChoices("500:60, 1000:30,5000:0.5")
+	require.NoError(t, err)
+	assert.Equal(t, []bench.Choice{{Value: 500, Weight: 60}, {Value: 1000, Weight: 30}, {Value: 5000, Weight: 0.5}}, choices)
+}
+
+func TestParseChoices_ShouldRejectMalformedChoices(t *testing.T) {
+	for _, list := range []string{"", "500", "500:", "-1:10", "abc:10", "500:-1", "500:0,1000:0", "500:10:2"} {
+		_, err := bench.ParseChoices(list)
+		assert.Error(t, err, list)
+	}
+}
@@ -0,0 +1,345 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultGridSize is the grid cell size used when none is configured, roughly 55m at the equator
+const DefaultGridSize = 0.0005
+
+const (
+	// loadTimeout bounds a load from the underlying repository, which runs apart from the requests waiting on it
+	loadTimeout = 10 * time.Second
+	// earthRadius is the mean radius of the earth in meters
+	earthRadius = 6371008.8
+	// sphereError is how far, relative to the distance, distances on a sphere may be off from the spheroid of PostGIS
+	sphereError = 0.005
+	// entryFactor times the max limit is the most vehicles an entry holds
+	entryFactor = 4
+	// minPrune is how many entries are tracked before the ones that expired are first dropped
+	minPrune = 1024
+)
+
+// Stats are the cache counters since the process started
+type Stats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Loads       uint64 `json:"loads"`
+	LoadErrors  uint64 `json:"load_errors"`
+	StoreErrors uint64 `json:"store_errors"`
+	Bypasses    uint64 `json:"bypasses"`
+}
+
+// LocationRepository is a repository.LocationRepository decorator that caches nearby results.
+// Origins in the same grid cell share a cache entry, whatever the limit, and concurrent misses for the same key are
+// collapsed into a single query to the underlying repository. Distances of cached results are computed on a sphere,
+// so they are within half a percent of those of the database. A cell with more vehicles within the radius than an
+// entry holds is searched in the underlying repository instead, so a large radius can't load the whole fleet.
+// Holders find the vehicles they hold, which are left out for everyone else, so their searches have entries of their own.
+// Writes drop only the entries they make stale, which are tracked in process: a store shared by several instances
+// has the entries of the other instances expire with the TTL instead
+type LocationRepository struct {
+	// counters come first to keep them 64-bit aligned for sync/atomic
+	hits        uint64
+	misses      uint64
+	loads       uint64
+	loadErrors  uint64
+	storeErrors uint64
+	bypasses    uint64
+	ttl         int64
+	maxLimit    int64
+
+	logger     logger.Logger
+	repository repository.LocationRepository
+	store      Store
+	gridSize   float64
+	group      singleflight.Group
+
+	mu        sync.Mutex
+	entries   map[string]entry
+	nextPrune int
+}
+
+// entry is what the cache knows of an entry it stored: the circle it was loaded from and the vehicles it lists
+type entry struct {
+	latitude   float64
+	longitude  float64
+	radius     float64
+	vehicleIDs []int64
+	expiresAt  time.Time
+}
+
+// NewLocationRepository is a constructor for LocationRepository.
+// gridSize is the size of a grid cell in degrees, ttl is how long results stay in the store and maxLimit is the
+// largest limit searches can ask for
+func NewLocationRepository(logger logger.Logger, repository repository.LocationRepository, store Store, gridSize float64, ttl time.Duration, maxLimit int) *LocationRepository {
+	if gridSize <= 0 {
+		gridSize = DefaultGridSize
+	}
+	return &LocationRepository{
+		logger:     logger,
+		repository: repository,
+		store:      store,
+		gridSize:   gridSize,
+		ttl:        int64(ttl),
+		maxLimit:   int64(maxLimit),
+		entries:    make(map[string]entry),
+		nextPrune:  minPrune,
+	}
+}
+
+// FindVehicleLocations returns the vehicles within radius of the origin, closest first. Origins in the same grid cell
+// share an entry: on a miss every vehicle within radius of the cell centre, widened by half the cell diagonal, is
+// loaded, so the entry covers any origin in the cell. Hits then measure the distances from the actual origin, drop
+// the vehicles beyond radius and keep the closest limit of them. At most entryFactor times the max limit vehicles are
+// loaded; when there are more, the entry only records that, and the search goes to the underlying repository
+func (r *LocationRepository) FindVehicleLocations(ctx context.Context, latitude, longitude float64, radius, limit int) (model.NearbyLocations, error) {
+	span := trace.SpanFromContext(ctx)
+	cellLat, cellLng := r.cell(latitude), r.cell(longitude)
+	entryLimit := r.entryLimit()
+	key := fmt.Sprintf("nearby:%d:%d:%d:%d", cellLat, cellLng, radius, entryLimit)
+	holder := repository.HolderFromContext(ctx)
+	if holder != "" {
+		key += ":" + holder
+	}
+
+	cell, found, err := r.store.Get(ctx, key)
+	if err != nil {
+		atomic.AddUint64(&r.storeErrors, 1)
+		r.logger.WithContext(ctx).Warnf("failed to read nearby cache entry %s, err: %s", key, err.Error())
+	}
+	if found {
+		atomic.AddUint64(&r.hits, 1)
+		span.SetAttributes(attribute.Bool("nearby.cache_hit", true))
+		if overflowed(cell) {
+			return r.bypass(ctx, latitude, longitude, radius, limit)
+		}
+		return nearest(cell, latitude, longitude, radius, limit), nil
+	}
+	atomic.AddUint64(&r.misses, 1)
+	span.SetAttributes(attribute.Bool("nearby.cache_hit", false))
+
+	// the load is shared by every caller collapsed onto it, so one of them going away mustn't cancel it
+	result, err, _ := r.group.Do(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(trace.ContextWithSpanContext(context.Background(), span.SpanContext()), loadTimeout)
+		loadCtx = repository.ContextWithHolder(loadCtx, holder)
+		defer cancel()
+		atomic.AddUint64(&r.loads, 1)
+		centreLat := math.Max(-90, math.Min(90, r.centre(cellLat)))
+		centreLng := math.Max(-180, math.Min(180, r.centre(cellLng)))
+		cellRadius := r.cellRadius(radius)
+		loaded, err := r.repository.FindVehicleLocations(loadCtx, centreLat, centreLng, cellRadius, entryLimit)
+		if err != nil {
+			atomic.AddUint64(&r.loadErrors, 1)
+			return nil, err
+		}
+		if overflowed(loaded) {
+			// the entry can't answer for origins other than the centre; it only saves the next miss a load
+			loaded = model.NearbyLocations{Total: loaded.Total}
+		}
+		ttl := r.TTL()
+		if err := r.store.Set(loadCtx, key, loaded, ttl); err != nil {
+			atomic.AddUint64(&r.storeErrors, 1)
+			r.logger.WithContext(ctx).Warnf("failed to write nearby cache entry %s, err: %s", key, err.Error())
+		} else {
+			r.track(key, centreLat, centreLng, cellRadius, loaded, ttl)
+		}
+		return loaded, nil
+	})
+	if err != nil {
+		return model.NearbyLocations{}, err
+	}
+	if overflowed(result.(model.NearbyLocations)) {
+		return r.bypass(ctx, latitude, longitude, radius, limit)
+	}
+	return nearest(result.(model.NearbyLocations), latitude, longitude, radius, limit), nil
+}
+
+// bypass searches the underlying repository from the origin, for cells with too many vehicles to cache
+func (r *LocationRepository) bypass(ctx context.Context, latitude, longitude float64, radius, limit int) (model.NearbyLocations, error) {
+	atomic.AddUint64(&r.bypasses, 1)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("nearby.cache_bypass", true))
+	return r.repository.FindVehicleLocations(ctx, latitude, longitude, radius, limit)
+}
+
+// ExplainVehicleLocations passes through to the underlying repository, so that the plan is of the database query
+func (r *LocationRepository) ExplainVehicleLocations(ctx context.Context, latitude, longitude float64, radius, limit int) ([]string, error) {
+	return r.repository.ExplainVehicleLocations(ctx, latitude, longitude, radius, limit)
+}
+
+// FindLocationBounds passes through to the underlying repository; it is only asked for by tools, not by searches
+func (r *LocationRepository) FindLocationBounds(ctx context.Context) (*model.Bounds, error) {
+	return r.repository.FindLocationBounds(ctx)
+}
+
+// FindVehicleLocationsBatch passes batch searches through to the underlying repository. Batches come from dispatchers
+// with origins spread across the map and are already a single query, so caching them per cell would gain little
+func (r *LocationRepository) FindVehicleLocationsBatch(ctx context.Context, queries []model.NearbyQuery) ([]model.NearbyLocations, error) {
+	return r.repository.FindVehicleLocationsBatch(ctx, queries)
+}
+
+// FindVehicleLocationsAlongRoute passes corridor searches through to the underlying repository; routes rarely repeat
+func (r *LocationRepository) FindVehicleLocationsAlongRoute(ctx context.Context, corridor model.Corridor) (model.NearbyLocations, error) {
+	return r.repository.FindVehicleLocationsAlongRoute(ctx, corridor)
+}
+
+// Invalidate drops the cached results that list any of vehicleIDs, which may have moved away or be taken, and those
+// loaded from a circle that covers any of positions, where vehicles may have moved to or become available. Write paths
+// call it after changing where vehicles are or whether they show up nearby
+func (r *LocationRepository) Invalidate(ctx context.Context, vehicleIDs []int64, positions []model.Location) error {
+	changed := make(map[int64]bool, len(vehicleIDs))
+	for _, id := range vehicleIDs {
+		changed[id] = true
+	}
+	now := time.Now()
+	var stale []string
+	r.mu.Lock()
+	for key, e := range r.entries {
+		switch {
+		case !now.Before(e.expiresAt):
+			delete(r.entries, key)
+		case e.lists(changed) || e.covers(positions):
+			delete(r.entries, key)
+			stale = append(stale, key)
+		}
+	}
+	r.mu.Unlock()
+	if len(stale) == 0 {
+		return nil
+	}
+	return r.store.Delete(ctx, stale...)
+}
+
+// track records an entry that was stored, so that Invalidate can find it. The entries that expired are dropped once
+// their number doubles, which bounds them by the loads of twice the TTL
+func (r *LocationRepository) track(key string, latitude, longitude float64, radius int, loaded model.NearbyLocations, ttl time.Duration) {
+	vehicleIDs := make([]int64, len(loaded.Locations))
+	for i, location := range loaded.Locations {
+		vehicleIDs[i] = location.VehicleID
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[key] = entry{latitude: latitude, longitude: longitude, radius: float64(radius), vehicleIDs: vehicleIDs, expiresAt: now.Add(ttl)}
+	if len(r.entries) < r.nextPrune {
+		return
+	}
+	for key, e := range r.entries {
+		if !now.Before(e.expiresAt) {
+			delete(r.entries, key)
+		}
+	}
+	r.nextPrune = int(math.Max(minPrune, float64(2*len(r.entries))))
+}
+
+// lists tells whether the entry lists any of the vehicles in changed
+func (e entry) lists(changed map[int64]bool) bool {
+	for _, id := range e.vehicleIDs {
+		if changed[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// covers tells whether any of positions is within the circle the entry was loaded from
+func (e entry) covers(positions []model.Location) bool {
+	for _, position := range positions {
+		if distance(e.latitude, e.longitude, position.Latitude, position.Longitude) <= e.radius*(1+sphereError) {
+			return true
+		}
+	}
+	return false
+}
+
+// TTL returns how long new entries stay in the store
+func (r *LocationRepository) TTL() time.Duration {
+	return time.Duration(atomic.LoadInt64(&r.ttl))
+}
+
+// SetTTL changes how long new entries stay in the store
+func (r *LocationRepository) SetTTL(ttl time.Duration) {
+	atomic.StoreInt64(&r.ttl, int64(ttl))
+}
+
+// SetMaxLimit changes the largest limit searches can ask for, and with it how many vehicles an entry holds
+func (r *LocationRepository) SetMaxLimit(maxLimit int) {
+	atomic.StoreInt64(&r.maxLimit, int64(maxLimit))
+}
+
+// Stats returns a snapshot of the cache counters
+func (r *LocationRepository) Stats() Stats {
+	return Stats{
+		Hits:        atomic.LoadUint64(&r.hits),
+		Misses:      atomic.LoadUint64(&r.misses),
+		Loads:       atomic.LoadUint64(&r.loads),
+		LoadErrors:  atomic.LoadUint64(&r.loadErrors),
+		StoreErrors: atomic.LoadUint64(&r.storeErrors),
+		Bypasses:    atomic.LoadUint64(&r.bypasses),
+	}
+}
+
+func (r *LocationRepository) entryLimit() int {
+	return int(math.Min(float64(atomic.LoadInt64(&r.maxLimit))*entryFactor, math.MaxInt32))
+}
+
+func (r *LocationRepository) cell(degrees float64) int64 {
+	return int64(math.Floor(degrees / r.gridSize))
+}
+
+func (r *LocationRepository) centre(cell int64) float64 {
+	return (float64(cell) + 0.5) * r.gridSize
+}
+
+// cellRadius widens radius so that the circle around the centre of a cell covers the circle around any origin in it.
+// A degree of longitude is never longer than one of latitude, so half the diagonal is at most that of a square cell
+func (r *LocationRepository) cellRadius(radius int) int {
+	halfDiagonal := r.gridSize * math.Pi / 180 * earthRadius * math.Sqrt2 / 2
+	return int(math.Ceil(float64(radius)*(1+2*sphereError) + halfDiagonal*(1+sphereError)))
+}
+
+// overflowed tells whether there were more vehicles within the radius of an entry than it was loaded with
+func overflowed(cell model.NearbyLocations) bool {
+	return cell.Total > len(cell.Locations)
+}
+
+// nearest returns the locations of cell within radius of the origin, closest first, up to limit. Total counts every
+// one of them. It copies the locations, so callers don't mutate those held by the store
+func nearest(cell model.NearbyLocations, latitude, longitude float64, radius, limit int) model.NearbyLocations {
+	var nearby model.NearbyLocations
+	for _, location := range cell.Locations {
+		location.Distance = distance(latitude, longitude, location.Latitude, location.Longitude)
+		if location.Distance <= float64(radius) {
+			nearby.Locations = append(nearby.Locations, location)
+		}
+	}
+	sort.SliceStable(nearby.Locations, func(i, j int) bool { return nearby.Locations[i].Distance < nearby.Locations[j].Distance })
+	nearby.Total = len(nearby.Locations)
+	if len(nearby.Locations) > limit {
+		nearby.Locations = nearby.Locations[:limit]
+	}
+	return nearby
+}
+
+// distance is the great-circle distance between two points in meters
+func distance(lat1, lng1, lat2, lng2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,249 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"find-nearby-backend/cache"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	locationMock "find-nearby-backend/repository/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// cellLocations are around the grid cell of (1.0002, 103.0002) and (1.0004, 103.0004), 1e-4 degrees being about 11m
+var cellLocations = model.NearbyLocations{Locations: []model.Location{
+	{VehicleID: 1, Latitude: 1.0003, Longitude: 103.0003, Distance: 7},
+	{VehicleID: 2, Latitude: 0.9995, Longitude: 102.9998, Distance: 84},
+	{VehicleID: 3, Latitude: 1.0000, Longitude: 103.0014, Distance: 120},
+}, Total: 3}
+
+func TestLocationRepository_WhenOriginsShareGridCell_ShouldQueryOnceFromTheCellCentre(t *testing.T) {
+	ctx := context.Background()
+	repo := &locationMock.LocationRepository{}
+	widened := mock.MatchedBy(func(radius int) bool { return radius > 100+39 && radius < 100+45 })
+	repo.On("FindVehicleLocations", mock.Anything, approx(1.00025), approx(103.00025), widened, 400).Return(cellLocations, nil).Once()
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 100)
+
+	_, err := cached.FindVehicleLocations(ctx, 1.0002, 103.0002, 100, 10)
+	assert.NoError(t, err)
+	_, err = cached.FindVehicleLocations(ctx, 1.0004, 103.0004, 100, 10)
+	assert.NoError(t, err)
+
+	assert.Equal(t, cache.Stats{Hits: 1, Misses: 1, Loads: 1}, cached.Stats())
+	repo.AssertExpectations(t)
+}
+
+func TestLocationRepository_ShouldMeasureDistancesFromTheRequestOrigin(t *testing.T) {
+	ctx := context.Background()
+	repo := &locationMock.LocationRepository{}
+	repo.On("FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(cellLocations, nil).Once()
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 100)
+
+	fromFirst, err := cached.FindVehicleLocations(ctx, 1.0002, 103.0002, 100, 10)
+	assert.NoError(t, err)
+	fromSecond, err := cached.FindVehicleLocations(ctx, 1.0004, 103.0004, 100, 10)
+	assert.NoError(t, err)
+
+	// vehicle 3 is 135m from the first origin, and vehicle 2 is 90m from it but 120m from the second
+	assert.Equal(t, []int64{1, 2}, vehicleIDs(fromFirst))
+	assert.Equal(t, 2, fromFirst.Total)
+	assert.InDelta(t, 15.7, fromFirst.Locations[0].Distance, 0.1)
+	assert.InDelta(t, 89.6, fromFirst.Locations[1].Distance, 0.1)
+	assert.Equal(t, []int64{1}, vehicleIDs(fromSecond))
+	assert.Equal(t, 1, fromSecond.Total)
+	assert.InDelta(t, 15.7, fromSecond.Locations[0].Distance, 0.1)
+	assert.Equal(t, 7.0, cellLocations.Locations[0].Distance, "the cached locations must not change")
+}
+
+func TestLocationRepository_WhenLimitIsSmaller_ShouldKeepTheClosestAndCountTheRest(t *testing.T) {
+	ctx := context.Background()
+	repo := &locationMock.LocationRepository{}
+	repo.On("FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(cellLocations, nil).Once()
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 100)
+
+	nearby, err := cached.FindVehicleLocations(ctx, 1.0002, 103.0002, 200, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, vehicleIDs(nearby))
+	nearby, err = cached.FindVehicleLocations(ctx, 1.0002, 103.0002, 200, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, vehicleIDs(nearby))
+	assert.Equal(t, 3, nearby.Total)
+	repo.AssertExpectations(t)
+}
+
+func TestLocationRepository_WhenRadiusDiffers_ShouldUseDifferentKeys(t *testing.T) {
+	ctx := context.Background()
+	repo := &locationMock.LocationRepository{}
+	repo.On("FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.NearbyLocations{}, nil)
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 100)
+
+	_, _ = cached.FindVehicleLocations(ctx, 1.0001, 103.0001, 100, 10)
+	_, _ = cached.FindVehicleLocations(ctx, 1.0001, 103.0001, 200, 10)
+	_, _ = cached.FindVehicleLocations(ctx, 1.0001, 103.0001, 100, 20)
+
+	assert.Equal(t, uint64(2), cached.Stats().Loads)
+	repo.AssertNumberOfCalls(t, "FindVehicleLocations", 2)
+}
+
+func TestLocationRepository_WhenCallerHoldsVehicles_ShouldUseAnEntryOfItsOwn(t *testing.T) {
+	repo := &locationMock.LocationRepository{}
+	repo.On("FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.NearbyLocations{}, nil)
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 100)
+
+	_, _ = cached.FindVehicleLocations(context.Background(), 1.0001, 103.0001, 100, 10)
+	_, _ = cached.FindVehicleLocations(repository.ContextWithHolder(context.Background(), "alice"), 1.0001, 103.0001, 100, 10)
+	_, _ = cached.FindVehicleLocations(repository.ContextWithHolder(context.Background(), "alice"), 1.0001, 103.0001, 100, 10)
+
+	assert.Equal(t, uint64(2), cached.Stats().Loads)
+	repo.AssertCalled(t, "FindVehicleLocations", mock.MatchedBy(func(ctx context.Context) bool {
+		return repository.HolderFromContext(ctx) == "alice"
+	}), mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLocationRepository_WhenACellHasMoreVehiclesThanAnEntryHolds_ShouldSearchTheRepository(t *testing.T) {
+	ctx := context.Background()
+	crowded := model.NearbyLocations{Locations: cellLocations.Locations, Total: 10}
+	fromOrigin := model.NearbyLocations{Locations: cellLocations.Locations[:1], Total: 3}
+	repo := &locationMock.LocationRepository{}
+	repo.On("FindVehicleLocations", mock.Anything, approx(1.00025), approx(103.00025), mock.Anything, 4).Return(crowded, nil).Once()
+	repo.On("FindVehicleLocations", mock.Anything, 1.0002, 103.0002, 100, 1).Return(fromOrigin, nil).Twice()
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 1)
+
+	for i := 0; i < 2; i++ {
+		nearby, err := cached.FindVehicleLocations(ctx, 1.0002, 103.0002, 100, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, fromOrigin, nearby)
+	}
+	assert.Equal(t, cache.Stats{Hits: 1, Misses: 1, Loads: 1, Bypasses: 2}, cached.Stats())
+	repo.AssertExpectations(t)
+}
+
+func TestLocationRepository_WhenRepoReturnsError_ShouldNotCache(t *testing.T) {
+	ctx := context.Background()
+	repo := &locationMock.LocationRepository{}
+	repo.On("FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.NearbyLocations{}, errors.New("some repo error"))
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 100)
+
+	_, err := cached.FindVehicleLocations(ctx, 1.0001, 103.0001, 100, 10)
+	assert.EqualError(t, err, "some repo error")
+	_, err = cached.FindVehicleLocations(ctx, 1.0001, 103.0001, 100, 10)
+	assert.EqualError(t, err, "some repo error")
+
+	assert.Equal(t, cache.Stats{Misses: 2, Loads: 2, LoadErrors: 2}, cached.Stats())
+}
+
+func TestLocationRepository_WhenAListedVehicleChanges_ShouldQueryAgain(t *testing.T) {
+	ctx := context.Background()
+	repo := &locationMock.LocationRepository{}
+	repo.On("FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(cellLocations, nil)
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 100)
+
+	_, _ = cached.FindVehicleLocations(ctx, 1.0001, 103.0001, 200, 10)
+	assert.NoError(t, cached.Invalidate(ctx, []int64{42}, nil))
+	_, _ = cached.FindVehicleLocations(ctx, 1.0001, 103.0001, 200, 10)
+	repo.AssertNumberOfCalls(t, "FindVehicleLocations", 1)
+
+	assert.NoError(t, cached.Invalidate(ctx, []int64{2}, nil))
+	_, _ = cached.FindVehicleLocations(ctx, 1.0001, 103.0001, 200, 10)
+	repo.AssertNumberOfCalls(t, "FindVehicleLocations", 2)
+}
+
+func TestLocationRepository_WhenAVehicleShowsUpWithinAnEntry_ShouldQueryOnlyThatCellAgain(t *testing.T) {
+	ctx := context.Background()
+	repo := &locationMock.LocationRepository{}
+	repo.On("FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.NearbyLocations{}, nil)
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 100)
+
+	_, _ = cached.FindVehicleLocations(ctx, 1.0001, 103.0001, 100, 10)
+	_, _ = cached.FindVehicleLocations(ctx, 1.1001, 103.1001, 100, 10)
+	assert.NoError(t, cached.Invalidate(ctx, []int64{42}, []model.Location{{VehicleID: 42, Latitude: 1.0009, Longitude: 103.0009}}))
+	_, _ = cached.FindVehicleLocations(ctx, 1.0001, 103.0001, 100, 10)
+	_, _ = cached.FindVehicleLocations(ctx, 1.1001, 103.1001, 100, 10)
+
+	assert.Equal(t, uint64(3), cached.Stats().Loads, "the cell 11km away is still cached")
+}
+
+func TestLocationRepository_WhenMissesAreConcurrent_ShouldCollapseIntoOneQuery(t *testing.T) {
+	ctx := context.Background()
+	callers := 10
+	release := make(chan struct{})
+	repo := &locationMock.LocationRepository{}
+	repo.On("FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { <-release }).
+		Return(cellLocations, nil)
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nearby, err := cached.FindVehicleLocations(ctx, 1.0002, 103.0002, 100, 10)
+			assert.NoError(t, err)
+			assert.Len(t, nearby.Locations, 2)
+		}()
+	}
+	assert.Eventually(t, func() bool { return cached.Stats().Misses == uint64(callers) }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, uint64(1), cached.Stats().Loads)
+	repo.AssertNumberOfCalls(t, "FindVehicleLocations", 1)
+}
+
+func TestLocationRepository_WhenTheFirstCallerGoesAway_ShouldStillLoadForTheOthers(t *testing.T) {
+	release := make(chan struct{})
+	repo := &locationMock.LocationRepository{}
+	repo.On("FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			<-release
+			assert.NoError(t, args.Get(0).(context.Context).Err())
+		}).
+		Return(cellLocations, nil).Once()
+	cached := cache.NewLocationRepository(logger.New("debug", "plaintext"), repo, cache.NewLRUStore(10), 0.0005, time.Minute, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = cached.FindVehicleLocations(ctx, 1.0002, 103.0002, 100, 10)
+	}()
+	assert.Eventually(t, func() bool { return cached.Stats().Loads == 1 }, time.Second, time.Millisecond)
+	var nearby model.NearbyLocations
+	var err error
+	waiter := make(chan struct{})
+	go func() {
+		defer close(waiter)
+		nearby, err = cached.FindVehicleLocations(context.Background(), 1.0002, 103.0002, 100, 10)
+	}()
+	assert.Eventually(t, func() bool { return cached.Stats().Misses == 2 }, time.Second, time.Millisecond)
+	cancel()
+	close(release)
+	<-done
+	<-waiter
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, vehicleIDs(nearby))
+	repo.AssertExpectations(t)
+}
+
+func vehicleIDs(nearby model.NearbyLocations) []int64 {
+	var ids []int64
+	for _, location := range nearby.Locations {
+		ids = append(ids, location.VehicleID)
+	}
+	return ids
+}
+
+func approx(expected float64) interface{} {
+	return mock.MatchedBy(func(actual float64) bool { return math.Abs(expected-actual) < 1e-9 })
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"find-nearby-backend/model"
+)
+
+// Store keeps nearby results by key. The in-process LRU store is the default implementation;
+// a shared cache (e.g. Redis or Memcached) can be plugged in by implementing this interface.
+// Implementations must be safe for concurrent use
+type Store interface {
+	Get(ctx context.Context, key string) (model.NearbyLocations, bool, error)
+	Set(ctx context.Context, key string, nearby model.NearbyLocations, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	Purge(ctx context.Context) error
+}
+
+type lruEntry struct {
+	key       string
+	nearby    model.NearbyLocations
+	expiresAt time.Time
+}
+
+type lruStore struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+// NewLRUStore is a constructor for an in-process Store that keeps at most capacity entries,
+// evicting the least recently used one when full
+func NewLRUStore(capacity int) Store {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &lruStore{
+		capacity: capacity,
+		items:    make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// Get returns the entry stored under key unless it is missing or expired
+func (s *lruStore) Get(_ context.Context, key string) (model.NearbyLocations, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.items[key]
+	if !ok {
+		return model.NearbyLocations{}, false, nil
+	}
+	entry := elem.Value.(*lruEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		s.remove(elem)
+		return model.NearbyLocations{}, false, nil
+	}
+	s.order.MoveToFront(elem)
+	return entry.nearby, true, nil
+}
+
+// Set stores nearby under key for ttl
+func (s *lruStore) Set(_ context.Context, key string, nearby model.NearbyLocations, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiresAt := time.Now().Add(ttl)
+	if elem, ok := s.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.nearby = nearby
+		entry.expiresAt = expiresAt
+		s.order.MoveToFront(elem)
+		return nil
+	}
+	s.items[key] = s.order.PushFront(&lruEntry{key: key, nearby: nearby, expiresAt: expiresAt})
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// Delete drops the entries stored under keys; keys that aren't stored are skipped
+func (s *lruStore) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := s.items[key]; ok {
+			s.remove(elem)
+		}
+	}
+	return nil
+}
+
+// Purge drops every entry
+func (s *lruStore) Purge(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = make(map[string]*list.Element, s.capacity)
+	s.order.Init()
+	return nil
+}
+
+func (s *lruStore) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.items, elem.Value.(*lruEntry).key)
+}
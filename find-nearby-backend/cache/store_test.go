@@ -0,0 +1,80 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"find-nearby-backend/cache"
+	"find-nearby-backend/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUStore_WhenKeyIsSet_ShouldReturnLocations(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewLRUStore(2)
+	nearby := model.NearbyLocations{Locations: []model.Location{{VehicleID: 1}}, Total: 3}
+
+	assert.NoError(t, store.Set(ctx, "a", nearby, time.Minute))
+	actual, found, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, nearby, actual)
+
+	_, found, err = store.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestLRUStore_WhenFull_ShouldEvictLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewLRUStore(2)
+
+	assert.NoError(t, store.Set(ctx, "a", model.NearbyLocations{Locations: []model.Location{{VehicleID: 1}}, Total: 1}, time.Minute))
+	assert.NoError(t, store.Set(ctx, "b", model.NearbyLocations{Locations: []model.Location{{VehicleID: 2}}, Total: 1}, time.Minute))
+	_, found, _ := store.Get(ctx, "a")
+	assert.True(t, found)
+	assert.NoError(t, store.Set(ctx, "c", model.NearbyLocations{Locations: []model.Location{{VehicleID: 3}}, Total: 1}, time.Minute))
+
+	_, found, _ = store.Get(ctx, "b")
+	assert.False(t, found)
+	_, found, _ = store.Get(ctx, "a")
+	assert.True(t, found)
+	_, found, _ = store.Get(ctx, "c")
+	assert.True(t, found)
+}
+
+func TestLRUStore_WhenEntryExpires_ShouldMiss(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewLRUStore(2)
+
+	assert.NoError(t, store.Set(ctx, "a", model.NearbyLocations{Locations: []model.Location{{VehicleID: 1}}, Total: 1}, 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	_, found, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestLRUStore_Delete_ShouldDropOnlyTheGivenEntries(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewLRUStore(3)
+
+	assert.NoError(t, store.Set(ctx, "a", model.NearbyLocations{Total: 1}, time.Minute))
+	assert.NoError(t, store.Set(ctx, "b", model.NearbyLocations{Total: 2}, time.Minute))
+	assert.NoError(t, store.Delete(ctx, "a", "missing"))
+	_, found, _ := store.Get(ctx, "a")
+	assert.False(t, found)
+	_, found, _ = store.Get(ctx, "b")
+	assert.True(t, found)
+}
+
+func TestLRUStore_Purge_ShouldDropAllEntries(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewLRUStore(2)
+
+	assert.NoError(t, store.Set(ctx, "a", model.NearbyLocations{Locations: []model.Location{{VehicleID: 1}}, Total: 1}, time.Minute))
+	assert.NoError(t, store.Purge(ctx))
+	_, found, _ := store.Get(ctx, "a")
+	assert.False(t, found)
+}
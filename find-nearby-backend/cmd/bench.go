@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"find-nearby-backend/bench"
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+
+	"github.com/spf13/cobra"
+)
+
+func newBenchCmd() *cobra.Command {
+	var (
+		baseURL, apiKey, bbox, radii, limits, levels, output string
+		timeout, duration, warmup                            time.Duration
+		requests                                             int
+		seed                                                 int64
+	)
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Load-test nearby searches against a running server, or the location repository directly",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			var workload bench.Workload
+			var err error
+			if workload.Radii, err = bench.ParseChoices(radii); err != nil {
+				log.Fatalf("invalid --radii: %v", err)
+			}
+			if workload.Limits, err = bench.ParseChoices(limits); err != nil {
+				log.Fatalf("invalid --limits: %v", err)
+			}
+			concurrency, err := parseLevels(levels)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if output != "text" && output != "json" {
+				log.Fatalf("invalid --output: %s; --output must be text or json", output)
+			}
+			if duration <= 0 {
+				log.Fatalf("--duration must be positive, got %s", duration)
+			}
+			if !cmd.Flags().Changed("random-seed") {
+				seed = time.Now().UnixNano()
+			}
+
+			cfg := loadConfig()
+			logs := logger.New(cfg.LogLevel(), cfg.LogFormat())
+			var repo repository.LocationRepository
+			if baseURL == "" || bbox == "" {
+				db, err := database.New(cfg, logs)
+				if err != nil {
+					log.Fatal(err)
+				}
+				defer db.Close()
+				repo = repository.NewPostgresLocationRepository(logs, db)
+			}
+			if workload.Bounds, err = benchBounds(repo, bbox); err != nil {
+				log.Fatal(err)
+			}
+			target := bench.NewRepositoryTarget(repo)
+			report := bench.Report{Target: "repository", StartedAt: time.Now().UTC(), Seed: seed, Workload: workload}
+			if baseURL != "" {
+				// the default transport keeps two idle connections per host, so at higher concurrency most requests
+				// would pay for a new connection and the bench would measure handshakes rather than the server
+				transport := http.DefaultTransport.(*http.Transport).Clone()
+				transport.MaxIdleConnsPerHost = highest(concurrency)
+				transport.MaxConnsPerHost = highest(concurrency)
+				if transport.MaxIdleConns < transport.MaxIdleConnsPerHost {
+					transport.MaxIdleConns = transport.MaxIdleConnsPerHost
+				}
+				target = bench.NewHTTPTarget(&http.Client{Timeout: timeout, Transport: transport}, baseURL, apiKey)
+				report.Target = baseURL
+			}
+
+			ctx := context.Background()
+			if warmup > 0 {
+				fmt.Fprintf(os.Stderr, "Warming up for %s\n", warmup)
+				bench.Run(ctx, target, workload, bench.Stage{Concurrency: highest(concurrency), Duration: warmup}, seed)
+			}
+			for _, level := range concurrency {
+				fmt.Fprintf(os.Stderr, "Running %d concurrent clients for %s\n", level, duration)
+				report.Results = append(report.Results, bench.Run(ctx, target, workload, bench.Stage{Concurrency: level, Duration: duration, Requests: requests}, seed))
+			}
+			if output == "json" {
+				err = report.WriteJSON(os.Stdout)
+			} else {
+				err = report.WriteText(os.Stdout)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&baseURL, "url", "", "base URL of a running server, e.g. http://localhost:3333; without it the location repository is queried directly")
+	cmd.Flags().StringVar(&apiKey, "api-key", "", "X-API-Key to send to the server")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Second, "timeout of a request to the server")
+	cmd.Flags().StringVar(&bbox, "bbox", "", "bounding box of the origins, as minLng,minLat,maxLng,maxLat (default the bounding box of the locations)")
+	cmd.Flags().StringVar(&radii, "radii", "500:40,1000:40,5000:20", "radii in meters and their shares")
+	cmd.Flags().StringVar(&limits, "limits", "10:60,50:30,200:10", "limits and their shares")
+	cmd.Flags().StringVar(&levels, "concurrency", "1,8,32", "concurrency levels, run one after the other")
+	cmd.Flags().DurationVar(&duration, "duration", 10*time.Second, "how long every concurrency level runs")
+	cmd.Flags().IntVar(&requests, "requests", 0, "stop a concurrency level after this many requests, if it comes before --duration")
+	cmd.Flags().DurationVar(&warmup, "warmup", 2*time.Second, "run at the highest concurrency for this long first, without measuring")
+	cmd.Flags().Int64Var(&seed, "random-seed", 0, "random seed, to send the same queries again (default the current time, reported)")
+	cmd.Flags().StringVar(&output, "output", "text", "text or json")
+	return cmd
+}
+
+// benchBounds returns the bounding box of --bbox, or of the locations when it isn't given
+func benchBounds(repo repository.LocationRepository, bbox string) (model.Bounds, error) {
+	if bbox != "" {
+		coords, err := parseBBox(bbox)
+		if err != nil {
+			return model.Bounds{}, err
+		}
+		return model.Bounds{MinLongitude: coords[0], MinLatitude: coords[1], MaxLongitude: coords[2], MaxLatitude: coords[3]}, nil
+	}
+	bounds, err := repo.FindLocationBounds(context.Background())
+	if err != nil {
+		return model.Bounds{}, fmt.Errorf("failed to find the bounding box of the locations: %v", err)
+	}
+	if bounds == nil {
+		return model.Bounds{}, errors.New("there are no locations to take the bounding box of; seed some or pass --bbox")
+	}
+	return *bounds, nil
+}
+
+func parseLevels(list string) ([]int, error) {
+	var levels []int
+	for _, level := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(level))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid --concurrency: %s; --concurrency must be a list of positive integers", list)
+		}
+		levels = append(levels, n)
+	}
+	return levels, nil
+}
+
+// highest returns the highest of the concurrency levels, which needn't be in order
+func highest(levels []int) int {
+	max := 0
+	for _, level := range levels {
+		if level > max {
+			max = level
+		}
+	}
+	return max
+}
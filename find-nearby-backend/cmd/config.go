@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"find-nearby-backend/config"
+
+	"github.com/spf13/cobra"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "check",
+		Short: "Validate the configuration and print the effective values and where they came from",
+		Run: func(_ *cobra.Command, _ []string) {
+			cfg, err := config.Load()
+			printConfig(os.Stdout, cfg)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, formatConfigError(err))
+				os.Exit(1)
+			}
+			fmt.Println("Config is valid")
+		},
+	})
+	return cmd
+}
+
+func printConfig(out io.Writer, cfg config.Config) {
+	configFile := cfg.ConfigFile()
+	if configFile == "" {
+		configFile = "not found"
+	}
+	fmt.Fprintf(out, "Config file: %s\n\n", configFile)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, setting := range cfg.Settings() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Source)
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+}
+
+func formatConfigError(err error) string {
+	problems, ok := err.(config.ValidationErrors)
+	if !ok {
+		return err.Error()
+	}
+	message := fmt.Sprintf("Config is invalid, %d problem(s) found:", len(problems))
+	for _, problem := range problems {
+		message += "\n  - " + problem.Message
+	}
+	return message
+}
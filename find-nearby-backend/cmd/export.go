@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/snapshot"
+
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd() *cobra.Command {
+	var format, columnList string
+	cmd := &cobra.Command{
+		Use:   "export FILE",
+		Short: "Export a fleet snapshot of locations and vehicles to CSV, GeoJSON or NDJSON; - writes to stdout",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			var err error
+			if format, err = snapshotFormat(format, args[0]); err != nil {
+				log.Fatal(err)
+			}
+			columns, err := snapshotColumns(columnList)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			cfg := loadConfig()
+			logs := logger.New(cfg.LogLevel(), cfg.LogFormat())
+			db, err := database.New(cfg, logs)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer db.Close()
+
+			out := os.Stdout
+			if args[0] != "-" {
+				if out, err = os.Create(args[0]); err != nil {
+					log.Fatal(err)
+				}
+				defer out.Close()
+			}
+			buffered := bufio.NewWriter(out)
+			writer, err := snapshot.NewWriter(format, buffered, columns)
+			if err != nil {
+				log.Fatal(err)
+			}
+			exporter := snapshot.NewExporter(logs, repository.NewPostgresSnapshotRepository(logs, db))
+			written, err := exporter.Export(context.Background(), writer, func(written int) { progressf("Exported %d records", written) })
+			fmt.Fprintln(os.Stderr)
+			if err == nil {
+				err = buffered.Flush()
+			}
+			if err != nil {
+				log.Fatalf("failed after %d records: %v", written, err)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "csv, geojson or ndjson (default by the file extension)")
+	cmd.Flags().StringVar(&columnList, "columns", "", "columns of a CSV export, in order (default "+strings.Join(snapshot.Columns, ",")+")")
+	return cmd
+}
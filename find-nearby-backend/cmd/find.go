@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/routing"
+	"find-nearby-backend/usecase"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/spf13/cobra"
+)
+
+func newFindCmd() *cobra.Command {
+	var (
+		lat, lng, radius    float64
+		limit               int
+		units, sort, output string
+		explain             bool
+	)
+	cmd := &cobra.Command{
+		Use:   "find",
+		Short: "Find the vehicles near a point in the configured database, the way GET /locations/find does",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			unit, err := model.ParseUnit(units)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+				log.Fatalf("invalid --lat %f --lng %f; latitude must be within -90..90 and longitude within -180..180", lat, lng)
+			}
+			if sort != "distance" && sort != "eta" {
+				log.Fatalf("invalid --sort: %s; --sort must be distance or eta", sort)
+			}
+			if output != "table" && output != "json" && output != "geojson" {
+				log.Fatalf("invalid --output: %s; --output must be table, json or geojson", output)
+			}
+
+			cfg := loadConfig()
+			logs := logger.New(cfg.LogLevel(), cfg.LogFormat())
+			meters, lim := cfg.QueryLimits().DefaultRadius, cfg.QueryLimits().DefaultLimit
+			if cmd.Flags().Changed("radius") {
+				meters = int(math.Round(unit.ToMeters(radius)))
+			}
+			if cmd.Flags().Changed("limit") {
+				lim = limit
+			}
+			if meters < 0 || lim < 0 {
+				log.Fatalf("--radius and --limit must not be negative")
+			}
+			db, err := database.New(cfg, logs)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer db.Close()
+			repo := repository.NewPostgresLocationRepository(logs, db)
+			ctx := context.Background()
+
+			if explain {
+				// ranking by eta runs the same search for more candidates
+				if sort == "eta" {
+					lim *= cfg.RoutingCandidateFactor()
+				}
+				plan, err := repo.ExplainVehicleLocations(ctx, lat, lng, meters, lim)
+				if err != nil {
+					log.Fatal(err)
+				}
+				for _, line := range plan {
+					fmt.Println(line)
+				}
+				return
+			}
+
+			var router usecase.Router
+			if sort == "eta" {
+				if cfg.RoutingGraphFile() == "" {
+					log.Fatal(usecase.ErrRoutingUnavailable)
+				}
+				graph, err := routing.Load(ctx, cfg.RoutingGraphFile())
+				if err != nil {
+					log.Fatal(err)
+				}
+				router = routing.NewRouter(graph, cfg.RoutingMaxSnapDistance())
+			}
+			locations := usecase.NewLocationUsecase(logs, repo, router, cfg.RoutingCandidateFactor())
+			find := locations.FindVehicleLocations
+			if sort == "eta" {
+				find = locations.FindVehicleLocationsByETA
+			}
+			nearby, err := find(ctx, lat, lng, meters, lim)
+			if err != nil {
+				log.Fatal(err)
+			}
+			switch output {
+			case "json":
+				err = writeNearbyJSON(os.Stdout, unit, nearby)
+			case "geojson":
+				err = writeNearbyGeoJSON(os.Stdout, unit, nearby)
+			default:
+				err = writeNearbyTable(os.Stdout, unit, meters, nearby)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmd.Flags().Float64Var(&lat, "lat", 0, "latitude of the origin")
+	cmd.Flags().Float64Var(&lng, "lng", 0, "longitude of the origin")
+	cmd.Flags().Float64Var(&radius, "radius", 0, "radius in --units (default QUERY_DEFAULT_RADIUS)")
+	cmd.Flags().IntVar(&limit, "limit", 0, "how many vehicles to return at most (default QUERY_DEFAULT_LIMIT)")
+	cmd.Flags().StringVar(&units, "units", "m", "unit of the radius and the distances: m, km or mi")
+	cmd.Flags().StringVar(&sort, "sort", "distance", "distance, or eta to rank by drive time over ROUTING_GRAPH_FILE")
+	cmd.Flags().StringVar(&output, "output", "table", "table, json or geojson")
+	cmd.Flags().BoolVar(&explain, "explain", false, "print the PostgreSQL plan of the search, with actual timings, instead of its results")
+	_ = cmd.MarkFlagRequired("lat")
+	_ = cmd.MarkFlagRequired("lng")
+	return cmd
+}
+
+// writeNearbyTable writes a row per vehicle, then how many of the vehicles within the radius were shown
+func writeNearbyTable(w io.Writer, unit model.Unit, radius int, nearby model.NearbyLocations) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "VEHICLE\tLATITUDE\tLONGITUDE\tDISTANCE (%s)\tSNAPPED\tETA (s)\tROUTE DISTANCE (%s)\n", unit, unit)
+	for _, location := range nearby.Locations {
+		eta, routeDistance := "-", "-"
+		if location.ETA != nil {
+			eta = fmt.Sprintf("%.0f", *location.ETA)
+		}
+		if location.RouteDistance != nil {
+			routeDistance = fmt.Sprintf("%.2f", unit.FromMeters(*location.RouteDistance))
+		}
+		fmt.Fprintf(table, "%d\t%f\t%f\t%.2f\t%t\t%s\t%s\n", location.VehicleID, location.Latitude, location.Longitude,
+			unit.FromMeters(location.Distance), location.Snapped, eta, routeDistance)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d of %d vehicles within %g%s\n", len(nearby.Locations), nearby.Total, unit.FromMeters(float64(radius)), unit)
+	return err
+}
+
+func writeNearbyJSON(w io.Writer, unit model.Unit, nearby model.NearbyLocations) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Total     int              `json:"total"`
+		Units     string           `json:"units"`
+		Locations []model.Location `json:"locations"`
+	}{Total: nearby.Total, Units: string(unit), Locations: locationsIn(unit, nearby.Locations)})
+}
+
+// writeNearbyGeoJSON writes a FeatureCollection with a Point per vehicle, closest first
+func writeNearbyGeoJSON(w io.Writer, unit model.Unit, nearby model.NearbyLocations) error {
+	collection := geojson.NewFeatureCollection()
+	for _, location := range locationsIn(unit, nearby.Locations) {
+		feature := geojson.NewPointFeature([]float64{location.Longitude, location.Latitude})
+		feature.SetProperty("vehicle_id", location.VehicleID)
+		feature.SetProperty("distance", location.Distance)
+		feature.SetProperty("snapped", location.Snapped)
+		if location.ETA != nil {
+			feature.SetProperty("eta", *location.ETA)
+		}
+		if location.RouteDistance != nil {
+			feature.SetProperty("route_distance", *location.RouteDistance)
+		}
+		collection.AddFeature(feature)
+	}
+	data, err := collection.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// locationsIn returns locations with their distances converted from meters to unit
+func locationsIn(unit model.Unit, locations []model.Location) []model.Location {
+	converted := make([]model.Location, len(locations))
+	for i, location := range locations {
+		location.Distance = unit.FromMeters(location.Distance)
+		if location.RouteDistance != nil {
+			routeDistance := unit.FromMeters(*location.RouteDistance)
+			location.RouteDistance = &routeDistance
+		}
+		converted[i] = location
+	}
+	return converted
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/snapshot"
+
+	"github.com/spf13/cobra"
+)
+
+func newImportCmd() *cobra.Command {
+	var format, columnList, mode string
+	var batchSize, maxErrors int
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import a fleet snapshot of locations and vehicles from CSV, GeoJSON or NDJSON; - reads stdin",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			var err error
+			if format, err = snapshotFormat(format, args[0]); err != nil {
+				log.Fatal(err)
+			}
+			columns, err := snapshotColumns(columnList)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if batchSize <= 0 {
+				log.Fatalf("--batch-size must be positive, got %d", batchSize)
+			}
+			var in io.Reader = os.Stdin
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					log.Fatal(err)
+				}
+				defer f.Close()
+				in = f
+			}
+			reader, err := snapshot.NewReader(format, in, columns)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			cfg := loadConfig()
+			logs := logger.New(cfg.LogLevel(), cfg.LogFormat())
+			db, err := database.New(cfg, logs)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer db.Close()
+			importer := snapshot.NewImporter(logs, repository.NewPostgresSnapshotRepository(logs, db), batchSize, maxErrors)
+			report, err := importer.Import(context.Background(), reader, mode,
+				func(read int) { progressf("Read %d records", read) },
+				func(rowErr *snapshot.RowError) { fmt.Fprintf(os.Stderr, "\rSkipped %v\n", rowErr) })
+			fmt.Fprintln(os.Stderr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Imported %d records in %s mode, skipped %d malformed ones\n", report.Imported, mode, report.Malformed)
+			if report.Malformed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "csv, geojson or ndjson (default by the file extension)")
+	cmd.Flags().StringVar(&columnList, "columns", "", "columns of a CSV file without a header row, e.g. vehicle_id,latitude,longitude")
+	cmd.Flags().StringVar(&mode, "mode", model.SnapshotModeUpsert, "upsert adds and updates vehicles; replace also removes the vehicles that aren't in the file")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 5000, "records stored per statement")
+	cmd.Flags().IntVar(&maxErrors, "max-errors", 100, "stop and import nothing after this many malformed records; -1 never stops")
+	return cmd
+}
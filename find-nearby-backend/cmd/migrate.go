@@ -1,47 +1,248 @@
 package cmd
 
 import (
-	"find-nearby-backend/config"
+	"bufio"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"find-nearby-backend/database/migrations"
 
 	"github.com/golang-migrate/migrate"
-	_ "github.com/golang-migrate/migrate/database/postgres" // Need this to talk to postgres
-	_ "github.com/golang-migrate/migrate/source/file"
+	migratedb "github.com/golang-migrate/migrate/database"
 	"github.com/spf13/cobra"
 )
 
 func newMigrateCmd() *cobra.Command {
-	return &cobra.Command{
+	var yes bool
+	cmd := &cobra.Command{
 		Use:   "migrate",
-		Short: "Migrate Database",
-		Run: func(_ *cobra.Command, args []string) {
-			cfg := config.LoadConfig()
-			migrateUp(args, cfg)
+		Short: "Migrate the database all the way up, or step by step with the subcommands",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			m := openMigrator(yes)
+			defer m.close()
+			m.report(m.instance.Up(), "Success!")
 		},
 	}
+	cmd.PersistentFlags().BoolVarP(&yes, "yes", "y", false, "don't ask before migrations that can lose data")
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show the version of the database, whether it is dirty, and which migrations are applied",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			m := openMigrator(yes)
+			defer m.close()
+			m.status()
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "up [N]",
+		Short: "Apply the next N migrations, or all of them",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			m := openMigrator(yes)
+			defer m.close()
+			if len(args) == 0 {
+				m.report(m.instance.Up(), "Success!")
+				return
+			}
+			// Steps fails when there are fewer than N migrations to apply, so apply the ones there are
+			n := positiveArg(args[0], "N")
+			if pending := len(m.between(m.current(), ^uint(0))); n > pending {
+				n = pending
+			}
+			m.report(m.instance.Steps(n), fmt.Sprintf("Applied %d migrations", n))
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "down N",
+		Short: "Roll back the last N migrations",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			m := openMigrator(yes)
+			defer m.close()
+			n := positiveArg(args[0], "N")
+			current := m.current()
+			undone := m.between(0, current)
+			if len(undone) > n {
+				undone = undone[len(undone)-n:]
+			}
+			if !m.confirmRollback(undone) {
+				return
+			}
+			// roll back exactly what was confirmed, since Steps fails when there are fewer than N migrations applied
+			m.report(m.instance.Steps(-len(undone)), fmt.Sprintf("Rolled back %d migrations", len(undone)))
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "goto V",
+		Short: "Migrate up or down to version V",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			m := openMigrator(yes)
+			defer m.close()
+			version := uint(positiveArg(args[0], "V"))
+			if !m.known(version) {
+				log.Fatalf("there is no migration %d; see migrate status", version)
+			}
+			if !m.confirmRollback(m.between(version, m.current())) {
+				return
+			}
+			m.report(m.instance.Migrate(version), fmt.Sprintf("Migrated to %d", version))
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "force V",
+		Short: "Mark the database as clean at version V without running any migration",
+		Long:  "Mark the database as clean at version V without running any migration, once a failed migration was fixed by hand. -1 marks it as never migrated",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			m := openMigrator(yes)
+			defer m.close()
+			version, err := strconv.Atoi(args[0])
+			if err != nil || (version != migratedb.NilVersion && (version <= 0 || !m.known(uint(version)))) {
+				log.Fatalf("invalid V: %s; V must be the version of a migration, see migrate status, or -1", args[0])
+			}
+			if !m.confirm(fmt.Sprintf("This marks the database as clean at version %d without running any migration. "+
+				"Only do this once its schema matches that version.", version)) {
+				return
+			}
+			m.report(m.instance.Force(version), fmt.Sprintf("Forced version %d", version))
+		},
+	})
+	return cmd
 }
 
-func migrateUp(args []string, cfg config.Config) {
-	migrationPath := "file://database/migrations"
+// migrator wraps migrate with the embedded migrations and what the CLI needs to describe and confirm them
+type migrator struct {
+	instance *migrate.Migrate
+	list     []migrations.Migration
+	yes      bool
+}
 
-	if len(args) > 0 {
-		migrationPath = fmt.Sprintf("file://%s", args[0])
+func openMigrator(yes bool) *migrator {
+	cfg := loadConfig()
+	m, err := migrations.New(cfg.DatabaseConnectionURL())
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	connURL := cfg.DatabaseConnectionURL()
-	m, err := migrate.New(migrationPath, connURL)
+	list, err := migrations.List()
 	if err != nil {
 		log.Fatal(err)
 	}
+	return &migrator{instance: m, list: list, yes: yes}
+}
 
-	err = m.Up()
+func (m *migrator) close() {
+	_, _ = m.instance.Close()
+}
+
+// current returns the version of the database, 0 when it was never migrated
+func (m *migrator) current() uint {
+	version, _, err := m.instance.Version()
+	if err == migrate.ErrNilVersion {
+		return 0
+	}
 	if err != nil {
-		if err == migrate.ErrNoChange {
-			fmt.Printf("[FIND-NEARBY-BACKEND][MIGRATION] %s \n", err.Error())
-			return
+		log.Fatal(err)
+	}
+	return version
+}
+
+func (m *migrator) known(version uint) bool {
+	for _, migration := range m.list {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// between returns the migrations after from, up to and including to
+func (m *migrator) between(from, to uint) []migrations.Migration {
+	var list []migrations.Migration
+	for _, migration := range m.list {
+		if migration.Version > from && migration.Version <= to {
+			list = append(list, migration)
+		}
+	}
+	return list
+}
+
+func (m *migrator) status() {
+	version, dirty, err := m.instance.Version()
+	switch {
+	case err == migrate.ErrNilVersion:
+		fmt.Println("Version: none, the database was never migrated")
+	case err != nil:
+		log.Fatal(err)
+	default:
+		fmt.Printf("Version: %d\nDirty: %t\n", version, dirty)
+	}
+	for _, migration := range m.list {
+		state := "pending"
+		switch {
+		case err == nil && migration.Version == version && dirty:
+			state = "dirty"
+		case err == nil && migration.Version <= version:
+			state = "applied"
+		}
+		fmt.Printf("%-8s %d %s\n", state, migration.Version, migration.Name)
+	}
+	if dirty {
+		fmt.Printf("Migration %d failed halfway; fix the schema by hand, then run migrate force with the version it matches\n", version)
+	}
+}
+
+// confirmRollback asks before rolling migrations back, since the tables they drop take their data with them
+func (m *migrator) confirmRollback(undone []migrations.Migration) bool {
+	if len(undone) == 0 {
+		return true
+	}
+	names := make([]string, len(undone))
+	for i, migration := range undone {
+		names[len(undone)-1-i] = fmt.Sprintf("%d %s", migration.Version, migration.Name)
+	}
+	return m.confirm(fmt.Sprintf("This rolls back %d migrations, dropping the data of what they created:\n  %s",
+		len(undone), strings.Join(names, "\n  ")))
+}
+
+// confirm asks on stdin unless --yes was given. Anything but y or yes, including no terminal to answer on, is a no
+func (m *migrator) confirm(what string) bool {
+	if m.yes {
+		return true
+	}
+	fmt.Printf("%s\nContinue? [y/N] ", what)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "y" || answer == "yes" {
+		return true
+	}
+	fmt.Println("[FIND-NEARBY-BACKEND][MIGRATION] Cancelled")
+	return false
+}
+
+func (m *migrator) report(err error, success string) {
+	switch {
+	case err == migrate.ErrNoChange:
+		fmt.Printf("[FIND-NEARBY-BACKEND][MIGRATION] %s\n", err.Error())
+	case err != nil:
+		if _, ok := err.(migrate.ErrDirty); ok {
+			log.Fatalf("%v; see migrate status", err)
 		}
 		log.Fatal(err)
+	default:
+		fmt.Printf("[FIND-NEARBY-BACKEND][MIGRATION] %s\n", success)
+	}
+}
+
+func positiveArg(value, name string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Fatalf("invalid %s: %s; %s must be a positive integer", name, value, name)
 	}
-	fmt.Println("[FIND-NEARBY-BACKEND][MIGRATION] Success!")
+	return n
 }
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/usecase"
+
+	"github.com/spf13/cobra"
+)
+
+func newRetentionCmd() *cobra.Command {
+	var policy usecase.RetentionPolicy
+	cmd := &cobra.Command{
+		Use:   "retention",
+		Short: "Downsample old location history and delete what is past the retention horizon",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cfg := loadConfig()
+			if !cmd.Flags().Changed("downsample-after") {
+				policy.DownsampleAfter = cfg.RetentionDownsampleAfter()
+			}
+			if !cmd.Flags().Changed("interval") {
+				policy.DownsampleInterval = cfg.RetentionDownsampleInterval()
+			}
+			if !cmd.Flags().Changed("horizon") {
+				policy.Horizon = cfg.RetentionHorizon()
+			}
+			if !cmd.Flags().Changed("batch-size") {
+				policy.BatchSize = cfg.RetentionBatchSize()
+			}
+			if policy.DownsampleAfter >= policy.Horizon || policy.DownsampleInterval <= 0 || policy.BatchSize <= 0 {
+				log.Fatalf("--downsample-after (%s) must be shorter than --horizon (%s), and --interval and --batch-size positive", policy.DownsampleAfter, policy.Horizon)
+			}
+
+			logs := logger.New(cfg.LogLevel(), cfg.LogFormat())
+			db, err := database.New(cfg, logs)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer db.Close()
+			retention := usecase.NewRetentionUsecase(logs, repository.NewPostgresHistoryRepository(logs, db), policy)
+			report, err := retention.Compact(context.Background(), time.Now())
+			fmt.Printf("Deleted %d points recorded before %s\n", report.Deleted, report.DeletedBefore.Format(time.RFC3339))
+			fmt.Printf("Downsampled %d points recorded before %s to one per vehicle every %s\n", report.Downsampled, report.DownsampledBefore.Format(time.RFC3339), policy.DownsampleInterval)
+			if err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmd.Flags().DurationVar(&policy.DownsampleAfter, "downsample-after", 0, "downsample the history older than this (default RETENTION_DOWNSAMPLE_AFTER)")
+	cmd.Flags().DurationVar(&policy.DownsampleInterval, "interval", 0, "keep a point per vehicle and interval of downsampled history (default RETENTION_DOWNSAMPLE_INTERVAL)")
+	cmd.Flags().DurationVar(&policy.Horizon, "horizon", 0, "delete the history older than this (default RETENTION_HORIZON)")
+	cmd.Flags().IntVar(&policy.BatchSize, "batch-size", 0, "delete at most this many rows per statement (default RETENTION_BATCH_SIZE)")
+	return cmd
+}
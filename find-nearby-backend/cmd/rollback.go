@@ -1,44 +1,25 @@
 package cmd
 
 import (
-	"find-nearby-backend/config"
-	"fmt"
-	"log"
-
-	"github.com/golang-migrate/migrate"
 	"github.com/spf13/cobra"
 )
 
 func newRollbackCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "rollback",
-		Short: "Rollback all databases migration",
-		Run: func(_ *cobra.Command, args []string) {
-			cfg := config.LoadConfig()
-			migrateDown(args, cfg)
+	var yes bool
+	cmd := &cobra.Command{
+		Use:        "rollback",
+		Short:      "Roll back every migration, dropping the whole schema",
+		Deprecated: "use migrate down N to roll back only what needs to go",
+		Args:       cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			m := openMigrator(yes)
+			defer m.close()
+			if !m.confirmRollback(m.between(0, m.current())) {
+				return
+			}
+			m.report(m.instance.Down(), "Rollback success!")
 		},
 	}
-}
-
-func migrateDown(args []string, cfg config.Config) {
-	migrationPath := "file://database/migrations"
-
-	if len(args) > 0 {
-		migrationPath = fmt.Sprintf("file://%s", args[0])
-	}
-
-	m, err := migrate.New(migrationPath, cfg.DatabaseConnectionURL())
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if err := m.Down(); err != nil {
-		if err == migrate.ErrNoChange {
-			fmt.Printf("[FIND-NEARBY-BACKEND][MIGRATION] %s", err.Error())
-			return
-		}
-
-		log.Fatal(err)
-	}
-	fmt.Println("[FIND-NEARBY-BACKEND][MIGRATION] Rollback success!")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "don't ask before rolling back")
+	return cmd
 }
@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"log"
+
+	"find-nearby-backend/config"
+
 	"github.com/spf13/cobra"
 )
 
@@ -12,6 +16,21 @@ func NewRootCmd() *cobra.Command {
 	cli.AddCommand(newMigrateCmd())
 	cli.AddCommand(newRollbackCmd())
 	cli.AddCommand(newSeedCmd())
+	cli.AddCommand(newConfigCmd())
+	cli.AddCommand(newRetentionCmd())
+	cli.AddCommand(newImportCmd())
+	cli.AddCommand(newExportCmd())
+	cli.AddCommand(newBenchCmd())
+	cli.AddCommand(newFindCmd())
 
 	return cli
 }
+
+// loadConfig loads the config and exits with every problem found if it is unusable
+func loadConfig() config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
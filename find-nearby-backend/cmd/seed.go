@@ -1,21 +1,158 @@
 package cmd
 
 import (
-	"find-nearby-backend/config"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/routing"
 	"find-nearby-backend/seed"
 
 	"github.com/spf13/cobra"
 )
 
+const seedBatchSize = 5000
+
 func newSeedCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		fleet                 seed.Fleet
+		options               seed.Options
+		bbox, areaFile, roads string
+		types, statuses, city string
+	)
+	cmd := &cobra.Command{
 		Use:   "seed",
-		Short: "Seed Singapore Locations",
-		Run: func(_ *cobra.Command, _ []string) {
-			cfg := config.LoadConfig()
-			if err := seed.NewSeed(cfg).Generate(); err != nil {
-				panic(err.Error())
+		Short: "Seed Singapore locations, or a synthetic fleet of --count vehicles anywhere",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cfg := loadConfig()
+			s, err := seed.NewSeed(cfg, options)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer s.Close()
+			if fleet.Count == 0 {
+				report, err := s.Generate()
+				if err != nil {
+					log.Fatal(err)
+				}
+				printSeedReport(report, options)
+				return
+			}
+
+			if fleet.Area, err = seedArea(bbox, areaFile); err != nil {
+				log.Fatal(err)
+			}
+			if fleet.Types, err = seed.ParseShares(types); err != nil {
+				log.Fatalf("invalid --types: %v", err)
+			}
+			if fleet.Statuses, err = seed.ParseShares(statuses); err != nil {
+				log.Fatalf("invalid --statuses: %v", err)
 			}
+			if fleet.Distribution == seed.DistributionRoads {
+				if roads == "" {
+					roads = cfg.RoutingGraphFile()
+				}
+				if fleet.Roads, err = seedRoads(roads); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if !cmd.Flags().Changed("random-seed") {
+				fleet.Seed = time.Now().UnixNano()
+			}
+			generator, err := seed.NewGenerator(fleet)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			fmt.Printf("Seeding %d vehicles with random seed %d\n", fleet.Count, fleet.Seed)
+			report, err := s.GenerateFleet(generator, city, seedBatchSize, func(seeded int) {
+				fmt.Printf("\rSeeded %d/%d vehicles", seeded, fleet.Count)
+			})
+			fmt.Println()
+			if err != nil {
+				log.Fatal(err)
+			}
+			printSeedReport(report, options)
 		},
 	}
+	cmd.Flags().IntVar(&fleet.Count, "count", 0, "number of vehicles to generate; 0 seeds the Singapore locations of seed/locations.csv")
+	cmd.Flags().StringVar(&bbox, "bbox", "103.6,1.24,104.0,1.46", "bounding box to generate vehicles in, as minLng,minLat,maxLng,maxLat")
+	cmd.Flags().StringVar(&areaFile, "area", "", "GeoJSON Polygon or MultiPolygon to generate vehicles in, instead of --bbox")
+	cmd.Flags().StringVar(&fleet.Distribution, "distribution", seed.DistributionUniform, "how vehicles are spread over the area: uniform, clustered or roads")
+	cmd.Flags().IntVar(&fleet.Hotspots, "hotspots", 20, "number of hot spots of a clustered distribution")
+	cmd.Flags().Float64Var(&fleet.Spread, "spread", 500, "how far in meters vehicles spread around a hot spot (one standard deviation)")
+	cmd.Flags().StringVar(&roads, "roads", "", "OSM extract the roads of a roads distribution are loaded from (default ROUTING_GRAPH_FILE)")
+	cmd.Flags().StringVar(&types, "types", "scooter:70,bike:20,car:10", "vehicle types and their shares")
+	cmd.Flags().StringVar(&statuses, "statuses", "available:85,busy:10,offline:3,maintenance:2", "vehicle statuses and their shares")
+	cmd.Flags().StringVar(&city, "city", "", "city of the generated vehicles")
+	cmd.Flags().BoolVar(&options.Truncate, "truncate", false, "remove every vehicle, location, location history point, anomaly and reservation before seeding")
+	cmd.Flags().BoolVar(&options.Upsert, "upsert", false, "overwrite the vehicles that exist instead of skipping them")
+	cmd.Flags().BoolVar(&options.DryRun, "dry-run", false, "report what seeding would do and roll it back; the database isn't migrated")
+	cmd.Flags().BoolVar(&options.Force, "force", false, "seed a database marked as production")
+	cmd.Flags().Int64Var(&fleet.Seed, "random-seed", 0, "random seed, to generate the same fleet again (default the current time, printed)")
+	return cmd
+}
+
+func printSeedReport(report seed.Report, options seed.Options) {
+	verb := "Inserted"
+	if options.DryRun {
+		verb = "Dry run, rolled back: would have inserted"
+	}
+	fmt.Printf("%s %d vehicles, updated %d and skipped %d that exist\n", verb, report.Inserted, report.Updated, report.Skipped)
+}
+
+func seedArea(bbox, areaFile string) (*model.Area, error) {
+	if areaFile != "" {
+		data, err := ioutil.ReadFile(areaFile)
+		if err != nil {
+			return nil, err
+		}
+		return model.ParseArea(data)
+	}
+	coords, err := parseBBox(bbox)
+	if err != nil {
+		return nil, err
+	}
+	return model.NewBoxArea(coords[0], coords[1], coords[2], coords[3]), nil
+}
+
+// parseBBox reads a --bbox flag, minLng,minLat,maxLng,maxLat
+func parseBBox(bbox string) ([4]float64, error) {
+	var coords [4]float64
+	parts := strings.Split(bbox, ",")
+	if len(parts) != 4 {
+		return coords, fmt.Errorf("invalid --bbox: %s; --bbox must look like minLng,minLat,maxLng,maxLat", bbox)
+	}
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return coords, fmt.Errorf("invalid --bbox: %s; %s is not a number", bbox, part)
+		}
+		coords[i] = value
+	}
+	if coords[0] >= coords[2] || coords[1] >= coords[3] || coords[0] < -180 || coords[2] > 180 || coords[1] < -90 || coords[3] > 90 {
+		return coords, fmt.Errorf("invalid --bbox: %s; the minimums must be below the maximums and within -180..180 and -90..90", bbox)
+	}
+	return coords, nil
+}
+
+func seedRoads(path string) ([]seed.Road, error) {
+	if path == "" {
+		return nil, errors.New("a roads distribution needs an OSM extract; pass --roads or set ROUTING_GRAPH_FILE")
+	}
+	graph, err := routing.Load(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	var roads []seed.Road
+	graph.EachSegment(func(fromLat, fromLng, toLat, toLng, meters float64) {
+		roads = append(roads, seed.Road{FromLatitude: fromLat, FromLongitude: fromLng, ToLatitude: toLat, ToLongitude: toLng, Meters: meters})
+	})
+	return roads, nil
 }
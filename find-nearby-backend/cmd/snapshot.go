@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"find-nearby-backend/snapshot"
+)
+
+// snapshotFormat returns format, or the format the extension of path stands for when it isn't given
+func snapshotFormat(format, path string) (string, error) {
+	if format != "" {
+		return format, nil
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return snapshot.FormatCSV, nil
+	case ".geojson", ".json":
+		return snapshot.FormatGeoJSON, nil
+	case ".ndjson", ".jsonl":
+		return snapshot.FormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("can't tell the format of %q; pass --format csv, geojson or ndjson", path)
+	}
+}
+
+// snapshotColumns parses a --columns flag, which is optional
+func snapshotColumns(list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	columns, err := snapshot.ParseColumns(list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --columns: %v", err)
+	}
+	return columns, nil
+}
+
+// progressf reports progress on stderr, on a single line, so that it stays out of an export written to stdout
+func progressf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "\r"+format, args...)
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"find-nearby-backend/config"
 	"find-nearby-backend/server"
 
 	"github.com/spf13/cobra"
@@ -12,7 +11,7 @@ func newStartCmd() *cobra.Command {
 		Use:   "start",
 		Short: "Start HTTP Server",
 		Run: func(_ *cobra.Command, _ []string) {
-			cfg := config.LoadConfig()
+			cfg := loadConfig()
 			server.Start(cfg)
 		},
 	}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type auditConfig struct {
+	enabled       bool
+	bufferSize    int
+	batchSize     int
+	flushInterval time.Duration
+	readerAPIKeys []string
+}
+
+func newAuditConfig(vp *viper.Viper) *auditConfig {
+	return &auditConfig{
+		enabled:       vp.GetBool("AUDIT_ENABLED"),
+		bufferSize:    vp.GetInt("AUDIT_BUFFER_SIZE"),
+		batchSize:     vp.GetInt("AUDIT_BATCH_SIZE"),
+		flushInterval: vp.GetDuration("AUDIT_FLUSH_INTERVAL"),
+		readerAPIKeys: splitList(vp.GetStringSlice("AUDIT_READER_API_KEYS")),
+	}
+}
+
+// validate checks that a batch can fill up before the buffer does
+func (a *auditConfig) validate() ValidationErrors {
+	if a.batchSize > a.bufferSize {
+		return ValidationErrors{newValidationError("AUDIT_BATCH_SIZE", "AUDIT_BATCH_SIZE (%d) must not exceed AUDIT_BUFFER_SIZE (%d)", a.batchSize, a.bufferSize)}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type cacheConfig struct {
+	enabled  bool
+	ttl      time.Duration
+	size     int
+	gridSize float64
+}
+
+func newCacheConfig(vp *viper.Viper) *cacheConfig {
+	return &cacheConfig{
+		enabled:  vp.GetBool("CACHE_ENABLED"),
+		ttl:      vp.GetDuration("CACHE_TTL"),
+		size:     vp.GetInt("CACHE_SIZE"),
+		gridSize: vp.GetFloat64("CACHE_GRID_SIZE"),
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -9,31 +10,138 @@ import (
 
 type Config interface {
 	Addr() string
-	DatabaseMaxPoolSize() int
 	DatabaseConnectionURL() string
+	DatabaseReplicaConnectionURLs() []string
+	DatabaseReplicaMaxLag() time.Duration
+	DatabaseReplicaHealthCheckInterval() time.Duration
 	DatabaseMaxIdleConn() int
 	DatabaseMaxOpenConn() int
 	DatabaseConnMaxLifetime() time.Duration
+	DatabaseConnMaxIdleTime() time.Duration
 	LogLevel() string
 	LogFormat() string
+	TracingExporter() string
+	TracingOTLPEndpoint() string
+	TracingFilePath() string
+	CacheEnabled() bool
+	CacheTTL() time.Duration
+	CacheSize() int
+	CacheGridSize() float64
+	QueryLimits() QueryLimits
+	QueryAPIKeyLimits() map[string]QueryLimits
+	QueryMaxBatchSize() int
+	QueryMaxRoutePoints() int
+	DispatchMaxPickupDistance() int
+	DispatchCandidates() int
+	DispatchMaxRequests() int
+	ReservationDefaultHold() time.Duration
+	ReservationMaxHold() time.Duration
+	ReservationExpiryInterval() time.Duration
+	ReservationAPIKeys() []string
+	ReservationMaxHolds() int
+	RoutingGraphFile() string
+	RoutingCandidateFactor() int
+	RoutingMaxSnapDistance() int
+	IngestSnapToRoad() bool
+	IngestSnapMaxDistance() int
+	IngestServe() string
+	IngestMaxBatch() int
+	IngestAnomalyAction() string
+	IngestMaxSpeed() int
+	IngestMaxSpeeds() map[string]int
+	IngestServiceAreaFile() string
+	IngestAPIKeys() []string
+	PrivacyEnabled() bool
+	PrivacyTrustedAPIKeys() []string
+	PrivacySecret() string
+	PrivacyMode() string
+	PrivacyPrecision() int
+	PrivacyDistanceBucket() int
+	PrivacyWindow() time.Duration
+	AuditEnabled() bool
+	AuditBufferSize() int
+	AuditBatchSize() int
+	AuditFlushInterval() time.Duration
+	AuditReaderAPIKeys() []string
+	HistoryEnabled() bool
+	RetentionDownsampleAfter() time.Duration
+	RetentionDownsampleInterval() time.Duration
+	RetentionHorizon() time.Duration
+	RetentionBatchSize() int
+	RetentionSchedule() time.Duration
+	Validate() error
+	Settings() []Setting
+	ConfigFile() string
 }
 
 type config struct {
-	appHost   string
-	appPort   int
-	dbConfig  *databaseConfig
-	logLevel  string
-	logFormat string
+	appHost     string
+	appPort     int
+	dbConfig    *databaseConfig
+	logLevel    string
+	logFormat   string
+	tracing     *tracingConfig
+	cache       *cacheConfig
+	query       *queryConfig
+	dispatch    *dispatchConfig
+	reservation *reservationConfig
+	routing     *routingConfig
+	ingest      *ingestConfig
+	privacy     *privacyConfig
+	audit       *auditConfig
+	retention   *retentionConfig
+
+	configFile string
+	settings   []Setting
+	secrets    map[string]string
+	problems   ValidationErrors
 }
 
+// configPaths are searched in order for application.yml
+var configPaths = []string{"./", "../", "../../"}
+
+// Load reads application.yml, if there is one, and the env, and validates the result.
+// It returns a *FileError when the file exists but can't be parsed and ValidationErrors when a key is
+// missing, malformed or out of range; the config is returned either way so callers can report on it
+func Load() (Config, error) {
+	vp := viper.New()
+	fileErr := readInConfig(vp)
+	cfg := newConfig(vp)
+	if fileErr != nil {
+		return cfg, fileErr
+	}
+	return cfg, cfg.Validate()
+}
+
+// LoadConfig is like Load but leaves reporting problems to a later call to Validate
 func LoadConfig() Config {
-	vp := newWithViper()
+	vp := viper.New()
+	_ = readInConfig(vp)
+	return newConfig(vp)
+}
+
+func newConfig(vp *viper.Viper) config {
 	return config{
-		appHost:   vp.GetString("APP_HOST"),
-		appPort:   vp.GetInt("APP_PORT"),
-		dbConfig:  newDatabaseConfig(vp),
-		logLevel:  vp.GetString("LOG_LEVEL"),
-		logFormat: vp.GetString("LOG_FORMAT"),
+		appHost:     vp.GetString("APP_HOST"),
+		appPort:     vp.GetInt("APP_PORT"),
+		dbConfig:    newDatabaseConfig(vp),
+		logLevel:    vp.GetString("LOG_LEVEL"),
+		logFormat:   vp.GetString("LOG_FORMAT"),
+		tracing:     newTracingConfig(vp),
+		cache:       newCacheConfig(vp),
+		query:       newQueryConfig(vp),
+		dispatch:    newDispatchConfig(vp),
+		reservation: newReservationConfig(vp),
+		routing:     newRoutingConfig(vp),
+		ingest:      newIngestConfig(vp),
+		privacy:     newPrivacyConfig(vp),
+		audit:       newAuditConfig(vp),
+		retention:   newRetentionConfig(vp),
+
+		configFile: vp.ConfigFileUsed(),
+		settings:   settings(vp),
+		secrets:    secrets(vp),
+		problems:   validateKeys(vp),
 	}
 }
 
@@ -42,14 +150,32 @@ func (c config) Addr() string {
 	return fmt.Sprintf("%s:%d", c.appHost, c.appPort)
 }
 
-// DatabaseMaxPoolSize returns max pool size for DB
-func (c config) DatabaseMaxPoolSize() int {
-	return c.dbConfig.maxPoolSize
+// DatabaseConnectionURL returns connection url for DB, including sslmode, application_name, connect_timeout and statement_timeout
+func (c config) DatabaseConnectionURL() string {
+	return c.dbConfig.connectionURL(fmt.Sprintf("%s:%d", c.dbConfig.host, c.dbConfig.port))
 }
 
-// DatabaseConnectionURL returns connection url for DB
-func (c config) DatabaseConnectionURL() string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable", c.dbConfig.username, c.dbConfig.password, c.dbConfig.host, c.dbConfig.port, c.dbConfig.name)
+// DatabaseReplicaConnectionURLs returns connection urls for the read replicas of the DB.
+// Replicas share the credentials and database name of the primary, and its port unless one is given
+func (c config) DatabaseReplicaConnectionURLs() []string {
+	urls := make([]string, 0, len(c.dbConfig.replicaHosts))
+	for _, host := range c.dbConfig.replicaHosts {
+		if !strings.Contains(host, ":") {
+			host = fmt.Sprintf("%s:%d", host, c.dbConfig.port)
+		}
+		urls = append(urls, c.dbConfig.connectionURL(host))
+	}
+	return urls
+}
+
+// DatabaseReplicaMaxLag returns how far behind the primary a replica may fall before reads stop going to it; zero disables the check
+func (c config) DatabaseReplicaMaxLag() time.Duration {
+	return c.dbConfig.replicaMaxLag
+}
+
+// DatabaseReplicaHealthCheckInterval returns how often the read replicas are checked
+func (c config) DatabaseReplicaHealthCheckInterval() time.Duration {
+	return c.dbConfig.replicaCheckInterval
 }
 
 // DatabaseMaxIdleConn returns max idle connections for DB
@@ -67,6 +193,11 @@ func (c config) DatabaseConnMaxLifetime() time.Duration {
 	return c.dbConfig.maxConnLifetimeDuration
 }
 
+// DatabaseConnMaxIdleTime returns how long a connection may stay idle in the pool for DB
+func (c config) DatabaseConnMaxIdleTime() time.Duration {
+	return c.dbConfig.maxConnIdleTimeDuration
+}
+
 // LogLevel return log level config
 func (c config) LogLevel() string {
 	return c.logLevel
@@ -77,13 +208,304 @@ func (c config) LogFormat() string {
 	return c.logFormat
 }
 
-func newWithViper() *viper.Viper {
-	vp := viper.New()
+// TracingExporter returns where spans are exported to: otlp, stdout, file or none.
+// When empty, OTLP is used if an endpoint is configured, then a file if a path is configured, then stdout
+func (c config) TracingExporter() string {
+	return c.tracing.exporter
+}
+
+// TracingOTLPEndpoint returns the host:port of the OTLP/HTTP collector
+func (c config) TracingOTLPEndpoint() string {
+	return c.tracing.otlpEndpoint
+}
+
+// TracingFilePath returns the file spans are written to by the file exporter
+func (c config) TracingFilePath() string {
+	return c.tracing.filePath
+}
+
+// CacheEnabled returns whether nearby results are cached
+func (c config) CacheEnabled() bool {
+	return c.cache.enabled
+}
+
+// CacheTTL returns how long a cached nearby result stays fresh
+func (c config) CacheTTL() time.Duration {
+	return c.cache.ttl
+}
+
+// CacheSize returns the max number of nearby results kept by the in-process cache
+func (c config) CacheSize() int {
+	return c.cache.size
+}
+
+// CacheGridSize returns the size, in degrees, of the grid cells origins are snapped to when building cache keys
+func (c config) CacheGridSize() float64 {
+	return c.cache.gridSize
+}
+
+// QueryLimits returns the default and max radius and limit of nearby queries
+func (c config) QueryLimits() QueryLimits {
+	return c.query.limits
+}
+
+// QueryAPIKeyLimits returns the limits of the API keys whose caps differ from QueryLimits, by API key
+func (c config) QueryAPIKeyLimits() map[string]QueryLimits {
+	limits := make(map[string]QueryLimits, len(c.query.apiKeys))
+	for apiKey, l := range c.query.apiKeys {
+		limits[apiKey] = l
+	}
+	return limits
+}
+
+// QueryMaxBatchSize returns the max number of origins of a batch nearby search
+func (c config) QueryMaxBatchSize() int {
+	return c.query.maxBatchSize
+}
+
+// QueryMaxRoutePoints returns the max number of points of the route of a corridor search
+func (c config) QueryMaxRoutePoints() int {
+	return c.query.maxRoutePoints
+}
+
+// DispatchMaxPickupDistance returns, in meters, how far a vehicle may be from a ride request to be assigned to it
+func (c config) DispatchMaxPickupDistance() int {
+	return c.dispatch.maxPickupDistance
+}
+
+// DispatchCandidates returns how many of the nearest vehicles are considered for every ride request
+func (c config) DispatchCandidates() int {
+	return c.dispatch.candidates
+}
+
+// DispatchMaxRequests returns the max number of ride requests of a single assignment
+func (c config) DispatchMaxRequests() int {
+	return c.dispatch.maxRequests
+}
+
+// ReservationDefaultHold returns how long a vehicle is held when the request doesn't say
+func (c config) ReservationDefaultHold() time.Duration {
+	return c.reservation.defaultHold
+}
+
+// ReservationMaxHold returns the longest a vehicle can be held
+func (c config) ReservationMaxHold() time.Duration {
+	return c.reservation.maxHold
+}
+
+// ReservationExpiryInterval returns how often lapsed holds are expired
+func (c config) ReservationExpiryInterval() time.Duration {
+	return c.reservation.expiryInterval
+}
+
+// ReservationAPIKeys returns the API keys allowed to hold vehicles, through reservations or dispatch; no one may when
+// there are none
+func (c config) ReservationAPIKeys() []string {
+	return append([]string(nil), c.reservation.apiKeys...)
+}
+
+// ReservationMaxHolds returns how many vehicles an API key can hold at a time
+func (c config) ReservationMaxHolds() int {
+	return c.reservation.maxHolds
+}
+
+// RoutingGraphFile returns the path of the OSM extract (.osm.pbf or .osm) the road graph is loaded from; empty disables sort=eta
+func (c config) RoutingGraphFile() string {
+	return c.routing.graphFile
+}
+
+// RoutingCandidateFactor returns how many times the limit of straight-line candidates is re-ranked by ETA
+func (c config) RoutingCandidateFactor() int {
+	return c.routing.candidateFactor
+}
+
+// RoutingMaxSnapDistance returns, in meters, how far a point may be from the road graph to be routed
+func (c config) RoutingMaxSnapDistance() int {
+	return c.routing.maxSnapDistance
+}
+
+// IngestSnapToRoad returns whether ingested pings are snapped to the road graph
+func (c config) IngestSnapToRoad() bool {
+	return c.ingest.snapToRoad
+}
+
+// IngestSnapMaxDistance returns, in meters, how far a ping may be from a road to be snapped to it
+func (c config) IngestSnapMaxDistance() int {
+	return c.ingest.snapMaxDistance
+}
+
+// IngestServe returns which coordinates of a vehicle searches use and return: snapped or raw
+func (c config) IngestServe() string {
+	return c.ingest.serve
+}
+
+// IngestMaxBatch returns the max number of pings of a single ingest request
+func (c config) IngestMaxBatch() int {
+	return c.ingest.maxBatch
+}
+
+// IngestAnomalyAction returns what is done with pings over the speed limit or outside the service area: reject or flag
+func (c config) IngestAnomalyAction() string {
+	return c.ingest.anomalyAction
+}
+
+// IngestMaxSpeed returns, in km/h, the speed limit of the vehicle types without one of their own; zero turns the check off
+func (c config) IngestMaxSpeed() int {
+	return c.ingest.maxSpeed
+}
+
+// IngestMaxSpeeds returns the speed limits in km/h of the vehicle types that have one of their own, by vehicle type
+func (c config) IngestMaxSpeeds() map[string]int {
+	speeds := make(map[string]int, len(c.ingest.maxSpeeds))
+	for vehicleType, speed := range c.ingest.maxSpeeds {
+		speeds[vehicleType] = speed
+	}
+	return speeds
+}
+
+// IngestServiceAreaFile returns the path of the GeoJSON polygon pings must fall in; empty lets them be anywhere
+func (c config) IngestServiceAreaFile() string {
+	return c.ingest.serviceAreaFile
+}
+
+// IngestAPIKeys returns the API keys allowed to report vehicle positions; no one may when there are none
+func (c config) IngestAPIKeys() []string {
+	return c.ingest.apiKeys
+}
+
+// PrivacyEnabled returns whether callers without a trusted API key get fuzzed locations
+func (c config) PrivacyEnabled() bool {
+	return c.privacy.enabled
+}
+
+// PrivacyTrustedAPIKeys returns the API keys that get exact locations and vehicle IDs
+func (c config) PrivacyTrustedAPIKeys() []string {
+	return append([]string(nil), c.privacy.trustedAPIKeys...)
+}
+
+// PrivacySecret returns the key the jitter and the vehicle tokens are derived from
+func (c config) PrivacySecret() string {
+	return c.privacy.secret
+}
+
+// PrivacyMode returns how coordinates are fuzzed: jitter or round
+func (c config) PrivacyMode() string {
+	return c.privacy.mode
+}
+
+// PrivacyPrecision returns, in meters, how far fuzzed coordinates may be from the exact ones
+func (c config) PrivacyPrecision() int {
+	return c.privacy.precision
+}
+
+// PrivacyDistanceBucket returns, in meters, the size of the buckets distances are rounded up to
+func (c config) PrivacyDistanceBucket() int {
+	return c.privacy.distanceBucket
+}
+
+// PrivacyWindow returns how long the jitter of a vehicle and its token stay the same
+func (c config) PrivacyWindow() time.Duration {
+	return c.privacy.window
+}
+
+// AuditEnabled returns whether searches and writes are recorded in the audit log
+func (c config) AuditEnabled() bool {
+	return c.audit.enabled
+}
+
+// AuditBufferSize returns how many audit records may wait to be written before new ones are dropped
+func (c config) AuditBufferSize() int {
+	return c.audit.bufferSize
+}
+
+// AuditBatchSize returns how many audit records are written in a single statement at most
+func (c config) AuditBatchSize() int {
+	return c.audit.batchSize
+}
+
+// AuditFlushInterval returns how long audit records may wait before a batch that isn't full is written
+func (c config) AuditFlushInterval() time.Duration {
+	return c.audit.flushInterval
+}
+
+// AuditReaderAPIKeys returns the API keys allowed to read the audit log and /debug/vars and to review anomalies; no one may when there are none
+func (c config) AuditReaderAPIKeys() []string {
+	return append([]string(nil), c.audit.readerAPIKeys...)
+}
+
+// HistoryEnabled returns whether every accepted ping is kept in the location history, not just the latest per vehicle
+func (c config) HistoryEnabled() bool {
+	return c.retention.historyEnabled
+}
+
+// RetentionDownsampleAfter returns how old location history gets before it is downsampled
+func (c config) RetentionDownsampleAfter() time.Duration {
+	return c.retention.downsampleAfter
+}
+
+// RetentionDownsampleInterval returns how far apart the points of downsampled location history are at least
+func (c config) RetentionDownsampleInterval() time.Duration {
+	return c.retention.downsampleInterval
+}
+
+// RetentionHorizon returns how old location history gets before it is deleted; the latest locations are kept
+func (c config) RetentionHorizon() time.Duration {
+	return c.retention.horizon
+}
+
+// RetentionBatchSize returns how many rows of location history a single statement deletes at most
+func (c config) RetentionBatchSize() int {
+	return c.retention.batchSize
+}
+
+// RetentionSchedule returns how often the server compacts the location history; zero leaves it to the retention command
+func (c config) RetentionSchedule() time.Duration {
+	return c.retention.schedule
+}
+
+// Validate checks every key against the schema, then the rules spanning several keys,
+// and returns all problems found as ValidationErrors
+func (c config) Validate() error {
+	problems := append(ValidationErrors{}, c.problems...)
+	problems = append(problems, c.dbConfig.validate()...)
+	problems = append(problems, c.tracing.validate()...)
+	problems = append(problems, c.query.validate()...)
+	problems = append(problems, c.reservation.validate()...)
+	problems = append(problems, c.ingest.validate(c.routing)...)
+	problems = append(problems, c.privacy.validate()...)
+	problems = append(problems, c.audit.validate()...)
+	problems = append(problems, c.retention.validate()...)
+	if len(problems) == 0 {
+		return nil
+	}
+	if c.configFile == "" {
+		problems = append(problems, newValidationError("", "no application.yml found in %s, only env and defaults were used", strings.Join(configPaths, ", ")))
+	}
+	return problems
+}
+
+// Settings returns the effective value of every config key and where it came from, with secrets redacted
+func (c config) Settings() []Setting {
+	return c.settings
+}
+
+// ConfigFile returns the path of the application.yml in use, or an empty string if none was found
+func (c config) ConfigFile() string {
+	return c.configFile
+}
+
+// readInConfig sets up vp to read the env and application.yml. A missing file is not an error,
+// since every key can come from the env, but a file that can't be parsed is
+func readInConfig(vp *viper.Viper) error {
+	setDefaults(vp)
 	vp.AutomaticEnv()
 	vp.SetConfigName("application")
-	vp.AddConfigPath("./")
-	vp.AddConfigPath("../")
-	vp.AddConfigPath("../../")
-	vp.ReadInConfig()
-	return vp
+	for _, path := range configPaths {
+		vp.AddConfigPath(path)
+	}
+	err := vp.ReadInConfig()
+	if _, notFound := err.(viper.ConfigFileNotFoundError); err == nil || notFound {
+		return nil
+	}
+	return &FileError{Path: vp.ConfigFileUsed(), Err: err}
 }
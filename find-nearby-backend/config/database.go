@@ -1,21 +1,35 @@
 package config
 
 import (
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
+var validSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
 type databaseConfig struct {
 	host                    string
 	port                    int
 	username                string
 	password                string
 	name                    string
-	maxPoolSize             int
+	sslMode                 string
+	applicationName         string
+	connectTimeout          time.Duration
+	statementTimeout        time.Duration
 	maxIdleConn             int
 	maxOpenConn             int
 	maxConnLifetimeDuration time.Duration
+	maxConnIdleTimeDuration time.Duration
+	replicaHosts            []string
+	replicaMaxLag           time.Duration
+	replicaCheckInterval    time.Duration
 }
 
 func newDatabaseConfig(vp *viper.Viper) *databaseConfig {
@@ -25,9 +39,79 @@ func newDatabaseConfig(vp *viper.Viper) *databaseConfig {
 		name:                    vp.GetString("DB_NAME"),
 		username:                vp.GetString("DB_USER"),
 		password:                vp.GetString("DB_PASS"),
-		maxPoolSize:             10,
+		sslMode:                 vp.GetString("DB_SSLMODE"),
+		applicationName:         vp.GetString("DB_APPLICATION_NAME"),
+		connectTimeout:          vp.GetDuration("DB_CONNECT_TIMEOUT"),
+		statementTimeout:        vp.GetDuration("DB_STATEMENT_TIMEOUT"),
 		maxIdleConn:             vp.GetInt("DB_MAX_IDLE_CONN"),
 		maxOpenConn:             vp.GetInt("DB_MAX_OPEN_CONN"),
 		maxConnLifetimeDuration: vp.GetDuration("DB_CONN_MAX_LIFETIME"),
+		maxConnIdleTimeDuration: vp.GetDuration("DB_CONN_MAX_IDLE_TIME"),
+		replicaHosts:            splitList(vp.GetStringSlice("DB_REPLICA_HOSTS")),
+		replicaMaxLag:           vp.GetDuration("DB_REPLICA_MAX_LAG"),
+		replicaCheckInterval:    vp.GetDuration("DB_REPLICA_HEALTH_CHECK_INTERVAL"),
+	}
+}
+
+// splitList accepts both YAML lists and comma separated env values
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// connectionURL builds a lib/pq connection url. Parameters lib/pq doesn't know about,
+// like statement_timeout, are sent to the server as run-time parameters
+func (d *databaseConfig) connectionURL(hostPort string) string {
+	params := url.Values{}
+	params.Set("sslmode", d.sslMode)
+	if d.applicationName != "" {
+		params.Set("application_name", d.applicationName)
+	}
+	if d.connectTimeout > 0 {
+		// lib/pq takes whole seconds, round up so a sub-second timeout doesn't turn into "wait forever"
+		params.Set("connect_timeout", strconv.Itoa(int(math.Ceil(d.connectTimeout.Seconds()))))
+	}
+	if d.statementTimeout > 0 {
+		params.Set("statement_timeout", strconv.FormatInt(d.statementTimeout.Milliseconds(), 10))
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.username, d.password),
+		Host:     hostPort,
+		Path:     "/" + d.name,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
+
+// validate checks the rules that span several keys; single keys are checked against the schema
+func (d *databaseConfig) validate() ValidationErrors {
+	var problems ValidationErrors
+	if d.maxOpenConn > 0 && d.maxIdleConn > d.maxOpenConn {
+		problems = append(problems, newValidationError("DB_MAX_IDLE_CONN", "DB_MAX_IDLE_CONN (%d) must not exceed DB_MAX_OPEN_CONN (%d)", d.maxIdleConn, d.maxOpenConn))
+	}
+	for _, host := range d.replicaHosts {
+		if _, port, err := net.SplitHostPort(host); err == nil {
+			if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+				problems = append(problems, newValidationError("DB_REPLICA_HOSTS", "DB_REPLICA_HOSTS entry %q has an invalid port", host))
+			}
+		}
+	}
+	return problems
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
+	return false
 }
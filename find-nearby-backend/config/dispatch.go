@@ -0,0 +1,17 @@
+package config
+
+import "github.com/spf13/viper"
+
+type dispatchConfig struct {
+	maxPickupDistance int
+	candidates        int
+	maxRequests       int
+}
+
+func newDispatchConfig(vp *viper.Viper) *dispatchConfig {
+	return &dispatchConfig{
+		maxPickupDistance: vp.GetInt("DISPATCH_MAX_PICKUP_DISTANCE"),
+		candidates:        vp.GetInt("DISPATCH_CANDIDATES"),
+		maxRequests:       vp.GetInt("DISPATCH_MAX_REQUESTS"),
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileError is returned when application.yml exists but can't be read or parsed
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("failed to read config file %s: %s", e.Path, e.Err.Error())
+}
+
+// Unwrap returns the underlying read or parse error
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError describes a single config key that is missing, malformed or out of range
+type ValidationError struct {
+	Key     string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors lists every problem found while validating the config
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Message)
+	}
+	return fmt.Sprintf("invalid config: %s", strings.Join(messages, "; "))
+}
+
+func newValidationError(key, format string, args ...interface{}) ValidationError {
+	return ValidationError{Key: key, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Values of INGEST_SERVE
+const (
+	IngestServeSnapped = "snapped"
+	IngestServeRaw     = "raw"
+)
+
+// Values of INGEST_ANOMALY_ACTION
+const (
+	IngestAnomalyReject = "reject"
+	IngestAnomalyFlag   = "flag"
+)
+
+type ingestConfig struct {
+	snapToRoad      bool
+	snapMaxDistance int
+	serve           string
+	maxBatch        int
+	anomalyAction   string
+	maxSpeed        int
+	maxSpeeds       map[string]int
+	malformedSpeeds []string
+	serviceAreaFile string
+	apiKeys         []string
+}
+
+func newIngestConfig(vp *viper.Viper) *ingestConfig {
+	i := &ingestConfig{
+		snapToRoad:      vp.GetBool("INGEST_SNAP_TO_ROAD"),
+		snapMaxDistance: vp.GetInt("INGEST_SNAP_MAX_DISTANCE"),
+		serve:           vp.GetString("INGEST_SERVE"),
+		maxBatch:        vp.GetInt("INGEST_MAX_BATCH"),
+		anomalyAction:   vp.GetString("INGEST_ANOMALY_ACTION"),
+		maxSpeed:        vp.GetInt("INGEST_MAX_SPEED"),
+		maxSpeeds:       make(map[string]int),
+		serviceAreaFile: vp.GetString("INGEST_SERVICE_AREA_FILE"),
+		apiKeys:         splitList(vp.GetStringSlice("INGEST_API_KEYS")),
+	}
+	for _, entry := range splitList(vp.GetStringSlice("INGEST_MAX_SPEEDS")) {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 || parts[0] == "" {
+			i.malformedSpeeds = append(i.malformedSpeeds, entry)
+			continue
+		}
+		speed, err := strconv.Atoi(parts[1])
+		if err != nil || speed < 1 {
+			i.malformedSpeeds = append(i.malformedSpeeds, entry)
+			continue
+		}
+		i.maxSpeeds[parts[0]] = speed
+	}
+	return i
+}
+
+// validate checks that there is a road graph to snap to when snapping is enabled and that the speed limits parse
+func (i *ingestConfig) validate(routing *routingConfig) ValidationErrors {
+	var problems ValidationErrors
+	if i.snapToRoad && routing.graphFile == "" {
+		problems = append(problems, newValidationError("INGEST_SNAP_TO_ROAD", "INGEST_SNAP_TO_ROAD needs a road graph; set ROUTING_GRAPH_FILE"))
+	}
+	if len(i.malformedSpeeds) > 0 {
+		problems = append(problems, newValidationError("INGEST_MAX_SPEEDS", "INGEST_MAX_SPEEDS entries must look like type:km/h with a positive speed, got %s", strings.Join(i.malformedSpeeds, ", ")))
+	}
+	return problems
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Values of PRIVACY_MODE
+const (
+	PrivacyModeJitter = "jitter"
+	PrivacyModeRound  = "round"
+)
+
+type privacyConfig struct {
+	enabled        bool
+	trustedAPIKeys []string
+	secret         string
+	mode           string
+	precision      int
+	distanceBucket int
+	window         time.Duration
+}
+
+func newPrivacyConfig(vp *viper.Viper) *privacyConfig {
+	return &privacyConfig{
+		enabled:        vp.GetBool("PRIVACY_ENABLED"),
+		trustedAPIKeys: splitList(vp.GetStringSlice("PRIVACY_TRUSTED_API_KEYS")),
+		secret:         vp.GetString("PRIVACY_SECRET"),
+		mode:           vp.GetString("PRIVACY_MODE"),
+		precision:      vp.GetInt("PRIVACY_PRECISION"),
+		distanceBucket: vp.GetInt("PRIVACY_DISTANCE_BUCKET"),
+		window:         vp.GetDuration("PRIVACY_WINDOW"),
+	}
+}
+
+// validate checks that there is a secret to derive the jitter and the tokens from when fuzzing is enabled
+func (p *privacyConfig) validate() ValidationErrors {
+	if p.enabled && len(p.secret) < 16 {
+		return ValidationErrors{newValidationError("PRIVACY_SECRET", "PRIVACY_SECRET must be at least 16 characters when PRIVACY_ENABLED is set")}
+	}
+	return nil
+}
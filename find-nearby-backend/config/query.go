@@ -0,0 +1,92 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// QueryLimits are the defaults and caps applied to the radius (in meters) and the limit of nearby queries
+type QueryLimits struct {
+	DefaultRadius int
+	MaxRadius     int
+	DefaultLimit  int
+	MaxLimit      int
+}
+
+type queryConfig struct {
+	limits         QueryLimits
+	apiKeys        map[string]QueryLimits
+	malformed      []string
+	maxBatchSize   int
+	maxRoutePoints int
+}
+
+func newQueryConfig(vp *viper.Viper) *queryConfig {
+	q := &queryConfig{
+		limits: QueryLimits{
+			DefaultRadius: vp.GetInt("QUERY_DEFAULT_RADIUS"),
+			MaxRadius:     vp.GetInt("QUERY_MAX_RADIUS"),
+			DefaultLimit:  vp.GetInt("QUERY_DEFAULT_LIMIT"),
+			MaxLimit:      vp.GetInt("QUERY_MAX_LIMIT"),
+		},
+		apiKeys:        make(map[string]QueryLimits),
+		maxBatchSize:   vp.GetInt("QUERY_MAX_BATCH_SIZE"),
+		maxRoutePoints: vp.GetInt("QUERY_MAX_ROUTE_POINTS"),
+	}
+	for i, entry := range splitList(vp.GetStringSlice("QUERY_API_KEY_LIMITS")) {
+		apiKey, limits, ok := q.parseAPIKeyLimits(entry)
+		if !ok {
+			// the entry holds an API key, so only its position is reported
+			q.malformed = append(q.malformed, strconv.Itoa(i+1))
+			continue
+		}
+		q.apiKeys[apiKey] = limits
+	}
+	return q
+}
+
+// parseAPIKeyLimits parses an "apikey:max_radius:max_limit" entry. The defaults are the global ones,
+// lowered to the caps of the key when they are above them
+func (q *queryConfig) parseAPIKeyLimits(entry string) (string, QueryLimits, bool) {
+	parts := strings.Split(entry, ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", QueryLimits{}, false
+	}
+	maxRadius, err := strconv.Atoi(parts[1])
+	if err != nil || maxRadius < 1 {
+		return "", QueryLimits{}, false
+	}
+	maxLimit, err := strconv.Atoi(parts[2])
+	if err != nil || maxLimit < 1 {
+		return "", QueryLimits{}, false
+	}
+	return parts[0], QueryLimits{
+		DefaultRadius: minInt(q.limits.DefaultRadius, maxRadius),
+		MaxRadius:     maxRadius,
+		DefaultLimit:  minInt(q.limits.DefaultLimit, maxLimit),
+		MaxLimit:      maxLimit,
+	}, true
+}
+
+func (q *queryConfig) validate() ValidationErrors {
+	var problems ValidationErrors
+	if q.limits.DefaultRadius > q.limits.MaxRadius {
+		problems = append(problems, newValidationError("QUERY_DEFAULT_RADIUS", "QUERY_DEFAULT_RADIUS (%d) must not exceed QUERY_MAX_RADIUS (%d)", q.limits.DefaultRadius, q.limits.MaxRadius))
+	}
+	if q.limits.DefaultLimit > q.limits.MaxLimit {
+		problems = append(problems, newValidationError("QUERY_DEFAULT_LIMIT", "QUERY_DEFAULT_LIMIT (%d) must not exceed QUERY_MAX_LIMIT (%d)", q.limits.DefaultLimit, q.limits.MaxLimit))
+	}
+	if len(q.malformed) > 0 {
+		problems = append(problems, newValidationError("QUERY_API_KEY_LIMITS", "QUERY_API_KEY_LIMITS entries must look like apikey:max_radius:max_limit with positive caps, entries %s don't", strings.Join(q.malformed, ", ")))
+	}
+	return problems
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type reservationConfig struct {
+	defaultHold    time.Duration
+	maxHold        time.Duration
+	expiryInterval time.Duration
+	apiKeys        []string
+	maxHolds       int
+}
+
+func newReservationConfig(vp *viper.Viper) *reservationConfig {
+	return &reservationConfig{
+		defaultHold:    vp.GetDuration("RESERVATION_DEFAULT_HOLD"),
+		maxHold:        vp.GetDuration("RESERVATION_MAX_HOLD"),
+		expiryInterval: vp.GetDuration("RESERVATION_EXPIRY_INTERVAL"),
+		apiKeys:        splitList(vp.GetStringSlice("RESERVATION_API_KEYS")),
+		maxHolds:       vp.GetInt("RESERVATION_MAX_HOLDS"),
+	}
+}
+
+func (r *reservationConfig) validate() ValidationErrors {
+	if r.defaultHold > r.maxHold {
+		return ValidationErrors{newValidationError("RESERVATION_DEFAULT_HOLD", "RESERVATION_DEFAULT_HOLD (%s) must not exceed RESERVATION_MAX_HOLD (%s)", r.defaultHold, r.maxHold)}
+	}
+	return nil
+}
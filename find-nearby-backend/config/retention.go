@@ -0,0 +1,40 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type retentionConfig struct {
+	historyEnabled     bool
+	downsampleAfter    time.Duration
+	downsampleInterval time.Duration
+	horizon            time.Duration
+	batchSize          int
+	schedule           time.Duration
+}
+
+func newRetentionConfig(vp *viper.Viper) *retentionConfig {
+	return &retentionConfig{
+		historyEnabled:     vp.GetBool("HISTORY_ENABLED"),
+		downsampleAfter:    vp.GetDuration("RETENTION_DOWNSAMPLE_AFTER"),
+		downsampleInterval: vp.GetDuration("RETENTION_DOWNSAMPLE_INTERVAL"),
+		horizon:            vp.GetDuration("RETENTION_HORIZON"),
+		batchSize:          vp.GetInt("RETENTION_BATCH_SIZE"),
+		schedule:           vp.GetDuration("RETENTION_SCHEDULE"),
+	}
+}
+
+// validate checks that history is downsampled before it is deleted and that the schedule, when set, isn't so tight
+// that runs pile up
+func (r *retentionConfig) validate() ValidationErrors {
+	var problems ValidationErrors
+	if r.downsampleAfter >= r.horizon {
+		problems = append(problems, newValidationError("RETENTION_DOWNSAMPLE_AFTER", "RETENTION_DOWNSAMPLE_AFTER (%s) must be shorter than RETENTION_HORIZON (%s)", r.downsampleAfter, r.horizon))
+	}
+	if r.schedule > 0 && r.schedule < time.Minute {
+		problems = append(problems, newValidationError("RETENTION_SCHEDULE", "RETENTION_SCHEDULE must be 0 or at least 1m, got %s", r.schedule))
+	}
+	return problems
+}
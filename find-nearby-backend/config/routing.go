@@ -0,0 +1,17 @@
+package config
+
+import "github.com/spf13/viper"
+
+type routingConfig struct {
+	graphFile       string
+	candidateFactor int
+	maxSnapDistance int
+}
+
+func newRoutingConfig(vp *viper.Viper) *routingConfig {
+	return &routingConfig{
+		graphFile:       vp.GetString("ROUTING_GRAPH_FILE"),
+		candidateFactor: vp.GetInt("ROUTING_CANDIDATE_FACTOR"),
+		maxSnapDistance: vp.GetInt("ROUTING_MAX_SNAP_DISTANCE"),
+	}
+}
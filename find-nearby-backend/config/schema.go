@@ -0,0 +1,294 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Source tells where the effective value of a config key came from
+type Source string
+
+// Config sources, in order of precedence
+const (
+	SourceEnv     Source = "env"
+	SourceFile    Source = "file"
+	SourceDefault Source = "default"
+	SourceUnset   Source = "unset"
+)
+
+// Setting is the effective value of a config key
+type Setting struct {
+	Key    string
+	Value  string
+	Source Source
+	Secret bool
+}
+
+type keyKind int
+
+const (
+	kindString keyKind = iota
+	kindInt
+	kindFloat
+	kindBool
+	kindDuration
+	kindList
+)
+
+type keySchema struct {
+	name         string
+	kind         keyKind
+	required     bool
+	secret       bool
+	reloadable   bool
+	defaultValue interface{}
+	// check validates the parsed value and returns a problem description, or "" if the value is fine
+	check func(value interface{}) string
+}
+
+// maxInt32 bounds the radius and limit keys, which are sent to PostGIS as int32
+const maxInt32 = 1<<31 - 1
+
+var schema = []keySchema{
+	{name: "LOG_LEVEL", kind: kindString, reloadable: true, defaultValue: "warn", check: oneOf("trace", "debug", "info", "warn", "warning", "error", "fatal", "panic")},
+	{name: "LOG_FORMAT", kind: kindString, reloadable: true, defaultValue: "plaintext", check: oneOf("json", "plaintext")},
+
+	{name: "APP_HOST", kind: kindString},
+	{name: "APP_PORT", kind: kindInt, required: true, check: intBetween(1, 65535)},
+
+	{name: "DB_HOST", kind: kindString, required: true},
+	{name: "DB_PORT", kind: kindInt, defaultValue: 5432, check: intBetween(1, 65535)},
+	{name: "DB_NAME", kind: kindString, required: true},
+	{name: "DB_USER", kind: kindString, required: true},
+	{name: "DB_PASS", kind: kindString, secret: true},
+	{name: "DB_SSLMODE", kind: kindString, defaultValue: "disable", check: oneOf(validSSLModes...)},
+	{name: "DB_APPLICATION_NAME", kind: kindString},
+	{name: "DB_CONNECT_TIMEOUT", kind: kindDuration, check: durationAtLeast(0)},
+	{name: "DB_STATEMENT_TIMEOUT", kind: kindDuration, check: durationAtLeast(0)},
+	{name: "DB_MAX_IDLE_CONN", kind: kindInt, check: intBetween(0, 100000)},
+	{name: "DB_MAX_OPEN_CONN", kind: kindInt, check: intBetween(0, 100000)},
+	{name: "DB_CONN_MAX_LIFETIME", kind: kindDuration, check: durationAtLeast(0)},
+	{name: "DB_CONN_MAX_IDLE_TIME", kind: kindDuration, check: durationAtLeast(0)},
+	{name: "DB_REPLICA_HOSTS", kind: kindList},
+	{name: "DB_REPLICA_MAX_LAG", kind: kindDuration, check: durationAtLeast(0)},
+	{name: "DB_REPLICA_HEALTH_CHECK_INTERVAL", kind: kindDuration, defaultValue: 5 * time.Second, check: durationAtLeast(time.Second)},
+
+	{name: "TRACING_EXPORTER", kind: kindString, check: oneOf("", "otlp", "stdout", "file", "none")},
+	{name: "TRACING_OTLP_ENDPOINT", kind: kindString},
+	{name: "TRACING_FILE_PATH", kind: kindString},
+
+	{name: "CACHE_ENABLED", kind: kindBool, defaultValue: false},
+	{name: "CACHE_TTL", kind: kindDuration, reloadable: true, defaultValue: 5 * time.Second, check: durationAtLeast(time.Millisecond)},
+	{name: "CACHE_SIZE", kind: kindInt, defaultValue: 10000, check: intBetween(1, 10000000)},
+	{name: "CACHE_GRID_SIZE", kind: kindFloat, defaultValue: 0.0005, check: floatBetween(0.00001, 1)},
+
+	{name: "QUERY_DEFAULT_RADIUS", kind: kindInt, reloadable: true, defaultValue: 1000, check: intBetween(0, maxInt32)},
+	{name: "QUERY_MAX_RADIUS", kind: kindInt, reloadable: true, defaultValue: 50000, check: intBetween(1, maxInt32)},
+	{name: "QUERY_DEFAULT_LIMIT", kind: kindInt, reloadable: true, defaultValue: 20, check: intBetween(0, maxInt32)},
+	{name: "QUERY_MAX_LIMIT", kind: kindInt, reloadable: true, defaultValue: 500, check: intBetween(1, maxInt32)},
+	{name: "QUERY_API_KEY_LIMITS", kind: kindList, secret: true, reloadable: true},
+	{name: "QUERY_MAX_BATCH_SIZE", kind: kindInt, reloadable: true, defaultValue: 500, check: intBetween(1, 100000)},
+	{name: "QUERY_MAX_ROUTE_POINTS", kind: kindInt, reloadable: true, defaultValue: 1000, check: intBetween(2, 100000)},
+
+	{name: "DISPATCH_MAX_PICKUP_DISTANCE", kind: kindInt, defaultValue: 3000, check: intBetween(1, maxInt32)},
+	{name: "DISPATCH_CANDIDATES", kind: kindInt, defaultValue: 10, check: intBetween(1, 1000)},
+	{name: "DISPATCH_MAX_REQUESTS", kind: kindInt, defaultValue: 200, check: intBetween(1, 10000)},
+
+	{name: "RESERVATION_DEFAULT_HOLD", kind: kindDuration, defaultValue: 10 * time.Minute, check: durationAtLeast(time.Minute)},
+	{name: "RESERVATION_MAX_HOLD", kind: kindDuration, defaultValue: 30 * time.Minute, check: durationAtLeast(time.Minute)},
+	{name: "RESERVATION_EXPIRY_INTERVAL", kind: kindDuration, defaultValue: 30 * time.Second, check: durationAtLeast(time.Second)},
+	{name: "RESERVATION_API_KEYS", kind: kindList, secret: true},
+	{name: "RESERVATION_MAX_HOLDS", kind: kindInt, defaultValue: 200, check: intBetween(1, 100000)},
+
+	{name: "ROUTING_GRAPH_FILE", kind: kindString},
+	{name: "ROUTING_CANDIDATE_FACTOR", kind: kindInt, defaultValue: 3, check: intBetween(1, 20)},
+	{name: "ROUTING_MAX_SNAP_DISTANCE", kind: kindInt, defaultValue: 200, check: intBetween(1, 10000)},
+
+	{name: "INGEST_SNAP_TO_ROAD", kind: kindBool, defaultValue: false},
+	{name: "INGEST_SNAP_MAX_DISTANCE", kind: kindInt, defaultValue: 30, check: intBetween(1, 1000)},
+	{name: "INGEST_SERVE", kind: kindString, defaultValue: "snapped", check: oneOf("snapped", "raw")},
+	{name: "INGEST_MAX_BATCH", kind: kindInt, defaultValue: 1000, check: intBetween(1, 100000)},
+	{name: "INGEST_ANOMALY_ACTION", kind: kindString, defaultValue: "reject", check: oneOf("reject", "flag")},
+	{name: "INGEST_MAX_SPEED", kind: kindInt, defaultValue: 250, check: intBetween(0, 100000)},
+	{name: "INGEST_MAX_SPEEDS", kind: kindList},
+	{name: "INGEST_SERVICE_AREA_FILE", kind: kindString},
+	{name: "INGEST_API_KEYS", kind: kindList, secret: true},
+
+	{name: "PRIVACY_ENABLED", kind: kindBool, reloadable: true, defaultValue: false},
+	{name: "PRIVACY_TRUSTED_API_KEYS", kind: kindList, secret: true, reloadable: true},
+	{name: "PRIVACY_SECRET", kind: kindString, secret: true, reloadable: true},
+	{name: "PRIVACY_MODE", kind: kindString, reloadable: true, defaultValue: "jitter", check: oneOf("jitter", "round")},
+	{name: "PRIVACY_PRECISION", kind: kindInt, reloadable: true, defaultValue: 200, check: intBetween(1, 100000)},
+	{name: "PRIVACY_DISTANCE_BUCKET", kind: kindInt, reloadable: true, defaultValue: 100, check: intBetween(1, 100000)},
+	{name: "PRIVACY_WINDOW", kind: kindDuration, reloadable: true, defaultValue: 15 * time.Minute, check: durationAtLeast(time.Minute)},
+
+	{name: "AUDIT_ENABLED", kind: kindBool, defaultValue: true},
+	{name: "AUDIT_BUFFER_SIZE", kind: kindInt, defaultValue: 10000, check: intBetween(1, 1000000)},
+	{name: "AUDIT_BATCH_SIZE", kind: kindInt, defaultValue: 500, check: intBetween(1, 10000)},
+	{name: "AUDIT_FLUSH_INTERVAL", kind: kindDuration, defaultValue: time.Second, check: durationAtLeast(10 * time.Millisecond)},
+	{name: "AUDIT_READER_API_KEYS", kind: kindList, secret: true},
+
+	{name: "HISTORY_ENABLED", kind: kindBool, defaultValue: false},
+	{name: "RETENTION_DOWNSAMPLE_AFTER", kind: kindDuration, defaultValue: 7 * 24 * time.Hour, check: durationAtLeast(time.Hour)},
+	{name: "RETENTION_DOWNSAMPLE_INTERVAL", kind: kindDuration, defaultValue: time.Minute, check: durationAtLeast(time.Second)},
+	{name: "RETENTION_HORIZON", kind: kindDuration, defaultValue: 90 * 24 * time.Hour, check: durationAtLeast(time.Hour)},
+	{name: "RETENTION_BATCH_SIZE", kind: kindInt, defaultValue: 5000, check: intBetween(1, 1000000)},
+	{name: "RETENTION_SCHEDULE", kind: kindDuration, defaultValue: time.Duration(0), check: durationAtLeast(0)},
+}
+
+func setDefaults(vp *viper.Viper) {
+	for _, key := range schema {
+		if key.defaultValue != nil {
+			vp.SetDefault(key.name, key.defaultValue)
+		}
+	}
+}
+
+// validateKeys checks every key of the schema for presence, type and range, and flags file keys that aren't in the schema
+func validateKeys(vp *viper.Viper) ValidationErrors {
+	var problems ValidationErrors
+	known := make(map[string]bool, len(schema))
+	for _, key := range schema {
+		known[key.name] = true
+		raw := vp.Get(key.name)
+		if raw == nil || raw == "" {
+			if key.required {
+				problems = append(problems, newValidationError(key.name, "%s is required", key.name))
+			}
+			continue
+		}
+		value, err := parse(key.kind, raw)
+		if err != nil {
+			problems = append(problems, newValidationError(key.name, "%s must be %s, got %q", key.name, kindName(key.kind), fmt.Sprint(raw)))
+			continue
+		}
+		if key.check == nil {
+			continue
+		}
+		if problem := key.check(value); problem != "" {
+			problems = append(problems, newValidationError(key.name, "%s %s", key.name, problem))
+		}
+	}
+
+	var unknown []string
+	for _, name := range vp.AllKeys() {
+		if !known[strings.ToUpper(name)] && vp.InConfig(name) {
+			unknown = append(unknown, strings.ToUpper(name))
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, newValidationError(name, "%s in %s is not a known config key", name, vp.ConfigFileUsed()))
+	}
+	return problems
+}
+
+// settings returns the effective value and source of every key of the schema
+func settings(vp *viper.Viper) []Setting {
+	result := make([]Setting, 0, len(schema))
+	for _, key := range schema {
+		setting := Setting{Key: key.name, Secret: key.secret, Source: SourceUnset}
+		switch {
+		case os.Getenv(key.name) != "":
+			setting.Source = SourceEnv
+		case vp.InConfig(strings.ToLower(key.name)):
+			setting.Source = SourceFile
+		case key.defaultValue != nil:
+			setting.Source = SourceDefault
+		}
+		if setting.Source != SourceUnset {
+			setting.Value = fmt.Sprint(vp.Get(key.name))
+		}
+		if key.secret && setting.Value != "" {
+			setting.Value = "******"
+		}
+		result = append(result, setting)
+	}
+	return result
+}
+
+func secrets(vp *viper.Viper) map[string]string {
+	values := make(map[string]string)
+	for _, key := range schema {
+		if key.secret {
+			values[key.name] = fmt.Sprint(vp.Get(key.name))
+		}
+	}
+	return values
+}
+
+func parse(kind keyKind, raw interface{}) (interface{}, error) {
+	switch kind {
+	case kindInt:
+		return cast.ToIntE(raw)
+	case kindFloat:
+		return cast.ToFloat64E(raw)
+	case kindBool:
+		return cast.ToBoolE(raw)
+	case kindDuration:
+		return cast.ToDurationE(raw)
+	case kindList:
+		return cast.ToStringSliceE(raw)
+	default:
+		return cast.ToStringE(raw)
+	}
+}
+
+func kindName(kind keyKind) string {
+	switch kind {
+	case kindInt:
+		return "an integer"
+	case kindFloat:
+		return "a number"
+	case kindBool:
+		return "true or false"
+	case kindDuration:
+		return "a duration like 500ms, 5s or 30m"
+	case kindList:
+		return "a list"
+	default:
+		return "a string"
+	}
+}
+
+func oneOf(values ...string) func(interface{}) string {
+	return func(value interface{}) string {
+		if contains(values, value.(string)) {
+			return ""
+		}
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(values, ", "), value)
+	}
+}
+
+func intBetween(min, max int) func(interface{}) string {
+	return func(value interface{}) string {
+		if v := value.(int); v < min || v > max {
+			return fmt.Sprintf("must be between %d and %d, got %d", min, max, v)
+		}
+		return ""
+	}
+}
+
+func floatBetween(min, max float64) func(interface{}) string {
+	return func(value interface{}) string {
+		if v := value.(float64); v < min || v > max {
+			return fmt.Sprintf("must be between %g and %g, got %g", min, max, v)
+		}
+		return ""
+	}
+}
+
+func durationAtLeast(min time.Duration) func(interface{}) string {
+	return func(value interface{}) string {
+		if v := value.(time.Duration); v < min {
+			return fmt.Sprintf("must be at least %s, got %s", min, v)
+		}
+		return ""
+	}
+}
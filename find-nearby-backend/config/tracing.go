@@ -0,0 +1,24 @@
+package config
+
+import "github.com/spf13/viper"
+
+type tracingConfig struct {
+	exporter     string
+	otlpEndpoint string
+	filePath     string
+}
+
+func newTracingConfig(vp *viper.Viper) *tracingConfig {
+	return &tracingConfig{
+		exporter:     vp.GetString("TRACING_EXPORTER"),
+		otlpEndpoint: vp.GetString("TRACING_OTLP_ENDPOINT"),
+		filePath:     vp.GetString("TRACING_FILE_PATH"),
+	}
+}
+
+func (t *tracingConfig) validate() ValidationErrors {
+	if t.exporter == "file" && t.filePath == "" {
+		return ValidationErrors{newValidationError("TRACING_FILE_PATH", "TRACING_FILE_PATH is required when TRACING_EXPORTER is file")}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Change is a config key whose effective value differs after a reload
+type Change struct {
+	Key        string
+	Old        string
+	New        string
+	Reloadable bool
+}
+
+// Reload describes one reload of application.yml. When Err is set the new file was rejected
+// and the previous config stays in effect
+type Reload struct {
+	Config  Config
+	Changes []Change
+	Err     error
+}
+
+// Watcher reloads application.yml whenever it changes and tells its listeners what changed.
+// Only keys marked reloadable in the schema are meant to be applied at runtime; any other change
+// is reported so that the operator knows a restart is needed
+type Watcher struct {
+	mu        sync.Mutex
+	vp        *viper.Viper
+	current   config
+	listeners []func(Reload)
+}
+
+// NewWatcher loads the config the same way Load does and returns a Watcher for it
+func NewWatcher() (*Watcher, error) {
+	vp := viper.New()
+	if err := readInConfig(vp); err != nil {
+		return nil, err
+	}
+	cfg := newConfig(vp)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Watcher{vp: vp, current: cfg}, nil
+}
+
+// Current returns the config currently in effect
+func (w *Watcher) Current() Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// OnReload registers fn to be called after every reload of the config file
+func (w *Watcher) OnReload(fn func(Reload)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// Start watches the config file for changes. It does nothing when no config file was found
+func (w *Watcher) Start() {
+	if w.vp.ConfigFileUsed() == "" {
+		return
+	}
+	w.vp.OnConfigChange(func(fsnotify.Event) { w.reload() })
+	w.vp.WatchConfig()
+}
+
+func (w *Watcher) reload() {
+	w.mu.Lock()
+	next := newConfig(w.vp)
+	reload := Reload{Changes: diff(w.current, next)}
+	if len(reload.Changes) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	if reload.Err = next.Validate(); reload.Err == nil {
+		w.current = next
+		reload.Config = next
+	}
+	listeners := append([]func(Reload){}, w.listeners...)
+	w.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(reload)
+	}
+}
+
+// diff returns the keys whose effective value differs between two configs. Secrets are compared on their
+// raw values but reported redacted, so a changed password shows up without revealing either value
+func diff(old, next config) []Change {
+	oldSettings := make(map[string]Setting, len(old.settings))
+	for _, setting := range old.settings {
+		oldSettings[setting.Key] = setting
+	}
+	var changes []Change
+	for i, key := range schema {
+		before, after := oldSettings[key.name], next.settings[i]
+		if before.Value == after.Value && old.secrets[key.name] == next.secrets[key.name] {
+			continue
+		}
+		changes = append(changes, Change{Key: key.name, Old: before.Value, New: after.Value, Reloadable: key.reloadable})
+	}
+	return changes
+}
@@ -0,0 +1,88 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"find-nearby-backend/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_WhenFileChanges_ShouldReportChanges(t *testing.T) {
+	sample, err := ioutil.ReadFile("../application.yml.sample")
+	require.NoError(t, err)
+	dir := chdirTemp(t)
+	path := filepath.Join(dir, "application.yml")
+	require.NoError(t, ioutil.WriteFile(path, sample, 0644))
+
+	watcher, err := config.NewWatcher()
+	require.NoError(t, err)
+	reloads := make(chan config.Reload, 10)
+	watcher.OnReload(func(r config.Reload) { reloads <- r })
+	watcher.Start()
+
+	updated := strings.Replace(string(sample), `LOG_LEVEL: "debug"`, `LOG_LEVEL: "info"`, 1)
+	updated = strings.Replace(updated, "DB_PASS: postgres", "DB_PASS: rotated", 1)
+	require.NoError(t, ioutil.WriteFile(path, []byte(updated), 0644))
+
+	reload := waitForReload(t, reloads)
+	assert.NoError(t, reload.Err)
+	assert.Equal(t, "info", reload.Config.LogLevel())
+	assert.Equal(t, "info", watcher.Current().LogLevel())
+	assert.Equal(t, []config.Change{
+		{Key: "LOG_LEVEL", Old: "debug", New: "info", Reloadable: true},
+		{Key: "DB_PASS", Old: "******", New: "******", Reloadable: false},
+	}, reload.Changes)
+}
+
+func TestWatcher_WhenFileBecomesInvalid_ShouldKeepPreviousConfig(t *testing.T) {
+	sample, err := ioutil.ReadFile("../application.yml.sample")
+	require.NoError(t, err)
+	dir := chdirTemp(t)
+	path := filepath.Join(dir, "application.yml")
+	require.NoError(t, ioutil.WriteFile(path, sample, 0644))
+
+	watcher, err := config.NewWatcher()
+	require.NoError(t, err)
+	reloads := make(chan config.Reload, 10)
+	watcher.OnReload(func(r config.Reload) { reloads <- r })
+	watcher.Start()
+
+	updated := strings.Replace(string(sample), "CACHE_TTL: 5s", "CACHE_TTL: -1s", 1)
+	require.NoError(t, ioutil.WriteFile(path, []byte(updated), 0644))
+
+	reload := waitForReload(t, reloads)
+	assert.Error(t, reload.Err)
+	assert.Nil(t, reload.Config)
+	assert.Equal(t, 5*time.Second, watcher.Current().CacheTTL())
+}
+
+// chdirTemp moves the test into an empty directory so that only the application.yml it writes is found
+func chdirTemp(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "config-watcher")
+	require.NoError(t, err)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func waitForReload(t *testing.T, reloads chan config.Reload) config.Reload {
+	select {
+	case reload := <-reloads:
+		return reload
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+		return config.Reload{}
+	}
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"find-nearby-backend/logger"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// replicationLagQuery returns the replication lag in seconds. A replica that has replayed everything it received
+// reports no lag even if the primary has been idle, and a server that is not in recovery is never behind
+const replicationLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// Replica is a read replica of the primary DB
+type Replica struct {
+	Name string
+	DB   *sqlx.DB
+}
+
+type replicaState struct {
+	Replica
+	healthy int32
+}
+
+// Cluster routes reads to healthy read replicas in round-robin order and everything else to the primary.
+// When no replica is healthy, reads fall back to the primary
+type Cluster struct {
+	next     uint32
+	logger   logger.Logger
+	primary  *sqlx.DB
+	replicas []*replicaState
+	maxLag   time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewCluster is a constructor for Cluster. Replicas start out unhealthy until CheckReplicas has seen them
+// respond within maxLag; a zero maxLag disables the lag check
+func NewCluster(logger logger.Logger, primary *sqlx.DB, replicas []Replica, maxLag time.Duration) *Cluster {
+	states := make([]*replicaState, 0, len(replicas))
+	for _, r := range replicas {
+		states = append(states, &replicaState{Replica: r})
+	}
+	return &Cluster{
+		logger:   logger,
+		primary:  primary,
+		replicas: states,
+		maxLag:   maxLag,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Primary returns the primary DB, which serves all writes
+func (c *Cluster) Primary() *sqlx.DB {
+	return c.primary
+}
+
+// Reader returns a healthy replica for read-only queries, or the primary if there is none
+func (c *Cluster) Reader() *sqlx.DB {
+	n := len(c.replicas)
+	if n == 0 {
+		return c.primary
+	}
+	start := int(atomic.AddUint32(&c.next, 1))
+	for i := 0; i < n; i++ {
+		r := c.replicas[(start+i)%n]
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			return r.DB
+		}
+	}
+	return c.primary
+}
+
+// PrimaryOnly returns a cluster over the same primary that serves reads from the primary as well, for repositories
+// whose reads feed decisions that a lagging replica would get wrong. It shares the primary, so only c is closed
+func (c *Cluster) PrimaryOnly() *Cluster {
+	return NewCluster(c.logger, c.primary, nil, 0)
+}
+
+// HealthyReplicas returns the names of the replicas currently receiving reads
+func (c *Cluster) HealthyReplicas() []string {
+	var names []string
+	for _, r := range c.replicas {
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// CheckReplicas pings every replica and checks its replication lag, updating which replicas receive reads
+func (c *Cluster) CheckReplicas(ctx context.Context) {
+	for _, r := range c.replicas {
+		err := c.checkReplica(ctx, r)
+		healthy := int32(1)
+		if err != nil {
+			healthy = 0
+		}
+		previous := atomic.SwapInt32(&r.healthy, healthy)
+		switch {
+		case previous == 1 && healthy == 0:
+			c.logger.Warnf("database replica %s removed from read routing, err: %s", r.Name, err.Error())
+		case previous == 0 && healthy == 1:
+			c.logger.Infof("database replica %s added to read routing", r.Name)
+		case healthy == 0:
+			c.logger.Debugf("database replica %s is still unavailable, err: %s", r.Name, err.Error())
+		}
+	}
+}
+
+// StartHealthChecks checks the replicas right away and then every interval until Close is called
+func (c *Cluster) StartHealthChecks(interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	c.CheckReplicas(ctx)
+	cancel()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				c.CheckReplicas(ctx)
+				cancel()
+			}
+		}
+	}()
+}
+
+// Close stops the health checks and closes the primary and all replicas
+func (c *Cluster) Close() error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	c.wg.Wait()
+	err := c.primary.Close()
+	for _, r := range c.replicas {
+		if closeErr := r.DB.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (c *Cluster) checkReplica(ctx context.Context, r *replicaState) error {
+	var lagSeconds float64
+	if err := r.DB.GetContext(ctx, &lagSeconds, replicationLagQuery); err != nil {
+		return err
+	}
+	lag := time.Duration(lagSeconds * float64(time.Second))
+	if c.maxLag > 0 && lag > c.maxLag {
+		return fmt.Errorf("replication lag %s exceeds %s", lag, c.maxLag)
+	}
+	return nil
+}
+
+// replicaName identifies a replica in logs without leaking its credentials
+func replicaName(index int, connectionURL string) string {
+	u, err := url.Parse(connectionURL)
+	if err != nil || u.Host == "" {
+		return fmt.Sprintf("replica-%d", index)
+	}
+	return u.Host
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"time"
+
 	"find-nearby-backend/config"
 	"find-nearby-backend/logger"
 
@@ -8,26 +10,68 @@ import (
 	_ "github.com/lib/pq" // init PG driver as required by sqlx
 )
 
-func New(cfg config.Config, logger logger.Logger) (*sqlx.DB, error) {
-	db, err := newDBHandle(cfg.DatabaseConnectionURL(), cfg.DatabaseMaxPoolSize(), cfg.DatabaseMaxIdleConn())
+// New opens the primary DB and its read replicas. The primary must be reachable;
+// replicas that are down are left out of read routing until a health check succeeds
+func New(cfg config.Config, logger logger.Logger) (*Cluster, error) {
+	pool := newPoolSettings(cfg)
+	primary, err := newDBHandle(cfg.DatabaseConnectionURL(), pool)
 	if err != nil {
-		return db, err
+		return nil, err
+	}
+	if err = primary.Ping(); err != nil {
+		primary.Close()
+		return nil, err
+	}
+	logger.Infof("database connection initialized with max %d open and %d idle connections, max lifetime %s and max idle time %s",
+		pool.maxOpenConns, pool.maxIdleConns, pool.connMaxLifetime, pool.connMaxIdleTime)
+
+	var replicas []Replica
+	for i, url := range cfg.DatabaseReplicaConnectionURLs() {
+		db, err := newDBHandle(url, pool)
+		if err != nil {
+			primary.Close()
+			for _, r := range replicas {
+				r.DB.Close()
+			}
+			return nil, err
+		}
+		replicas = append(replicas, Replica{Name: replicaName(i, url), DB: db})
+	}
+
+	cluster := NewCluster(logger, primary, replicas, cfg.DatabaseReplicaMaxLag())
+	if len(replicas) > 0 {
+		cluster.StartHealthChecks(cfg.DatabaseReplicaHealthCheckInterval())
+		logger.Infof("database read routing initialized with %d replicas", len(replicas))
 	}
-	logger.Infof("database connection initialized with pool size %d", cfg.DatabaseMaxPoolSize())
-	return db, err
+	return cluster, nil
 }
 
-func newDBHandle(conStr string, maxPoolSize int, maxIdleConns int) (*sqlx.DB, error) {
-	db, err := sqlx.Open("postgres", conStr)
-	if err != nil {
-		return nil, err
+// poolSettings are applied to the primary and to every replica; zero values mean no limit
+type poolSettings struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+	connMaxIdleTime time.Duration
+}
+
+func newPoolSettings(cfg config.Config) poolSettings {
+	return poolSettings{
+		maxOpenConns:    cfg.DatabaseMaxOpenConn(),
+		maxIdleConns:    cfg.DatabaseMaxIdleConn(),
+		connMaxLifetime: cfg.DatabaseConnMaxLifetime(),
+		connMaxIdleTime: cfg.DatabaseConnMaxIdleTime(),
 	}
+}
 
-	if err = db.Ping(); err != nil {
+func newDBHandle(conStr string, pool poolSettings) (*sqlx.DB, error) {
+	db, err := sqlx.Open("postgres", conStr)
+	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(maxPoolSize)
-	db.SetMaxIdleConns(maxIdleConns)
+	db.SetMaxOpenConns(pool.maxOpenConns)
+	db.SetMaxIdleConns(pool.maxIdleConns)
+	db.SetConnMaxLifetime(pool.connMaxLifetime)
+	db.SetConnMaxIdleTime(pool.connMaxIdleTime)
 	return db, nil
 }
@@ -0,0 +1 @@
+DROP TABLE vehicles;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE vehicles(
+    id INT8 PRIMARY KEY,
+    type TEXT NOT NULL DEFAULT 'scooter',
+    city TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'available' CHECK (status IN ('available', 'busy', 'offline', 'maintenance')),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+INSERT INTO vehicles (id) SELECT vehicle_id FROM locations ON CONFLICT DO NOTHING;
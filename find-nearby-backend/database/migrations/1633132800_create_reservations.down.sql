@@ -0,0 +1 @@
+DROP TABLE reservations;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE reservations(
+    id BIGSERIAL PRIMARY KEY,
+    vehicle_id INT8 NOT NULL REFERENCES vehicles (id),
+    holder TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'held' CHECK (status IN ('held', 'confirmed', 'cancelled', 'expired')),
+    version INT NOT NULL DEFAULT 1,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- a vehicle can only have one hold at a time; this is what keeps two users from reserving the same vehicle
+CREATE UNIQUE INDEX reservations_held_vehicle_idx ON reservations (vehicle_id) WHERE status = 'held';
@@ -0,0 +1,5 @@
+ALTER TABLE locations
+    DROP COLUMN raw_location,
+    DROP COLUMN snapped_location,
+    DROP COLUMN snapped,
+    DROP COLUMN recorded_at;
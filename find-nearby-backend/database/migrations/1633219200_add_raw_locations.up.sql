@@ -0,0 +1,6 @@
+ALTER TABLE locations
+    ADD COLUMN raw_location GEOMETRY,
+    ADD COLUMN snapped_location GEOMETRY,
+    ADD COLUMN snapped BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN recorded_at TIMESTAMPTZ;
+UPDATE locations SET raw_location = location;
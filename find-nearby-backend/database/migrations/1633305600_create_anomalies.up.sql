@@ -0,0 +1,17 @@
+CREATE TABLE anomalies(
+    id BIGSERIAL PRIMARY KEY,
+    vehicle_id INT8 NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('speed', 'null_island', 'out_of_area')),
+    action TEXT NOT NULL CHECK (action IN ('rejected', 'flagged')),
+    latitude FLOAT8 NOT NULL,
+    longitude FLOAT8 NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL,
+    speed FLOAT8,
+    max_speed FLOAT8,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    reviewed_at TIMESTAMPTZ,
+    reviewed_by TEXT
+);
+CREATE INDEX anomalies_vehicle_id_idx ON anomalies (vehicle_id, id);
+-- the review queue reads the anomalies nobody has looked at yet, newest first
+CREATE INDEX anomalies_unreviewed_idx ON anomalies (id) WHERE reviewed_at IS NULL;
@@ -0,0 +1,26 @@
+CREATE TABLE audit_log(
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    request_id TEXT NOT NULL,
+    caller TEXT NOT NULL,
+    remote_addr TEXT NOT NULL,
+    action TEXT NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    results INT,
+    status INT NOT NULL
+);
+CREATE INDEX audit_log_caller_idx ON audit_log (caller, id);
+CREATE INDEX audit_log_action_idx ON audit_log (action, id);
+CREATE INDEX audit_log_request_id_idx ON audit_log (request_id);
+CREATE INDEX audit_log_occurred_at_idx ON audit_log (occurred_at);
+
+-- the audit log is append-only: records can't be changed or removed, short of dropping the table
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE PROCEDURE audit_log_append_only();
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE PROCEDURE audit_log_append_only();
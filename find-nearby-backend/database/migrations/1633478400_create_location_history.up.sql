@@ -0,0 +1,11 @@
+CREATE TABLE location_history(
+    id BIGSERIAL PRIMARY KEY,
+    vehicle_id INT8 NOT NULL,
+    location GEOMETRY NOT NULL,
+    raw_location GEOMETRY NOT NULL,
+    snapped BOOLEAN NOT NULL DEFAULT FALSE,
+    recorded_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX location_history_vehicle_id_idx ON location_history (vehicle_id, recorded_at);
+-- retention works through the history by age, across vehicles
+CREATE INDEX location_history_recorded_at_idx ON location_history (recorded_at);
@@ -0,0 +1,3 @@
+UPDATE reservations SET status = 'cancelled' WHERE status = 'completed';
+ALTER TABLE reservations DROP CONSTRAINT reservations_status_check;
+ALTER TABLE reservations ADD CONSTRAINT reservations_status_check CHECK (status IN ('held', 'confirmed', 'cancelled', 'expired'));
@@ -0,0 +1,3 @@
+-- a confirmed reservation ends when the ride is completed, which hands the vehicle back
+ALTER TABLE reservations DROP CONSTRAINT reservations_status_check;
+ALTER TABLE reservations ADD CONSTRAINT reservations_status_check CHECK (status IN ('held', 'confirmed', 'cancelled', 'expired', 'completed'));
@@ -0,0 +1,5 @@
+-- how far the history has been downsampled to each interval, so a compaction does not rank it all again
+CREATE TABLE history_watermarks(
+    downsample_interval INTERVAL PRIMARY KEY,
+    downsampled_before TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,71 @@
+// Package migrations embeds the SQL migrations in the binary, so that migrating doesn't depend on the working directory
+package migrations
+
+import (
+	"embed"
+	"sort"
+
+	"github.com/golang-migrate/migrate"
+	_ "github.com/golang-migrate/migrate/database/postgres" // required
+	"github.com/golang-migrate/migrate/source"
+	bindata "github.com/golang-migrate/migrate/source/go_bindata"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// New returns a migrate instance for the database at databaseURL that reads the embedded migrations
+func New(databaseURL string) (*migrate.Migrate, error) {
+	src, err := Source()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewWithSourceInstance("go-bindata", src, databaseURL)
+}
+
+// Source returns a source driver of the embedded migrations
+func Source() (source.Driver, error) {
+	names, err := names()
+	if err != nil {
+		return nil, err
+	}
+	return bindata.WithInstance(bindata.Resource(names, files.ReadFile))
+}
+
+// Migration is an embedded migration, e.g. version 1633046400 named create_vehicles
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// List returns the embedded migrations, oldest first
+func List() ([]Migration, error) {
+	names, err := names()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[uint]bool, len(names))
+	var list []Migration
+	for _, name := range names {
+		m, err := source.DefaultParse(name)
+		if err != nil || seen[m.Version] {
+			continue
+		}
+		seen[m.Version] = true
+		list = append(list, Migration{Version: m.Version, Name: m.Identifier})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func names() ([]string, error) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names, nil
+}
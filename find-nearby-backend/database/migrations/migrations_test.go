@@ -0,0 +1,39 @@
+package migrations_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"find-nearby-backend/database/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource_ShouldServeEveryEmbeddedMigrationInOrder(t *testing.T) {
+	list, err := migrations.List()
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+	assert.Equal(t, migrations.Migration{Version: 1618574327, Name: "create_locations"}, list[0])
+
+	src, err := migrations.Source()
+	require.NoError(t, err)
+	version, err := src.First()
+	require.NoError(t, err)
+	for i, m := range list {
+		assert.Equal(t, m.Version, version)
+		up, _, err := src.ReadUp(version)
+		require.NoError(t, err, m.Name)
+		body, err := ioutil.ReadAll(up)
+		require.NoError(t, err)
+		assert.NotEmpty(t, body, m.Name)
+		_, _, err = src.ReadDown(version)
+		assert.NoError(t, err, m.Name)
+		if i < len(list)-1 {
+			version, err = src.Next(version)
+			require.NoError(t, err)
+		}
+	}
+	_, err = src.Next(version)
+	assert.Error(t, err, "there is nothing after the last migration")
+}
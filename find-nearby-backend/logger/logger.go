@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"os"
 
 	"github.com/sirupsen/logrus"
@@ -19,16 +20,22 @@ type Logger interface {
 	Fatalf(format string, args ...interface{})
 	Panicf(format string, args ...interface{})
 	ErrorWithTag(err error, fields Fields)
+	WithFields(fields Fields) Logger
+	WithContext(ctx context.Context) Logger
+	SetLevel(logLevel string) error
+	SetFormat(logFormat string)
 }
 
 type logger struct {
-	logrus *logrus.Logger
+	entry *logrus.Entry
 }
 
 type Error struct {
 	Error error
 }
 
+type requestIDKey struct{}
+
 // Setup the logger with appropriate log-level and format.
 // Valid log-levels are debug, info, warn, error, fatal, panic.
 // Valid log-formats are plain or json (default: json)
@@ -38,50 +45,94 @@ func New(logLevel, logFormat string) Logger {
 		level = logrus.WarnLevel
 	}
 
-	formatter := &logrus.JSONFormatter{
-		DataKey: "xcontext",
-	}
-
-	log := &logger{logrus: &logrus.Logger{
+	l := &logrus.Logger{
 		Out:       os.Stderr,
 		Hooks:     make(logrus.LevelHooks),
 		Level:     level,
-		Formatter: formatter,
-	}}
+		Formatter: newFormatter(logFormat),
+	}
+
+	return &logger{entry: logrus.NewEntry(l)}
+}
 
+func newFormatter(logFormat string) logrus.Formatter {
 	if logFormat != "json" {
-		log.logrus.Formatter = &logrus.TextFormatter{}
+		return &logrus.TextFormatter{}
 	}
+	return &logrus.JSONFormatter{
+		DataKey: "xcontext",
+	}
+}
+
+// ContextWithRequestID returns a copy of ctx carrying the given request ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or an empty string if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// WithFields returns a logger that adds the given fields to every line it writes
+func (log *logger) WithFields(fields Fields) Logger {
+	return &logger{entry: log.entry.WithFields(logrus.Fields(fields))}
+}
+
+// WithContext returns a logger that adds the request ID found in ctx to every line it writes
+func (log *logger) WithContext(ctx context.Context) Logger {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		return log
+	}
+	return log.WithFields(Fields{"request_id": requestID})
+}
+
+// SetLevel changes the log level at runtime. Loggers derived with WithFields or WithContext share the level
+func (log *logger) SetLevel(logLevel string) error {
+	level, err := logrus.ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	log.entry.Logger.SetLevel(level)
+	return nil
+}
 
-	return log
+// SetFormat changes the log format at runtime. Loggers derived with WithFields or WithContext share the format
+func (log *logger) SetFormat(logFormat string) {
+	log.entry.Logger.SetFormatter(newFormatter(logFormat))
 }
 
 func (log *logger) ErrorWithTag(err error, fields Fields) {
 	if err != nil {
-		log.logrus.WithFields(logrus.Fields(fields)).Error(err.Error())
+		log.entry.WithFields(logrus.Fields(fields)).Error(err.Error())
 	}
 }
 
 func (log *logger) Debugf(format string, args ...interface{}) {
-	log.logrus.Debugf(format, args...)
+	log.entry.Debugf(format, args...)
 }
 
 func (log *logger) Infof(format string, args ...interface{}) {
-	log.logrus.Infof(format, args...)
+	log.entry.Infof(format, args...)
 }
 
 func (log *logger) Warnf(format string, args ...interface{}) {
-	log.logrus.Warnf(format, args...)
+	log.entry.Warnf(format, args...)
 }
 
 func (log *logger) Errorf(format string, args ...interface{}) {
-	log.logrus.Errorf(format, args...)
+	log.entry.Errorf(format, args...)
 }
 
 func (log *logger) Fatalf(format string, args ...interface{}) {
-	log.logrus.Fatalf(format, args...)
+	log.entry.Fatalf(format, args...)
 }
 
 func (log *logger) Panicf(format string, args ...interface{}) {
-	log.logrus.Panicf(format, args...)
+	log.entry.Panicf(format, args...)
 }
@@ -1,6 +1,7 @@
 package logger_test
 
 import (
+	"context"
 	"errors"
 	"find-nearby-backend/logger"
 	"testing"
@@ -26,6 +27,33 @@ func TestNoPanic(t *testing.T) {
 	})
 }
 
+func TestWithFieldsAndContextNoPanic(t *testing.T) {
+	assert.NotPanics(t, func() {
+		l := logger.New("debug", "json")
+		ctx := logger.ContextWithRequestID(context.Background(), "req-1")
+		l.WithFields(logger.Fields{"bar": "baz"}).Infof("foo %d", 1)
+		l.WithContext(ctx).Infof("foo %d", 1)
+		l.WithContext(context.Background()).Debugf("foo %d", 1)
+		l.WithContext(ctx).WithFields(nil).ErrorWithTag(errors.New("foo"), logger.Fields{"bar": "baz"})
+	})
+}
+
+func TestRequestIDFromContext(t *testing.T) {
+	ctx := logger.ContextWithRequestID(context.Background(), "req-1")
+	assert.Equal(t, "req-1", logger.RequestIDFromContext(ctx))
+	assert.Equal(t, "", logger.RequestIDFromContext(context.Background()))
+}
+
+func TestSetLevelAndFormat(t *testing.T) {
+	l := logger.New("debug", "plaintext")
+	assert.NoError(t, l.SetLevel("error"))
+	assert.Error(t, l.SetLevel("loud"))
+	assert.NotPanics(t, func() {
+		l.SetFormat("json")
+		l.WithFields(logger.Fields{"bar": "baz"}).Errorf("foo %d", 1)
+	})
+}
+
 func TestPanic(t *testing.T) {
 	assert.Panics(t, func() {
 		l := logger.New("debug", "plaintext")
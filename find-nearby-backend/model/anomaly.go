@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// Anomaly kinds
+const (
+	AnomalyKindSpeed      = "speed"
+	AnomalyKindNullIsland = "null_island"
+	AnomalyKindOutOfArea  = "out_of_area"
+)
+
+// What was done with the ping of an anomaly. A flagged ping is stored like any other, a rejected one isn't
+const (
+	AnomalyActionRejected = "rejected"
+	AnomalyActionFlagged  = "flagged"
+)
+
+// Anomaly is a ping that failed a check on ingest. Speed is the speed in km/h the ping implies the vehicle drove at
+// since its previous fix and MaxSpeed the limit of its type; both are only set for speed anomalies. VehicleToken
+// stands in for VehicleID for callers who may not see it, as on Location
+type Anomaly struct {
+	ID           int64      `db:"id" json:"id"`
+	VehicleID    int64      `db:"vehicle_id" json:"vehicle_id"`
+	Kind         string     `db:"kind" json:"kind"`
+	Action       string     `db:"action" json:"action"`
+	Latitude     float64    `db:"latitude" json:"latitude"`
+	Longitude    float64    `db:"longitude" json:"longitude"`
+	RecordedAt   time.Time  `db:"recorded_at" json:"recorded_at"`
+	Speed        *float64   `db:"speed" json:"speed,omitempty"`
+	MaxSpeed     *float64   `db:"max_speed" json:"max_speed,omitempty"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	ReviewedAt   *time.Time `db:"reviewed_at" json:"reviewed_at,omitempty"`
+	ReviewedBy   *string    `db:"reviewed_by" json:"reviewed_by,omitempty"`
+	VehicleToken string     `db:"-" json:"vehicle_token,omitempty"`
+}
+
+// AnomalyFilter selects anomalies for review, newest first. Zero values match everything; BeforeID pages
+// through the results by passing the ID of the last anomaly of the previous page
+type AnomalyFilter struct {
+	VehicleID int64
+	Kind      string
+	Reviewed  *bool
+	BeforeID  int64
+	Limit     int
+}
+
+// Track is what is known of a vehicle before a batch of its pings is checked: its type and, when it has one, the raw
+// coordinates of its last stored fix and when that was recorded
+type Track struct {
+	VehicleID  int64
+	Type       string
+	Latitude   float64
+	Longitude  float64
+	RecordedAt *time.Time
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// Area is a region made of one or more polygons, each a ring of [lng, lat] points followed by the rings of its holes
+type Area struct {
+	polygons [][][][]float64
+}
+
+// ParseArea reads an area from a GeoJSON Polygon or MultiPolygon, either bare or as the geometry of a Feature
+// or of every Feature of a FeatureCollection
+func ParseArea(data []byte) (*Area, error) {
+	var probe struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("invalid area: %v", err)
+	}
+	var geometries []*geojson.Geometry
+	switch probe.Type {
+	case "FeatureCollection":
+		collection, err := geojson.UnmarshalFeatureCollection(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid area: %v", err)
+		}
+		for _, feature := range collection.Features {
+			geometries = append(geometries, feature.Geometry)
+		}
+	case "Feature":
+		feature, err := geojson.UnmarshalFeature(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid area: %v", err)
+		}
+		geometries = append(geometries, feature.Geometry)
+	default:
+		geometry, err := geojson.UnmarshalGeometry(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid area: %v", err)
+		}
+		geometries = append(geometries, geometry)
+	}
+
+	area := &Area{}
+	for _, geometry := range geometries {
+		switch {
+		case geometry == nil:
+			return nil, errors.New("invalid area: a feature has no geometry")
+		case geometry.IsPolygon():
+			area.polygons = append(area.polygons, geometry.Polygon)
+		case geometry.IsMultiPolygon():
+			area.polygons = append(area.polygons, geometry.MultiPolygon...)
+		default:
+			return nil, fmt.Errorf("invalid area: expected a Polygon or MultiPolygon, got a %s", geometry.Type)
+		}
+	}
+	for _, polygon := range area.polygons {
+		if len(polygon) == 0 || len(polygon[0]) < 4 {
+			return nil, errors.New("invalid area: a polygon needs a ring of at least 4 points")
+		}
+		for _, ring := range polygon {
+			for _, point := range ring {
+				if len(point) < 2 {
+					return nil, fmt.Errorf("invalid area: expected a [lng, lat] point, got %v", point)
+				}
+			}
+		}
+	}
+	if len(area.polygons) == 0 {
+		return nil, errors.New("invalid area: it has no polygons")
+	}
+	return area, nil
+}
+
+// NewBoxArea returns the area within a bounding box
+func NewBoxArea(minLongitude, minLatitude, maxLongitude, maxLatitude float64) *Area {
+	return &Area{polygons: [][][][]float64{{{
+		{minLongitude, minLatitude}, {maxLongitude, minLatitude}, {maxLongitude, maxLatitude}, {minLongitude, maxLatitude}, {minLongitude, minLatitude},
+	}}}}
+}
+
+// Bounds returns the bounding box of the area
+func (a *Area) Bounds() (minLatitude, minLongitude, maxLatitude, maxLongitude float64) {
+	minLatitude, minLongitude, maxLatitude, maxLongitude = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, polygon := range a.polygons {
+		for _, point := range polygon[0] {
+			minLongitude, maxLongitude = math.Min(minLongitude, point[0]), math.Max(maxLongitude, point[0])
+			minLatitude, maxLatitude = math.Min(minLatitude, point[1]), math.Max(maxLatitude, point[1])
+		}
+	}
+	return minLatitude, minLongitude, maxLatitude, maxLongitude
+}
+
+// Contains reports whether a point is inside the area. Points on a boundary may fall either way
+func (a *Area) Contains(latitude, longitude float64) bool {
+	for _, polygon := range a.polygons {
+		if inRing(polygon[0], latitude, longitude) {
+			inHole := false
+			for _, hole := range polygon[1:] {
+				if inRing(hole, latitude, longitude) {
+					inHole = true
+					break
+				}
+			}
+			if !inHole {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inRing casts a ray east of the point and counts the edges of the ring it crosses
+func inRing(ring [][]float64, latitude, longitude float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi, xj, yj := ring[i][0], ring[i][1], ring[j][0], ring[j][1]
+		if (yi > latitude) != (yj > latitude) && longitude < (xj-xi)*(latitude-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
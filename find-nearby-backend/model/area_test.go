@@ -0,0 +1,32 @@
+package model_test
+
+import (
+	"find-nearby-backend/model"
+
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArea_ShouldContainThePointsInsideButOutsideTheHoles(t *testing.T) {
+	area, err := model.ParseArea([]byte(`{"type": "Polygon", "coordinates": [
+		[[103.6, 1.2], [104.1, 1.2], [104.1, 1.5], [103.6, 1.5], [103.6, 1.2]],
+		[[103.8, 1.3], [103.9, 1.3], [103.9, 1.4], [103.8, 1.4], [103.8, 1.3]]
+	]}`))
+	require.NoError(t, err)
+	assert.True(t, area.Contains(1.25, 103.7))
+	assert.False(t, area.Contains(1.35, 103.85))
+	assert.False(t, area.Contains(1.6, 103.9))
+}
+
+func TestParseArea_WhenAPointHasNoLatitude_ShouldReturnError(t *testing.T) {
+	for _, data := range []string{
+		`{"type": "Polygon", "coordinates": [[[103.6, 1.2], [104.1], [104.1, 1.5], [103.6, 1.5], [103.6, 1.2]]]}`,
+		`{"type": "Polygon", "coordinates": [[[103.6, 1.2], [104.1, 1.2], [104.1, 1.5], [103.6, 1.2]], [[103.8, 1.3], [], [103.9, 1.4], [103.8, 1.3]]]}`,
+	} {
+		_, err := model.ParseArea([]byte(data))
+		assert.Error(t, err, data)
+		assert.Contains(t, err.Error(), "expected a [lng, lat] point")
+	}
+}
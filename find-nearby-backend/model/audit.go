@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+)
+
+// AuditRecord is an entry of the audit log: a search or a write, who made it and what came of it. Caller is the
+// fingerprint of the caller's API key, empty for callers without one. Action is the method and route of the request,
+// Params the params it was made with and Results how many vehicles, locations or records it returned or changed;
+// Results is nil when the request failed before getting that far
+type AuditRecord struct {
+	ID         int64          `db:"id" json:"id"`
+	OccurredAt time.Time      `db:"occurred_at" json:"occurred_at"`
+	RequestID  string         `db:"request_id" json:"request_id"`
+	Caller     string         `db:"caller" json:"caller"`
+	RemoteAddr string         `db:"remote_addr" json:"remote_addr"`
+	Action     string         `db:"action" json:"action"`
+	Params     types.JSONText `db:"params" json:"params"`
+	Results    *int           `db:"results" json:"results,omitempty"`
+	Status     int            `db:"status" json:"status"`
+}
+
+// AuditFilter selects audit records, newest first. Zero values match everything; VehicleID matches the records whose
+// params name the vehicle, and BeforeID pages through the results by passing the ID of the last record of the
+// previous page
+type AuditFilter struct {
+	Caller    string
+	Action    string
+	RequestID string
+	VehicleID int64
+	From      time.Time
+	To        time.Time
+	BeforeID  int64
+	Limit     int
+}
@@ -0,0 +1,33 @@
+package model
+
+import "fmt"
+
+// CorridorOrder is the order the vehicles found along a route are returned in
+type CorridorOrder string
+
+const (
+	// OrderByDistance returns the vehicles closest to the route first
+	OrderByDistance CorridorOrder = "distance"
+	// OrderByStart returns the vehicles in the order the route passes them
+	OrderByStart CorridorOrder = "start"
+)
+
+// Corridor is a search for the vehicles within Buffer meters of a route
+type Corridor struct {
+	// Route holds the [longitude, latitude] points of the route, from its start, like a GeoJSON LineString
+	Route  [][]float64
+	Buffer int
+	Limit  int
+	Order  CorridorOrder
+}
+
+// ParseCorridorOrder parses distance or start; an empty string means distance
+func ParseCorridorOrder(order string) (CorridorOrder, error) {
+	switch CorridorOrder(order) {
+	case "", OrderByDistance:
+		return OrderByDistance, nil
+	case OrderByStart:
+		return OrderByStart, nil
+	}
+	return "", fmt.Errorf("invalid order: %q; order must be one of distance, start", order)
+}
@@ -0,0 +1,29 @@
+package model
+
+// RideRequest is a rider waiting to be picked up
+type RideRequest struct {
+	ID        string
+	Latitude  float64
+	Longitude float64
+}
+
+// Assignment pairs a ride request with the vehicle sent to pick it up. Distance is in meters, and Reservation is the
+// hold on the vehicle, to be confirmed once the rider accepts it. VehicleToken stands in for VehicleID for callers who
+// may not see it, as on Location
+type Assignment struct {
+	RequestID    string      `json:"request_id"`
+	VehicleID    int64       `json:"vehicle_id"`
+	Distance     float64     `json:"distance"`
+	Reservation  Reservation `json:"reservation"`
+	VehicleToken string      `json:"vehicle_token,omitempty"`
+}
+
+// DispatchPlan is the result of assigning vehicles to ride requests. Every vehicle is assigned at most once;
+// requests with no available vehicle within MaxPickupDistance, or whose vehicle was held first by someone else,
+// are left unassigned
+type DispatchPlan struct {
+	Assignments       []Assignment `json:"assignments"`
+	Unassigned        []string     `json:"unassigned"`
+	TotalDistance     float64      `json:"total_distance"`
+	MaxPickupDistance int          `json:"max_pickup_distance"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Ping is a GPS fix reported by a vehicle. RecordedAt is when the vehicle took the fix
+type Ping struct {
+	VehicleID  int64     `json:"vehicle_id"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Snap is a GPS fix moved onto the road network. Found is false when no road was close enough to move it to
+type Snap struct {
+	Found     bool
+	Latitude  float64
+	Longitude float64
+	// Distance is how far, in meters, the fix was moved
+	Distance float64
+}
+
+// Position is where a vehicle is stored to be. Latitude and Longitude are the coordinates searches use and return:
+// the snapped ones when Snapped is set, otherwise the raw ones. Snap is kept either way so the policy can be switched
+type Position struct {
+	VehicleID    int64
+	Latitude     float64
+	Longitude    float64
+	Snapped      bool
+	RawLatitude  float64
+	RawLongitude float64
+	Snap         Snap
+	RecordedAt   time.Time
+}
+
+// IngestResult sums up an ingested batch of pings. Only the latest ping of a vehicle that passes the checks is stored,
+// and not even that when a later one is already stored. Rejected and Flagged count the anomalies found
+type IngestResult struct {
+	Received int `json:"received"`
+	Stored   int `json:"stored"`
+	Snapped  int `json:"snapped"`
+	Rejected int `json:"rejected"`
+	Flagged  int `json:"flagged"`
+}
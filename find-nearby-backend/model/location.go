@@ -2,8 +2,41 @@ package model
 
 // Location represents the location of the vehicle. The Vehicle can be of any type.
 type Location struct {
-	VehicleID int64   `db:"vehicle_id" json:"vehicle_id"`
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	Distance  float64 `json:"distance"`
+	VehicleID int64 `db:"vehicle_id" json:"vehicle_id"`
+	// VehicleToken stands in for VehicleID for callers who may not see it. It changes with every privacy window
+	VehicleToken string  `db:"-" json:"vehicle_token,omitempty"`
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	Distance     float64 `json:"distance"`
+	// Snapped is set when the coordinates were moved onto the road network on ingest
+	Snapped bool `json:"snapped"`
+	// ETA and RouteDistance are the drive time in seconds and the road distance to the origin.
+	// They are only set when results are ranked by ETA and the vehicle can reach the origin over the road graph
+	ETA           *float64 `db:"-" json:"eta,omitempty"`
+	RouteDistance *float64 `db:"-" json:"route_distance,omitempty"`
+	// Along is how far along a route, from its start, the point closest to the vehicle is. It is only set by corridor searches
+	Along *float64 `db:"-" json:"along,omitempty"`
+}
+
+// NearbyLocations are the vehicle locations found around an origin, closest first
+type NearbyLocations struct {
+	Locations []Location
+	// Total is the number of vehicles within the radius, which is more than len(Locations) when the limit cut the results
+	Total int
+}
+
+// Bounds is the bounding box of a set of points
+type Bounds struct {
+	MinLatitude  float64 `db:"min_latitude" json:"min_latitude"`
+	MinLongitude float64 `db:"min_longitude" json:"min_longitude"`
+	MaxLatitude  float64 `db:"max_latitude" json:"max_latitude"`
+	MaxLongitude float64 `db:"max_longitude" json:"max_longitude"`
+}
+
+// NearbyQuery is one origin of a batch search. Radius is in meters
+type NearbyQuery struct {
+	Latitude  float64
+	Longitude float64
+	Radius    int
+	Limit     int
 }
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DecodePolyline decodes a route in the encoded polyline format into [longitude, latitude] points.
+// precision is the number of decimal places the coordinates were encoded with: 5 for Google, 6 for OSRM and Valhalla
+func DecodePolyline(encoded string, precision int) ([][]float64, error) {
+	if precision < 1 || precision > 7 {
+		return nil, fmt.Errorf("invalid polyline precision: %d; precision must be between 1 and 7", precision)
+	}
+	factor := math.Pow10(precision)
+	var points [][]float64
+	var lat, lng int64
+	for i := 0; i < len(encoded); {
+		dLat, next, err := decodeValue(encoded, i)
+		if err != nil {
+			return nil, err
+		}
+		dLng, next, err := decodeValue(encoded, next)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+		lat, lng = lat+dLat, lng+dLng
+		points = append(points, []float64{float64(lng) / factor, float64(lat) / factor})
+	}
+	return points, nil
+}
+
+// decodeValue decodes the signed value starting at i and returns it with the index of the next value
+func decodeValue(encoded string, i int) (int64, int, error) {
+	var result int64
+	for shift := uint(0); ; shift += 5 {
+		if i >= len(encoded) {
+			return 0, 0, errors.New("invalid polyline: it ends in the middle of a value")
+		}
+		b := int64(encoded[i]) - 63
+		i++
+		if b < 0 || b > 63 || shift > 60 {
+			return 0, 0, fmt.Errorf("invalid polyline: unexpected character %q at %d", encoded[i-1], i-1)
+		}
+		result |= (b & 0x1f) << shift
+		if b < 0x20 {
+			break
+		}
+	}
+	if result&1 != 0 {
+		return ^(result >> 1), i, nil
+	}
+	return result >> 1, i, nil
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Reservation statuses. A held reservation whose hold has lapsed is reported as expired
+const (
+	ReservationStatusHeld      = "held"
+	ReservationStatusConfirmed = "confirmed"
+	ReservationStatusCancelled = "cancelled"
+	ReservationStatusExpired   = "expired"
+	ReservationStatusCompleted = "completed"
+)
+
+// Reservation is a hold on a vehicle by a user. Version is incremented on every change and must be
+// sent back to cancel, confirm or complete the reservation, so that a stale view of it can't overwrite a newer one.
+// VehicleToken stands in for VehicleID for callers who may not see it, as on Location
+type Reservation struct {
+	ID           int64     `db:"id" json:"id"`
+	VehicleID    int64     `db:"vehicle_id" json:"vehicle_id"`
+	Holder       string    `db:"holder" json:"holder"`
+	Status       string    `db:"status" json:"status"`
+	Version      int       `db:"version" json:"version"`
+	ExpiresAt    time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+	VehicleToken string    `db:"-" json:"vehicle_token,omitempty"`
+}
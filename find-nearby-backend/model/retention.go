@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// RetentionReport is what a compaction of the location history removed. History recorded before DeletedBefore was
+// deleted; history recorded between then and DownsampledBefore was thinned out to a point per vehicle and interval
+type RetentionReport struct {
+	Deleted           int       `json:"deleted"`
+	Downsampled       int       `json:"downsampled"`
+	DeletedBefore     time.Time `json:"deleted_before"`
+	DownsampledBefore time.Time `json:"downsampled_before"`
+}
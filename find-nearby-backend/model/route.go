@@ -0,0 +1,11 @@
+package model
+
+// Route is the fastest way from a vehicle to an origin over the road graph
+type Route struct {
+	// Found is false when the vehicle or the origin is too far from the road graph or the roads don't connect them
+	Found bool
+	// Duration is in seconds
+	Duration float64
+	// Distance is in meters
+	Distance float64
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// How an import treats the vehicles already stored. Upsert adds the imported vehicles and updates the ones it has
+// a record of; replace also removes every vehicle it has no record of
+const (
+	SnapshotModeUpsert  = "upsert"
+	SnapshotModeReplace = "replace"
+)
+
+// SnapshotRecord is a vehicle and its location as they are exported and imported. Vehicle fields left empty keep the
+// value the vehicle already has, or the default of the vehicles table for a new vehicle
+type SnapshotRecord struct {
+	VehicleID  int64
+	Latitude   float64
+	Longitude  float64
+	Type       string
+	City       string
+	Status     string
+	RecordedAt *time.Time
+}
@@ -0,0 +1,40 @@
+package model
+
+import "fmt"
+
+// Unit is a unit of distance. Distances are stored and computed in meters
+type Unit string
+
+// Supported units of distance
+const (
+	Meters     Unit = "m"
+	Kilometers Unit = "km"
+	Miles      Unit = "mi"
+)
+
+var metersPerUnit = map[Unit]float64{
+	Meters:     1,
+	Kilometers: 1000,
+	Miles:      1609.344,
+}
+
+// ParseUnit parses m, km or mi; an empty string means meters
+func ParseUnit(unit string) (Unit, error) {
+	if unit == "" {
+		return Meters, nil
+	}
+	if _, ok := metersPerUnit[Unit(unit)]; !ok {
+		return "", fmt.Errorf("invalid units: %q; units must be one of m, km, mi", unit)
+	}
+	return Unit(unit), nil
+}
+
+// ToMeters converts a distance in u to meters
+func (u Unit) ToMeters(distance float64) float64 {
+	return distance * metersPerUnit[u]
+}
+
+// FromMeters converts a distance in meters to u
+func (u Unit) FromMeters(meters float64) float64 {
+	return meters / metersPerUnit[u]
+}
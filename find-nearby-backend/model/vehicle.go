@@ -1,8 +1,14 @@
 package model
 
+// Vehicle statuses. Only available vehicles can be dispatched
+const (
+	VehicleStatusAvailable   = "available"
+	VehicleStatusBusy        = "busy"
+	VehicleStatusOffline     = "offline"
+	VehicleStatusMaintenance = "maintenance"
+)
+
 // Vehicle represents a vehicle of a certain type (e.g scooter, car, bike)
-// This entity is not used in this implementation.
-// The purpose of it being here is to showcase how the system can be extended to display Vehicle details together with its location.
 type Vehicle struct {
 	ID     int64
 	Type   string
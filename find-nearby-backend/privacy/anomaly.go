@@ -0,0 +1,50 @@
+package privacy
+
+import (
+	"context"
+	"time"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/usecase"
+)
+
+// AnomalyUsecase is a usecase.AnomalyUsecase decorator that hands out anomalies with the vehicle ID and position as
+// the scope of the caller, taken from the context, allows
+type AnomalyUsecase struct {
+	usecase usecase.AnomalyUsecase
+	policy  *Policy
+}
+
+// NewAnomalyUsecase is a constructor for AnomalyUsecase
+func NewAnomalyUsecase(usecase usecase.AnomalyUsecase, policy *Policy) *AnomalyUsecase {
+	return &AnomalyUsecase{usecase: usecase, policy: policy}
+}
+
+// FindAnomalies returns the anomalies that match filter
+func (a *AnomalyUsecase) FindAnomalies(ctx context.Context, filter model.AnomalyFilter) ([]model.Anomaly, error) {
+	anomalies, err := a.usecase.FindAnomalies(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	scope, now := ScopeFromContext(ctx), time.Now()
+	fuzzed := make([]model.Anomaly, len(anomalies))
+	for i, anomaly := range anomalies {
+		fuzzed[i] = a.hide(scope, anomaly, now)
+	}
+	return fuzzed, nil
+}
+
+// Review marks an anomaly as reviewed by reviewer
+func (a *AnomalyUsecase) Review(ctx context.Context, id int64, reviewer string) (model.Anomaly, error) {
+	anomaly, err := a.usecase.Review(ctx, id, reviewer)
+	if err != nil {
+		return model.Anomaly{}, err
+	}
+	return a.hide(ScopeFromContext(ctx), anomaly, time.Now()), nil
+}
+
+func (a *AnomalyUsecase) hide(scope Scope, anomaly model.Anomaly, at time.Time) model.Anomaly {
+	anomaly.Latitude, anomaly.Longitude = a.policy.FuzzPoint(scope, anomaly.VehicleID, anomaly.Latitude, anomaly.Longitude, at)
+	anomaly.VehicleID, anomaly.VehicleToken = a.policy.HideVehicle(scope, anomaly.VehicleID, at)
+	return anomaly
+}
@@ -0,0 +1,30 @@
+package privacy_test
+
+import (
+	"context"
+	"testing"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnomalyUsecase_WhenCallerIsPublic_ShouldFuzzAnomalies(t *testing.T) {
+	anomalies := []model.Anomaly{{ID: 1, VehicleID: 7, Kind: model.AnomalyKindSpeed, Latitude: 1.3, Longitude: 103.9}}
+	anomalyUsecaseMock := new(usecaseMocks.AnomalyUsecase)
+	anomalyUsecaseMock.On("FindAnomalies", mock.Anything, model.AnomalyFilter{Limit: 50}).Return(anomalies, nil)
+
+	public, err := privacy.NewAnomalyUsecase(anomalyUsecaseMock, newPolicy(t, "jitter")).
+		FindAnomalies(privacy.ContextWithScope(context.Background(), privacy.ScopePublic), model.AnomalyFilter{Limit: 50})
+	require.NoError(t, err)
+	require.Len(t, public, 1)
+	assert.Equal(t, int64(0), public[0].VehicleID)
+	assert.NotEmpty(t, public[0].VehicleToken)
+	assert.NotEqual(t, 1.3, public[0].Latitude)
+	assert.InDelta(t, 1.3, public[0].Latitude, 200/111320.0)
+	assert.Equal(t, int64(7), anomalies[0].VehicleID, "the anomalies of the usecase are left as they are")
+}
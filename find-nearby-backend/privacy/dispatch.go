@@ -0,0 +1,42 @@
+package privacy
+
+import (
+	"context"
+	"time"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/usecase"
+)
+
+// DispatchUsecase is a usecase.DispatchUsecase decorator that hands out dispatch plans with the vehicle IDs and pickup
+// distances as the scope of the caller, taken from the context, allows
+type DispatchUsecase struct {
+	usecase usecase.DispatchUsecase
+	policy  *Policy
+}
+
+// NewDispatchUsecase is a constructor for DispatchUsecase
+func NewDispatchUsecase(usecase usecase.DispatchUsecase, policy *Policy) *DispatchUsecase {
+	return &DispatchUsecase{usecase: usecase, policy: policy}
+}
+
+// Assign assigns vehicles to ride requests and holds them for holder
+func (d *DispatchUsecase) Assign(ctx context.Context, requests []model.RideRequest, maxPickupDistance int, holder string) (model.DispatchPlan, error) {
+	plan, err := d.usecase.Assign(ctx, requests, maxPickupDistance, holder)
+	if err != nil {
+		return model.DispatchPlan{}, err
+	}
+	scope, now := ScopeFromContext(ctx), time.Now()
+	assignments := make([]model.Assignment, len(plan.Assignments))
+	// the total adds up the bucketed distances, since the exact one would give away the distance of a single assignment
+	plan.TotalDistance = 0
+	for i, assignment := range plan.Assignments {
+		assignment.VehicleID, assignment.VehicleToken = d.policy.HideVehicle(scope, assignment.VehicleID, now)
+		assignment.Reservation.VehicleID, assignment.Reservation.VehicleToken = assignment.VehicleID, assignment.VehicleToken
+		assignment.Distance = d.policy.BucketDistance(scope, assignment.Distance)
+		plan.TotalDistance += assignment.Distance
+		assignments[i] = assignment
+	}
+	plan.Assignments = assignments
+	return plan, nil
+}
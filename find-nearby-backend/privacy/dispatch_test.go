@@ -0,0 +1,53 @@
+package privacy_test
+
+import (
+	"context"
+	"testing"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatchUsecase_WhenCallerIsPublic_ShouldHideTheAssignedVehicles(t *testing.T) {
+	plan := model.DispatchPlan{Assignments: []model.Assignment{{RequestID: "a", VehicleID: 7, Distance: 250, Reservation: model.Reservation{ID: 1, VehicleID: 7}}}}
+	dispatchUsecaseMock := new(usecaseMocks.DispatchUsecase)
+	dispatchUsecaseMock.On("Assign", mock.Anything, mock.Anything, 1000, "dispatcher").Return(plan, nil)
+
+	public, err := privacy.NewDispatchUsecase(dispatchUsecaseMock, newPolicy(t, "jitter")).
+		Assign(privacy.ContextWithScope(context.Background(), privacy.ScopePublic), nil, 1000, "dispatcher")
+	require.NoError(t, err)
+	require.Len(t, public.Assignments, 1)
+	assignment := public.Assignments[0]
+	assert.Equal(t, int64(0), assignment.VehicleID)
+	assert.NotEmpty(t, assignment.VehicleToken)
+	assert.Equal(t, int64(0), assignment.Reservation.VehicleID)
+	assert.Equal(t, assignment.VehicleToken, assignment.Reservation.VehicleToken)
+	assert.Equal(t, int64(7), plan.Assignments[0].VehicleID, "the plan of the usecase is left as it is")
+}
+
+func TestDispatchUsecase_WhenCallerIsPublic_ShouldBucketTheDistances(t *testing.T) {
+	plan := model.DispatchPlan{Assignments: []model.Assignment{
+		{RequestID: "a", VehicleID: 7, Distance: 250.4},
+		{RequestID: "b", VehicleID: 8, Distance: 1201},
+	}, TotalDistance: 1451.4}
+	dispatchUsecaseMock := new(usecaseMocks.DispatchUsecase)
+	dispatchUsecaseMock.On("Assign", mock.Anything, mock.Anything, 3000, "dispatcher").Return(plan, nil)
+	dispatch := privacy.NewDispatchUsecase(dispatchUsecaseMock, newPolicy(t, "jitter"))
+
+	public, err := dispatch.Assign(privacy.ContextWithScope(context.Background(), privacy.ScopePublic), nil, 3000, "dispatcher")
+	require.NoError(t, err)
+	require.Len(t, public.Assignments, 2)
+	assert.Equal(t, 300.0, public.Assignments[0].Distance)
+	assert.Equal(t, 1300.0, public.Assignments[1].Distance)
+	assert.Equal(t, 1600.0, public.TotalDistance)
+
+	trusted, err := dispatch.Assign(privacy.ContextWithScope(context.Background(), privacy.ScopeTrusted), nil, 3000, "dispatcher")
+	require.NoError(t, err)
+	assert.Equal(t, 250.4, trusted.Assignments[0].Distance)
+	assert.Equal(t, 1451.4, trusted.TotalDistance)
+}
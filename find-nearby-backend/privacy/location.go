@@ -0,0 +1,116 @@
+package privacy
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/usecase"
+)
+
+// LocationUsecase is a usecase.LocationUsecase decorator that hands out locations as the scope of the caller,
+// taken from the context, allows. It sits between the usecase and the handlers, so the cache and ranking
+// still work on exact locations. The radius, or buffer, of a public caller is rounded up to the distance bucket and
+// its results are ordered by the rounded values, so that which vehicles are found, how many and in what order tells no
+// more than the rounded distances do: narrowing the radius meter by meter can't tell where a vehicle is
+type LocationUsecase struct {
+	usecase usecase.LocationUsecase
+	policy  *Policy
+}
+
+// NewLocationUsecase is a constructor for LocationUsecase
+func NewLocationUsecase(usecase usecase.LocationUsecase, policy *Policy) *LocationUsecase {
+	return &LocationUsecase{usecase: usecase, policy: policy}
+}
+
+// FindVehicleLocations finds nearby locations
+func (l *LocationUsecase) FindVehicleLocations(ctx context.Context, latitude, longitude float64, radius, limit int) (model.NearbyLocations, error) {
+	radius = l.bucketRadius(ctx, radius)
+	return l.apply(ctx, byDistance)(l.usecase.FindVehicleLocations(ctx, latitude, longitude, radius, limit))
+}
+
+// FindVehicleLocationsBatch finds nearby locations for many origins at once; the results are in the order of the queries
+func (l *LocationUsecase) FindVehicleLocationsBatch(ctx context.Context, queries []model.NearbyQuery) ([]model.NearbyLocations, error) {
+	bucketed := make([]model.NearbyQuery, len(queries))
+	for i, query := range queries {
+		query.Radius = l.bucketRadius(ctx, query.Radius)
+		bucketed[i] = query
+	}
+	results, err := l.usecase.FindVehicleLocationsBatch(ctx, bucketed)
+	if err != nil {
+		return nil, err
+	}
+	scope, now := ScopeFromContext(ctx), time.Now()
+	for i := range results {
+		results[i].Locations = reorder(scope, l.policy.Apply(scope, results[i].Locations, now), byDistance)
+	}
+	return results, nil
+}
+
+// FindVehicleLocationsByETA finds nearby locations ranked by drive time to the origin
+func (l *LocationUsecase) FindVehicleLocationsByETA(ctx context.Context, latitude, longitude float64, radius, limit int) (model.NearbyLocations, error) {
+	radius = l.bucketRadius(ctx, radius)
+	return l.apply(ctx, byETA)(l.usecase.FindVehicleLocationsByETA(ctx, latitude, longitude, radius, limit))
+}
+
+// FindVehicleLocationsAlongRoute finds the locations within the buffer of a route
+func (l *LocationUsecase) FindVehicleLocationsAlongRoute(ctx context.Context, corridor model.Corridor) (model.NearbyLocations, error) {
+	corridor.Buffer = l.bucketRadius(ctx, corridor.Buffer)
+	rank := byDistance
+	if corridor.Order == model.OrderByStart {
+		rank = byAlong
+	}
+	return l.apply(ctx, rank)(l.usecase.FindVehicleLocationsAlongRoute(ctx, corridor))
+}
+
+func (l *LocationUsecase) apply(ctx context.Context, rank func(model.Location) float64) func(model.NearbyLocations, error) (model.NearbyLocations, error) {
+	return func(nearby model.NearbyLocations, err error) (model.NearbyLocations, error) {
+		if err != nil {
+			return model.NearbyLocations{}, err
+		}
+		scope := ScopeFromContext(ctx)
+		nearby.Locations = reorder(scope, l.policy.Apply(scope, nearby.Locations, time.Now()), rank)
+		return nearby, nil
+	}
+}
+
+// bucketRadius rounds a radius in meters up to the distance bucket for public callers
+func (l *LocationUsecase) bucketRadius(ctx context.Context, radius int) int {
+	return int(l.policy.BucketDistance(ScopeFromContext(ctx), float64(radius)))
+}
+
+// reorder sorts the locations a public caller gets by the rounded value they were ranked by, and by token where that
+// is the same, so that the order doesn't tell which of two vehicles in the same bucket is closer
+func reorder(scope Scope, locations []model.Location, rank func(model.Location) float64) []model.Location {
+	if scope == ScopeTrusted {
+		return locations
+	}
+	sort.SliceStable(locations, func(i, j int) bool {
+		if ri, rj := rank(locations[i]), rank(locations[j]); ri != rj {
+			return ri < rj
+		}
+		return locations[i].VehicleToken < locations[j].VehicleToken
+	})
+	return locations
+}
+
+func byDistance(location model.Location) float64 {
+	return location.Distance
+}
+
+// byETA ranks the vehicles that can't reach the origin over the road graph last, as the usecase does
+func byETA(location model.Location) float64 {
+	if location.ETA == nil {
+		return math.Inf(1)
+	}
+	return *location.ETA
+}
+
+func byAlong(location model.Location) float64 {
+	if location.Along == nil {
+		return math.Inf(1)
+	}
+	return *location.Along
+}
@@ -0,0 +1,56 @@
+package privacy_test
+
+import (
+	"context"
+	"testing"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocationUsecase_WhenCallerIsPublic_ShouldRoundTheRadiusUpToTheBucket(t *testing.T) {
+	nearby := model.NearbyLocations{Locations: []model.Location{
+		{VehicleID: 1, Latitude: 1.3, Longitude: 103.9, Distance: 210},
+		{VehicleID: 2, Latitude: 1.3, Longitude: 103.9, Distance: 260},
+		{VehicleID: 3, Latitude: 1.3, Longitude: 103.9, Distance: 290},
+	}, Total: 3}
+	locationUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationUsecaseMock.On("FindVehicleLocations", mock.Anything, 1.3, 103.9, 300, 10).Return(nearby, nil)
+	locationUsecaseMock.On("FindVehicleLocations", mock.Anything, 1.3, 103.9, 201, 10).Return(nearby, nil)
+	locations := privacy.NewLocationUsecase(locationUsecaseMock, newPolicy(t, "jitter"))
+
+	for _, radius := range []int{201, 250, 300} {
+		public, err := locations.FindVehicleLocations(privacy.ContextWithScope(context.Background(), privacy.ScopePublic), 1.3, 103.9, radius, 10)
+		require.NoError(t, err)
+		require.Len(t, public.Locations, 3, "radius %d", radius)
+		for i, location := range public.Locations {
+			assert.Equal(t, 300.0, location.Distance)
+			if i > 0 {
+				assert.Less(t, public.Locations[i-1].VehicleToken, location.VehicleToken, "vehicles in the same bucket are ordered by token")
+			}
+		}
+	}
+
+	trusted, err := locations.FindVehicleLocations(privacy.ContextWithScope(context.Background(), privacy.ScopeTrusted), 1.3, 103.9, 201, 10)
+	require.NoError(t, err)
+	assert.Equal(t, nearby, trusted)
+	locationUsecaseMock.AssertNumberOfCalls(t, "FindVehicleLocations", 4)
+}
+
+func TestLocationUsecase_WhenCallerIsPublic_ShouldRoundTheBufferOfACorridorUp(t *testing.T) {
+	corridor := model.Corridor{Route: [][]float64{{103.9, 1.3}, {103.91, 1.31}}, Buffer: 120, Limit: 10, Order: model.OrderByDistance}
+	bucketed := corridor
+	bucketed.Buffer = 200
+	locationUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationUsecaseMock.On("FindVehicleLocationsAlongRoute", mock.Anything, bucketed).Return(model.NearbyLocations{}, nil)
+
+	_, err := privacy.NewLocationUsecase(locationUsecaseMock, newPolicy(t, "jitter")).
+		FindVehicleLocationsAlongRoute(privacy.ContextWithScope(context.Background(), privacy.ScopePublic), corridor)
+	require.NoError(t, err)
+	locationUsecaseMock.AssertExpectations(t)
+}
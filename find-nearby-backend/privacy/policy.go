@@ -0,0 +1,226 @@
+package privacy
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/model"
+)
+
+const metersPerDegree = 111320.0
+
+// ErrInvalidToken is returned for a vehicle token that wasn't handed out, or was handed out more than a window ago
+var ErrInvalidToken = errors.New("invalid or expired vehicle token")
+
+// Scope is what a caller may see of a vehicle
+type Scope string
+
+// Scopes. Trusted callers get exact locations and vehicle IDs, public callers fuzzed locations and rotating tokens
+const (
+	ScopePublic  Scope = "public"
+	ScopeTrusted Scope = "trusted"
+)
+
+type scopeKey struct{}
+
+// ContextWithScope returns a copy of ctx carrying the scope of the caller
+func ContextWithScope(ctx context.Context, scope Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFromContext returns the scope of the caller, or ScopePublic when ctx doesn't carry one
+func ScopeFromContext(ctx context.Context) Scope {
+	if scope, ok := ctx.Value(scopeKey{}).(Scope); ok {
+		return scope
+	}
+	return ScopePublic
+}
+
+// Policy decides the scope of callers and fuzzes what public callers see. Within a time window a vehicle keeps
+// the same jitter and token, so asking again and averaging the answers doesn't give its position away, and
+// a token can't be followed from one window to the next. Tokens are the window and the vehicle ID encrypted with
+// a key derived from the secret, so that a public caller can hand one back, to reserve the vehicle for instance
+type Policy struct {
+	mu             sync.RWMutex
+	enabled        bool
+	trusted        map[string]bool
+	secret         []byte
+	tokens         cipher.Block
+	round          bool
+	precision      float64
+	distanceBucket float64
+	window         time.Duration
+}
+
+// NewPolicy is a constructor for Policy
+func NewPolicy(cfg config.Config) *Policy {
+	p := &Policy{}
+	p.Update(cfg)
+	return p
+}
+
+// Update replaces the settings with the ones in cfg. It is called when the config is reloaded
+func (p *Policy) Update(cfg config.Config) {
+	trusted := make(map[string]bool)
+	for _, apiKey := range cfg.PrivacyTrustedAPIKeys() {
+		trusted[apiKey] = true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enabled = cfg.PrivacyEnabled()
+	p.trusted = trusted
+	p.secret = []byte(cfg.PrivacySecret())
+	key := sha256.Sum256([]byte("token:" + cfg.PrivacySecret()))
+	// a 32 byte key always makes a valid AES-256 cipher
+	p.tokens, _ = aes.NewCipher(key[:])
+	p.round = cfg.PrivacyMode() == config.PrivacyModeRound
+	p.precision = float64(cfg.PrivacyPrecision())
+	p.distanceBucket = float64(cfg.PrivacyDistanceBucket())
+	p.window = cfg.PrivacyWindow()
+}
+
+// ScopeOf returns the scope of a caller by its API key. Everyone is trusted while fuzzing is disabled
+func (p *Policy) ScopeOf(apiKey string) Scope {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.enabled || (apiKey != "" && p.trusted[apiKey]) {
+		return ScopeTrusted
+	}
+	return ScopePublic
+}
+
+// Apply returns locations as a caller of scope may see them at a time. For public callers coordinates are jittered
+// or rounded to the precision, distances rounded up to the distance bucket, drive times up to the minute, and vehicle
+// IDs replaced by tokens. Locations is left as it is
+func (p *Policy) Apply(scope Scope, locations []model.Location, at time.Time) []model.Location {
+	if scope == ScopeTrusted {
+		return locations
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	window := at.UnixNano() / int64(p.window)
+	fuzzed := make([]model.Location, len(locations))
+	for i, location := range locations {
+		seed := p.sum("vehicle", window, location.VehicleID)
+		location.Latitude, location.Longitude = p.fuzz(location.Latitude, location.Longitude, seed)
+		location.Distance = bucket(location.Distance, p.distanceBucket)
+		if location.RouteDistance != nil {
+			routeDistance := bucket(*location.RouteDistance, p.distanceBucket)
+			location.RouteDistance = &routeDistance
+		}
+		if location.Along != nil {
+			along := bucket(*location.Along, p.distanceBucket)
+			location.Along = &along
+		}
+		if location.ETA != nil {
+			eta := bucket(*location.ETA, 60)
+			location.ETA = &eta
+		}
+		location.VehicleToken = p.token(window, location.VehicleID)
+		location.VehicleID = 0
+		fuzzed[i] = location
+	}
+	return fuzzed
+}
+
+// HideVehicle returns the vehicle ID and token a caller of scope may see of a vehicle at a time: the ID to trusted
+// callers and a token, with an ID of 0, to public ones
+func (p *Policy) HideVehicle(scope Scope, vehicleID int64, at time.Time) (int64, string) {
+	if scope == ScopeTrusted {
+		return vehicleID, ""
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return 0, p.token(at.UnixNano()/int64(p.window), vehicleID)
+}
+
+// BucketDistance returns a distance in meters as a caller of scope may see it, rounded up to the distance bucket the
+// way Apply rounds it for public callers
+func (p *Policy) BucketDistance(scope Scope, distance float64) float64 {
+	if scope == ScopeTrusted {
+		return distance
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return bucket(distance, p.distanceBucket)
+}
+
+// FuzzPoint returns a point of a vehicle as a caller of scope may see it at a time, moved the way Apply moves it
+func (p *Policy) FuzzPoint(scope Scope, vehicleID int64, lat, lng float64, at time.Time) (float64, float64) {
+	if scope == ScopeTrusted {
+		return lat, lng
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.fuzz(lat, lng, p.sum("vehicle", at.UnixNano()/int64(p.window), vehicleID))
+}
+
+// ResolveToken returns the ID of the vehicle a token stands for. Tokens handed out in the window of at and in the
+// one before it resolve, so that a token doesn't stop working the moment the window turns
+func (p *Policy) ResolveToken(token string, at time.Time) (int64, error) {
+	block, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(block) != aes.BlockSize {
+		return 0, ErrInvalidToken
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	p.tokens.Decrypt(block, block)
+	window, current := int64(binary.BigEndian.Uint64(block[:8])), at.UnixNano()/int64(p.window)
+	if window != current && window != current-1 {
+		return 0, ErrInvalidToken
+	}
+	return int64(binary.BigEndian.Uint64(block[8:])), nil
+}
+
+// token encrypts a time window and a vehicle ID into a single AES block
+func (p *Policy) token(window, vehicleID int64) string {
+	block := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(block[:8], uint64(window))
+	binary.BigEndian.PutUint64(block[8:], uint64(vehicleID))
+	p.tokens.Encrypt(block, block)
+	return base64.RawURLEncoding.EncodeToString(block)
+}
+
+// fuzz rounds a point to a grid of precision meters, or moves it up to precision meters in a direction, both drawn from seed
+func (p *Policy) fuzz(lat, lng float64, seed []byte) (float64, float64) {
+	step := p.precision / metersPerDegree
+	if p.round {
+		lat = (math.Floor(lat/step) + 0.5) * step
+		lngStep := step / math.Max(math.Cos(lat*math.Pi/180), 0.01)
+		return lat, (math.Floor(lng/lngStep) + 0.5) * lngStep
+	}
+	// the square root spreads the offsets evenly over the disc rather than bunching them around the centre
+	angle := unit(seed[0:8]) * 2 * math.Pi
+	radius := math.Sqrt(unit(seed[8:16])) * step
+	lat += radius * math.Sin(angle)
+	lng += radius * math.Cos(angle) / math.Max(math.Cos(lat*math.Pi/180), 0.01)
+	return math.Max(-90, math.Min(90, lat)), lng
+}
+
+// sum derives a value for a vehicle in a time window from the secret
+func (p *Policy) sum(purpose string, window, vehicleID int64) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(purpose + ":" + strconv.FormatInt(window, 10) + ":" + strconv.FormatInt(vehicleID, 10)))
+	return mac.Sum(nil)
+}
+
+// unit turns 8 bytes into a number in [0, 1)
+func unit(b []byte) float64 {
+	return float64(binary.BigEndian.Uint64(b)>>11) / (1 << 53)
+}
+
+// bucket rounds a value up to a multiple of size
+func bucket(value, size float64) float64 {
+	return math.Ceil(value/size) * size
+}
@@ -0,0 +1,102 @@
+package privacy_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPolicy(t *testing.T, mode string) *privacy.Policy {
+	os.Setenv("PRIVACY_ENABLED", "true")
+	os.Setenv("PRIVACY_SECRET", "0123456789abcdef")
+	os.Setenv("PRIVACY_TRUSTED_API_KEYS", "ops-key")
+	os.Setenv("PRIVACY_MODE", mode)
+	defer os.Unsetenv("PRIVACY_ENABLED")
+	defer os.Unsetenv("PRIVACY_SECRET")
+	defer os.Unsetenv("PRIVACY_TRUSTED_API_KEYS")
+	defer os.Unsetenv("PRIVACY_MODE")
+
+	cfg := config.LoadConfig()
+	require.NoError(t, cfg.Validate())
+	return privacy.NewPolicy(cfg)
+}
+
+func TestScopeOf_ShouldTrustOnlyTrustedAPIKeys(t *testing.T) {
+	policy := newPolicy(t, "jitter")
+	assert.Equal(t, privacy.ScopeTrusted, policy.ScopeOf("ops-key"))
+	assert.Equal(t, privacy.ScopePublic, policy.ScopeOf("partner-key"))
+	assert.Equal(t, privacy.ScopePublic, policy.ScopeOf(""))
+	assert.Equal(t, privacy.ScopePublic, privacy.ScopeFromContext(context.Background()))
+
+	policy.Update(config.LoadConfig())
+	assert.Equal(t, privacy.ScopeTrusted, policy.ScopeOf(""), "everyone is trusted once fuzzing is disabled")
+}
+
+func TestApply_WhenCallerIsPublic_ShouldFuzzTheSameWayWithinAWindow(t *testing.T) {
+	policy := newPolicy(t, "jitter")
+	eta, along := 125.0, 1234.0
+	locations := []model.Location{{VehicleID: 7, Latitude: 1.3, Longitude: 103.9, Distance: 230, ETA: &eta, Along: &along}}
+	at := time.Date(2021, 10, 3, 8, 1, 0, 0, time.UTC)
+
+	first := policy.Apply(privacy.ScopePublic, locations, at)
+	again := policy.Apply(privacy.ScopePublic, locations, at.Add(10*time.Minute))
+	later := policy.Apply(privacy.ScopePublic, locations, at.Add(15*time.Minute))
+
+	require.Len(t, first, 1)
+	assert.Equal(t, int64(0), first[0].VehicleID)
+	assert.Len(t, first[0].VehicleToken, 22)
+	assert.Equal(t, first, again)
+	assert.NotEqual(t, first[0].VehicleToken, later[0].VehicleToken)
+	assert.NotEqual(t, first[0].Latitude, later[0].Latitude)
+	assert.NotEqual(t, 1.3, first[0].Latitude)
+	assert.InDelta(t, 1.3, first[0].Latitude, 200/111320.0)
+	assert.InDelta(t, 103.9, first[0].Longitude, 200/111320.0)
+	assert.Equal(t, 300.0, first[0].Distance)
+	assert.Equal(t, 180.0, *first[0].ETA)
+	assert.Equal(t, 1300.0, *first[0].Along)
+	assert.Equal(t, int64(7), locations[0].VehicleID, "the exact locations are left as they are")
+}
+
+func TestApply_WhenModeIsRound_ShouldPutNearbyVehiclesInTheSameCell(t *testing.T) {
+	policy := newPolicy(t, "round")
+	locations := []model.Location{{VehicleID: 1, Latitude: 1.30001, Longitude: 103.90001}, {VehicleID: 2, Latitude: 1.30002, Longitude: 103.90002}}
+
+	fuzzed := policy.Apply(privacy.ScopePublic, locations, time.Now())
+	assert.Equal(t, fuzzed[0].Latitude, fuzzed[1].Latitude)
+	assert.Equal(t, fuzzed[0].Longitude, fuzzed[1].Longitude)
+	assert.NotEqual(t, fuzzed[0].VehicleToken, fuzzed[1].VehicleToken)
+}
+
+func TestApply_WhenCallerIsTrusted_ShouldReturnExactLocations(t *testing.T) {
+	policy := newPolicy(t, "jitter")
+	locations := []model.Location{{VehicleID: 7, Latitude: 1.3, Longitude: 103.9, Distance: 230}}
+	assert.Equal(t, locations, policy.Apply(privacy.ScopeTrusted, locations, time.Now()))
+}
+
+func TestResolveToken_ShouldResolveTokensOfThisWindowAndTheOneBefore(t *testing.T) {
+	policy := newPolicy(t, "jitter")
+	at := time.Date(2021, 10, 3, 8, 1, 0, 0, time.UTC)
+	token := policy.Apply(privacy.ScopePublic, []model.Location{{VehicleID: 7}}, at)[0].VehicleToken
+
+	vehicleID, err := policy.ResolveToken(token, at)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), vehicleID)
+	vehicleID, err = policy.ResolveToken(token, at.Add(15*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), vehicleID)
+
+	_, err = policy.ResolveToken(token, at.Add(30*time.Minute))
+	assert.Equal(t, privacy.ErrInvalidToken, err)
+	_, err = policy.ResolveToken("not-a-token", at)
+	assert.Equal(t, privacy.ErrInvalidToken, err)
+	_, token = policy.HideVehicle(privacy.ScopePublic, 7, at)
+	assert.Equal(t, token, policy.Apply(privacy.ScopePublic, []model.Location{{VehicleID: 7}}, at)[0].VehicleToken)
+}
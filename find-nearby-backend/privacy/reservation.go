@@ -0,0 +1,62 @@
+package privacy
+
+import (
+	"context"
+	"time"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/usecase"
+)
+
+// ReservationUsecase is a usecase.ReservationUsecase decorator that hands out reservations with the vehicle ID as
+// the scope of the caller, taken from the context, allows. Vehicles are still held by their IDs, which callers of the
+// public scope get by resolving a token first
+type ReservationUsecase struct {
+	usecase usecase.ReservationUsecase
+	policy  *Policy
+}
+
+// NewReservationUsecase is a constructor for ReservationUsecase
+func NewReservationUsecase(usecase usecase.ReservationUsecase, policy *Policy) *ReservationUsecase {
+	return &ReservationUsecase{usecase: usecase, policy: policy}
+}
+
+// Reserve holds a vehicle for holder
+func (r *ReservationUsecase) Reserve(ctx context.Context, vehicleID int64, holder string, hold time.Duration) (model.Reservation, error) {
+	return r.apply(ctx)(r.usecase.Reserve(ctx, vehicleID, holder, hold))
+}
+
+// Get returns a reservation
+func (r *ReservationUsecase) Get(ctx context.Context, id int64, holder string) (model.Reservation, error) {
+	return r.apply(ctx)(r.usecase.Get(ctx, id, holder))
+}
+
+// Cancel releases a hold, or a confirmed reservation
+func (r *ReservationUsecase) Cancel(ctx context.Context, id int64, holder string, version int) (model.Reservation, error) {
+	return r.apply(ctx)(r.usecase.Cancel(ctx, id, holder, version))
+}
+
+// Confirm turns a hold into a ride
+func (r *ReservationUsecase) Confirm(ctx context.Context, id int64, holder string, version int) (model.Reservation, error) {
+	return r.apply(ctx)(r.usecase.Confirm(ctx, id, holder, version))
+}
+
+// Complete ends the ride of a confirmed reservation
+func (r *ReservationUsecase) Complete(ctx context.Context, id int64, holder string, version int) (model.Reservation, error) {
+	return r.apply(ctx)(r.usecase.Complete(ctx, id, holder, version))
+}
+
+// ExpireHolds expires the holds that have lapsed
+func (r *ReservationUsecase) ExpireHolds(ctx context.Context) (int64, error) {
+	return r.usecase.ExpireHolds(ctx)
+}
+
+func (r *ReservationUsecase) apply(ctx context.Context) func(model.Reservation, error) (model.Reservation, error) {
+	return func(reservation model.Reservation, err error) (model.Reservation, error) {
+		if err != nil {
+			return model.Reservation{}, err
+		}
+		reservation.VehicleID, reservation.VehicleToken = r.policy.HideVehicle(ScopeFromContext(ctx), reservation.VehicleID, time.Now())
+		return reservation, nil
+	}
+}
@@ -0,0 +1,33 @@
+package privacy_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReservationUsecase_WhenCallerIsPublic_ShouldHideTheVehicleBehindAToken(t *testing.T) {
+	policy := newPolicy(t, "jitter")
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Reserve", mock.Anything, int64(7), "alice", time.Minute).Return(model.Reservation{ID: 1, VehicleID: 7, Holder: "alice"}, nil)
+	reservations := privacy.NewReservationUsecase(reservationUsecaseMock, policy)
+
+	public, err := reservations.Reserve(privacy.ContextWithScope(context.Background(), privacy.ScopePublic), 7, "alice", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), public.VehicleID)
+	vehicleID, err := policy.ResolveToken(public.VehicleToken, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), vehicleID, "the token can be handed back")
+
+	trusted, err := reservations.Reserve(privacy.ContextWithScope(context.Background(), privacy.ScopeTrusted), 7, "alice", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, model.Reservation{ID: 1, VehicleID: 7, Holder: "alice"}, trusted)
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrAnomalyNotFound is returned by AnomalyRepository when there is no anomaly with the given ID
+var ErrAnomalyNotFound = errors.New("anomaly not found")
+
+const anomalyColumns = `id, vehicle_id, kind, action, latitude, longitude, recorded_at, speed, max_speed, created_at, reviewed_at, reviewed_by`
+
+// AnomalyRepository represents the repository layer for the anomalies found on ingest
+type AnomalyRepository interface {
+	SaveAnomalies(ctx context.Context, anomalies []model.Anomaly) error
+	FindAnomalies(ctx context.Context, filter model.AnomalyFilter) ([]model.Anomaly, error)
+	Review(ctx context.Context, id int64, reviewer string) (model.Anomaly, error)
+}
+
+type postgresAnomalyRepository struct {
+	logger logger.Logger
+	db     *database.Cluster
+}
+
+// NewPostgresAnomalyRepository is a constructor for postgresAnomalyRepository
+func NewPostgresAnomalyRepository(logger logger.Logger, db *database.Cluster) AnomalyRepository {
+	return postgresAnomalyRepository{logger: logger, db: db}
+}
+
+// SaveAnomalies stores anomalies in a single statement
+func (p postgresAnomalyRepository) SaveAnomalies(ctx context.Context, anomalies []model.Anomaly) error {
+	ctx, span := p.startSpan(ctx, "postgresAnomalyRepository.SaveAnomalies", "INSERT")
+	defer span.End()
+
+	if len(anomalies) == 0 {
+		return nil
+	}
+	n := len(anomalies)
+	vehicleIDs := make([]int64, n)
+	kinds, actions := make([]string, n), make([]string, n)
+	latitudes, longitudes := make([]float64, n), make([]float64, n)
+	recordedAt := make([]string, n)
+	speeds, maxSpeeds := make([]sql.NullFloat64, n), make([]sql.NullFloat64, n)
+	for i, anomaly := range anomalies {
+		vehicleIDs[i] = anomaly.VehicleID
+		kinds[i], actions[i] = anomaly.Kind, anomaly.Action
+		latitudes[i], longitudes[i] = anomaly.Latitude, anomaly.Longitude
+		recordedAt[i] = anomaly.RecordedAt.UTC().Format(time.RFC3339Nano)
+		if anomaly.Speed != nil {
+			speeds[i] = sql.NullFloat64{Float64: *anomaly.Speed, Valid: true}
+		}
+		if anomaly.MaxSpeed != nil {
+			maxSpeeds[i] = sql.NullFloat64{Float64: *anomaly.MaxSpeed, Valid: true}
+		}
+	}
+	query := `INSERT INTO anomalies (vehicle_id, kind, action, latitude, longitude, recorded_at, speed, max_speed)
+				SELECT * FROM unnest($1::int8[], $2::text[], $3::text[], $4::float8[], $5::float8[], $6::timestamptz[], $7::float8[], $8::float8[])`
+	_, err := p.db.Primary().ExecContext(ctx, query, pq.Array(vehicleIDs), pq.Array(kinds), pq.Array(actions),
+		pq.Array(latitudes), pq.Array(longitudes), pq.Array(recordedAt), pq.Array(speeds), pq.Array(maxSpeeds))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// FindAnomalies returns the anomalies matching filter, newest first
+func (p postgresAnomalyRepository) FindAnomalies(ctx context.Context, filter model.AnomalyFilter) ([]model.Anomaly, error) {
+	ctx, span := p.startSpan(ctx, "postgresAnomalyRepository.FindAnomalies", "SELECT")
+	defer span.End()
+
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.VehicleID > 0 {
+		where("vehicle_id = $%d", filter.VehicleID)
+	}
+	if filter.Kind != "" {
+		where("kind = $%d", filter.Kind)
+	}
+	if filter.Reviewed != nil {
+		where("(reviewed_at IS NOT NULL) = $%d", *filter.Reviewed)
+	}
+	if filter.BeforeID > 0 {
+		where("id < $%d", filter.BeforeID)
+	}
+	query := `SELECT ` + anomalyColumns + ` FROM anomalies`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+
+	anomalies := []model.Anomaly{}
+	if err := p.db.Reader().SelectContext(ctx, &anomalies, query, args...); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return anomalies, nil
+}
+
+// Review marks an anomaly as reviewed by reviewer. Reviewing it again keeps the first review
+func (p postgresAnomalyRepository) Review(ctx context.Context, id int64, reviewer string) (model.Anomaly, error) {
+	ctx, span := p.startSpan(ctx, "postgresAnomalyRepository.Review", "UPDATE")
+	defer span.End()
+
+	var anomaly model.Anomaly
+	err := p.db.Primary().GetContext(ctx, &anomaly, `UPDATE anomalies SET reviewed_at = coalesce(reviewed_at, now()), reviewed_by = coalesce(reviewed_by, $2)
+		WHERE id = $1 RETURNING `+anomalyColumns, id, reviewer)
+	if err == sql.ErrNoRows {
+		err = ErrAnomalyNotFound
+	}
+	if err != nil {
+		tracing.RecordError(span, err)
+		return model.Anomaly{}, err
+	}
+	return anomaly, nil
+}
+
+func (p postgresAnomalyRepository) startSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(operation), semconv.DBSQLTableKey.String("anomalies"))
+	return ctx, span
+}
@@ -0,0 +1,48 @@
+package repository_test
+
+import (
+	"context"
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+
+	"time"
+)
+
+func (s *RepositoryTestSuite) newAnomalyRepository() repository.AnomalyRepository {
+	log := logger.New("debug", "plaintext")
+	return repository.NewPostgresAnomalyRepository(log, database.NewCluster(log, s.db, nil, 0))
+}
+
+func (s *RepositoryTestSuite) TestFindAnomalies_ShouldFilterAndReturnTheNewestFirst() {
+	anomalies := s.newAnomalyRepository()
+	recordedAt := time.Date(2021, 10, 3, 8, 0, 0, 0, time.UTC)
+	speed, maxSpeed := 5400.0, 60.0
+	s.Require().NoError(anomalies.SaveAnomalies(context.Background(), []model.Anomaly{
+		{VehicleID: 7, Kind: model.AnomalyKindSpeed, Action: model.AnomalyActionRejected, Latitude: 1.75, Longitude: 103.9, RecordedAt: recordedAt, Speed: &speed, MaxSpeed: &maxSpeed},
+		{VehicleID: 7, Kind: model.AnomalyKindNullIsland, Action: model.AnomalyActionRejected, RecordedAt: recordedAt},
+		{VehicleID: 8, Kind: model.AnomalyKindOutOfArea, Action: model.AnomalyActionFlagged, Latitude: 1.6, Longitude: 103.9, RecordedAt: recordedAt},
+	}))
+
+	found, err := anomalies.FindAnomalies(context.Background(), model.AnomalyFilter{VehicleID: 7, Limit: 10})
+	s.Assert().NoError(err)
+	s.Require().Len(found, 2)
+	s.Assert().Equal(model.AnomalyKindNullIsland, found[0].Kind)
+	s.Assert().Nil(found[0].Speed)
+	s.Assert().Equal(speed, *found[1].Speed)
+
+	reviewed, err := anomalies.Review(context.Background(), found[0].ID, "ops-1")
+	s.Assert().NoError(err)
+	s.Assert().Equal("ops-1", *reviewed.ReviewedBy)
+
+	notReviewed := false
+	found, err = anomalies.FindAnomalies(context.Background(), model.AnomalyFilter{Reviewed: &notReviewed, Limit: 10})
+	s.Assert().NoError(err)
+	s.Assert().Len(found, 2)
+}
+
+func (s *RepositoryTestSuite) TestReviewAnomaly_WhenAnomalyIsMissing_ShouldReturnErrAnomalyNotFound() {
+	_, err := s.newAnomalyRepository().Review(context.Background(), 42, "ops-1")
+	s.Assert().Equal(repository.ErrAnomalyNotFound, err)
+}
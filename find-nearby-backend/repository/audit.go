@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const auditColumns = `id, occurred_at, request_id, caller, remote_addr, action, params, results, status`
+
+// AuditRepository represents the repository layer for the audit log. Records are only ever added
+type AuditRepository interface {
+	SaveAuditRecords(ctx context.Context, records []model.AuditRecord) error
+	FindAuditRecords(ctx context.Context, filter model.AuditFilter) ([]model.AuditRecord, error)
+}
+
+type postgresAuditRepository struct {
+	logger logger.Logger
+	db     *database.Cluster
+}
+
+// NewPostgresAuditRepository is a constructor for postgresAuditRepository
+func NewPostgresAuditRepository(logger logger.Logger, db *database.Cluster) AuditRepository {
+	return postgresAuditRepository{logger: logger, db: db}
+}
+
+// SaveAuditRecords appends records to the audit log in a single statement
+func (p postgresAuditRepository) SaveAuditRecords(ctx context.Context, records []model.AuditRecord) error {
+	ctx, span := p.startSpan(ctx, "postgresAuditRepository.SaveAuditRecords", "INSERT")
+	defer span.End()
+
+	if len(records) == 0 {
+		return nil
+	}
+	n := len(records)
+	occurredAt, requestIDs, callers := make([]string, n), make([]string, n), make([]string, n)
+	remoteAddrs, actions, params := make([]string, n), make([]string, n), make([]string, n)
+	results, statuses := make([]sql.NullInt64, n), make([]int64, n)
+	for i, record := range records {
+		occurredAt[i] = record.OccurredAt.UTC().Format(time.RFC3339Nano)
+		requestIDs[i], callers[i], remoteAddrs[i] = record.RequestID, record.Caller, record.RemoteAddr
+		actions[i], params[i] = record.Action, "{}"
+		if len(record.Params) > 0 {
+			params[i] = string(record.Params)
+		}
+		if record.Results != nil {
+			results[i] = sql.NullInt64{Int64: int64(*record.Results), Valid: true}
+		}
+		statuses[i] = int64(record.Status)
+	}
+	query := `INSERT INTO audit_log (occurred_at, request_id, caller, remote_addr, action, params, results, status)
+				SELECT * FROM unnest($1::timestamptz[], $2::text[], $3::text[], $4::text[], $5::text[], $6::jsonb[], $7::int4[], $8::int4[])`
+	_, err := p.db.Primary().ExecContext(ctx, query, pq.Array(occurredAt), pq.Array(requestIDs), pq.Array(callers),
+		pq.Array(remoteAddrs), pq.Array(actions), pq.Array(params), pq.Array(results), pq.Array(statuses))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// FindAuditRecords returns the audit records matching filter, newest first
+func (p postgresAuditRepository) FindAuditRecords(ctx context.Context, filter model.AuditFilter) ([]model.AuditRecord, error) {
+	ctx, span := p.startSpan(ctx, "postgresAuditRepository.FindAuditRecords", "SELECT")
+	defer span.End()
+
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Caller != "" {
+		where("caller = $%d", filter.Caller)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.RequestID != "" {
+		where("request_id = $%d", filter.RequestID)
+	}
+	if filter.VehicleID > 0 {
+		where("(params->'vehicle_id' = to_jsonb($%[1]d::int8) OR params->'vehicle_ids' @> to_jsonb(ARRAY[$%[1]d::int8]))", filter.VehicleID)
+	}
+	if !filter.From.IsZero() {
+		where("occurred_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("occurred_at < $%d", filter.To)
+	}
+	if filter.BeforeID > 0 {
+		where("id < $%d", filter.BeforeID)
+	}
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+
+	records := []model.AuditRecord{}
+	if err := p.db.Reader().SelectContext(ctx, &records, query, args...); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return records, nil
+}
+
+func (p postgresAuditRepository) startSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(operation), semconv.DBSQLTableKey.String("audit_log"))
+	return ctx, span
+}
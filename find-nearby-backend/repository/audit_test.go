@@ -0,0 +1,44 @@
+package repository_test
+
+import (
+	"context"
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+)
+
+func (s *RepositoryTestSuite) newAuditRepository() repository.AuditRepository {
+	log := logger.New("debug", "plaintext")
+	return repository.NewPostgresAuditRepository(log, database.NewCluster(log, s.db, nil, 0))
+}
+
+func (s *RepositoryTestSuite) TestFindAuditRecords_ShouldFilterAndReturnTheNewestFirst() {
+	audit := s.newAuditRepository()
+	occurredAt := time.Date(2021, 10, 4, 8, 0, 0, 0, time.UTC)
+	one, five := 1, 5
+	s.Require().NoError(audit.SaveAuditRecords(context.Background(), []model.AuditRecord{
+		{OccurredAt: occurredAt, RequestID: "r-1", Caller: "c1", Action: "GET /locations/find", Params: types.JSONText(`{"latitude": 1.3}`), Results: &five, Status: 200},
+		{OccurredAt: occurredAt.Add(time.Minute), RequestID: "r-2", Caller: "c1", Action: "POST /reservations", Params: types.JSONText(`{"vehicle_id": 7}`), Results: &one, Status: 201},
+		{OccurredAt: occurredAt.Add(2 * time.Minute), RequestID: "r-3", Caller: "c2", Action: "POST /locations/ingest", Params: types.JSONText(`{"vehicle_ids": [7, 8]}`), Status: 400},
+	}))
+
+	found, err := audit.FindAuditRecords(context.Background(), model.AuditFilter{VehicleID: 7, Limit: 10})
+	s.Assert().NoError(err)
+	s.Require().Len(found, 2)
+	s.Assert().Equal("r-3", found[0].RequestID)
+	s.Assert().Nil(found[0].Results)
+	s.Assert().Equal(one, *found[1].Results)
+
+	found, err = audit.FindAuditRecords(context.Background(), model.AuditFilter{Caller: "c1", From: occurredAt, To: occurredAt.Add(time.Minute), Limit: 10})
+	s.Assert().NoError(err)
+	s.Require().Len(found, 1)
+	s.Assert().Equal("GET /locations/find", found[0].Action)
+
+	_, err = s.db.Exec(`DELETE FROM audit_log`)
+	s.Assert().Error(err, "the audit log is append-only")
+}
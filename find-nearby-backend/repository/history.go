@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/tracing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HistoryRepository represents the repository layer for compacting the location history. Its deletes are bounded
+// by a limit, so that a compaction is a series of short statements rather than one that holds locks for minutes. The
+// watermark is how far the history has been downsampled to an interval, so the next compaction can start from there
+type HistoryRepository interface {
+	OldestHistory(ctx context.Context) (*time.Time, error)
+	DeleteHistory(ctx context.Context, before time.Time, limit int) (int, error)
+	DownsampleHistory(ctx context.Context, from, to time.Time, interval time.Duration, limit int) (int, error)
+	Watermark(ctx context.Context, interval time.Duration) (*time.Time, error)
+	SetWatermark(ctx context.Context, interval time.Duration, before time.Time) error
+}
+
+type postgresHistoryRepository struct {
+	logger logger.Logger
+	db     *database.Cluster
+}
+
+// NewPostgresHistoryRepository is a constructor for postgresHistoryRepository
+func NewPostgresHistoryRepository(logger logger.Logger, db *database.Cluster) HistoryRepository {
+	return postgresHistoryRepository{logger: logger, db: db}
+}
+
+// OldestHistory returns when the oldest point of the history was recorded, or nil when the history is empty
+func (p postgresHistoryRepository) OldestHistory(ctx context.Context) (*time.Time, error) {
+	ctx, span := p.startSpan(ctx, "postgresHistoryRepository.OldestHistory", "SELECT")
+	defer span.End()
+
+	var oldest *time.Time
+	if err := p.db.Primary().GetContext(ctx, &oldest, `SELECT min(recorded_at) FROM location_history`); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return oldest, nil
+}
+
+// DeleteHistory deletes up to limit points recorded before before and returns how many it deleted
+func (p postgresHistoryRepository) DeleteHistory(ctx context.Context, before time.Time, limit int) (int, error) {
+	ctx, span := p.startSpan(ctx, "postgresHistoryRepository.DeleteHistory", "DELETE")
+	defer span.End()
+
+	query := `DELETE FROM location_history WHERE id IN (
+				SELECT id FROM location_history WHERE recorded_at < $1 LIMIT $2
+			)`
+	return p.exec(ctx, span, query, before, limit)
+}
+
+// DownsampleHistory deletes up to limit points recorded from from until to that aren't the first of their vehicle
+// within their interval, and returns how many it deleted. Intervals start at multiples of interval since the Unix
+// epoch, so from and to should be such multiples too, or the intervals at the edges are only partly thinned out
+func (p postgresHistoryRepository) DownsampleHistory(ctx context.Context, from, to time.Time, interval time.Duration, limit int) (int, error) {
+	ctx, span := p.startSpan(ctx, "postgresHistoryRepository.DownsampleHistory", "DELETE")
+	defer span.End()
+
+	query := `DELETE FROM location_history WHERE id IN (
+				SELECT id FROM (
+					SELECT id, row_number() OVER (
+						PARTITION BY vehicle_id, floor(extract(epoch FROM recorded_at) / $3)
+						ORDER BY recorded_at, id
+					) AS n
+					FROM location_history
+					WHERE recorded_at >= $1 AND recorded_at < $2
+				) ranked
+				WHERE n > 1
+				LIMIT $4
+			)`
+	return p.exec(ctx, span, query, from, to, interval.Seconds(), limit)
+}
+
+// Watermark returns before when the history has been downsampled to interval, or nil when it hasn't been yet
+func (p postgresHistoryRepository) Watermark(ctx context.Context, interval time.Duration) (*time.Time, error) {
+	ctx, span := p.startSpan(ctx, "postgresHistoryRepository.Watermark", "SELECT")
+	defer span.End()
+
+	var before time.Time
+	query := `SELECT downsampled_before FROM history_watermarks WHERE downsample_interval = make_interval(secs => $1)`
+	err := p.db.Primary().GetContext(ctx, &before, query, interval.Seconds())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return &before, nil
+}
+
+// SetWatermark records that the history recorded before before has been downsampled to interval. The watermark only
+// moves forward, so a compaction that started from an older one can't undo the progress of another
+func (p postgresHistoryRepository) SetWatermark(ctx context.Context, interval time.Duration, before time.Time) error {
+	ctx, span := p.startSpan(ctx, "postgresHistoryRepository.SetWatermark", "INSERT")
+	defer span.End()
+
+	query := `INSERT INTO history_watermarks (downsample_interval, downsampled_before) VALUES (make_interval(secs => $1), $2)
+				ON CONFLICT (downsample_interval) DO UPDATE
+				SET downsampled_before = greatest(history_watermarks.downsampled_before, excluded.downsampled_before)`
+	if _, err := p.db.Primary().ExecContext(ctx, query, interval.Seconds(), before); err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (p postgresHistoryRepository) exec(ctx context.Context, span trace.Span, query string, args ...interface{}) (int, error) {
+	result, err := p.db.Primary().ExecContext(ctx, query, args...)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	return int(deleted), nil
+}
+
+func (p postgresHistoryRepository) startSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(operation), semconv.DBSQLTableKey.String("location_history"))
+	return ctx, span
+}
@@ -0,0 +1,66 @@
+package repository_test
+
+import (
+	"context"
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+
+	"time"
+)
+
+func (s *RepositoryTestSuite) newHistoryRepository() repository.HistoryRepository {
+	log := logger.New("debug", "plaintext")
+	return repository.NewPostgresHistoryRepository(log, database.NewCluster(log, s.db, nil, 0))
+}
+
+func (s *RepositoryTestSuite) TestHistory_ShouldKeepTheFirstPointPerIntervalAndDeleteInBatches() {
+	start := time.Date(2021, 10, 3, 8, 0, 0, 0, time.UTC)
+	var positions []model.Position
+	for _, offset := range []time.Duration{-48 * time.Hour, -47 * time.Hour, 0, 10 * time.Second, 50 * time.Second, time.Minute} {
+		positions = append(positions, model.Position{VehicleID: 1, Latitude: 1.3, Longitude: 103.9, RawLatitude: 1.3, RawLongitude: 103.9, RecordedAt: start.Add(offset)})
+	}
+	positions = append(positions, model.Position{VehicleID: 2, Latitude: 1.3, Longitude: 103.9, RawLatitude: 1.3, RawLongitude: 103.9, RecordedAt: start.Add(30 * time.Second)})
+	s.Require().NoError(s.newIngestRepository().SaveHistory(context.Background(), positions))
+
+	history := s.newHistoryRepository()
+	oldest, err := history.OldestHistory(context.Background())
+	s.Assert().NoError(err)
+	s.Assert().True(start.Add(-48 * time.Hour).Equal(*oldest))
+
+	downsampled, err := history.DownsampleHistory(context.Background(), start, start.Add(time.Hour), time.Minute, 10)
+	s.Assert().NoError(err)
+	s.Assert().Equal(2, downsampled, "vehicle 1 keeps 08:00:00 and 08:01:00, vehicle 2 its only point")
+
+	deleted, err := history.DeleteHistory(context.Background(), start.Add(-time.Hour), 1)
+	s.Assert().NoError(err)
+	s.Assert().Equal(1, deleted)
+	deleted, err = history.DeleteHistory(context.Background(), start.Add(-time.Hour), 10)
+	s.Assert().NoError(err)
+	s.Assert().Equal(1, deleted)
+
+	var left int
+	s.Require().NoError(s.db.Get(&left, `SELECT count(*) FROM location_history`))
+	s.Assert().Equal(3, left)
+}
+
+func (s *RepositoryTestSuite) TestHistory_WatermarkShouldOnlyMoveForwardPerInterval() {
+	history := s.newHistoryRepository()
+	before := time.Date(2021, 10, 3, 8, 0, 0, 0, time.UTC)
+
+	watermark, err := history.Watermark(context.Background(), time.Minute)
+	s.Assert().NoError(err)
+	s.Assert().Nil(watermark)
+
+	s.Require().NoError(history.SetWatermark(context.Background(), time.Minute, before))
+	s.Require().NoError(history.SetWatermark(context.Background(), time.Minute, before.Add(-time.Hour)))
+	watermark, err = history.Watermark(context.Background(), time.Minute)
+	s.Assert().NoError(err)
+	s.Require().NotNil(watermark)
+	s.Assert().True(before.Equal(*watermark))
+
+	watermark, err = history.Watermark(context.Background(), 5*time.Minute)
+	s.Assert().NoError(err)
+	s.Assert().Nil(watermark, "the history hasn't been downsampled to five minutes yet")
+}
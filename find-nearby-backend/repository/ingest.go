@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IngestRepository represents the repository layer for the positions vehicles report
+type IngestRepository interface {
+	FindTracks(ctx context.Context, vehicleIDs []int64) (map[int64]model.Track, error)
+	SaveHistory(ctx context.Context, positions []model.Position) error
+	SavePositions(ctx context.Context, positions []model.Position) (int, error)
+}
+
+type postgresIngestRepository struct {
+	logger logger.Logger
+	db     *database.Cluster
+}
+
+// NewPostgresIngestRepository is a constructor for postgresIngestRepository
+func NewPostgresIngestRepository(logger logger.Logger, db *database.Cluster) IngestRepository {
+	return postgresIngestRepository{logger: logger, db: db}
+}
+
+// FindTracks returns the type and the last raw fix of each of the given vehicles. Vehicles without a row are left out;
+// vehicles that were never ingested have no RecordedAt, since when their position was taken isn't known
+func (p postgresIngestRepository) FindTracks(ctx context.Context, vehicleIDs []int64) (map[int64]model.Track, error) {
+	ctx, span := tracer.Start(ctx, "postgresIngestRepository.FindTracks", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String("SELECT"), semconv.DBSQLTableKey.String("vehicles"))
+
+	tracks := make(map[int64]model.Track, len(vehicleIDs))
+	if len(vehicleIDs) == 0 {
+		return tracks, nil
+	}
+	query := `SELECT
+				v.id,
+				v.type,
+				coalesce(st_y(coalesce(l.raw_location, l.location)), 0),
+				coalesce(st_x(coalesce(l.raw_location, l.location)), 0),
+				l.recorded_at
+				FROM vehicles v
+				LEFT JOIN locations l ON l.vehicle_id = v.id
+				WHERE v.id = ANY($1)`
+	rows, err := p.db.Primary().QueryxContext(ctx, query, pq.Array(vehicleIDs))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var track model.Track
+		if err = rows.Scan(&track.VehicleID, &track.Type, &track.Latitude, &track.Longitude, &track.RecordedAt); err != nil {
+			tracing.RecordError(span, err)
+			return nil, err
+		}
+		tracks[track.VehicleID] = track
+	}
+	if err = rows.Err(); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// SaveHistory appends positions, any number per vehicle, to the location history in a single statement
+func (p postgresIngestRepository) SaveHistory(ctx context.Context, positions []model.Position) error {
+	ctx, span := tracer.Start(ctx, "postgresIngestRepository.SaveHistory", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String("INSERT"), semconv.DBSQLTableKey.String("location_history"))
+
+	if len(positions) == 0 {
+		return nil
+	}
+	n := len(positions)
+	vehicleIDs := make([]int64, n)
+	longitudes, latitudes := make([]float64, n), make([]float64, n)
+	rawLongitudes, rawLatitudes := make([]float64, n), make([]float64, n)
+	snapped := make([]bool, n)
+	recordedAt := make([]string, n)
+	for i, position := range positions {
+		vehicleIDs[i] = position.VehicleID
+		longitudes[i], latitudes[i] = position.Longitude, position.Latitude
+		rawLongitudes[i], rawLatitudes[i] = position.RawLongitude, position.RawLatitude
+		snapped[i] = position.Snapped
+		recordedAt[i] = position.RecordedAt.UTC().Format(time.RFC3339Nano)
+	}
+	query := `INSERT INTO location_history (vehicle_id, location, raw_location, snapped, recorded_at)
+				SELECT vehicle_id, st_setsrid(st_makepoint(lng, lat), 4326), st_setsrid(st_makepoint(raw_lng, raw_lat), 4326), snapped, recorded_at
+				FROM unnest($1::int8[], $2::float8[], $3::float8[], $4::float8[], $5::float8[], $6::bool[], $7::timestamptz[])
+				AS p(vehicle_id, lng, lat, raw_lng, raw_lat, snapped, recorded_at)`
+	_, err := p.db.Primary().ExecContext(ctx, query, pq.Array(vehicleIDs), pq.Array(longitudes), pq.Array(latitudes),
+		pq.Array(rawLongitudes), pq.Array(rawLatitudes), pq.Array(snapped), pq.Array(recordedAt))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// SavePositions stores the position of every vehicle, at most one per vehicle, in a single statement and returns
+// how many were stored. A position recorded before the one already stored is dropped, so late or replayed pings
+// can't move a vehicle back. Vehicles seen for the first time are added with the defaults of the vehicles table
+func (p postgresIngestRepository) SavePositions(ctx context.Context, positions []model.Position) (int, error) {
+	ctx, span := tracer.Start(ctx, "postgresIngestRepository.SavePositions", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String("INSERT"), semconv.DBSQLTableKey.String("locations"))
+
+	if len(positions) == 0 {
+		return 0, nil
+	}
+	n := len(positions)
+	vehicleIDs := make([]int64, n)
+	longitudes, latitudes := make([]float64, n), make([]float64, n)
+	rawLongitudes, rawLatitudes := make([]float64, n), make([]float64, n)
+	snapLongitudes, snapLatitudes := make([]float64, n), make([]float64, n)
+	found, snapped := make([]bool, n), make([]bool, n)
+	recordedAt := make([]string, n)
+	for i, position := range positions {
+		vehicleIDs[i] = position.VehicleID
+		longitudes[i], latitudes[i] = position.Longitude, position.Latitude
+		rawLongitudes[i], rawLatitudes[i] = position.RawLongitude, position.RawLatitude
+		snapLongitudes[i], snapLatitudes[i] = position.Snap.Longitude, position.Snap.Latitude
+		found[i], snapped[i] = position.Snap.Found, position.Snapped
+		recordedAt[i] = position.RecordedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	query := `WITH position AS (
+				SELECT * FROM unnest($1::int8[], $2::float8[], $3::float8[], $4::float8[], $5::float8[], $6::float8[], $7::float8[], $8::bool[], $9::bool[], $10::timestamptz[])
+				AS p(vehicle_id, lng, lat, raw_lng, raw_lat, snap_lng, snap_lat, found, snapped, recorded_at)
+			), vehicle AS (
+				INSERT INTO vehicles (id) SELECT vehicle_id FROM position ON CONFLICT DO NOTHING
+			)
+			INSERT INTO locations (vehicle_id, location, raw_location, snapped_location, snapped, recorded_at)
+			SELECT
+			vehicle_id,
+			st_setsrid(st_makepoint(lng, lat), 4326),
+			st_setsrid(st_makepoint(raw_lng, raw_lat), 4326),
+			CASE WHEN found THEN st_setsrid(st_makepoint(snap_lng, snap_lat), 4326) END,
+			snapped,
+			recorded_at
+			FROM position
+			ON CONFLICT (vehicle_id) DO UPDATE SET
+			location = EXCLUDED.location,
+			raw_location = EXCLUDED.raw_location,
+			snapped_location = EXCLUDED.snapped_location,
+			snapped = EXCLUDED.snapped,
+			recorded_at = EXCLUDED.recorded_at
+			WHERE locations.recorded_at IS NULL OR locations.recorded_at <= EXCLUDED.recorded_at`
+	result, err := p.db.Primary().ExecContext(ctx, query, pq.Array(vehicleIDs), pq.Array(longitudes), pq.Array(latitudes),
+		pq.Array(rawLongitudes), pq.Array(rawLatitudes), pq.Array(snapLongitudes), pq.Array(snapLatitudes),
+		pq.Array(found), pq.Array(snapped), pq.Array(recordedAt))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	stored, err := result.RowsAffected()
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	p.logger.WithContext(ctx).Debugf("stored %d of %d positions", stored, n)
+	return int(stored), nil
+}
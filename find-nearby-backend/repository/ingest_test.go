@@ -0,0 +1,51 @@
+package repository_test
+
+import (
+	"context"
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+
+	"time"
+)
+
+func (s *RepositoryTestSuite) newIngestRepository() repository.IngestRepository {
+	log := logger.New("debug", "plaintext")
+	return repository.NewPostgresIngestRepository(log, database.NewCluster(log, s.db, nil, 0))
+}
+
+func (s *RepositoryTestSuite) TestSavePositions_ShouldServeTheGivenCoordinatesAndKeepTheRawOnes() {
+	recordedAt := time.Date(2021, 10, 3, 8, 0, 0, 0, time.UTC)
+	stored, err := s.newIngestRepository().SavePositions(context.Background(), []model.Position{{
+		VehicleID: 9, Latitude: 1.306, Longitude: 103.927, Snapped: true, RawLatitude: 1.3061, RawLongitude: 103.927,
+		Snap: model.Snap{Found: true, Latitude: 1.306, Longitude: 103.927, Distance: 11}, RecordedAt: recordedAt,
+	}})
+	s.Assert().NoError(err)
+	s.Assert().Equal(1, stored)
+
+	nearby, err := s.repository.FindVehicleLocations(context.Background(), 1.306, 103.927, 10, 10)
+	s.Assert().NoError(err)
+	s.Require().Len(nearby.Locations, 1)
+	s.Assert().Equal(int64(9), nearby.Locations[0].VehicleID)
+	s.Assert().True(nearby.Locations[0].Snapped)
+
+	var rawLatitude float64
+	s.Assert().NoError(s.db.Get(&rawLatitude, `SELECT st_y(raw_location) FROM locations WHERE vehicle_id = 9`))
+	s.Assert().InDelta(1.3061, rawLatitude, 1e-9)
+}
+
+func (s *RepositoryTestSuite) TestSavePositions_WhenPositionIsOlderThanTheStoredOne_ShouldKeepTheStoredOne() {
+	positions := s.newIngestRepository()
+	recordedAt := time.Date(2021, 10, 3, 8, 0, 0, 0, time.UTC)
+	_, err := positions.SavePositions(context.Background(), []model.Position{{VehicleID: 9, Latitude: 1.306, Longitude: 103.927, RecordedAt: recordedAt}})
+	s.Require().NoError(err)
+
+	stored, err := positions.SavePositions(context.Background(), []model.Position{{VehicleID: 9, Latitude: 1.4, Longitude: 103.8, RecordedAt: recordedAt.Add(-time.Minute)}})
+	s.Assert().NoError(err)
+	s.Assert().Equal(0, stored)
+
+	var latitude float64
+	s.Assert().NoError(s.db.Get(&latitude, `SELECT st_y(location) FROM locations WHERE vehicle_id = 9`))
+	s.Assert().InDelta(1.306, latitude, 1e-9)
+}
@@ -1,56 +1,290 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
 	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	geojson "github.com/paulmach/go.geojson"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("find-nearby-backend/repository")
+
+// nearbyQuery finds the available vehicles within $5 meters of the origin $1, $2 (and $3, $4), closest first.
+// The vehicles held by $7 are available to it
+const nearbyQuery = `SELECT
+ 				vehicle_id,
+ 				st_asgeojson(location) as loc,
+				snapped,
+				st_distance(geography(location), geography(st_setsrid(st_makepoint($1, $2), 4326))) as distance,
+				count(*) OVER () as total
+ 				FROM locations
+				WHERE st_within(location, geometry(st_buffer(geography(st_setsrid(st_makepoint($3, $4), 4326)), $5)))
+				AND NOT EXISTS (SELECT 1 FROM reservations r WHERE r.vehicle_id = locations.vehicle_id AND r.status = 'held' AND r.expires_at > now() AND r.holder <> $7)
+				AND NOT EXISTS (SELECT 1 FROM vehicles v WHERE v.id = locations.vehicle_id AND v.status <> 'available')
+				ORDER BY distance ASC
+				LIMIT $6
+`
+
 // LocationRepository represents the repository layer for locations
 type LocationRepository interface {
-	FindVehicleLocations(latitude, longitude float64, radius, limit int) ([]model.Location, error)
+	ExplainVehicleLocations(ctx context.Context, latitude, longitude float64, radius, limit int) ([]string, error)
+	FindLocationBounds(ctx context.Context) (*model.Bounds, error)
+	FindVehicleLocations(ctx context.Context, latitude, longitude float64, radius, limit int) (model.NearbyLocations, error)
+	FindVehicleLocationsBatch(ctx context.Context, queries []model.NearbyQuery) ([]model.NearbyLocations, error)
+	FindVehicleLocationsAlongRoute(ctx context.Context, corridor model.Corridor) (model.NearbyLocations, error)
 }
 
 type postgresLocationRepository struct {
-	db *sqlx.DB
+	logger logger.Logger
+	db     *database.Cluster
 }
 
-// NewPostgresLocationRepository is a constructor for postgresLocationRepository
-func NewPostgresLocationRepository(db *sqlx.DB) LocationRepository {
-	return postgresLocationRepository{db: db}
+// NewPostgresLocationRepository is a constructor for postgresLocationRepository.
+// Reads are served by the read replicas of db when they are healthy
+func NewPostgresLocationRepository(logger logger.Logger, db *database.Cluster) LocationRepository {
+	return postgresLocationRepository{logger: logger, db: db}
 }
 
-// FindVehicleLocations fetches the nearby locations from the underlying storage
-func (p postgresLocationRepository) FindVehicleLocations(latitude, longitude float64, radius, limit int) ([]model.Location, error) {
-	var locations []model.Location
+// ExplainVehicleLocations runs the nearby search of FindVehicleLocations under EXPLAIN ANALYZE and returns the lines of
+// its query plan, with the actual timings and buffers. It is meant for operators looking into slow searches
+func (p postgresLocationRepository) ExplainVehicleLocations(ctx context.Context, latitude, longitude float64, radius, limit int) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "postgresLocationRepository.ExplainVehicleLocations", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String("EXPLAIN"), semconv.DBSQLTableKey.String("locations"))
+	span.SetAttributes(tracing.QueryAttributes(latitude, longitude, radius, limit)...)
+
+	var plan []string
+	err := p.db.Reader().SelectContext(ctx, &plan, "EXPLAIN (ANALYZE, BUFFERS) "+nearbyQuery, longitude, latitude, longitude, latitude, radius, limit, HolderFromContext(ctx))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return plan, nil
+}
+
+// FindLocationBounds returns the bounding box of every location, or nil when there are none
+func (p postgresLocationRepository) FindLocationBounds(ctx context.Context) (*model.Bounds, error) {
+	ctx, span := tracer.Start(ctx, "postgresLocationRepository.FindLocationBounds", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String("SELECT"), semconv.DBSQLTableKey.String("locations"))
+
+	// st_extent would be faster but rounds to single precision
 	query := `SELECT
- 				vehicle_id,
- 				st_asgeojson(location) as loc,
-				st_distance(geography(location), geography(st_setsrid(st_makepoint($1, $2), 4326))) as distance
- 				FROM locations
-				WHERE st_within(location, geometry(st_buffer(geography(st_setsrid(st_makepoint($3, $4), 4326)), $5)))
-				ORDER BY distance ASC
-				LIMIT $6
+				min(st_y(location)) AS min_latitude,
+				min(st_x(location)) AS min_longitude,
+				max(st_y(location)) AS max_latitude,
+				max(st_x(location)) AS max_longitude
+				FROM locations
+				HAVING count(location) > 0`
+	var bounds model.Bounds
+	err := p.db.Reader().GetContext(ctx, &bounds, query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return &bounds, nil
+}
+
+// FindVehicleLocations fetches the nearby locations from the underlying storage, together with the number of vehicles within the radius.
+// Vehicles with an active hold, unless the holder in ctx holds them, and vehicles that are busy, offline or in
+// maintenance, are left out
+func (p postgresLocationRepository) FindVehicleLocations(ctx context.Context, latitude, longitude float64, radius, limit int) (model.NearbyLocations, error) {
+	ctx, span := tracer.Start(ctx, "postgresLocationRepository.FindVehicleLocations", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String("SELECT"), semconv.DBSQLTableKey.String("locations"))
+	span.SetAttributes(tracing.QueryAttributes(latitude, longitude, radius, limit)...)
+
+	var nearby model.NearbyLocations
+	rows, err := p.db.Reader().QueryxContext(ctx, nearbyQuery, longitude, latitude, longitude, latitude, radius, limit, HolderFromContext(ctx))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return model.NearbyLocations{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var vehicleID int64
+		var distance float64
+		var snapped bool
+		var location geojson.Geometry
+		err = rows.Scan(&vehicleID, &location, &snapped, &distance, &nearby.Total)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return model.NearbyLocations{}, err
+		}
+		nearby.Locations = append(nearby.Locations, model.Location{
+			VehicleID: vehicleID,
+			Latitude:  location.Point[1],
+			Longitude: location.Point[0],
+			Distance:  distance,
+			Snapped:   snapped,
+		})
+	}
+	if err = rows.Err(); err != nil {
+		tracing.RecordError(span, err)
+		return model.NearbyLocations{}, err
+	}
+	span.SetAttributes(tracing.ResultCountKey.Int(len(nearby.Locations)))
+	p.logger.WithContext(ctx).Debugf("fetched %d of %d locations within %dm of (%f, %f)", len(nearby.Locations), nearby.Total, radius, latitude, longitude)
+	return nearby, nil
+}
+
+// FindVehicleLocationsBatch runs the nearby search for every query in a single round trip, joining the origins
+// against locations laterally. The results are in the order of the queries
+func (p postgresLocationRepository) FindVehicleLocationsBatch(ctx context.Context, queries []model.NearbyQuery) ([]model.NearbyLocations, error) {
+	ctx, span := tracer.Start(ctx, "postgresLocationRepository.FindVehicleLocationsBatch", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String("SELECT"), semconv.DBSQLTableKey.String("locations"))
+	span.SetAttributes(tracing.BatchSizeKey.Int(len(queries)))
+
+	results := make([]model.NearbyLocations, len(queries))
+	if len(queries) == 0 {
+		return results, nil
+	}
+	indexes := make([]int64, len(queries))
+	longitudes := make([]float64, len(queries))
+	latitudes := make([]float64, len(queries))
+	radiuses := make([]int64, len(queries))
+	limits := make([]int64, len(queries))
+	for i, q := range queries {
+		indexes[i], longitudes[i], latitudes[i], radiuses[i], limits[i] = int64(i), q.Longitude, q.Latitude, int64(q.Radius), int64(q.Limit)
+	}
+	query := `SELECT
+				origin.idx,
+				nearest.vehicle_id,
+				st_asgeojson(nearest.location) as loc,
+				nearest.snapped,
+				nearest.distance,
+				nearest.total
+				FROM unnest($1::int8[], $2::float8[], $3::float8[], $4::int8[], $5::int8[]) AS origin(idx, lng, lat, radius, lim)
+				CROSS JOIN LATERAL (
+					SELECT
+					vehicle_id,
+					location,
+					snapped,
+					st_distance(geography(location), geography(st_setsrid(st_makepoint(origin.lng, origin.lat), 4326))) as distance,
+					count(*) OVER () as total
+					FROM locations
+					WHERE st_within(location, geometry(st_buffer(geography(st_setsrid(st_makepoint(origin.lng, origin.lat), 4326)), origin.radius)))
+					AND NOT EXISTS (SELECT 1 FROM reservations r WHERE r.vehicle_id = locations.vehicle_id AND r.status = 'held' AND r.expires_at > now() AND r.holder <> $6)
+					AND NOT EXISTS (SELECT 1 FROM vehicles v WHERE v.id = locations.vehicle_id AND v.status <> 'available')
+					ORDER BY distance ASC
+					LIMIT origin.lim
+				) nearest
+				ORDER BY origin.idx, nearest.distance ASC
 `
-	rows, err := p.db.Queryx(query, longitude, latitude, longitude, latitude, radius, limit)
+	rows, err := p.db.Reader().QueryxContext(ctx, query, pq.Array(indexes), pq.Array(longitudes), pq.Array(latitudes), pq.Array(radiuses), pq.Array(limits), HolderFromContext(ctx))
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, err
 	}
+	defer rows.Close()
+	found := 0
 	for rows.Next() {
+		var index int
 		var vehicleID int64
 		var distance float64
+		var total int
+		var snapped bool
 		var location geojson.Geometry
-		err = rows.Scan(&vehicleID, &location, &distance)
+		err = rows.Scan(&index, &vehicleID, &location, &snapped, &distance, &total)
 		if err != nil {
+			tracing.RecordError(span, err)
 			return nil, err
 		}
-		locations = append(locations, model.Location{
+		results[index].Total = total
+		results[index].Locations = append(results[index].Locations, model.Location{
+			VehicleID: vehicleID,
+			Latitude:  location.Point[1],
+			Longitude: location.Point[0],
+			Distance:  distance,
+			Snapped:   snapped,
+		})
+		found++
+	}
+	if err = rows.Err(); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(tracing.ResultCountKey.Int(found))
+	p.logger.WithContext(ctx).Debugf("fetched %d locations for %d origins", found, len(queries))
+	return results, nil
+}
+
+// FindVehicleLocationsAlongRoute fetches the locations within the buffer of a route, together with the number of them.
+// Distance is the distance to the route and Along how far along the route, from its start, the vehicle is.
+// Buffering the route in geography keeps the buffer in meters while the search still goes through the GIST index.
+// Along is the geodesic length of the route up to the point closest to the vehicle; that point is found in planar
+// degrees, since PostGIS has no geography st_linelocatepoint, so it can be off within a segment but never across them
+func (p postgresLocationRepository) FindVehicleLocationsAlongRoute(ctx context.Context, corridor model.Corridor) (model.NearbyLocations, error) {
+	ctx, span := tracer.Start(ctx, "postgresLocationRepository.FindVehicleLocationsAlongRoute", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String("SELECT"), semconv.DBSQLTableKey.String("locations"))
+	span.SetAttributes(tracing.RoutePointsKey.Int(len(corridor.Route)), tracing.BufferKey.Int(corridor.Buffer), tracing.LimitKey.Int(corridor.Limit))
+
+	route, err := json.Marshal(geojson.NewLineStringGeometry(corridor.Route))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return model.NearbyLocations{}, err
+	}
+	var nearby model.NearbyLocations
+	query := `WITH route AS (SELECT st_setsrid(st_geomfromgeojson($1), 4326) AS line)
+				SELECT
+				vehicle_id,
+				st_asgeojson(location) as loc,
+				snapped,
+				st_distance(geography(location), geography(route.line)) as distance,
+				st_length(geography(st_linesubstring(route.line, 0, st_linelocatepoint(route.line, location)))) as along,
+				count(*) OVER () as total
+				FROM locations, route
+				WHERE st_within(location, geometry(st_buffer(geography(route.line), $2)))
+				AND NOT EXISTS (SELECT 1 FROM reservations r WHERE r.vehicle_id = locations.vehicle_id AND r.status = 'held' AND r.expires_at > now() AND r.holder <> $5)
+				AND NOT EXISTS (SELECT 1 FROM vehicles v WHERE v.id = locations.vehicle_id AND v.status <> 'available')
+				ORDER BY CASE WHEN $3::text = 'start' THEN st_linelocatepoint(route.line, location) ELSE st_distance(geography(location), geography(route.line)) END, vehicle_id
+				LIMIT $4
+`
+	rows, err := p.db.Reader().QueryxContext(ctx, query, string(route), corridor.Buffer, string(corridor.Order), corridor.Limit, HolderFromContext(ctx))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return model.NearbyLocations{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var vehicleID int64
+		var distance, along float64
+		var snapped bool
+		var location geojson.Geometry
+		err = rows.Scan(&vehicleID, &location, &snapped, &distance, &along, &nearby.Total)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return model.NearbyLocations{}, err
+		}
+		nearby.Locations = append(nearby.Locations, model.Location{
 			VehicleID: vehicleID,
 			Latitude:  location.Point[1],
 			Longitude: location.Point[0],
 			Distance:  distance,
+			Snapped:   snapped,
+			Along:     &along,
 		})
 	}
-	return locations, nil
+	if err = rows.Err(); err != nil {
+		tracing.RecordError(span, err)
+		return model.NearbyLocations{}, err
+	}
+	span.SetAttributes(tracing.ResultCountKey.Int(len(nearby.Locations)))
+	p.logger.WithContext(ctx).Debugf("fetched %d of %d locations within %dm of a route of %d points", len(nearby.Locations), nearby.Total, corridor.Buffer, len(corridor.Route))
+	return nearby, nil
 }
@@ -1,8 +1,10 @@
 package repository_test
 
 import (
+	"context"
 	"find-nearby-backend/config"
 	"find-nearby-backend/database"
+	"find-nearby-backend/database/migrations"
 	"find-nearby-backend/logger"
 	"find-nearby-backend/model"
 	"find-nearby-backend/repository"
@@ -10,8 +12,6 @@ import (
 	"testing"
 
 	"github.com/golang-migrate/migrate"
-	_ "github.com/golang-migrate/migrate/database/postgres"
-	_ "github.com/golang-migrate/migrate/source/file"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/suite"
 )
@@ -28,11 +28,13 @@ type RepositoryTestSuite struct {
 func (s *RepositoryTestSuite) SetupSuite() {
 	cfg := config.LoadConfig()
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
-	s.db, _ = database.New(cfg, log)
-	m, err := migrate.New("file://../database/migrations", cfg.DatabaseConnectionURL())
+	cluster, err := database.New(cfg, log)
+	s.Require().NoError(err)
+	s.db = cluster.Primary()
+	m, err := migrations.New(cfg.DatabaseConnectionURL())
 	s.Require().NoError(err)
 	s.dbMigration = m
-	s.repository = repository.NewPostgresLocationRepository(s.db)
+	s.repository = repository.NewPostgresLocationRepository(log, cluster)
 	s.originLat = 1.305649
 	s.originLng = 103.926768
 }
@@ -63,8 +65,10 @@ func (s *RepositoryTestSuite) TestFindVehicleLocations_WhenLimitIsTwo_ShouldRetu
 	s.Require().NoError(err)
 
 	candidateLocations := getData()
-	actualLocations, err := s.repository.FindVehicleLocations(s.originLat, s.originLng, 1000, 2)
+	actual, err := s.repository.FindVehicleLocations(context.Background(), s.originLat, s.originLng, 1000, 2)
 	s.Assert().NoError(err)
+	s.Assert().Equal(4, actual.Total)
+	actualLocations := actual.Locations
 	s.Assert().Equal(2, len(actualLocations))
 	s.Assert().Equal(candidateLocations[0].VehicleID, actualLocations[0].VehicleID)
 	s.Assert().Equal(candidateLocations[0].Latitude, actualLocations[0].Latitude)
@@ -79,9 +83,10 @@ func (s *RepositoryTestSuite) TestFindVehicleLocations_WhenRadiusIsTooSmall_Shou
 	err := s.insertLocations()
 	s.Require().NoError(err)
 
-	actualLocations, err := s.repository.FindVehicleLocations(s.originLat, s.originLng, 1, 20)
+	actual, err := s.repository.FindVehicleLocations(context.Background(), s.originLat, s.originLng, 1, 20)
 	s.Assert().NoError(err)
-	s.Assert().Equal(0, len(actualLocations))
+	s.Assert().Equal(0, len(actual.Locations))
+	s.Assert().Equal(0, actual.Total)
 }
 
 func (s *RepositoryTestSuite) TestFindVehicleLocations_WhenRadiusAndLimitAreBigEnough_ShouldReturnAllLocations() {
@@ -89,8 +94,10 @@ func (s *RepositoryTestSuite) TestFindVehicleLocations_WhenRadiusAndLimitAreBigE
 	s.Require().NoError(err)
 
 	candidateLocations := getData()
-	actualLocations, err := s.repository.FindVehicleLocations(s.originLat, s.originLng, 3000, 100)
+	actual, err := s.repository.FindVehicleLocations(context.Background(), s.originLat, s.originLng, 3000, 100)
 	s.Assert().NoError(err)
+	s.Assert().Equal(5, actual.Total)
+	actualLocations := actual.Locations
 	s.Assert().Equal(5, len(actualLocations))
 	s.Assert().Equal(candidateLocations[0].VehicleID, actualLocations[0].VehicleID)
 	s.Assert().Equal(candidateLocations[0].Latitude, actualLocations[0].Latitude)
@@ -117,9 +124,105 @@ func (s *RepositoryTestSuite) TestFindVehicleLocations_WhenDbReturnsError_Should
 	err := s.insertLocations()
 	s.Require().NoError(err)
 
-	actualLocations, err := s.repository.FindVehicleLocations(s.originLat, s.originLng, 3000, -2)
+	actual, err := s.repository.FindVehicleLocations(context.Background(), s.originLat, s.originLng, 3000, -2)
 	s.Assert().Error(err)
-	s.Assert().Nil(actualLocations)
+	s.Assert().Nil(actual.Locations)
+}
+
+func (s *RepositoryTestSuite) TestFindVehicleLocationsBatch_ShouldReturnResultsInQueryOrder() {
+	err := s.insertLocations()
+	s.Require().NoError(err)
+
+	candidateLocations := getData()
+	actual, err := s.repository.FindVehicleLocationsBatch(context.Background(), []model.NearbyQuery{
+		{Latitude: s.originLat, Longitude: s.originLng, Radius: 1, Limit: 20},
+		{Latitude: s.originLat, Longitude: s.originLng, Radius: 3000, Limit: 2},
+	})
+	s.Assert().NoError(err)
+	s.Require().Len(actual, 2)
+	s.Assert().Empty(actual[0].Locations)
+	s.Assert().Equal(0, actual[0].Total)
+	s.Require().Len(actual[1].Locations, 2)
+	s.Assert().Equal(5, actual[1].Total)
+	s.Assert().Equal(candidateLocations[0].VehicleID, actual[1].Locations[0].VehicleID)
+	s.Assert().Equal(candidateLocations[1].VehicleID, actual[1].Locations[1].VehicleID)
+}
+
+func (s *RepositoryTestSuite) TestFindVehicleLocationsAlongRoute_WhenOrderIsStart_ShouldFollowTheRoute() {
+	err := s.insertLocations()
+	s.Require().NoError(err)
+
+	candidateLocations := getData()
+	actual, err := s.repository.FindVehicleLocationsAlongRoute(context.Background(), model.Corridor{
+		Route:  [][]float64{{s.originLng, s.originLat}, {103.948, 1.3116}},
+		Buffer: 3000,
+		Limit:  3,
+		Order:  model.OrderByStart,
+	})
+	s.Assert().NoError(err)
+	s.Assert().Equal(5, actual.Total)
+	s.Require().Len(actual.Locations, 3)
+	for i, location := range actual.Locations {
+		s.Assert().Equal(candidateLocations[i].VehicleID, location.VehicleID)
+		s.Require().NotNil(location.Along)
+		if i > 0 {
+			s.Assert().Greater(*location.Along, *actual.Locations[i-1].Along)
+		}
+	}
+}
+
+func (s *RepositoryTestSuite) TestFindVehicleLocationsAlongRoute_WhenBufferIsTooSmall_ShouldReturnZeroLocations() {
+	err := s.insertLocations()
+	s.Require().NoError(err)
+
+	actual, err := s.repository.FindVehicleLocationsAlongRoute(context.Background(), model.Corridor{
+		Route:  [][]float64{{103.80, 1.40}, {103.81, 1.41}},
+		Buffer: 100,
+		Limit:  10,
+		Order:  model.OrderByDistance,
+	})
+	s.Assert().NoError(err)
+	s.Assert().Equal(0, actual.Total)
+	s.Assert().Empty(actual.Locations)
+}
+
+func (s *RepositoryTestSuite) TestFindVehicleStatuses_ShouldLeaveOutUnknownVehicles() {
+	_, err := s.db.Exec(`INSERT INTO vehicles (id, status) VALUES (1, 'available'), (2, 'busy')`)
+	s.Require().NoError(err)
+
+	vehicles := repository.NewPostgresVehicleRepository(logger.New("debug", "plaintext"), database.NewCluster(logger.New("debug", "plaintext"), s.db, nil, 0))
+	statuses, err := vehicles.FindVehicleStatuses(context.Background(), []int64{1, 2, 3})
+	s.Assert().NoError(err)
+	s.Assert().Equal(map[int64]string{1: "available", 2: "busy"}, statuses)
+}
+
+func (s *RepositoryTestSuite) TestFindVehiclePositions_ShouldLeaveOutVehiclesWithoutALocation() {
+	s.Require().NoError(s.insertLocations())
+
+	vehicles := repository.NewPostgresVehicleRepository(logger.New("debug", "plaintext"), database.NewCluster(logger.New("debug", "plaintext"), s.db, nil, 0))
+	positions, err := vehicles.FindVehiclePositions(context.Background(), []int64{2, 99})
+	s.Assert().NoError(err)
+	s.Assert().Equal([]model.Location{{VehicleID: 2, Latitude: 1.306002, Longitude: 103.927337}}, positions)
+}
+
+func (s *RepositoryTestSuite) TestFindLocationBounds_ShouldCoverEveryLocation() {
+	bounds, err := s.repository.FindLocationBounds(context.Background())
+	s.Assert().NoError(err)
+	s.Assert().Nil(bounds, "there are no locations yet")
+
+	s.Require().NoError(s.insertLocations())
+	bounds, err = s.repository.FindLocationBounds(context.Background())
+	s.Assert().NoError(err)
+	s.Assert().Equal(&model.Bounds{MinLatitude: 1.306002, MinLongitude: 103.927337, MaxLatitude: 1.311528, MaxLongitude: 103.947878}, bounds)
+}
+
+func (s *RepositoryTestSuite) TestExplainVehicleLocations_ShouldReturnThePlanOfTheSearch() {
+	s.Require().NoError(s.insertLocations())
+	plan, err := s.repository.ExplainVehicleLocations(context.Background(), 1.3099, 103.9377, 1000, 2)
+	s.Assert().NoError(err)
+	s.Require().NotEmpty(plan)
+	s.Assert().Contains(plan[0], "Limit")
+	s.Assert().Contains(plan[len(plan)-1], "Execution Time")
 }
 
 func (s *RepositoryTestSuite) insertLocations() error {
@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	model "find-nearby-backend/model"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,22 +15,110 @@ type LocationRepository struct {
 	mock.Mock
 }
 
-// FindVehicleLocations provides a mock function with given fields: latitude, longitude, radius, limit
-func (_m *LocationRepository) FindVehicleLocations(latitude float64, longitude float64, radius int, limit int) ([]model.Location, error) {
-	ret := _m.Called(latitude, longitude, radius, limit)
+// ExplainVehicleLocations provides a mock function with given fields: ctx, latitude, longitude, radius, limit
+func (_m *LocationRepository) ExplainVehicleLocations(ctx context.Context, latitude float64, longitude float64, radius int, limit int) ([]string, error) {
+	ret := _m.Called(ctx, latitude, longitude, radius, limit)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, float64, float64, int, int) []string); ok {
+		r0 = rf(ctx, latitude, longitude, radius, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, float64, float64, int, int) error); ok {
+		r1 = rf(ctx, latitude, longitude, radius, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindLocationBounds provides a mock function with given fields: ctx
+func (_m *LocationRepository) FindLocationBounds(ctx context.Context) (*model.Bounds, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *model.Bounds
+	if rf, ok := ret.Get(0).(func(context.Context) *model.Bounds); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Bounds)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindVehicleLocations provides a mock function with given fields: ctx, latitude, longitude, radius, limit
+func (_m *LocationRepository) FindVehicleLocations(ctx context.Context, latitude float64, longitude float64, radius int, limit int) (model.NearbyLocations, error) {
+	ret := _m.Called(ctx, latitude, longitude, radius, limit)
+
+	var r0 model.NearbyLocations
+	if rf, ok := ret.Get(0).(func(context.Context, float64, float64, int, int) model.NearbyLocations); ok {
+		r0 = rf(ctx, latitude, longitude, radius, limit)
+	} else {
+		r0 = ret.Get(0).(model.NearbyLocations)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, float64, float64, int, int) error); ok {
+		r1 = rf(ctx, latitude, longitude, radius, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindVehicleLocationsAlongRoute provides a mock function with given fields: ctx, corridor
+func (_m *LocationRepository) FindVehicleLocationsAlongRoute(ctx context.Context, corridor model.Corridor) (model.NearbyLocations, error) {
+	ret := _m.Called(ctx, corridor)
+
+	var r0 model.NearbyLocations
+	if rf, ok := ret.Get(0).(func(context.Context, model.Corridor) model.NearbyLocations); ok {
+		r0 = rf(ctx, corridor)
+	} else {
+		r0 = ret.Get(0).(model.NearbyLocations)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Corridor) error); ok {
+		r1 = rf(ctx, corridor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindVehicleLocationsBatch provides a mock function with given fields: ctx, queries
+func (_m *LocationRepository) FindVehicleLocationsBatch(ctx context.Context, queries []model.NearbyQuery) ([]model.NearbyLocations, error) {
+	ret := _m.Called(ctx, queries)
 
-	var r0 []model.Location
-	if rf, ok := ret.Get(0).(func(float64, float64, int, int) []model.Location); ok {
-		r0 = rf(latitude, longitude, radius, limit)
+	var r0 []model.NearbyLocations
+	if rf, ok := ret.Get(0).(func(context.Context, []model.NearbyQuery) []model.NearbyLocations); ok {
+		r0 = rf(ctx, queries)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.Location)
+			r0 = ret.Get(0).([]model.NearbyLocations)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(float64, float64, int, int) error); ok {
-		r1 = rf(latitude, longitude, radius, limit)
+	if rf, ok := ret.Get(1).(func(context.Context, []model.NearbyQuery) error); ok {
+		r1 = rf(ctx, queries)
 	} else {
 		r1 = ret.Error(1)
 	}
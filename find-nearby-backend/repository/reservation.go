@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+
+	"github.com/jmoiron/sqlx"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Errors returned by ReservationRepository
+var (
+	ErrReservationNotFound  = errors.New("reservation not found")
+	ErrVehicleUnavailable   = errors.New("vehicle is not available or is already held")
+	ErrNotReservationHolder = errors.New("reservation is held by someone else")
+	ErrStaleReservation     = errors.New("reservation was changed or is no longer held; fetch it again")
+	ErrInvalidTransition    = errors.New("reservation can't move to that status from its current one")
+	ErrTooManyHolds         = errors.New("too many vehicles are held already; confirm or cancel some of the holds first")
+)
+
+type holderKey struct{}
+
+// ContextWithHolder returns a copy of ctx carrying the holder the caller holds reservations as. Searches made with it
+// find the vehicles the holder holds, which are left out for everyone else
+func ContextWithHolder(ctx context.Context, holder string) context.Context {
+	return context.WithValue(ctx, holderKey{}, holder)
+}
+
+// HolderFromContext returns the holder of the caller, or "" when ctx doesn't carry one
+func HolderFromContext(ctx context.Context) string {
+	holder, _ := ctx.Value(holderKey{}).(string)
+	return holder
+}
+
+// transitions lists the statuses a reservation can move to from each status. A hold is confirmed or cancelled, and a
+// confirmed reservation ends when it is completed or cancelled; expired, cancelled and completed reservations are final
+var transitions = map[string][]string{
+	model.ReservationStatusHeld:      {model.ReservationStatusConfirmed, model.ReservationStatusCancelled},
+	model.ReservationStatusConfirmed: {model.ReservationStatusCompleted, model.ReservationStatusCancelled},
+}
+
+// reservationColumns reports a held reservation whose hold has lapsed as expired, even before the expiry job has run
+const reservationColumns = `id, vehicle_id, holder,
+	CASE WHEN status = 'held' AND expires_at <= now() THEN 'expired' ELSE status END AS status,
+	version, expires_at, created_at, updated_at`
+
+// ReservationRepository represents the repository layer for reservations
+type ReservationRepository interface {
+	Create(ctx context.Context, vehicleID int64, holder string, hold time.Duration, maxHolds int) (model.Reservation, error)
+	Get(ctx context.Context, id int64) (model.Reservation, error)
+	Transition(ctx context.Context, id int64, holder string, version int, status string) (model.Reservation, error)
+	ExpireHolds(ctx context.Context) (int64, error)
+}
+
+type postgresReservationRepository struct {
+	logger logger.Logger
+	db     *database.Cluster
+}
+
+// NewPostgresReservationRepository is a constructor for postgresReservationRepository.
+// Reservations are read and written on the primary, since a lagging replica would hand out stale versions
+func NewPostgresReservationRepository(logger logger.Logger, db *database.Cluster) ReservationRepository {
+	return postgresReservationRepository{logger: logger, db: db}
+}
+
+// Create holds an available vehicle for holder. Lapsed holds on the vehicle are expired first; the unique index on
+// held reservations makes sure that of two concurrent calls for the same vehicle only one succeeds. A vehicle with a
+// location but no row in vehicles counts as available, as it does in nearby searches, and gets a row to hold it by.
+// A holder can hold at most maxHolds vehicles at a time; the creates of a holder are serialized to keep to that
+func (p postgresReservationRepository) Create(ctx context.Context, vehicleID int64, holder string, hold time.Duration, maxHolds int) (model.Reservation, error) {
+	ctx, span := p.startSpan(ctx, "postgresReservationRepository.Create", "INSERT")
+	defer span.End()
+
+	var reservation model.Reservation
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, holder); err != nil {
+			return err
+		}
+		var holds int
+		if err := tx.GetContext(ctx, &holds, `SELECT count(*) FROM reservations
+			WHERE holder = $1 AND status = 'held' AND expires_at > now()`, holder); err != nil {
+			return err
+		}
+		if holds >= maxHolds {
+			return ErrTooManyHolds
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE reservations SET status = 'expired', version = version + 1, updated_at = now()
+			WHERE vehicle_id = $1 AND status = 'held' AND expires_at <= now()`, vehicleID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO vehicles (id) SELECT vehicle_id FROM locations WHERE vehicle_id = $1
+			ON CONFLICT DO NOTHING`, vehicleID); err != nil {
+			return err
+		}
+		err := tx.GetContext(ctx, &reservation, `INSERT INTO reservations (vehicle_id, holder, expires_at)
+			SELECT id, $2, now() + $3 * interval '1 millisecond' FROM vehicles WHERE id = $1 AND status = 'available'
+			ON CONFLICT (vehicle_id) WHERE status = 'held' DO NOTHING
+			RETURNING `+reservationColumns, vehicleID, holder, hold.Milliseconds())
+		if err == sql.ErrNoRows {
+			return ErrVehicleUnavailable
+		}
+		return err
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return model.Reservation{}, err
+	}
+	p.logger.WithContext(ctx).Debugf("vehicle %d held by %s until %s", vehicleID, holder, reservation.ExpiresAt)
+	return reservation, nil
+}
+
+// Get fetches a reservation by ID
+func (p postgresReservationRepository) Get(ctx context.Context, id int64) (model.Reservation, error) {
+	ctx, span := p.startSpan(ctx, "postgresReservationRepository.Get", "SELECT")
+	defer span.End()
+
+	var reservation model.Reservation
+	err := p.db.Primary().GetContext(ctx, &reservation, `SELECT `+reservationColumns+` FROM reservations WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		err = ErrReservationNotFound
+	}
+	if err != nil {
+		tracing.RecordError(span, err)
+		return model.Reservation{}, err
+	}
+	return reservation, nil
+}
+
+// Transition moves a reservation to status if it is still held by holder and still at version. Confirming a
+// reservation marks its vehicle busy, so that it isn't dispatched or held again, and completing or cancelling a
+// confirmed one marks it available again
+func (p postgresReservationRepository) Transition(ctx context.Context, id int64, holder string, version int, status string) (model.Reservation, error) {
+	ctx, span := p.startSpan(ctx, "postgresReservationRepository.Transition", "UPDATE")
+	defer span.End()
+
+	var reservation model.Reservation
+	err := p.inTx(ctx, func(tx *sqlx.Tx) error {
+		var current model.Reservation
+		err := tx.GetContext(ctx, &current, `SELECT `+reservationColumns+` FROM reservations WHERE id = $1 FOR UPDATE`, id)
+		if err == sql.ErrNoRows {
+			return ErrReservationNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err = checkTransition(current, holder, version, status); err != nil {
+			return err
+		}
+		err = tx.GetContext(ctx, &reservation, `UPDATE reservations SET status = $2, version = version + 1, updated_at = now()
+			WHERE id = $1 RETURNING `+reservationColumns, id, status)
+		if err != nil {
+			return err
+		}
+		vehicleStatus := model.VehicleStatusAvailable
+		switch {
+		case status == model.ReservationStatusConfirmed:
+			vehicleStatus = model.VehicleStatusBusy
+		case current.Status == model.ReservationStatusHeld:
+			// a hold never made the vehicle busy, so there is nothing to hand back
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE vehicles SET status = $2, updated_at = now() WHERE id = $1`, reservation.VehicleID, vehicleStatus)
+		return err
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return model.Reservation{}, err
+	}
+	p.logger.WithContext(ctx).Debugf("reservation %d of vehicle %d is now %s", id, reservation.VehicleID, status)
+	return reservation, nil
+}
+
+// ExpireHolds marks every held reservation whose hold has lapsed as expired and returns how many there were
+func (p postgresReservationRepository) ExpireHolds(ctx context.Context) (int64, error) {
+	ctx, span := p.startSpan(ctx, "postgresReservationRepository.ExpireHolds", "UPDATE")
+	defer span.End()
+
+	result, err := p.db.Primary().ExecContext(ctx, `UPDATE reservations SET status = 'expired', version = version + 1, updated_at = now()
+		WHERE status = 'held' AND expires_at <= now()`)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// checkTransition tells whether holder can move current, last seen at version, to status
+func checkTransition(current model.Reservation, holder string, version int, status string) error {
+	switch {
+	case current.Holder != holder:
+		return ErrNotReservationHolder
+	case current.Version != version:
+		return ErrStaleReservation
+	}
+	for _, next := range transitions[current.Status] {
+		if next == status {
+			return nil
+		}
+	}
+	if current.Status == model.ReservationStatusExpired {
+		return ErrStaleReservation
+	}
+	return ErrInvalidTransition
+}
+
+func (p postgresReservationRepository) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := p.db.Primary().BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p postgresReservationRepository) startSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(operation), semconv.DBSQLTableKey.String("reservations"))
+	return ctx, span
+}
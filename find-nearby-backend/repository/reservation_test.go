@@ -0,0 +1,148 @@
+package repository_test
+
+import (
+	"context"
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+
+	"time"
+)
+
+func (s *RepositoryTestSuite) newReservationRepository() repository.ReservationRepository {
+	log := logger.New("debug", "plaintext")
+	return repository.NewPostgresReservationRepository(log, database.NewCluster(log, s.db, nil, 0))
+}
+
+func (s *RepositoryTestSuite) TestCreateReservation_WhenVehicleIsHeld_ShouldReturnErrVehicleUnavailable() {
+	_, err := s.db.Exec(`INSERT INTO vehicles (id, status) VALUES (2, 'available'), (3, 'busy')`)
+	s.Require().NoError(err)
+	reservations := s.newReservationRepository()
+
+	held, err := reservations.Create(context.Background(), 2, "alice", time.Minute, 10)
+	s.Require().NoError(err)
+	s.Assert().Equal(model.ReservationStatusHeld, held.Status)
+	s.Assert().Equal(1, held.Version)
+
+	_, err = reservations.Create(context.Background(), 2, "bob", time.Minute, 10)
+	s.Assert().Equal(repository.ErrVehicleUnavailable, err)
+	_, err = reservations.Create(context.Background(), 3, "bob", time.Minute, 10)
+	s.Assert().Equal(repository.ErrVehicleUnavailable, err)
+}
+
+func (s *RepositoryTestSuite) TestCreateReservation_WhenHolderHoldsMaxHolds_ShouldReturnErrTooManyHolds() {
+	_, err := s.db.Exec(`INSERT INTO vehicles (id) VALUES (2), (3)`)
+	s.Require().NoError(err)
+	reservations := s.newReservationRepository()
+
+	_, err = reservations.Create(context.Background(), 2, "alice", time.Minute, 1)
+	s.Require().NoError(err)
+	_, err = reservations.Create(context.Background(), 3, "alice", time.Minute, 1)
+	s.Assert().Equal(repository.ErrTooManyHolds, err)
+	_, err = reservations.Create(context.Background(), 3, "bob", time.Minute, 1)
+	s.Assert().NoError(err, "the cap is per holder")
+}
+
+func (s *RepositoryTestSuite) TestCreateReservation_WhenVehicleHasALocationButNoRow_ShouldHoldIt() {
+	s.Require().NoError(s.insertLocations())
+
+	held, err := s.newReservationRepository().Create(context.Background(), 2, "alice", time.Minute, 10)
+	s.Require().NoError(err)
+	s.Assert().Equal(int64(2), held.VehicleID)
+}
+
+func (s *RepositoryTestSuite) TestFindVehicleLocations_WhenVehicleIsHeld_ShouldLeaveItOutForOthers() {
+	s.Require().NoError(s.insertLocations())
+	_, err := s.db.Exec(`INSERT INTO vehicles (id) VALUES (2)`)
+	s.Require().NoError(err)
+	_, err = s.newReservationRepository().Create(context.Background(), 2, "alice", time.Minute, 10)
+	s.Require().NoError(err)
+
+	for _, holder := range []string{"", "bob"} {
+		actual, err := s.repository.FindVehicleLocations(repository.ContextWithHolder(context.Background(), holder), s.originLat, s.originLng, 1000, 1)
+		s.Assert().NoError(err)
+		s.Assert().Equal(3, actual.Total)
+		s.Require().Len(actual.Locations, 1)
+		s.Assert().Equal(int64(3), actual.Locations[0].VehicleID)
+	}
+
+	actual, err := s.repository.FindVehicleLocations(repository.ContextWithHolder(context.Background(), "alice"), s.originLat, s.originLng, 1000, 1)
+	s.Assert().NoError(err)
+	s.Assert().Equal(4, actual.Total, "the holder finds the vehicles it holds")
+	s.Require().Len(actual.Locations, 1)
+	s.Assert().Equal(int64(2), actual.Locations[0].VehicleID)
+}
+
+func (s *RepositoryTestSuite) TestTransitionReservation_WhenVersionIsStale_ShouldReturnErrStaleReservation() {
+	_, err := s.db.Exec(`INSERT INTO vehicles (id) VALUES (2)`)
+	s.Require().NoError(err)
+	reservations := s.newReservationRepository()
+	held, err := reservations.Create(context.Background(), 2, "alice", time.Minute, 10)
+	s.Require().NoError(err)
+
+	_, err = reservations.Transition(context.Background(), held.ID, "bob", held.Version, model.ReservationStatusConfirmed)
+	s.Assert().Equal(repository.ErrNotReservationHolder, err)
+
+	confirmed, err := reservations.Transition(context.Background(), held.ID, "alice", held.Version, model.ReservationStatusConfirmed)
+	s.Require().NoError(err)
+	s.Assert().Equal(model.ReservationStatusConfirmed, confirmed.Status)
+	s.Assert().Equal(held.Version+1, confirmed.Version)
+
+	_, err = reservations.Transition(context.Background(), held.ID, "alice", held.Version, model.ReservationStatusCancelled)
+	s.Assert().Equal(repository.ErrStaleReservation, err)
+
+	statuses, err := repository.NewPostgresVehicleRepository(logger.New("debug", "plaintext"), database.NewCluster(logger.New("debug", "plaintext"), s.db, nil, 0)).
+		FindVehicleStatuses(context.Background(), []int64{2})
+	s.Assert().NoError(err)
+	s.Assert().Equal(model.VehicleStatusBusy, statuses[2])
+}
+
+func (s *RepositoryTestSuite) TestTransitionReservation_WhenConfirmedRideEnds_ShouldMakeTheVehicleAvailable() {
+	_, err := s.db.Exec(`INSERT INTO vehicles (id) VALUES (2), (3)`)
+	s.Require().NoError(err)
+	reservations := s.newReservationRepository()
+	vehicles := repository.NewPostgresVehicleRepository(logger.New("debug", "plaintext"), database.NewCluster(logger.New("debug", "plaintext"), s.db, nil, 0))
+
+	held, err := reservations.Create(context.Background(), 2, "alice", time.Minute, 10)
+	s.Require().NoError(err)
+	_, err = reservations.Transition(context.Background(), held.ID, "alice", held.Version, model.ReservationStatusCompleted)
+	s.Assert().Equal(repository.ErrInvalidTransition, err, "a hold has to be confirmed before it is completed")
+	confirmed, err := reservations.Transition(context.Background(), held.ID, "alice", held.Version, model.ReservationStatusConfirmed)
+	s.Require().NoError(err)
+	completed, err := reservations.Transition(context.Background(), held.ID, "alice", confirmed.Version, model.ReservationStatusCompleted)
+	s.Require().NoError(err)
+	s.Assert().Equal(model.ReservationStatusCompleted, completed.Status)
+
+	held, err = reservations.Create(context.Background(), 3, "bob", time.Minute, 10)
+	s.Require().NoError(err)
+	confirmed, err = reservations.Transition(context.Background(), held.ID, "bob", held.Version, model.ReservationStatusConfirmed)
+	s.Require().NoError(err)
+	cancelled, err := reservations.Transition(context.Background(), held.ID, "bob", confirmed.Version, model.ReservationStatusCancelled)
+	s.Require().NoError(err)
+	s.Assert().Equal(model.ReservationStatusCancelled, cancelled.Status)
+
+	statuses, err := vehicles.FindVehicleStatuses(context.Background(), []int64{2, 3})
+	s.Assert().NoError(err)
+	s.Assert().Equal(map[int64]string{2: model.VehicleStatusAvailable, 3: model.VehicleStatusAvailable}, statuses)
+	_, err = reservations.Create(context.Background(), 2, "bob", time.Minute, 10)
+	s.Assert().NoError(err, "a completed ride hands the vehicle back")
+}
+
+func (s *RepositoryTestSuite) TestExpireHolds_ShouldExpireLapsedHolds() {
+	_, err := s.db.Exec(`INSERT INTO vehicles (id) VALUES (2), (3)`)
+	s.Require().NoError(err)
+	reservations := s.newReservationRepository()
+	lapsed, err := reservations.Create(context.Background(), 2, "alice", time.Millisecond, 10)
+	s.Require().NoError(err)
+	_, err = reservations.Create(context.Background(), 3, "bob", time.Minute, 10)
+	s.Require().NoError(err)
+	time.Sleep(10 * time.Millisecond)
+
+	expired, err := reservations.ExpireHolds(context.Background())
+	s.Assert().NoError(err)
+	s.Assert().Equal(int64(1), expired)
+	actual, err := reservations.Get(context.Background(), lapsed.ID)
+	s.Assert().NoError(err)
+	s.Assert().Equal(model.ReservationStatusExpired, actual.Status)
+}
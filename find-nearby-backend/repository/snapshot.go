@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SnapshotRepository represents the repository layer for moving fleet snapshots, the locations and vehicles,
+// between environments. Both directions stream, so a snapshot never has to fit in memory
+type SnapshotRepository interface {
+	ExportSnapshot(ctx context.Context, fn func(record model.SnapshotRecord) error) error
+	ImportSnapshot(ctx context.Context, mode string, next func() ([]model.SnapshotRecord, error)) (int, error)
+}
+
+type postgresSnapshotRepository struct {
+	logger logger.Logger
+	db     *database.Cluster
+}
+
+// NewPostgresSnapshotRepository is a constructor for postgresSnapshotRepository
+func NewPostgresSnapshotRepository(logger logger.Logger, db *database.Cluster) SnapshotRepository {
+	return postgresSnapshotRepository{logger: logger, db: db}
+}
+
+// ExportSnapshot calls fn with every location and its vehicle, by vehicle ID, and stops at the first error fn returns.
+// Locations without a vehicle row are exported with empty vehicle fields
+func (p postgresSnapshotRepository) ExportSnapshot(ctx context.Context, fn func(record model.SnapshotRecord) error) error {
+	ctx, span := p.startSpan(ctx, "postgresSnapshotRepository.ExportSnapshot", "SELECT")
+	defer span.End()
+
+	query := `SELECT
+				l.vehicle_id,
+				st_y(l.location),
+				st_x(l.location),
+				coalesce(v.type, ''),
+				coalesce(v.city, ''),
+				coalesce(v.status, ''),
+				l.recorded_at
+				FROM locations l
+				LEFT JOIN vehicles v ON v.id = l.vehicle_id
+				WHERE l.location IS NOT NULL
+				ORDER BY l.vehicle_id`
+	rows, err := p.db.Primary().QueryxContext(ctx, query)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var record model.SnapshotRecord
+		if err = rows.Scan(&record.VehicleID, &record.Latitude, &record.Longitude, &record.Type, &record.City, &record.Status, &record.RecordedAt); err != nil {
+			tracing.RecordError(span, err)
+			return err
+		}
+		if err = fn(record); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// ImportSnapshot stores the batches next returns, until it returns an empty one, in a single transaction and returns
+// how many records it stored. Nothing is stored when next or a statement fails. A batch must not have two records
+// of the same vehicle. In replace mode the locations and vehicles without a record are removed too, apart from
+// vehicles with reservations, which keep their rows and lose only their location
+func (p postgresSnapshotRepository) ImportSnapshot(ctx context.Context, mode string, next func() ([]model.SnapshotRecord, error)) (int, error) {
+	ctx, span := p.startSpan(ctx, "postgresSnapshotRepository.ImportSnapshot", "INSERT")
+	defer span.End()
+
+	tx, err := p.db.Primary().BeginTxx(ctx, nil)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	imported, err := p.importSnapshot(ctx, tx, mode, next)
+	if err != nil {
+		_ = tx.Rollback()
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	p.logger.WithContext(ctx).Debugf("imported %d records in %s mode", imported, mode)
+	return imported, nil
+}
+
+func (p postgresSnapshotRepository) importSnapshot(ctx context.Context, tx *sqlx.Tx, mode string, next func() ([]model.SnapshotRecord, error)) (int, error) {
+	if mode == model.SnapshotModeReplace {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM locations`); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM vehicles WHERE id NOT IN (SELECT vehicle_id FROM reservations)`); err != nil {
+			return 0, err
+		}
+	}
+	imported := 0
+	for {
+		records, err := next()
+		if err != nil {
+			return 0, err
+		}
+		if len(records) == 0 {
+			return imported, nil
+		}
+		if err = p.importRecords(ctx, tx, records); err != nil {
+			return 0, err
+		}
+		imported += len(records)
+	}
+}
+
+// importRecords adds the vehicles that are missing with the defaults of the vehicles table first, so that the
+// vehicle fields a record leaves empty keep their values, then overwrites the rest and the locations
+func (p postgresSnapshotRepository) importRecords(ctx context.Context, tx *sqlx.Tx, records []model.SnapshotRecord) error {
+	n := len(records)
+	vehicleIDs := make([]int64, n)
+	longitudes, latitudes := make([]float64, n), make([]float64, n)
+	types, cities, statuses := make([]string, n), make([]string, n), make([]string, n)
+	recordedAt := make([]sql.NullString, n)
+	for i, record := range records {
+		vehicleIDs[i] = record.VehicleID
+		longitudes[i], latitudes[i] = record.Longitude, record.Latitude
+		types[i], cities[i], statuses[i] = record.Type, record.City, record.Status
+		if record.RecordedAt != nil {
+			recordedAt[i] = sql.NullString{String: record.RecordedAt.UTC().Format(time.RFC3339Nano), Valid: true}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO vehicles (id) SELECT unnest($1::int8[]) ON CONFLICT DO NOTHING`, pq.Array(vehicleIDs)); err != nil {
+		return err
+	}
+	query := `UPDATE vehicles v SET
+				type = coalesce(nullif(r.type, ''), v.type),
+				city = coalesce(nullif(r.city, ''), v.city),
+				status = coalesce(nullif(r.status, ''), v.status),
+				updated_at = now()
+				FROM unnest($1::int8[], $2::text[], $3::text[], $4::text[]) AS r(id, type, city, status)
+				WHERE v.id = r.id AND (r.type <> '' OR r.city <> '' OR r.status <> '')`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(vehicleIDs), pq.Array(types), pq.Array(cities), pq.Array(statuses)); err != nil {
+		return err
+	}
+	query = `INSERT INTO locations (vehicle_id, location, raw_location, snapped_location, snapped, recorded_at)
+				SELECT vehicle_id, st_setsrid(st_makepoint(lng, lat), 4326), st_setsrid(st_makepoint(lng, lat), 4326), NULL, FALSE, recorded_at
+				FROM unnest($1::int8[], $2::float8[], $3::float8[], $4::timestamptz[]) AS r(vehicle_id, lng, lat, recorded_at)
+				ON CONFLICT (vehicle_id) DO UPDATE SET
+				location = EXCLUDED.location,
+				raw_location = EXCLUDED.raw_location,
+				snapped_location = NULL,
+				snapped = FALSE,
+				recorded_at = EXCLUDED.recorded_at`
+	_, err := tx.ExecContext(ctx, query, pq.Array(vehicleIDs), pq.Array(longitudes), pq.Array(latitudes), pq.Array(recordedAt))
+	return err
+}
+
+func (p postgresSnapshotRepository) startSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(operation), semconv.DBSQLTableKey.String("locations"))
+	return ctx, span
+}
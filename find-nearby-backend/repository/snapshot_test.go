@@ -0,0 +1,88 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+
+	"time"
+)
+
+func (s *RepositoryTestSuite) newSnapshotRepository() repository.SnapshotRepository {
+	log := logger.New("debug", "plaintext")
+	return repository.NewPostgresSnapshotRepository(log, database.NewCluster(log, s.db, nil, 0))
+}
+
+// batches returns a next func that hands out the given batches one at a time
+func batches(batches ...[]model.SnapshotRecord) func() ([]model.SnapshotRecord, error) {
+	return func() ([]model.SnapshotRecord, error) {
+		if len(batches) == 0 {
+			return nil, nil
+		}
+		batch := batches[0]
+		batches = batches[1:]
+		return batch, nil
+	}
+}
+
+func (s *RepositoryTestSuite) exportSnapshot() []model.SnapshotRecord {
+	var records []model.SnapshotRecord
+	s.Require().NoError(s.newSnapshotRepository().ExportSnapshot(context.Background(), func(record model.SnapshotRecord) error {
+		records = append(records, record)
+		return nil
+	}))
+	return records
+}
+
+func (s *RepositoryTestSuite) TestImportSnapshot_WhenModeIsUpsert_ShouldKeepVehicleFieldsThatAreLeftEmpty() {
+	s.Require().NoError(s.insertLocations())
+	_, err := s.db.Exec(`INSERT INTO vehicles (id, type, city) VALUES (2, 'bike', 'Singapore')`)
+	s.Require().NoError(err)
+	recordedAt := time.Date(2021, 10, 3, 8, 0, 0, 0, time.UTC)
+
+	imported, err := s.newSnapshotRepository().ImportSnapshot(context.Background(), model.SnapshotModeUpsert, batches(
+		[]model.SnapshotRecord{{VehicleID: 2, Latitude: 1.31, Longitude: 103.93, Status: model.VehicleStatusBusy, RecordedAt: &recordedAt}},
+		[]model.SnapshotRecord{{VehicleID: 7, Latitude: 1.32, Longitude: 103.94, Type: "car"}},
+	))
+	s.Assert().NoError(err)
+	s.Assert().Equal(2, imported)
+
+	records := s.exportSnapshot()
+	s.Require().Len(records, 6)
+	s.Assert().Equal(model.SnapshotRecord{VehicleID: 2, Latitude: 1.31, Longitude: 103.93, Type: "bike", City: "Singapore", Status: model.VehicleStatusBusy, RecordedAt: records[0].RecordedAt}, records[0])
+	s.Assert().True(recordedAt.Equal(*records[0].RecordedAt))
+	s.Assert().Equal(model.SnapshotRecord{VehicleID: 3, Latitude: 1.306254, Longitude: 103.927858}, records[1], "a location without a vehicle row")
+	s.Assert().Equal(model.SnapshotRecord{VehicleID: 7, Latitude: 1.32, Longitude: 103.94, Type: "car", Status: model.VehicleStatusAvailable}, records[5])
+}
+
+func (s *RepositoryTestSuite) TestImportSnapshot_WhenModeIsReplace_ShouldRemoveWhatIsNotImported() {
+	s.Require().NoError(s.insertLocations())
+	_, err := s.db.Exec(`INSERT INTO vehicles (id) VALUES (2), (3)`)
+	s.Require().NoError(err)
+
+	_, err = s.newSnapshotRepository().ImportSnapshot(context.Background(), model.SnapshotModeReplace, batches(
+		[]model.SnapshotRecord{{VehicleID: 3, Latitude: 1.3, Longitude: 103.9}},
+	))
+	s.Assert().NoError(err)
+	s.Assert().Equal([]model.SnapshotRecord{{VehicleID: 3, Latitude: 1.3, Longitude: 103.9, Type: "scooter", Status: model.VehicleStatusAvailable}}, s.exportSnapshot())
+	var vehicles int
+	s.Require().NoError(s.db.Get(&vehicles, `SELECT count(*) FROM vehicles`))
+	s.Assert().Equal(1, vehicles)
+}
+
+func (s *RepositoryTestSuite) TestImportSnapshot_WhenNextFails_ShouldImportNothing() {
+	s.Require().NoError(s.insertLocations())
+	calls := 0
+	_, err := s.newSnapshotRepository().ImportSnapshot(context.Background(), model.SnapshotModeReplace, func() ([]model.SnapshotRecord, error) {
+		calls++
+		if calls > 1 {
+			return nil, errors.New("too many malformed records")
+		}
+		return []model.SnapshotRecord{{VehicleID: 9, Latitude: 1.3, Longitude: 103.9}}, nil
+	})
+	s.Assert().EqualError(err, "too many malformed records")
+	s.Assert().Len(s.exportSnapshot(), len(getData()))
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+
+	"find-nearby-backend/database"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// VehicleRepository represents the repository layer for vehicles
+type VehicleRepository interface {
+	FindVehiclePositions(ctx context.Context, vehicleIDs []int64) ([]model.Location, error)
+	FindVehicleStatuses(ctx context.Context, vehicleIDs []int64) (map[int64]string, error)
+}
+
+type postgresVehicleRepository struct {
+	logger logger.Logger
+	db     *database.Cluster
+}
+
+// NewPostgresVehicleRepository is a constructor for postgresVehicleRepository.
+// Statuses are read from the primary since dispatch decisions can't work off a lagging replica
+func NewPostgresVehicleRepository(logger logger.Logger, db *database.Cluster) VehicleRepository {
+	return postgresVehicleRepository{logger: logger, db: db}
+}
+
+// FindVehiclePositions returns where each of the given vehicles is. Vehicles without a location are left out
+func (p postgresVehicleRepository) FindVehiclePositions(ctx context.Context, vehicleIDs []int64) ([]model.Location, error) {
+	ctx, span := tracer.Start(ctx, "postgresVehicleRepository.FindVehiclePositions", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String("SELECT"), semconv.DBSQLTableKey.String("locations"))
+
+	var positions []model.Location
+	if len(vehicleIDs) == 0 {
+		return positions, nil
+	}
+	err := p.db.Primary().SelectContext(ctx, &positions, `SELECT vehicle_id, st_y(location) AS latitude, st_x(location) AS longitude
+		FROM locations WHERE vehicle_id = ANY($1)`, pq.Array(vehicleIDs))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return positions, nil
+}
+
+// FindVehicleStatuses returns the status of each of the given vehicles. Vehicles without a row are left out; they
+// count as available
+func (p postgresVehicleRepository) FindVehicleStatuses(ctx context.Context, vehicleIDs []int64) (map[int64]string, error) {
+	ctx, span := tracer.Start(ctx, "postgresVehicleRepository.FindVehicleStatuses", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String("SELECT"), semconv.DBSQLTableKey.String("vehicles"))
+
+	statuses := make(map[int64]string, len(vehicleIDs))
+	if len(vehicleIDs) == 0 {
+		return statuses, nil
+	}
+	rows, err := p.db.Primary().QueryxContext(ctx, `SELECT id, status FROM vehicles WHERE id = ANY($1)`, pq.Array(vehicleIDs))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var status string
+		if err = rows.Scan(&id, &status); err != nil {
+			tracing.RecordError(span, err)
+			return nil, err
+		}
+		statuses[id] = status
+	}
+	if err = rows.Err(); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	p.logger.WithContext(ctx).Debugf("fetched the statuses of %d of %d vehicles", len(statuses), len(vehicleIDs))
+	return statuses, nil
+}
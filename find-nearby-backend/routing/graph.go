@@ -0,0 +1,263 @@
+package routing
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	earthRadius = 6371008.8
+	// cellSize is the size in degrees of the cells nodes are indexed by for snapping, roughly 220m at the equator
+	cellSize = 0.002
+)
+
+// defaultSpeeds are the speeds in km/h used for the highway types vehicles can drive on when a way has no maxspeed.
+// They sit below typical limits to account for junctions and traffic
+var defaultSpeeds = map[string]float64{
+	"motorway":       80,
+	"motorway_link":  45,
+	"trunk":          65,
+	"trunk_link":     40,
+	"primary":        50,
+	"primary_link":   35,
+	"secondary":      45,
+	"secondary_link": 30,
+	"tertiary":       40,
+	"tertiary_link":  30,
+	"unclassified":   30,
+	"residential":    25,
+	"road":           25,
+	"service":        15,
+	"living_street":  10,
+}
+
+// edge is a directed road segment, stored with the node it leaves from because routes are searched backwards from the origin
+type edge struct {
+	from    int32
+	seconds float32
+	meters  float32
+}
+
+// Graph is a road network held in memory. Nodes are the OSM nodes of drivable ways and every edge is stored
+// with the node it leads to, so a single search from the origin finds the drive time from every node to it
+type Graph struct {
+	lats    []float64
+	lngs    []float64
+	inbound [][]edge
+	cells   map[[2]int32][]int32
+	// segments are the directed edges again, indexed by the cells they pass through for snapping onto the roads
+	segments     []roadSegment
+	segmentCells map[[2]int32][]int32
+}
+
+// roadSegment is a directed edge from one node to another
+type roadSegment struct {
+	from, to int32
+	meters   float64
+}
+
+// Nodes returns the number of nodes in the graph
+func (g *Graph) Nodes() int {
+	return len(g.lats)
+}
+
+// Edges returns the number of directed edges in the graph
+func (g *Graph) Edges() int {
+	return len(g.segments)
+}
+
+// EachSegment calls fn with the end points and the length in meters of every directed edge; two-way roads come twice
+func (g *Graph) EachSegment(fn func(fromLat, fromLng, toLat, toLng, meters float64)) {
+	for _, segment := range g.segments {
+		fn(g.lats[segment.from], g.lngs[segment.from], g.lats[segment.to], g.lngs[segment.to], segment.meters)
+	}
+}
+
+// nearest returns the node closest to a point and the distance to it in meters, or -1 when no node is within maxDistance
+func (g *Graph) nearest(lat, lng, maxDistance float64) (int32, float64) {
+	latCells := int32(math.Ceil(maxDistance / (earthRadius * math.Pi / 180) / cellSize))
+	lngCells := int32(math.Ceil(maxDistance / (earthRadius * math.Pi / 180 * math.Max(math.Cos(lat*math.Pi/180), 0.01)) / cellSize))
+	centre := cellOf(lat, lng)
+	best, bestDistance := int32(-1), maxDistance
+	for y := centre[0] - latCells; y <= centre[0]+latCells; y++ {
+		for x := centre[1] - lngCells; x <= centre[1]+lngCells; x++ {
+			for _, node := range g.cells[[2]int32{y, x}] {
+				if d := distance(lat, lng, g.lats[node], g.lngs[node]); d <= bestDistance {
+					best, bestDistance = node, d
+				}
+			}
+		}
+	}
+	return best, bestDistance
+}
+
+// graphBuilder turns drivable ways into a Graph. Ways are added before their nodes' coordinates are known,
+// which is the order both OSM formats store them in
+type graphBuilder struct {
+	index    map[int64]int32
+	segments []segment
+	lats     []float64
+	lngs     []float64
+	located  []bool
+}
+
+type segment struct {
+	from, to int32
+	speed    float64
+	forward  bool
+	backward bool
+}
+
+func newGraphBuilder() *graphBuilder {
+	return &graphBuilder{index: make(map[int64]int32)}
+}
+
+// addWay adds the segments of a way if vehicles can drive on it, in the directions they may drive in
+func (b *graphBuilder) addWay(nodes []int64, tags map[string]string) {
+	speed, ok := wayspeed(tags)
+	if !ok || len(nodes) < 2 {
+		return
+	}
+	forward, backward := directions(tags)
+	for i := 1; i < len(nodes); i++ {
+		b.segments = append(b.segments, segment{from: b.node(nodes[i-1]), to: b.node(nodes[i]), speed: speed, forward: forward, backward: backward})
+	}
+}
+
+// needs reports whether a node belongs to a way that was added
+func (b *graphBuilder) needs(id int64) bool {
+	_, ok := b.index[id]
+	return ok
+}
+
+// locate sets the coordinates of a node of an added way
+func (b *graphBuilder) locate(id int64, lat, lng float64) {
+	i, ok := b.index[id]
+	if !ok {
+		return
+	}
+	b.lats[i], b.lngs[i], b.located[i] = lat, lng, true
+}
+
+// build links the segments whose nodes were both located; the others run off the edge of the extract
+func (b *graphBuilder) build() *Graph {
+	g := &Graph{lats: b.lats, lngs: b.lngs, inbound: make([][]edge, len(b.lats)), cells: make(map[[2]int32][]int32), segmentCells: make(map[[2]int32][]int32)}
+	linked := make([]bool, len(b.lats))
+	for _, s := range b.segments {
+		if !b.located[s.from] || !b.located[s.to] {
+			continue
+		}
+		meters := distance(b.lats[s.from], b.lngs[s.from], b.lats[s.to], b.lngs[s.to])
+		seconds := meters / (s.speed / 3.6)
+		if s.forward {
+			g.inbound[s.to] = append(g.inbound[s.to], edge{from: s.from, seconds: float32(seconds), meters: float32(meters)})
+			g.addSegment(s.from, s.to, meters)
+		}
+		if s.backward {
+			g.inbound[s.from] = append(g.inbound[s.from], edge{from: s.to, seconds: float32(seconds), meters: float32(meters)})
+			g.addSegment(s.to, s.from, meters)
+		}
+		linked[s.from], linked[s.to] = true, true
+	}
+	for node := range linked {
+		if linked[node] {
+			cell := cellOf(g.lats[node], g.lngs[node])
+			g.cells[cell] = append(g.cells[cell], int32(node))
+		}
+	}
+	return g
+}
+
+// addSegment indexes a directed edge under every cell its bounding box touches
+func (g *Graph) addSegment(from, to int32, meters float64) {
+	i := int32(len(g.segments))
+	g.segments = append(g.segments, roadSegment{from: from, to: to, meters: meters})
+	a, b := cellOf(g.lats[from], g.lngs[from]), cellOf(g.lats[to], g.lngs[to])
+	for y := minInt32(a[0], b[0]); y <= maxInt32(a[0], b[0]); y++ {
+		for x := minInt32(a[1], b[1]); x <= maxInt32(a[1], b[1]); x++ {
+			g.segmentCells[[2]int32{y, x}] = append(g.segmentCells[[2]int32{y, x}], i)
+		}
+	}
+}
+
+func (b *graphBuilder) node(id int64) int32 {
+	if i, ok := b.index[id]; ok {
+		return i
+	}
+	i := int32(len(b.lats))
+	b.index[id] = i
+	b.lats = append(b.lats, 0)
+	b.lngs = append(b.lngs, 0)
+	b.located = append(b.located, false)
+	return i
+}
+
+// wayspeed returns the speed in km/h vehicles drive along a way at, or false if they can't drive on it
+func wayspeed(tags map[string]string) (float64, bool) {
+	speed, ok := defaultSpeeds[tags["highway"]]
+	if !ok || tags["access"] == "no" || tags["motor_vehicle"] == "no" || tags["area"] == "yes" {
+		return 0, false
+	}
+	if maxspeed, ok := parseMaxspeed(tags["maxspeed"]); ok && maxspeed < speed {
+		speed = maxspeed
+	}
+	return speed, true
+}
+
+// parseMaxspeed reads a numeric maxspeed in km/h or mph; values like "signals" or "none" are ignored
+func parseMaxspeed(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	factor := 1.0
+	if strings.HasSuffix(value, "mph") {
+		value, factor = strings.TrimSpace(strings.TrimSuffix(value, "mph")), 1.609344
+	}
+	speed, err := strconv.ParseFloat(value, 64)
+	if err != nil || speed <= 0 {
+		return 0, false
+	}
+	return speed * factor, true
+}
+
+// directions returns whether a way can be driven along and against the order of its nodes
+func directions(tags map[string]string) (bool, bool) {
+	switch tags["oneway"] {
+	case "yes", "true", "1":
+		return true, false
+	case "-1", "reverse":
+		return false, true
+	case "no", "false", "0":
+		return true, true
+	}
+	if tags["junction"] == "roundabout" || tags["highway"] == "motorway" || tags["highway"] == "motorway_link" {
+		return true, false
+	}
+	return true, true
+}
+
+func cellOf(lat, lng float64) [2]int32 {
+	return [2]int32{int32(math.Floor(lat / cellSize)), int32(math.Floor(lng / cellSize))}
+}
+
+// distance is the great-circle distance between two points in meters
+func distance(lat1, lng1, lat2, lng2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func minInt32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,105 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/paulmach/osm"
+	"github.com/paulmach/osm/osmpbf"
+	"github.com/paulmach/osm/osmxml"
+	"github.com/pkg/errors"
+)
+
+// Load builds a Graph from an OSM extract, either .osm.pbf or .osm xml. The file is read twice: first for the
+// drivable ways, then for the coordinates of their nodes, so only the nodes of roads are kept in memory
+func Load(ctx context.Context, path string) (*Graph, error) {
+	open, err := scannerFor(path)
+	if err != nil {
+		return nil, err
+	}
+	b := newGraphBuilder()
+	err = scan(path, func() (osm.Scanner, error) { return open(ctx, false) }, func(o osm.Object) {
+		if way, ok := o.(*osm.Way); ok {
+			nodes := make([]int64, len(way.Nodes))
+			for i, n := range way.Nodes {
+				nodes[i] = int64(n.ID)
+			}
+			b.addWay(nodes, way.Tags.Map())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = scan(path, func() (osm.Scanner, error) { return open(ctx, true) }, func(o osm.Object) {
+		if node, ok := o.(*osm.Node); ok && b.needs(int64(node.ID)) {
+			b.locate(int64(node.ID), node.Lat, node.Lon)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.build(), nil
+}
+
+type openFunc func(ctx context.Context, nodes bool) (osm.Scanner, error)
+
+// scannerFor picks the decoder for the format of path. A pbf scanner skips what the pass doesn't need
+// without decoding it; xml has to be decoded in full on both passes
+func scannerFor(path string) (openFunc, error) {
+	var open openFunc
+	switch {
+	case strings.HasSuffix(path, ".pbf"):
+		open = func(ctx context.Context, nodes bool) (osm.Scanner, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			s := osmpbf.New(ctx, f, runtime.GOMAXPROCS(0))
+			s.SkipNodes, s.SkipWays, s.SkipRelations = !nodes, nodes, true
+			return closingScanner{Scanner: s, file: f}, nil
+		}
+	case strings.HasSuffix(path, ".osm"):
+		open = func(ctx context.Context, nodes bool) (osm.Scanner, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			return closingScanner{Scanner: osmxml.New(ctx, f), file: f}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported road graph file %s; expected .osm.pbf or .osm", path)
+	}
+	return open, nil
+}
+
+func scan(path string, open func() (osm.Scanner, error), fn func(osm.Object)) error {
+	s, err := open()
+	if err != nil {
+		return errors.Wrapf(err, "failed to open the road graph file %s", path)
+	}
+	defer s.Close()
+	for s.Scan() {
+		fn(s.Object())
+	}
+	if err := s.Err(); err != nil {
+		return errors.Wrapf(err, "failed to read the road graph file %s", path)
+	}
+	return nil
+}
+
+// closingScanner closes the file along with the scanner, which leaves its reader open
+type closingScanner struct {
+	osm.Scanner
+	file *os.File
+}
+
+func (s closingScanner) Close() error {
+	err := s.Scanner.Close()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
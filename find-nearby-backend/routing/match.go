@@ -0,0 +1,227 @@
+package routing
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"sort"
+
+	"find-nearby-backend/model"
+)
+
+const (
+	// gpsSigma is the standard deviation, in meters, of GPS fixes around the road the vehicle is on
+	gpsSigma = 10.0
+	// transitionBeta is the scale, in meters, of how much the road distance between consecutive fixes
+	// may exceed or fall short of the straight-line distance between them
+	transitionBeta = 30.0
+	// maxCandidates is the number of closest road segments a fix may be matched to
+	maxCandidates = 5
+)
+
+// Matcher moves GPS fixes onto the road graph. It is safe for concurrent use
+type Matcher struct {
+	graph       *Graph
+	maxDistance float64
+}
+
+// NewMatcher is a constructor for Matcher. Fixes farther than maxDistance meters from every road aren't moved
+func NewMatcher(graph *Graph, maxDistance int) *Matcher {
+	return &Matcher{graph: graph, maxDistance: float64(maxDistance)}
+}
+
+// Match snaps the pings of a single vehicle, oldest first, onto the roads and returns a Snap per ping.
+// A lone ping goes to the closest road. A trajectory is matched as a whole with a hidden Markov model,
+// which keeps consecutive fixes on roads that connect at about the distance between them rather than letting
+// a fix jump to a parallel road it happens to be closer to. A ping with no road nearby splits the trajectory
+func (m *Matcher) Match(ctx context.Context, pings []model.Ping) []model.Snap {
+	snaps := make([]model.Snap, len(pings))
+	var chain [][]matchState
+	start := 0
+	for i, ping := range pings {
+		if ctx.Err() != nil {
+			break
+		}
+		candidates := m.candidates(ping.Latitude, ping.Longitude)
+		if len(candidates) == 0 {
+			m.settle(chain, start, snaps)
+			chain = nil
+			continue
+		}
+		layer := make([]matchState, len(candidates))
+		for j, c := range candidates {
+			layer[j] = matchState{candidate: c, cost: 0.5 * (c.distance / gpsSigma) * (c.distance / gpsSigma), back: -1}
+		}
+		if len(chain) > 0 && !m.link(chain[len(chain)-1], layer, distance(pings[i-1].Latitude, pings[i-1].Longitude, ping.Latitude, ping.Longitude)) {
+			m.settle(chain, start, snaps)
+			chain = nil
+		}
+		if len(chain) == 0 {
+			start = i
+		}
+		chain = append(chain, layer)
+	}
+	m.settle(chain, start, snaps)
+	return snaps
+}
+
+type candidate struct {
+	segment   int32
+	fraction  float64
+	latitude  float64
+	longitude float64
+	distance  float64
+}
+
+type matchState struct {
+	candidate candidate
+	cost      float64
+	back      int
+}
+
+// candidates returns the closest points on the closest road segments within the max distance, closest first
+func (m *Matcher) candidates(lat, lng float64) []candidate {
+	g := m.graph
+	latCells := int32(math.Ceil(m.maxDistance / (earthRadius * math.Pi / 180) / cellSize))
+	lngCells := int32(math.Ceil(m.maxDistance / (earthRadius * math.Pi / 180 * math.Max(math.Cos(lat*math.Pi/180), 0.01)) / cellSize))
+	centre := cellOf(lat, lng)
+	seen := make(map[int32]bool)
+	var candidates []candidate
+	for y := centre[0] - latCells; y <= centre[0]+latCells; y++ {
+		for x := centre[1] - lngCells; x <= centre[1]+lngCells; x++ {
+			for _, i := range g.segmentCells[[2]int32{y, x}] {
+				if seen[i] {
+					continue
+				}
+				seen[i] = true
+				if c := m.project(i, lat, lng); c.distance <= m.maxDistance {
+					candidates = append(candidates, c)
+				}
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+	return candidates
+}
+
+// project returns the point of a segment closest to a point, treating the few meters around it as flat
+func (m *Matcher) project(i int32, lat, lng float64) candidate {
+	g := m.graph
+	s := g.segments[i]
+	kx := math.Cos(lat * math.Pi / 180)
+	ax, ay := (g.lngs[s.from]-lng)*kx, g.lats[s.from]-lat
+	dx, dy := (g.lngs[s.to]-g.lngs[s.from])*kx, g.lats[s.to]-g.lats[s.from]
+	fraction := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		fraction = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+	}
+	pLat := g.lats[s.from] + fraction*(g.lats[s.to]-g.lats[s.from])
+	pLng := g.lngs[s.from] + fraction*(g.lngs[s.to]-g.lngs[s.from])
+	return candidate{segment: i, fraction: fraction, latitude: pLat, longitude: pLng, distance: distance(lat, lng, pLat, pLng)}
+}
+
+// link adds to every state of layer the cheapest way to reach it from the previous layer. States no previous one
+// connects to can't be part of the trajectory; link returns false, leaving layer as it was, when none can
+func (m *Matcher) link(previous, layer []matchState, straight float64) bool {
+	costs := make([]float64, len(layer))
+	backs := make([]int, len(layer))
+	linked := false
+	for j := range layer {
+		costs[j], backs[j] = math.Inf(1), -1
+		road := m.roadDistances(previous, layer[j].candidate, straight)
+		for k, p := range previous {
+			if math.IsInf(road[k], 1) || math.IsInf(p.cost, 1) {
+				continue
+			}
+			if cost := p.cost + math.Abs(road[k]-straight)/transitionBeta; cost < costs[j] {
+				costs[j], backs[j] = cost, k
+			}
+		}
+		linked = linked || backs[j] >= 0
+	}
+	if !linked {
+		return false
+	}
+	for j := range layer {
+		layer[j].cost += costs[j]
+		layer[j].back = backs[j]
+	}
+	return true
+}
+
+// roadDistances returns the distance over the roads from every state of previous to a candidate, or +Inf when it is
+// more than a few times the straight-line distance. Moving back and forth along the same segment is allowed, as a
+// vehicle that stands still jitters in both directions
+func (m *Matcher) roadDistances(previous []matchState, to candidate, straight float64) []float64 {
+	g := m.graph
+	target := g.segments[to.segment]
+	limit := 3*straight + 2*m.maxDistance + 100
+	var toNode map[int32]float64
+	road := make([]float64, len(previous))
+	for k, p := range previous {
+		from := g.segments[p.candidate.segment]
+		if p.candidate.segment == to.segment {
+			road[k] = math.Abs(to.fraction-p.candidate.fraction) * target.meters
+			continue
+		}
+		if toNode == nil {
+			toNode = g.distancesTo(target.from, limit)
+		}
+		d, ok := toNode[from.to]
+		if !ok {
+			road[k] = math.Inf(1)
+			continue
+		}
+		road[k] = (1-p.candidate.fraction)*from.meters + d + to.fraction*target.meters
+	}
+	return road
+}
+
+// settle picks the cheapest way through a chain of layers and stores it in snaps, starting at index start
+func (m *Matcher) settle(chain [][]matchState, start int, snaps []model.Snap) {
+	if len(chain) == 0 {
+		return
+	}
+	last := chain[len(chain)-1]
+	best := 0
+	for j := range last {
+		if last[j].cost < last[best].cost {
+			best = j
+		}
+	}
+	for i := len(chain) - 1; i >= 0 && best >= 0; i-- {
+		c := chain[i][best].candidate
+		snaps[start+i] = model.Snap{Found: true, Latitude: c.latitude, Longitude: c.longitude, Distance: c.distance}
+		best = chain[i][best].back
+	}
+}
+
+// distancesTo returns the road distance in meters from every node within limit to node
+func (g *Graph) distancesTo(node int32, limit float64) map[int32]float64 {
+	distances := map[int32]float64{}
+	labels := map[int32]label{node: {}}
+	queue := &labelQueue{{node: node}}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(queueItem)
+		if _, ok := distances[item.node]; ok {
+			continue
+		}
+		if item.label.meters > limit {
+			break
+		}
+		distances[item.node] = item.label.meters
+		for _, e := range g.inbound[item.node] {
+			// the queue orders by seconds, so a search by distance keeps its meters in both
+			meters := item.label.meters + float64(e.meters)
+			if current, ok := labels[e.from]; ok && current.meters <= meters {
+				continue
+			}
+			labels[e.from] = label{seconds: meters, meters: meters}
+			heap.Push(queue, queueItem{node: e.from, label: labels[e.from]})
+		}
+	}
+	return distances
+}
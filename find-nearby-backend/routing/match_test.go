@@ -0,0 +1,60 @@
+package routing_test
+
+import (
+	"context"
+	"testing"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/routing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch_WhenPingIsAlone_ShouldSnapToTheClosestRoad(t *testing.T) {
+	graph, err := routing.Load(context.Background(), "testdata/river.osm")
+	require.NoError(t, err)
+
+	snaps := routing.NewMatcher(graph, 100).Match(context.Background(), []model.Ping{{VehicleID: 1, Latitude: 1.30018, Longitude: 103.905}})
+	require.Len(t, snaps, 1)
+	assert.True(t, snaps[0].Found)
+	assert.InDelta(t, 1.3000, snaps[0].Latitude, 1e-9)
+	assert.InDelta(t, 103.905, snaps[0].Longitude, 1e-9)
+	assert.InDelta(t, 20, snaps[0].Distance, 0.5)
+}
+
+func TestMatch_WhenTrajectoryRunsAlongARoad_ShouldKeepToIt(t *testing.T) {
+	graph, err := routing.Load(context.Background(), "testdata/river.osm")
+	require.NoError(t, err)
+
+	// the second ping is closer to the north bank, but the south bank road is the only one the vehicle
+	// could have driven the 780m between the pings on
+	snaps := routing.NewMatcher(graph, 100).Match(context.Background(), []model.Ping{
+		{VehicleID: 1, Latitude: 1.30005, Longitude: 103.8960},
+		{VehicleID: 1, Latitude: 1.30055, Longitude: 103.9030},
+	})
+	require.Len(t, snaps, 2)
+	assert.True(t, snaps[1].Found)
+	assert.InDelta(t, 1.3000, snaps[1].Latitude, 1e-9)
+	assert.InDelta(t, 103.9030, snaps[1].Longitude, 1e-9)
+
+	// on its own the same ping goes to the north bank
+	alone := routing.NewMatcher(graph, 100).Match(context.Background(), []model.Ping{{VehicleID: 1, Latitude: 1.30055, Longitude: 103.9030}})
+	assert.InDelta(t, 1.3010, alone[0].Latitude, 1e-9)
+}
+
+func TestMatch_WhenPingIsFarFromRoads_ShouldNotSnapIt(t *testing.T) {
+	graph, err := routing.Load(context.Background(), "testdata/river.osm")
+	require.NoError(t, err)
+
+	snaps := routing.NewMatcher(graph, 30).Match(context.Background(), []model.Ping{
+		{VehicleID: 1, Latitude: 1.30010, Longitude: 103.9050},
+		{VehicleID: 1, Latitude: 1.35, Longitude: 103.95},
+		{VehicleID: 1, Latitude: 1.30010, Longitude: 103.9060},
+	})
+	require.Len(t, snaps, 3)
+	assert.True(t, snaps[0].Found)
+	assert.Equal(t, model.Snap{}, snaps[1])
+	assert.True(t, snaps[2].Found)
+	assert.InDelta(t, 1.3000, snaps[2].Latitude, 1e-9)
+}
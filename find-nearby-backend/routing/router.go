@@ -0,0 +1,97 @@
+package routing
+
+import (
+	"container/heap"
+	"context"
+
+	"find-nearby-backend/model"
+)
+
+// accessSpeed is the speed in m/s the stretch between a point and its nearest graph node is covered at
+const accessSpeed = 15 / 3.6
+
+// Router finds drive times from vehicles to an origin over a Graph. It is safe for concurrent use
+type Router struct {
+	graph           *Graph
+	maxSnapDistance float64
+}
+
+// NewRouter is a constructor for Router. Points farther than maxSnapDistance meters from every node can't be routed
+func NewRouter(graph *Graph, maxSnapDistance int) *Router {
+	return &Router{graph: graph, maxSnapDistance: float64(maxSnapDistance)}
+}
+
+// Routes returns the fastest route from every location to the origin, in the order of locations. Points are snapped
+// to their nearest graph node; the search settles nodes outwards from the origin and stops once every location is reached
+func (r *Router) Routes(ctx context.Context, latitude, longitude float64, locations []model.Location) []model.Route {
+	routes := make([]model.Route, len(locations))
+	origin, originSnap := r.graph.nearest(latitude, longitude, r.maxSnapDistance)
+	if origin < 0 {
+		return routes
+	}
+	targets := make(map[int32][]int)
+	snaps := make([]float64, len(locations))
+	for i, location := range locations {
+		node, snap := r.graph.nearest(location.Latitude, location.Longitude, r.maxSnapDistance)
+		if node < 0 {
+			continue
+		}
+		targets[node] = append(targets[node], i)
+		snaps[i] = snap
+	}
+
+	labels := map[int32]label{origin: {seconds: originSnap / accessSpeed, meters: originSnap}}
+	settled := make(map[int32]bool)
+	queue := &labelQueue{{node: origin, label: labels[origin]}}
+	for queue.Len() > 0 && len(targets) > 0 {
+		if len(settled)%1024 == 0 && ctx.Err() != nil {
+			break
+		}
+		item := heap.Pop(queue).(queueItem)
+		if settled[item.node] {
+			continue
+		}
+		settled[item.node] = true
+		for _, i := range targets[item.node] {
+			routes[i] = model.Route{
+				Found:    true,
+				Duration: item.label.seconds + snaps[i]/accessSpeed,
+				Distance: item.label.meters + snaps[i],
+			}
+		}
+		delete(targets, item.node)
+		for _, e := range r.graph.inbound[item.node] {
+			next := label{seconds: item.label.seconds + float64(e.seconds), meters: item.label.meters + float64(e.meters)}
+			if current, ok := labels[e.from]; ok && current.seconds <= next.seconds {
+				continue
+			}
+			labels[e.from] = next
+			heap.Push(queue, queueItem{node: e.from, label: next})
+		}
+	}
+	return routes
+}
+
+type label struct {
+	seconds float64
+	meters  float64
+}
+
+type queueItem struct {
+	node  int32
+	label label
+}
+
+// labelQueue is a min-heap of nodes by drive time
+type labelQueue []queueItem
+
+func (q labelQueue) Len() int            { return len(q) }
+func (q labelQueue) Less(i, j int) bool  { return q[i].label.seconds < q[j].label.seconds }
+func (q labelQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *labelQueue) Push(x interface{}) { *q = append(*q, x.(queueItem)) }
+func (q *labelQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,59 @@
+package routing_test
+
+import (
+	"context"
+	"testing"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/routing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_ShouldKeepOnlyDrivableWays(t *testing.T) {
+	graph, err := routing.Load(context.Background(), "testdata/river.osm")
+	require.NoError(t, err)
+	// the footway and its node 7 are left out; the oneway way has a single edge
+	assert.Equal(t, 6, graph.Nodes())
+	assert.Equal(t, 9, graph.Edges())
+}
+
+func TestLoad_WhenFormatIsUnsupported_ShouldReturnError(t *testing.T) {
+	_, err := routing.Load(context.Background(), "testdata/river.geojson")
+	assert.EqualError(t, err, "unsupported road graph file testdata/river.geojson; expected .osm.pbf or .osm")
+}
+
+func TestRoutes_ShouldFollowTheRoads(t *testing.T) {
+	graph, err := routing.Load(context.Background(), "testdata/river.osm")
+	require.NoError(t, err)
+	router := routing.NewRouter(graph, 50)
+
+	routes := router.Routes(context.Background(), 1.3000, 103.9000, []model.Location{
+		{VehicleID: 1, Latitude: 1.3010, Longitude: 103.9000},
+		{VehicleID: 2, Latitude: 1.3000, Longitude: 103.8950},
+		{VehicleID: 3, Latitude: 1.2990, Longitude: 103.9000},
+		{VehicleID: 4, Latitude: 1.3500, Longitude: 103.9500},
+	})
+	require.Len(t, routes, 4)
+
+	// vehicle 1 is 111m away across the river but has to drive over the bridge
+	assert.True(t, routes[0].Found)
+	assert.InDelta(t, 2335, routes[0].Distance, 5)
+	assert.True(t, routes[1].Found)
+	assert.InDelta(t, 556, routes[1].Distance, 2)
+	assert.InDelta(t, 556/(25/3.6), routes[1].Duration, 1)
+	assert.Less(t, routes[1].Duration, routes[0].Duration)
+	// vehicle 3 can only drive away from the origin along the oneway street
+	assert.Equal(t, model.Route{}, routes[2])
+	// vehicle 4 is nowhere near the road graph
+	assert.Equal(t, model.Route{}, routes[3])
+}
+
+func TestRoutes_WhenOriginIsOffTheGraph_ShouldFindNoRoutes(t *testing.T) {
+	graph, err := routing.Load(context.Background(), "testdata/river.osm")
+	require.NoError(t, err)
+
+	routes := routing.NewRouter(graph, 50).Routes(context.Background(), 1.4, 104.0, []model.Location{{VehicleID: 1, Latitude: 1.3, Longitude: 103.9}})
+	assert.Equal(t, []model.Route{{}}, routes)
+}
@@ -0,0 +1,254 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"find-nearby-backend/model"
+)
+
+// How the vehicles of a synthetic fleet are spread over its area
+const (
+	DistributionUniform   = "uniform"
+	DistributionClustered = "clustered"
+	DistributionRoads     = "roads"
+)
+
+// maxTries bounds how many points are drawn for a vehicle before giving up on an area that is too small to hit
+const maxTries = 10000
+
+const metersPerDegree = 111320.0
+
+// Share is a value and how often it comes up relative to the other shares
+type Share struct {
+	Value  string
+	Weight float64
+}
+
+// ParseShares reads shares from a list like scooter:70,bike:20,car:10
+func ParseShares(list string) ([]Share, error) {
+	var shares []Share
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid share %q; shares must look like value:weight", entry)
+		}
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || weight < 0 || math.IsInf(weight, 0) {
+			return nil, fmt.Errorf("invalid share %q; the weight must be a non-negative number", entry)
+		}
+		shares = append(shares, Share{Value: parts[0], Weight: weight})
+	}
+	if len(shares) == 0 {
+		return nil, errors.New("no shares given")
+	}
+	return shares, nil
+}
+
+// Road is a segment of a road between two points, Meters long
+type Road struct {
+	FromLatitude, FromLongitude float64
+	ToLatitude, ToLongitude     float64
+	Meters                      float64
+}
+
+// Fleet describes a synthetic fleet of Count vehicles within Area. Uniform fleets are spread evenly over the area,
+// clustered ones around Hotspots hot spots of different sizes, each Spread meters across (one standard deviation),
+// and road fleets along Roads. Types and Statuses are drawn from their shares. The same Seed generates the same fleet
+type Fleet struct {
+	Count        int
+	Area         *model.Area
+	Distribution string
+	Hotspots     int
+	Spread       float64
+	Roads        []Road
+	Types        []Share
+	Statuses     []Share
+	Seed         int64
+}
+
+// Vehicle is a generated vehicle and its position
+type Vehicle struct {
+	ID        int64
+	Type      string
+	Status    string
+	Latitude  float64
+	Longitude float64
+}
+
+// Generator generates the vehicles of a fleet one at a time, so that a fleet of millions never has to fit in memory
+type Generator struct {
+	fleet                      Fleet
+	rnd                        *rand.Rand
+	minLatitude, maxLatitude   float64
+	minLongitude, maxLongitude float64
+	types, statuses            []float64
+	hotspots                   [][2]float64
+	hotspotWeights             []float64
+	roads                      []Road
+	roadLengths                []float64
+	next                       int
+}
+
+// NewGenerator checks fleet and returns a generator of its vehicles
+func NewGenerator(fleet Fleet) (*Generator, error) {
+	if fleet.Count < 0 {
+		return nil, fmt.Errorf("invalid count: %d; count must not be negative", fleet.Count)
+	}
+	if fleet.Area == nil {
+		return nil, errors.New("a fleet needs an area")
+	}
+	g := &Generator{fleet: fleet, rnd: rand.New(rand.NewSource(fleet.Seed))}
+	g.minLatitude, g.minLongitude, g.maxLatitude, g.maxLongitude = fleet.Area.Bounds()
+	var err error
+	if g.types, err = cumulative(fleet.Types); err != nil {
+		return nil, fmt.Errorf("invalid types: %v", err)
+	}
+	if g.statuses, err = cumulative(fleet.Statuses); err != nil {
+		return nil, fmt.Errorf("invalid statuses: %v", err)
+	}
+	for _, status := range fleet.Statuses {
+		switch status.Value {
+		case model.VehicleStatusAvailable, model.VehicleStatusBusy, model.VehicleStatusOffline, model.VehicleStatusMaintenance:
+		default:
+			return nil, fmt.Errorf("invalid status: %s; status must be one of %s, %s, %s or %s", status.Value,
+				model.VehicleStatusAvailable, model.VehicleStatusBusy, model.VehicleStatusOffline, model.VehicleStatusMaintenance)
+		}
+	}
+
+	switch fleet.Distribution {
+	case DistributionUniform:
+	case DistributionClustered:
+		if fleet.Hotspots < 1 || fleet.Spread <= 0 {
+			return nil, errors.New("a clustered fleet needs at least one hot spot and a positive spread")
+		}
+		for i := 0; i < fleet.Hotspots; i++ {
+			lat, lng, err := g.uniform()
+			if err != nil {
+				return nil, err
+			}
+			g.hotspots = append(g.hotspots, [2]float64{lat, lng})
+			// a few hot spots take most of the fleet, like a downtown next to quieter neighbourhoods
+			g.hotspotWeights = append(g.hotspotWeights, g.rnd.ExpFloat64())
+		}
+		g.hotspotWeights = accumulate(g.hotspotWeights)
+	case DistributionRoads:
+		var lengths []float64
+		for _, road := range fleet.Roads {
+			if road.Meters > 0 && fleet.Area.Contains((road.FromLatitude+road.ToLatitude)/2, (road.FromLongitude+road.ToLongitude)/2) {
+				g.roads = append(g.roads, road)
+				lengths = append(lengths, road.Meters)
+			}
+		}
+		if len(g.roads) == 0 {
+			return nil, errors.New("there are no roads within the area")
+		}
+		g.roadLengths = accumulate(lengths)
+	default:
+		return nil, fmt.Errorf("invalid distribution: %s; distribution must be %s, %s or %s", fleet.Distribution, DistributionUniform, DistributionClustered, DistributionRoads)
+	}
+	return g, nil
+}
+
+// More reports whether there are vehicles left to generate
+func (g *Generator) More() bool {
+	return g.next < g.fleet.Count
+}
+
+// Next generates the next vehicle. IDs start at 1
+func (g *Generator) Next() (Vehicle, error) {
+	var lat, lng float64
+	var err error
+	switch g.fleet.Distribution {
+	case DistributionClustered:
+		lat, lng, err = g.clustered()
+	case DistributionRoads:
+		lat, lng = g.onRoad()
+	default:
+		lat, lng, err = g.uniform()
+	}
+	if err != nil {
+		return Vehicle{}, err
+	}
+	g.next++
+	return Vehicle{
+		ID:        int64(g.next),
+		Type:      g.fleet.Types[g.pick(g.types)].Value,
+		Status:    g.fleet.Statuses[g.pick(g.statuses)].Value,
+		Latitude:  lat,
+		Longitude: lng,
+	}, nil
+}
+
+// uniform draws points within the bounding box of the area until one is inside the area itself
+func (g *Generator) uniform() (float64, float64, error) {
+	for i := 0; i < maxTries; i++ {
+		lat := g.minLatitude + g.rnd.Float64()*(g.maxLatitude-g.minLatitude)
+		lng := g.minLongitude + g.rnd.Float64()*(g.maxLongitude-g.minLongitude)
+		if g.fleet.Area.Contains(lat, lng) {
+			return lat, lng, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no point within the area after %d tries; is it a polygon with an area?", maxTries)
+}
+
+// clustered draws a point around a hot spot picked by its weight, normally distributed, until one is inside the area
+func (g *Generator) clustered() (float64, float64, error) {
+	hotspot := g.hotspots[g.pick(g.hotspotWeights)]
+	for i := 0; i < maxTries; i++ {
+		lat := hotspot[0] + g.rnd.NormFloat64()*g.fleet.Spread/metersPerDegree
+		lng := hotspot[1] + g.rnd.NormFloat64()*g.fleet.Spread/(metersPerDegree*math.Cos(hotspot[0]*math.Pi/180))
+		if g.fleet.Area.Contains(lat, lng) {
+			return lat, lng, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no point within the area around the hot spot at %f,%f after %d tries", hotspot[0], hotspot[1], maxTries)
+}
+
+// onRoad picks a road by its length and a point along it, so every meter of road is as likely as any other
+func (g *Generator) onRoad() (float64, float64) {
+	road := g.roads[g.pick(g.roadLengths)]
+	t := g.rnd.Float64()
+	return road.FromLatitude + t*(road.ToLatitude-road.FromLatitude), road.FromLongitude + t*(road.ToLongitude-road.FromLongitude)
+}
+
+// pick returns the index of a weight drawn from accumulated weights
+func (g *Generator) pick(accumulated []float64) int {
+	i := sort.SearchFloat64s(accumulated, g.rnd.Float64()*accumulated[len(accumulated)-1])
+	if i == len(accumulated) {
+		i--
+	}
+	return i
+}
+
+func cumulative(shares []Share) ([]float64, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares given")
+	}
+	weights := make([]float64, len(shares))
+	for i, share := range shares {
+		weights[i] = share.Weight
+	}
+	accumulated := accumulate(weights)
+	if accumulated[len(accumulated)-1] <= 0 {
+		return nil, errors.New("the weights add up to 0")
+	}
+	return accumulated, nil
+}
+
+// accumulate replaces every weight with the sum of the weights up to it
+func accumulate(weights []float64) []float64 {
+	for i := 1; i < len(weights); i++ {
+		weights[i] += weights[i-1]
+	}
+	return weights
+}
@@ -0,0 +1,98 @@
+package seed_test
+
+import (
+	"testing"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/seed"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFleet(distribution string) seed.Fleet {
+	return seed.Fleet{
+		Count:        2000,
+		Area:         model.NewBoxArea(-0.2, 51.45, 0.0, 51.55),
+		Distribution: distribution,
+		Hotspots:     5,
+		Spread:       300,
+		Roads: []seed.Road{
+			{FromLatitude: 51.5, FromLongitude: -0.15, ToLatitude: 51.5, ToLongitude: -0.05, Meters: 6900},
+			{FromLatitude: 51.6, FromLongitude: -0.15, ToLatitude: 51.7, ToLongitude: -0.05, Meters: 12000},
+		},
+		Types:    []seed.Share{{Value: "scooter", Weight: 3}, {Value: "car", Weight: 1}},
+		Statuses: []seed.Share{{Value: model.VehicleStatusAvailable, Weight: 1}},
+		Seed:     42,
+	}
+}
+
+func generate(t *testing.T, fleet seed.Fleet) []seed.Vehicle {
+	generator, err := seed.NewGenerator(fleet)
+	require.NoError(t, err)
+	var vehicles []seed.Vehicle
+	for generator.More() {
+		vehicle, err := generator.Next()
+		require.NoError(t, err)
+		vehicles = append(vehicles, vehicle)
+	}
+	return vehicles
+}
+
+func TestGenerator_WhenSeedIsTheSame_ShouldGenerateTheSameFleet(t *testing.T) {
+	fleet := testFleet(seed.DistributionClustered)
+	first := generate(t, fleet)
+	assert.Equal(t, first, generate(t, fleet))
+
+	fleet.Seed = 43
+	assert.NotEqual(t, first, generate(t, fleet))
+}
+
+func TestGenerator_ShouldKeepEveryDistributionWithinTheArea(t *testing.T) {
+	for _, distribution := range []string{seed.DistributionUniform, seed.DistributionClustered, seed.DistributionRoads} {
+		fleet := testFleet(distribution)
+		vehicles := generate(t, fleet)
+		assert.Len(t, vehicles, fleet.Count, distribution)
+		for i, vehicle := range vehicles {
+			assert.Equal(t, int64(i+1), vehicle.ID)
+			assert.True(t, fleet.Area.Contains(vehicle.Latitude, vehicle.Longitude), "%s vehicle at %f,%f", distribution, vehicle.Latitude, vehicle.Longitude)
+		}
+	}
+}
+
+func TestGenerator_ShouldDrawTypesByTheirShares(t *testing.T) {
+	scooters := 0
+	for _, vehicle := range generate(t, testFleet(seed.DistributionUniform)) {
+		if vehicle.Type == "scooter" {
+			scooters++
+		}
+	}
+	assert.InDelta(t, 1500, scooters, 100)
+}
+
+func TestNewGenerator_WhenFleetIsInvalid_ShouldReturnError(t *testing.T) {
+	fleet := testFleet("scattered")
+	_, err := seed.NewGenerator(fleet)
+	assert.EqualError(t, err, "invalid distribution: scattered; distribution must be uniform, clustered or roads")
+
+	fleet = testFleet(seed.DistributionUniform)
+	fleet.Statuses = []seed.Share{{Value: "parked", Weight: 1}}
+	_, err = seed.NewGenerator(fleet)
+	assert.EqualError(t, err, "invalid status: parked; status must be one of available, busy, offline or maintenance")
+
+	fleet = testFleet(seed.DistributionRoads)
+	fleet.Area = model.NewBoxArea(100, 1, 101, 2)
+	_, err = seed.NewGenerator(fleet)
+	assert.EqualError(t, err, "there are no roads within the area")
+}
+
+func TestParseShares(t *testing.T) {
+	shares, err := seed.ParseShares("scooter:70, bike:20.5,car:0")
+	assert.NoError(t, err)
+	assert.Equal(t, []seed.Share{{Value: "scooter", Weight: 70}, {Value: "bike", Weight: 20.5}, {Value: "car", Weight: 0}}, shares)
+
+	_, err = seed.ParseShares("scooter:lots")
+	assert.EqualError(t, err, `invalid share "scooter:lots"; the weight must be a non-negative number`)
+	_, err = seed.ParseShares(" , ")
+	assert.EqualError(t, err, "no shares given")
+}
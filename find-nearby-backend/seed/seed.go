@@ -3,66 +3,184 @@ package seed
 import (
 	"find-nearby-backend/config"
 	"find-nearby-backend/database"
+	"find-nearby-backend/database/migrations"
 	"find-nearby-backend/logger"
 	"find-nearby-backend/model"
 
 	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/golang-migrate/migrate"
-	_ "github.com/golang-migrate/migrate/database/postgres" // required
-	_ "github.com/golang-migrate/migrate/source/file"       // required
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// EnvironmentSetting is the database setting that marks a database as production, with
+// ALTER DATABASE find_nearby SET find_nearby.environment = 'production'
+const EnvironmentSetting = "find_nearby.environment"
+
+const productionEnvironment = "production"
+
+// csvVehicleType is the type of the vehicles of seed/locations.csv, the default of the vehicles table
+const csvVehicleType = "scooter"
+
+// ErrProduction is returned when the database is marked as production and seeding isn't forced
+var ErrProduction = errors.New("the database is marked as production; pass --force to seed it anyway")
+
+// Options decide how seeding treats the vehicles already stored. By default vehicles that exist are skipped, so
+// that seeding twice changes nothing. Upsert overwrites them instead, and Truncate removes every vehicle, location,
+// location history point, anomaly and reservation first. DryRun does everything in a transaction that is rolled back, and doesn't migrate.
+// Force seeds a database marked as production
+type Options struct {
+	Truncate bool
+	Upsert   bool
+	DryRun   bool
+	Force    bool
+}
+
+// Report counts the vehicles a seed inserted, updated and skipped, or would have with DryRun
+type Report struct {
+	Inserted int
+	Updated  int
+	Skipped  int
+}
+
 type Seed struct {
-	db          *sqlx.DB
+	logger      logger.Logger
+	db          *database.Cluster
 	dbMigration *migrate.Migrate
+	options     Options
 }
 
-func NewSeed(cfg config.Config) *Seed {
+// NewSeed connects to the database of cfg. The connection is only checked once seeding starts
+func NewSeed(cfg config.Config, options Options) (*Seed, error) {
+	if options.Truncate && options.Upsert {
+		return nil, errors.New("truncate and upsert can't be combined; after a truncate there is nothing to upsert")
+	}
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
-	db, _ := database.New(cfg, log)
-	m, err := migrate.New("file://./database/migrations", cfg.DatabaseConnectionURL())
+	cluster, err := database.New(cfg, log)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("failed to connect to the database: %v", err)
+	}
+	m, err := migrations.New(cfg.DatabaseConnectionURL())
+	if err != nil {
+		cluster.Close()
+		return nil, fmt.Errorf("failed to set up the migrations: %v", err)
 	}
 	return &Seed{
-		db:          db,
+		logger:      log,
+		db:          cluster,
 		dbMigration: m,
-	}
+		options:     options,
+	}, nil
 }
 
-func (s *Seed) Generate() error {
-	err := s.migrateDB(true)
-	if err != nil {
+// Close closes the connections of the seed
+func (s *Seed) Close() error {
+	srcErr, dbErr := s.dbMigration.Close()
+	if err := s.db.Close(); err != nil {
 		return err
 	}
-	var generatedLocations []model.Location
+	if srcErr != nil {
+		return srcErr
+	}
+	return dbErr
+}
 
+// Generate seeds the Singapore locations of seed/locations.csv, one vehicle per line, with the line number as its ID
+func (s *Seed) Generate() (Report, error) {
 	f, err := os.Open("./seed/locations.csv")
 	if err != nil {
-		return err
+		return Report{}, err
 	}
 	defer f.Close()
-	lines, err := csv.NewReader(f).ReadAll()
+	vehicles, err := readLocations(f)
 	if err != nil {
+		return Report{}, err
+	}
+	var report Report
+	err = s.inTx(func(tx *sqlx.Tx) error {
+		return s.insertVehicles(tx, vehicles, "", &report)
+	})
+	return report, err
+}
+
+// GenerateFleet inserts the vehicles of a synthetic fleet in the given city, batchSize at a time, and calls progress
+// with the number of vehicles seeded so far after every batch. The fleet is seeded in a single transaction, so that
+// a seed that fails leaves nothing behind
+func (s *Seed) GenerateFleet(generator *Generator, city string, batchSize int, progress func(seeded int)) (Report, error) {
+	var report Report
+	err := s.inTx(func(tx *sqlx.Tx) error {
+		seeded := 0
+		batch := make([]Vehicle, 0, batchSize)
+		for generator.More() {
+			vehicle, err := generator.Next()
+			if err != nil {
+				return err
+			}
+			batch = append(batch, vehicle)
+			if len(batch) == batchSize || !generator.More() {
+				if err = s.insertVehicles(tx, batch, city, &report); err != nil {
+					return err
+				}
+				seeded += len(batch)
+				progress(seeded)
+				batch = batch[:0]
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+// inTx checks the database may be seeded, migrates it and runs fn in a transaction, which is rolled back on a dry run
+func (s *Seed) inTx(fn func(tx *sqlx.Tx) error) error {
+	if err := s.checkEnvironment(); err != nil {
 		return err
 	}
-	for i, line := range lines {
-		strs := strings.Split(line[0], " ")
-		lat, _ := strconv.ParseFloat(strs[0], 64)
-		lng, _ := strconv.ParseFloat(strs[1], 64)
-		loc := model.Location{
-			VehicleID: int64(i),
-			Latitude:  lat,
-			Longitude: lng,
+	if !s.options.DryRun {
+		if err := s.migrateDB(true); err != nil {
+			return fmt.Errorf("failed to migrate the database: %v", err)
+		}
+	}
+	tx, err := s.db.Primary().Beginx()
+	if err != nil {
+		return err
+	}
+	if s.options.Truncate {
+		if _, err = tx.Exec(`TRUNCATE locations, location_history, history_watermarks, anomalies, reservations, vehicles`); err != nil {
+			_ = tx.Rollback()
+			return err
 		}
-		generatedLocations = append(generatedLocations, loc)
 	}
-	return s.insertLocations(generatedLocations)
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if s.options.DryRun {
+		return tx.Rollback()
+	}
+	return tx.Commit()
+}
+
+func (s *Seed) checkEnvironment() error {
+	var environment string
+	if err := s.db.Primary().Get(&environment, `SELECT coalesce(current_setting($1, true), '')`, EnvironmentSetting); err != nil {
+		return fmt.Errorf("failed to check the environment of the database: %v", err)
+	}
+	if environment != productionEnvironment {
+		return nil
+	}
+	if !s.options.Force {
+		return ErrProduction
+	}
+	s.logger.Warnf("seeding a database marked as production")
+	return nil
 }
 
 func (s *Seed) migrateDB(up bool) error {
@@ -78,13 +196,83 @@ func (s *Seed) migrateDB(up bool) error {
 	return nil
 }
 
-func (s *Seed) insertLocations(locations []model.Location) error {
-	query := `INSERT INTO locations (vehicle_id, location) VALUES ($1, st_setsrid(st_makepoint($2, $3), 4326))`
-	for i := 0; i < len(locations); i++ {
-		_, err := s.db.Exec(query, locations[i].VehicleID, locations[i].Longitude, locations[i].Latitude)
+// readLocations reads lines of a latitude and a longitude separated by a space
+func readLocations(r io.Reader) ([]Vehicle, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 1
+	var vehicles []Vehicle
+	for line := 1; ; line++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return vehicles, nil
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
+		coordinates := strings.Fields(fields[0])
+		if len(coordinates) != 2 {
+			return nil, fmt.Errorf("line %d: expected a latitude and a longitude separated by a space, got %q", line, fields[0])
+		}
+		lat, err := strconv.ParseFloat(coordinates[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid latitude %q", line, coordinates[0])
+		}
+		lng, err := strconv.ParseFloat(coordinates[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid longitude %q", line, coordinates[1])
+		}
+		// vehicles are numbered from 0, as they always have been, so reseeding keeps their IDs
+		vehicles = append(vehicles, Vehicle{
+			ID:        int64(line - 1),
+			Type:      csvVehicleType,
+			Status:    model.VehicleStatusAvailable,
+			Latitude:  lat,
+			Longitude: lng,
+		})
+	}
+}
+
+// insertVehicles inserts vehicles and their locations in a single statement, and counts them in report. Vehicles
+// that exist are skipped, or overwritten with Upsert
+func (s *Seed) insertVehicles(tx *sqlx.Tx, vehicles []Vehicle, city string, report *Report) error {
+	n := len(vehicles)
+	ids := make([]int64, n)
+	types, statuses := make([]string, n), make([]string, n)
+	latitudes, longitudes := make([]float64, n), make([]float64, n)
+	for i, vehicle := range vehicles {
+		ids[i], types[i], statuses[i] = vehicle.ID, vehicle.Type, vehicle.Status
+		latitudes[i], longitudes[i] = vehicle.Latitude, vehicle.Longitude
+	}
+	onConflict := `ON CONFLICT DO NOTHING`
+	if s.options.Upsert {
+		onConflict = `ON CONFLICT (id) DO UPDATE SET type = EXCLUDED.type, city = EXCLUDED.city, status = EXCLUDED.status, updated_at = now()`
+	}
+	// xmax is 0 for a row this statement inserted rather than updated
+	query := `WITH fleet AS (
+				SELECT * FROM unnest($1::int8[], $2::text[], $3::text[], $4::float8[], $5::float8[]) AS f(id, type, status, lat, lng)
+			), vehicle AS (
+				INSERT INTO vehicles (id, type, city, status) SELECT id, type, $6, status FROM fleet
+				` + onConflict + `
+				RETURNING id, xmax = 0 AS inserted
+			), location AS (
+				INSERT INTO locations (vehicle_id, location, raw_location)
+				SELECT fleet.id, st_setsrid(st_makepoint(lng, lat), 4326), st_setsrid(st_makepoint(lng, lat), 4326)
+				FROM fleet JOIN vehicle ON vehicle.id = fleet.id
+				ON CONFLICT (vehicle_id) DO UPDATE SET
+				location = EXCLUDED.location,
+				raw_location = EXCLUDED.raw_location,
+				snapped_location = NULL,
+				snapped = FALSE,
+				recorded_at = NULL
+			)
+			SELECT count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted) FROM vehicle`
+	var inserted, updated int
+	err := tx.QueryRow(query, pq.Array(ids), pq.Array(types), pq.Array(statuses), pq.Array(latitudes), pq.Array(longitudes), city).Scan(&inserted, &updated)
+	if err != nil {
+		return err
 	}
+	report.Inserted += inserted
+	report.Updated += updated
+	report.Skipped += n - inserted - updated
 	return nil
 }
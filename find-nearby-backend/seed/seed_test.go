@@ -0,0 +1,16 @@
+package seed_test
+
+import (
+	"testing"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/seed"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSeed_WhenTruncateAndUpsertAreCombined_ShouldReturnError(t *testing.T) {
+	s, err := seed.NewSeed(config.LoadConfig(), seed.Options{Truncate: true, Upsert: true})
+	assert.Nil(t, s)
+	assert.EqualError(t, err, "truncate and upsert can't be combined; after a truncate there is nothing to upsert")
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/tracing"
+	"find-nearby-backend/usecase"
+
+	"github.com/labstack/echo"
+	pkgerrors "github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultAnomalyLimit = 50
+	maxAnomalyLimit     = 500
+)
+
+// AnomalyHandler serves the anomalies found on ingest for review
+type AnomalyHandler struct {
+	logger         logger.Logger
+	anomalyUsecase usecase.AnomalyUsecase
+	privacyPolicy  *privacy.Policy
+}
+
+// NewAnomalyHandler is a constructor for AnomalyHandler. privacyPolicy resolves the vehicle tokens callers filter by
+func NewAnomalyHandler(logger logger.Logger, anomalyUsecase usecase.AnomalyUsecase, privacyPolicy *privacy.Policy) *AnomalyHandler {
+	return &AnomalyHandler{logger: logger, anomalyUsecase: anomalyUsecase, privacyPolicy: privacyPolicy}
+}
+
+// FindAnomalies returns anomalies, newest first. vehicle_id (or vehicle_token), kind and reviewed filter them; limit
+// defaults to 50 and can't exceed 500, and before_id pages through them
+func (h *AnomalyHandler) FindAnomalies(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "AnomalyHandler.FindAnomalies")
+	defer span.End()
+
+	filter, err := h.anomalyFilter(c)
+	if err != nil {
+		return h.respondError(c, span, http.StatusBadRequest, err)
+	}
+	anomalies, err := h.anomalyUsecase.FindAnomalies(ctx, filter)
+	if err != nil {
+		return h.respondError(c, span, http.StatusInternalServerError, err)
+	}
+	auditResults(c, len(anomalies))
+	return c.JSON(http.StatusOK, AnomaliesResponse{Data: anomalies, Success: true, Error: ErrorResponse{}})
+}
+
+// Review marks an anomaly as reviewed by the caller, identified by its API key the way the audit log does, so that
+// a review can't be recorded in someone else's name
+func (h *AnomalyHandler) Review(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "AnomalyHandler.Review")
+	defer span.End()
+
+	reviewer := callerFingerprint(c.Request().Header.Get(HeaderAPIKey))
+	if reviewer == "" {
+		return h.respondError(c, span, http.StatusUnauthorized, fmt.Errorf("anomalies are reviewed by API key; send one in %s", HeaderAPIKey))
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return h.respondError(c, span, http.StatusBadRequest, fmt.Errorf("invalid anomaly id: %s", c.Param("id")))
+	}
+	anomaly, err := h.anomalyUsecase.Review(ctx, id, reviewer)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if pkgerrors.Cause(err) == repository.ErrAnomalyNotFound {
+			status = http.StatusNotFound
+		}
+		return h.respondError(c, span, status, err)
+	}
+	auditParams(c, vehicleParam(anomaly.VehicleID, anomaly.VehicleToken))
+	auditResults(c, 1)
+	return c.JSON(http.StatusOK, AnomalyResponse{Data: &anomaly, Success: true, Error: ErrorResponse{}})
+}
+
+func (h *AnomalyHandler) anomalyFilter(c echo.Context) (model.AnomalyFilter, error) {
+	filter := model.AnomalyFilter{Kind: c.QueryParam("kind"), Limit: defaultAnomalyLimit}
+	switch filter.Kind {
+	case "", model.AnomalyKindSpeed, model.AnomalyKindNullIsland, model.AnomalyKindOutOfArea:
+	default:
+		return model.AnomalyFilter{}, fmt.Errorf("invalid kind: %s; kind must be one of %s, %s or %s", filter.Kind, model.AnomalyKindSpeed, model.AnomalyKindNullIsland, model.AnomalyKindOutOfArea)
+	}
+	var err error
+	if filter.VehicleID, err = positiveParam(c, "vehicle_id"); err != nil {
+		return model.AnomalyFilter{}, err
+	}
+	if token := c.QueryParam("vehicle_token"); token != "" {
+		if filter.VehicleID != 0 {
+			return model.AnomalyFilter{}, errors.New("vehicle_id and vehicle_token are mutually exclusive")
+		}
+		if filter.VehicleID, err = h.privacyPolicy.ResolveToken(token, time.Now()); err != nil {
+			return model.AnomalyFilter{}, fmt.Errorf("invalid vehicle_token: %v", err)
+		}
+	}
+	if filter.BeforeID, err = positiveParam(c, "before_id"); err != nil {
+		return model.AnomalyFilter{}, err
+	}
+	if value := c.QueryParam("reviewed"); value != "" {
+		reviewed, err := strconv.ParseBool(value)
+		if err != nil {
+			return model.AnomalyFilter{}, fmt.Errorf("invalid reviewed: %s; reviewed must be true or false", value)
+		}
+		filter.Reviewed = &reviewed
+	}
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAnomalyLimit {
+			return model.AnomalyFilter{}, fmt.Errorf("invalid limit: %s; limit must be between 1 and %d", value, maxAnomalyLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// positiveParam returns the value of an optional query param that must be a positive integer, or 0 when it isn't given
+func positiveParam(c echo.Context, name string) (int64, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s: %s; %s must be a positive integer", name, value, name)
+	}
+	return n, nil
+}
+
+func (h *AnomalyHandler) respondError(c echo.Context, span trace.Span, status int, err error) error {
+	tracing.RecordError(span, err)
+	if status == http.StatusInternalServerError {
+		h.logger.WithContext(c.Request().Context()).Errorf("failed to handle the anomaly request, err: %s", err.Error())
+	} else {
+		h.logger.WithContext(c.Request().Context()).Debugf("rejected the anomaly request, err: %s", err.Error())
+	}
+	return c.JSON(status, AnomalyResponse{
+		Data:    nil,
+		Success: false,
+		Error: ErrorResponse{
+			Code:    strconv.Itoa(status),
+			Message: err.Error(),
+		},
+	})
+}
@@ -0,0 +1,109 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/server"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAnomalyHandler_FindAnomalies_ShouldPassTheFilter(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(echo.GET, "/anomalies?vehicle_id=7&kind=speed&reviewed=false&before_id=100&limit=10", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reviewed := false
+	speed, maxSpeed := 5400.0, 60.0
+	expected := []model.Anomaly{{ID: 99, VehicleID: 7, Kind: model.AnomalyKindSpeed, Action: model.AnomalyActionRejected, Speed: &speed, MaxSpeed: &maxSpeed}}
+	anomalyUsecaseMock := new(usecaseMocks.AnomalyUsecase)
+	anomalyUsecaseMock.On("FindAnomalies", mock.Anything, model.AnomalyFilter{VehicleID: 7, Kind: model.AnomalyKindSpeed, Reviewed: &reviewed, BeforeID: 100, Limit: 10}).Return(expected, nil)
+	server.NewAnomalyHandler(log, anomalyUsecaseMock, privacy.NewPolicy(cfg)).FindAnomalies(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.AnomaliesResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, server.AnomaliesResponse{Data: expected, Success: true}, resp)
+	anomalyUsecaseMock.AssertExpectations(t)
+}
+
+func TestAnomalyHandler_FindAnomalies_WhenParamsAreInvalid_ShouldReturn400(t *testing.T) {
+	cases := map[string]string{
+		"kind=teleport":      "invalid kind: teleport",
+		"vehicle_id=-1":      "invalid vehicle_id: -1",
+		"reviewed=sometimes": "invalid reviewed: sometimes",
+		"limit=501":          "invalid limit: 501; limit must be between 1 and 500",
+	}
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+	for query, message := range cases {
+		e := echo.New()
+		req := httptest.NewRequest(echo.GET, "/anomalies?"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		anomalyUsecaseMock := new(usecaseMocks.AnomalyUsecase)
+		server.NewAnomalyHandler(log, anomalyUsecaseMock, privacy.NewPolicy(cfg)).FindAnomalies(c)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+
+		resp := server.AnomaliesResponse{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Contains(t, resp.Error.Message, message, query)
+	}
+}
+
+func TestAnomalyHandler_Review_WhenAnomalyIsMissing_ShouldReturn404(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/anomalies/5/review", strings.NewReader(`{"reviewer": "someone-else"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(server.HeaderAPIKey, "ops-key")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("5")
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	anomalyUsecaseMock := new(usecaseMocks.AnomalyUsecase)
+	anomalyUsecaseMock.On("Review", mock.Anything, int64(5), holder("ops-key")).Return(model.Anomaly{}, errors.Wrap(repository.ErrAnomalyNotFound, "failed to review anomaly 5"))
+	server.NewAnomalyHandler(log, anomalyUsecaseMock, privacy.NewPolicy(cfg)).Review(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	resp := server.AnomalyResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "failed to review anomaly 5: anomaly not found", resp.Error.Message)
+}
+
+func TestAnomalyHandler_Review_WhenAPIKeyIsMissing_ShouldReturn401(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/anomalies/5/review", strings.NewReader(`{"reviewer": "ops-1"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("5")
+
+	cfg := config.LoadConfig()
+	anomalyUsecaseMock := new(usecaseMocks.AnomalyUsecase)
+	server.NewAnomalyHandler(logger.New(cfg.LogLevel(), cfg.LogFormat()), anomalyUsecaseMock, privacy.NewPolicy(cfg)).Review(c)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	anomalyUsecaseMock.AssertNotCalled(t, "Review", mock.Anything, mock.Anything, mock.Anything)
+}
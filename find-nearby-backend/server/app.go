@@ -1,9 +1,15 @@
 package server
 
 import (
+	"context"
+	"io/ioutil"
+
 	"find-nearby-backend/config"
 	"find-nearby-backend/database"
 	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/routing"
+	"find-nearby-backend/tracing"
 )
 
 // Start starts the app
@@ -13,6 +19,39 @@ func Start(cfg config.Config) {
 	if err != nil {
 		log.Panicf(err.Error())
 	}
-	srv := NewServer(cfg.Addr(), db, log)
+	shutdownTracing, err := tracing.Setup(cfg, log)
+	if err != nil {
+		log.Panicf(err.Error())
+	}
+	watcher, err := config.NewWatcher()
+	if err != nil {
+		log.Panicf(err.Error())
+	}
+	srv := NewServer(cfg, db, log)
+	srv.WatchConfig(watcher)
+	if cfg.RoutingGraphFile() != "" {
+		graph, err := routing.Load(context.Background(), cfg.RoutingGraphFile())
+		if err != nil {
+			log.Panicf(err.Error())
+		}
+		log.Infof("loaded the road graph from %s: %d nodes, %d edges", cfg.RoutingGraphFile(), graph.Nodes(), graph.Edges())
+		srv.RouteWith(routing.NewRouter(graph, cfg.RoutingMaxSnapDistance()))
+		if cfg.IngestSnapToRoad() {
+			srv.SnapWith(routing.NewMatcher(graph, cfg.IngestSnapMaxDistance()))
+		}
+	}
+	if cfg.IngestServiceAreaFile() != "" {
+		data, err := ioutil.ReadFile(cfg.IngestServiceAreaFile())
+		if err != nil {
+			log.Panicf(err.Error())
+		}
+		area, err := model.ParseArea(data)
+		if err != nil {
+			log.Panicf("%s: %s", cfg.IngestServiceAreaFile(), err.Error())
+		}
+		srv.RestrictTo(area)
+	}
+	srv.OnShutdown(shutdownTracing)
+	srv.OnShutdown(func(context.Context) error { return db.Close() })
 	srv.Start()
 }
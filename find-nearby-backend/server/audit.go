@@ -0,0 +1,217 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+	"find-nearby-backend/usecase"
+
+	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+
+	auditParamsKey  = "audit.params"
+	auditResultsKey = "audit.results"
+)
+
+// AuditHandler serves the audit log
+type AuditHandler struct {
+	logger        logger.Logger
+	auditUsecase  usecase.AuditUsecase
+	readerAPIKeys map[string]bool
+}
+
+// NewAuditHandler is a constructor for AuditHandler
+func NewAuditHandler(logger logger.Logger, auditUsecase usecase.AuditUsecase, cfg config.Config) *AuditHandler {
+	readerAPIKeys := map[string]bool{}
+	for _, apiKey := range cfg.AuditReaderAPIKeys() {
+		readerAPIKeys[apiKey] = true
+	}
+	return &AuditHandler{logger: logger, auditUsecase: auditUsecase, readerAPIKeys: readerAPIKeys}
+}
+
+// FindAuditRecords returns audit records, newest first. caller, action, request_id, vehicle_id, from and to filter
+// them; limit defaults to 50 and can't exceed 500, and before_id pages through them. Callers without one of the
+// reader API keys get a 403, which is everyone when none are configured
+func (h *AuditHandler) FindAuditRecords(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "AuditHandler.FindAuditRecords")
+	defer span.End()
+
+	if !h.readerAPIKeys[c.Request().Header.Get(HeaderAPIKey)] {
+		return h.respondError(c, span, http.StatusForbidden, errors.New("the audit log can only be read with an audit reader API key"))
+	}
+	filter, err := auditFilter(c)
+	if err != nil {
+		return h.respondError(c, span, http.StatusBadRequest, err)
+	}
+	records, err := h.auditUsecase.FindAuditRecords(ctx, filter)
+	if err != nil {
+		return h.respondError(c, span, http.StatusInternalServerError, err)
+	}
+	auditResults(c, len(records))
+	return c.JSON(http.StatusOK, AuditRecordsResponse{Data: records, Success: true, Error: ErrorResponse{}})
+}
+
+func auditFilter(c echo.Context) (model.AuditFilter, error) {
+	filter := model.AuditFilter{
+		Caller:    c.QueryParam("caller"),
+		Action:    c.QueryParam("action"),
+		RequestID: c.QueryParam("request_id"),
+		Limit:     defaultAuditLimit,
+	}
+	var err error
+	if filter.VehicleID, err = positiveParam(c, "vehicle_id"); err != nil {
+		return model.AuditFilter{}, err
+	}
+	if filter.BeforeID, err = positiveParam(c, "before_id"); err != nil {
+		return model.AuditFilter{}, err
+	}
+	if filter.From, err = timeParam(c, "from"); err != nil {
+		return model.AuditFilter{}, err
+	}
+	if filter.To, err = timeParam(c, "to"); err != nil {
+		return model.AuditFilter{}, err
+	}
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return model.AuditFilter{}, fmt.Errorf("invalid limit: %s; limit must be between 1 and %d", value, maxAuditLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// timeParam returns the value of an optional RFC 3339 query param, or the zero time when it isn't given
+func timeParam(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %s; %s must be an RFC 3339 time like 2021-10-03T08:00:00Z", name, value, name)
+	}
+	return t, nil
+}
+
+func (h *AuditHandler) respondError(c echo.Context, span trace.Span, status int, err error) error {
+	tracing.RecordError(span, err)
+	if status == http.StatusInternalServerError {
+		h.logger.WithContext(c.Request().Context()).Errorf("failed to handle the audit request, err: %s", err.Error())
+	} else {
+		h.logger.WithContext(c.Request().Context()).Debugf("rejected the audit request, err: %s", err.Error())
+	}
+	return c.JSON(status, AuditRecordsResponse{
+		Data:    nil,
+		Success: false,
+		Error: ErrorResponse{
+			Code:    strconv.Itoa(status),
+			Message: err.Error(),
+		},
+	})
+}
+
+// auditParams notes params of the request for its audit record, on top of its query and path params. Params is
+// encoded as JSON; the fields of an object are merged into the recorded params, anything else is recorded as body
+func auditParams(c echo.Context, params interface{}) {
+	noted, _ := c.Get(auditParamsKey).([]interface{})
+	c.Set(auditParamsKey, append(noted, params))
+}
+
+// vehicleParam is how a vehicle is noted for an audit record: by its ID, or by its token when the caller only got that
+func vehicleParam(vehicleID int64, token string) map[string]interface{} {
+	if token != "" {
+		return map[string]interface{}{"vehicle_token": token}
+	}
+	return map[string]interface{}{"vehicle_id": vehicleID}
+}
+
+// auditResults notes for the audit record of the request how many vehicles, locations or records it returned or changed
+func auditResults(c echo.Context, results int) {
+	c.Set(auditResultsKey, results)
+}
+
+// auditRecord describes a completed request for the audit log
+func auditRecord(c echo.Context, occurredAt time.Time, status int) model.AuditRecord {
+	req := c.Request()
+	record := model.AuditRecord{
+		OccurredAt: occurredAt,
+		RequestID:  logger.RequestIDFromContext(req.Context()),
+		Caller:     callerFingerprint(req.Header.Get(HeaderAPIKey)),
+		RemoteAddr: c.RealIP(),
+		Action:     req.Method + " " + c.Path(),
+		Params:     requestParams(c),
+		Status:     status,
+	}
+	if results, ok := c.Get(auditResultsKey).(int); ok {
+		record.Results = &results
+	}
+	return record
+}
+
+// requestParams encodes the query and path params of a request and the params noted by its handler as a JSON object.
+// Numeric values are recorded as numbers, so that records can be matched by vehicle_id whichever way it came in
+func requestParams(c echo.Context) []byte {
+	params := map[string]interface{}{}
+	for name, values := range c.QueryParams() {
+		if len(values) == 1 {
+			params[name] = paramValue(values[0])
+		} else {
+			params[name] = values
+		}
+	}
+	for i, name := range c.ParamNames() {
+		params[name] = paramValue(c.ParamValues()[i])
+	}
+	noted, _ := c.Get(auditParamsKey).([]interface{})
+	for _, value := range noted {
+		data, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		fields := map[string]json.RawMessage{}
+		if err = json.Unmarshal(data, &fields); err != nil {
+			params["body"] = json.RawMessage(data)
+			continue
+		}
+		for name, field := range fields {
+			params[name] = field
+		}
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return []byte("{}")
+	}
+	return data
+}
+
+func paramValue(value string) interface{} {
+	if _, err := json.Marshal(json.Number(value)); err == nil && value != "" {
+		return json.Number(value)
+	}
+	return value
+}
+
+// callerFingerprint identifies a caller by its API key without storing the key: the first 16 hex digits of its SHA-256
+func callerFingerprint(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
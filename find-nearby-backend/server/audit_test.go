@@ -0,0 +1,130 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"find-nearby-backend/audit"
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
+	repositoryMocks "find-nearby-backend/repository/mocks"
+	"find-nearby-backend/server"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditHandler_FindAuditRecords_ShouldPassTheFilter(t *testing.T) {
+	os.Setenv("AUDIT_READER_API_KEYS", "compliance-key")
+	defer os.Unsetenv("AUDIT_READER_API_KEYS")
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.GET, "/audit?caller=2bb80d537b1da3e3&action=POST+/reservations&vehicle_id=7&from=2021-10-04T08:00:00Z&before_id=100&limit=10", nil)
+	req.Header.Set(server.HeaderAPIKey, "compliance-key")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	expected := []model.AuditRecord{{ID: 99, RequestID: "r-1", Caller: "2bb80d537b1da3e3", Action: "POST /reservations", Params: []byte(`{"vehicle_id":7}`), Status: 201}}
+	auditUsecaseMock := new(usecaseMocks.AuditUsecase)
+	auditUsecaseMock.On("FindAuditRecords", mock.Anything, model.AuditFilter{
+		Caller:    "2bb80d537b1da3e3",
+		Action:    "POST /reservations",
+		VehicleID: 7,
+		From:      time.Date(2021, 10, 4, 8, 0, 0, 0, time.UTC),
+		BeforeID:  100,
+		Limit:     10,
+	}).Return(expected, nil)
+	server.NewAuditHandler(log, auditUsecaseMock, cfg).FindAuditRecords(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.AuditRecordsResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, server.AuditRecordsResponse{Data: expected, Success: true}, resp)
+	auditUsecaseMock.AssertExpectations(t)
+}
+
+func TestAuditHandler_FindAuditRecords_WhenCallerIsNotAReader_ShouldReturn403(t *testing.T) {
+	os.Setenv("AUDIT_READER_API_KEYS", "compliance-key")
+	defer os.Unsetenv("AUDIT_READER_API_KEYS")
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.GET, "/audit", nil)
+	req.Header.Set(server.HeaderAPIKey, "partner-key")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	auditUsecaseMock := new(usecaseMocks.AuditUsecase)
+	server.NewAuditHandler(log, auditUsecaseMock, cfg).FindAuditRecords(c)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	auditUsecaseMock.AssertNotCalled(t, "FindAuditRecords", mock.Anything, mock.Anything)
+}
+
+func TestAuditHandler_FindAuditRecords_WhenNoReaderIsConfigured_ShouldReturn403(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(echo.GET, "/audit", nil)
+	req.Header.Set(server.HeaderAPIKey, "partner-key")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	auditUsecaseMock := new(usecaseMocks.AuditUsecase)
+	server.NewAuditHandler(log, auditUsecaseMock, cfg).FindAuditRecords(c)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	auditUsecaseMock.AssertNotCalled(t, "FindAuditRecords", mock.Anything, mock.Anything)
+}
+
+func TestAudit_ShouldRecordTheCallerParamsAndResults(t *testing.T) {
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	auditRepositoryMock := new(repositoryMocks.AuditRepository)
+	auditRepositoryMock.On("SaveAuditRecords", mock.Anything, mock.Anything).Return(nil)
+	writer := audit.NewWriter(log, auditRepositoryMock, 10, 10, time.Hour)
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Cancel", mock.Anything, int64(3), mock.AnythingOfType("string"), 2).Return(model.Reservation{ID: 3, VehicleID: 7, Status: model.ReservationStatusCancelled, Version: 3}, nil)
+	e := echo.New()
+	e.Use(server.RequestLogger(log))
+	e.POST("/reservations/:id/cancel", server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Cancel, server.Audit(writer))
+
+	req := httptest.NewRequest(echo.POST, "/reservations/3/cancel?reason=no-show", strings.NewReader(`{"version": 2}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderXRequestID, "r-1")
+	req.Header.Set(server.HeaderAPIKey, "partner-key")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, writer.Close(context.Background()))
+
+	require.Len(t, auditRepositoryMock.Calls, 1)
+	records := auditRepositoryMock.Calls[0].Arguments.Get(1).([]model.AuditRecord)
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.Equal(t, "r-1", record.RequestID)
+	assert.Len(t, record.Caller, 16)
+	assert.NotContains(t, record.Caller, "partner")
+	assert.Equal(t, record.Caller, reservationUsecaseMock.Calls[0].Arguments.String(2), "the caller holds the reservation")
+	assert.Equal(t, "POST /reservations/:id/cancel", record.Action)
+	assert.JSONEq(t, `{"id": 3, "reason": "no-show", "version": 2, "vehicle_id": 7}`, string(record.Params))
+	assert.Equal(t, 1, *record.Results)
+	assert.Equal(t, http.StatusOK, record.Status)
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+
+	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FindLocationsBatchRequest is a request message of a batch search. Units applies to every origin
+type FindLocationsBatchRequest struct {
+	Units   string   `json:"units"`
+	Origins []Origin `json:"origins"`
+}
+
+// Origin is one origin of a batch search. ID is chosen by the caller and echoed in the result;
+// radius and limit are optional like in GET /locations/find, and the radius is in the units of the batch
+type Origin struct {
+	ID        string   `json:"id"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Radius    *float64 `json:"radius"`
+	Limit     *int     `json:"limit"`
+}
+
+// FindLocationsBatch returns nearby vehicle locations for many origins, found with a single query.
+// Origins that fail validation get their own error while the others are still searched
+func (h *Handler) FindLocationsBatch(c echo.Context) error {
+	c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+	ctx, span := tracer.Start(c.Request().Context(), "Handler.FindLocationsBatch")
+	defer span.End()
+
+	var req FindLocationsBatchRequest
+	if err := c.Bind(&req); err != nil {
+		return h.batchError(ctx, c, span, http.StatusBadRequest, fmt.Errorf("failed to parse the request body: %v", err))
+	}
+	auditParams(c, req)
+	unit, err := model.ParseUnit(req.Units)
+	if err != nil {
+		return h.batchError(ctx, c, span, http.StatusBadRequest, err)
+	}
+	if len(req.Origins) == 0 {
+		return h.batchError(ctx, c, span, http.StatusBadRequest, errors.New("origins is a required param"))
+	}
+	if maxOrigins := h.queryPolicy.MaxBatchSize(); len(req.Origins) > maxOrigins {
+		return h.batchError(ctx, c, span, http.StatusBadRequest, fmt.Errorf("too many origins: %d; at most %d origins are allowed per batch", len(req.Origins), maxOrigins))
+	}
+	span.SetAttributes(tracing.BatchSizeKey.Int(len(req.Origins)))
+
+	apiKey := c.Request().Header.Get(HeaderAPIKey)
+	results := make([]OriginResult, len(req.Origins))
+	queries := make([]model.NearbyQuery, 0, len(req.Origins))
+	positions := make([]int, 0, len(req.Origins))
+	clamps := make([][]Clamp, 0, len(req.Origins))
+	for i, origin := range req.Origins {
+		results[i].ID = origin.ID
+		query, clamped, err := h.originQuery(apiKey, unit, origin)
+		if err != nil {
+			results[i].Error = &ErrorResponse{Code: "400", Message: err.Error()}
+			continue
+		}
+		queries = append(queries, query)
+		positions = append(positions, i)
+		clamps = append(clamps, clamped)
+	}
+	if rejected := len(req.Origins) - len(queries); rejected > 0 {
+		h.logger.WithContext(ctx).Debugf("rejected %d of %d origins of the batch", rejected, len(req.Origins))
+	}
+
+	found := 0
+	if len(queries) > 0 {
+		started := time.Now()
+		nearby, err := h.locationsUsecase.FindVehicleLocationsBatch(ctx, queries)
+		queryTime := time.Since(started)
+		if err != nil {
+			return h.batchError(ctx, c, span, http.StatusInternalServerError, err)
+		}
+		for j, i := range positions {
+			q := queries[j]
+			results[i].Data = locationsIn(unit, nearby[j].Locations)
+			results[i].Meta = newMeta(q.Latitude, q.Longitude, q.Radius, q.Limit, unit, nearby[j], queryTime, clamps[j])
+			found += len(nearby[j].Locations)
+		}
+	}
+	auditResults(c, found)
+
+	_, encodeSpan := tracer.Start(ctx, "Handler.FindLocationsBatch.encodeResponse")
+	defer encodeSpan.End()
+	return c.JSON(http.StatusOK, FindLocationsBatchResponse{
+		Data:    results,
+		Success: true,
+		Error:   ErrorResponse{},
+	})
+}
+
+// originQuery validates an origin the same way FindLocations validates its query params and applies the query policy
+func (h *Handler) originQuery(apiKey string, unit model.Unit, origin Origin) (model.NearbyQuery, []Clamp, error) {
+	if origin.Latitude == nil {
+		return model.NearbyQuery{}, nil, errors.New("latitude is a required param")
+	}
+	if err := checkLatitude(*origin.Latitude); err != nil {
+		return model.NearbyQuery{}, nil, err
+	}
+	if origin.Longitude == nil {
+		return model.NearbyQuery{}, nil, errors.New("longitude is a required param")
+	}
+	if err := checkLongitude(*origin.Longitude); err != nil {
+		return model.NearbyQuery{}, nil, err
+	}
+	radius, limit := -1.0, -1
+	if origin.Radius != nil {
+		if err := checkRadius(*origin.Radius); err != nil {
+			return model.NearbyQuery{}, nil, err
+		}
+		radius = *origin.Radius
+	}
+	if origin.Limit != nil {
+		if err := checkLimit(*origin.Limit); err != nil {
+			return model.NearbyQuery{}, nil, err
+		}
+		limit = *origin.Limit
+	}
+	meters, limit, clamped := h.queryPolicy.Apply(apiKey, toMeters(unit, radius), limit)
+	return model.NearbyQuery{Latitude: *origin.Latitude, Longitude: *origin.Longitude, Radius: meters, Limit: limit}, clamped, nil
+}
+
+func (h *Handler) batchError(ctx context.Context, c echo.Context, span trace.Span, status int, err error) error {
+	tracing.RecordError(span, err)
+	if status == http.StatusBadRequest {
+		h.logger.WithContext(ctx).Errorf("failed to validate the batch request, err: %s", err.Error())
+	} else {
+		h.logger.WithContext(ctx).Errorf("failed to find vehicle locations for the batch, err: %s", err.Error())
+	}
+	return c.JSON(status, FindLocationsBatchResponse{
+		Data:    nil,
+		Success: false,
+		Error: ErrorResponse{
+			Code:    fmt.Sprint(status),
+			Message: err.Error(),
+		},
+	})
+}
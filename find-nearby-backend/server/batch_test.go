@@ -0,0 +1,119 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/server"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandler_FindLocationsBatch_ShouldReportEachOriginSeparately(t *testing.T) {
+	body := `{"units": "km", "origins": [
+		{"id": "pickup-1", "latitude": 1.3, "longitude": 103.9, "radius": 2.5, "limit": 5},
+		{"id": "pickup-2", "latitude": 91, "longitude": 103.9},
+		{"id": "pickup-3", "latitude": 1.4, "longitude": 103.8}
+	]}`
+	cfg := config.LoadConfig()
+	limits := cfg.QueryLimits()
+	expectedQueries := []model.NearbyQuery{
+		{Latitude: 1.3, Longitude: 103.9, Radius: 2500, Limit: 5},
+		{Latitude: 1.4, Longitude: 103.8, Radius: limits.DefaultRadius, Limit: limits.DefaultLimit},
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/locations/find/batch", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocationsBatch", mock.Anything, expectedQueries).Return([]model.NearbyLocations{
+		{Locations: []model.Location{{VehicleID: 1, Distance: 500}}, Total: 2},
+		{},
+	}, nil)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocationsBatch(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.FindLocationsBatchResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	assert.Len(t, resp.Data, 3)
+
+	assert.Equal(t, "pickup-1", resp.Data[0].ID)
+	assert.Equal(t, []model.Location{{VehicleID: 1, Distance: 0.5}}, resp.Data[0].Data)
+	assert.Equal(t, server.Query{Latitude: 1.3, Longitude: 103.9, Radius: 2.5, Limit: 5, Units: "km"}, resp.Data[0].Meta.Query)
+	assert.True(t, resp.Data[0].Meta.HasMore)
+	assert.Nil(t, resp.Data[0].Error)
+
+	assert.Equal(t, "pickup-2", resp.Data[1].ID)
+	assert.Nil(t, resp.Data[1].Meta)
+	assert.Equal(t, &server.ErrorResponse{Code: "400", Message: "invalid latitude: 91.000000; latitude must be between -/+ 90"}, resp.Data[1].Error)
+
+	assert.Equal(t, "pickup-3", resp.Data[2].ID)
+	assert.Empty(t, resp.Data[2].Data)
+	assert.Equal(t, 0, resp.Data[2].Meta.Total)
+	locationsUsecaseMock.AssertExpectations(t)
+}
+
+func TestHandler_FindLocationsBatch_WhenTooManyOrigins_ShouldReturn400(t *testing.T) {
+	os.Setenv("QUERY_MAX_BATCH_SIZE", "1")
+	defer os.Unsetenv("QUERY_MAX_BATCH_SIZE")
+	body := `{"origins": [{"latitude": 1.3, "longitude": 103.9}, {"latitude": 1.4, "longitude": 103.8}]}`
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/locations/find/batch", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocationsBatch(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	resp := server.FindLocationsBatchResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, server.ErrorResponse{Code: "400", Message: "too many origins: 2; at most 1 origins are allowed per batch"}, resp.Error)
+	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocationsBatch", mock.Anything, mock.Anything)
+}
+
+func TestHandler_FindLocationsBatch_WhenUsecaseReturnsError_ShouldReturn500(t *testing.T) {
+	body := `{"origins": [{"latitude": 1.3, "longitude": 103.9}]}`
+	expectedErr := errors.New("usecase error")
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/locations/find/batch", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocationsBatch", mock.Anything, mock.Anything).Return(nil, expectedErr)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocationsBatch(c)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	resp := server.FindLocationsBatchResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, server.ErrorResponse{Code: "500", Message: expectedErr.Error()}, resp.Error)
+	assert.False(t, resp.Success)
+}
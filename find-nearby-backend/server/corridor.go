@@ -0,0 +1,191 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+
+	"github.com/labstack/echo"
+	geojson "github.com/paulmach/go.geojson"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FindCorridorRequest is a request message of a corridor search. The route is either an encoded Polyline, with
+// Precision decimal places (5 by default), or a GeoJSON LineString in Line. Buffer is required and, like the radius
+// of a nearby search, capped for the caller; limit is optional. Order is distance (to the route, the default) or start
+type FindCorridorRequest struct {
+	Polyline  string            `json:"polyline"`
+	Precision int               `json:"precision"`
+	Line      *geojson.Geometry `json:"line"`
+	Buffer    *float64          `json:"buffer"`
+	Limit     *int              `json:"limit"`
+	Order     string            `json:"order"`
+	Units     string            `json:"units"`
+}
+
+// FindLocationsAlongRoute returns the vehicle locations within a buffer around a route. Distance is the distance
+// of a vehicle to the route and along how far along the route it is; both are in the units of the request
+func (h *Handler) FindLocationsAlongRoute(c echo.Context) error {
+	c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+	ctx, span := tracer.Start(c.Request().Context(), "Handler.FindLocationsAlongRoute")
+	defer span.End()
+
+	var req FindCorridorRequest
+	if err := c.Bind(&req); err != nil {
+		return h.corridorError(c, span, http.StatusBadRequest, fmt.Errorf("failed to parse the request body: %v", err))
+	}
+	auditParams(c, req)
+	corridor, unit, clamped, err := h.corridorQuery(c.Request().Header.Get(HeaderAPIKey), req)
+	if err != nil {
+		return h.corridorError(c, span, http.StatusBadRequest, err)
+	}
+	span.SetAttributes(tracing.RoutePointsKey.Int(len(corridor.Route)), tracing.BufferKey.Int(corridor.Buffer), tracing.LimitKey.Int(corridor.Limit))
+	span.SetAttributes(tracing.ClampedKey.Bool(len(clamped) > 0))
+
+	started := time.Now()
+	nearby, err := h.locationsUsecase.FindVehicleLocationsAlongRoute(ctx, corridor)
+	queryTime := time.Since(started)
+	if err != nil {
+		return h.corridorError(c, span, http.StatusInternalServerError, err)
+	}
+	span.SetAttributes(tracing.ResultCountKey.Int(len(nearby.Locations)))
+	auditResults(c, len(nearby.Locations))
+
+	for i := range clamped {
+		clamped[i].Requested = unit.FromMeters(clamped[i].Requested)
+		clamped[i].Applied = unit.FromMeters(clamped[i].Applied)
+	}
+	locations := locationsIn(unit, nearby.Locations)
+	if unit != model.Meters {
+		for i := range locations {
+			if locations[i].Along != nil {
+				along := unit.FromMeters(*locations[i].Along)
+				locations[i].Along = &along
+			}
+		}
+	}
+
+	_, encodeSpan := tracer.Start(ctx, "Handler.FindLocationsAlongRoute.encodeResponse")
+	defer encodeSpan.End()
+	return c.JSON(http.StatusOK, FindCorridorResponse{
+		Data: locations,
+		Meta: &CorridorMeta{
+			Query: CorridorQuery{
+				Points: len(corridor.Route),
+				Buffer: unit.FromMeters(float64(corridor.Buffer)),
+				Limit:  corridor.Limit,
+				Order:  string(corridor.Order),
+				Units:  string(unit),
+			},
+			Total:       nearby.Total,
+			HasMore:     nearby.Total > len(nearby.Locations),
+			QueryTimeMs: float64(queryTime) / float64(time.Millisecond),
+			Clamped:     clamped,
+		},
+		Success: true,
+		Error:   ErrorResponse{},
+	})
+}
+
+// corridorQuery validates a corridor search and applies the query policy, with the buffer capped like a radius
+func (h *Handler) corridorQuery(apiKey string, req FindCorridorRequest) (model.Corridor, model.Unit, []Clamp, error) {
+	unit, err := model.ParseUnit(req.Units)
+	if err != nil {
+		return model.Corridor{}, "", nil, err
+	}
+	order, err := model.ParseCorridorOrder(req.Order)
+	if err != nil {
+		return model.Corridor{}, "", nil, err
+	}
+	route, err := h.route(req)
+	if err != nil {
+		return model.Corridor{}, "", nil, err
+	}
+	if req.Buffer == nil {
+		return model.Corridor{}, "", nil, errors.New("buffer is a required param")
+	}
+	if err = checkRadius(*req.Buffer); err != nil {
+		return model.Corridor{}, "", nil, fmt.Errorf("invalid buffer: %g; buffer must be a positive number", *req.Buffer)
+	}
+	limit := -1
+	if req.Limit != nil {
+		if err = checkLimit(*req.Limit); err != nil {
+			return model.Corridor{}, "", nil, err
+		}
+		limit = *req.Limit
+	}
+	buffer, limit, clamped := h.queryPolicy.Apply(apiKey, toMeters(unit, *req.Buffer), limit)
+	for i := range clamped {
+		if clamped[i].Param == "radius" {
+			clamped[i].Param = "buffer"
+		}
+	}
+	return model.Corridor{Route: route, Buffer: buffer, Limit: limit, Order: order}, unit, clamped, nil
+}
+
+// route returns the [longitude, latitude] points of the route of a corridor search, from either of its formats
+func (h *Handler) route(req FindCorridorRequest) ([][]float64, error) {
+	var route [][]float64
+	switch {
+	case req.Polyline != "" && req.Line != nil:
+		return nil, errors.New("polyline and line are mutually exclusive")
+	case req.Polyline != "":
+		precision := req.Precision
+		if precision == 0 {
+			precision = 5
+		}
+		points, err := model.DecodePolyline(req.Polyline, precision)
+		if err != nil {
+			return nil, err
+		}
+		route = points
+	case req.Line != nil:
+		if !req.Line.IsLineString() {
+			return nil, fmt.Errorf("invalid line: %s; line must be a GeoJSON LineString", req.Line.Type)
+		}
+		route = req.Line.LineString
+	default:
+		return nil, errors.New("polyline or line is a required param")
+	}
+	if len(route) < 2 {
+		return nil, errors.New("invalid route: a route needs at least 2 points")
+	}
+	if maxPoints := h.queryPolicy.MaxRoutePoints(); len(route) > maxPoints {
+		return nil, fmt.Errorf("too many route points: %d; at most %d points are allowed", len(route), maxPoints)
+	}
+	for i, point := range route {
+		if len(point) < 2 {
+			return nil, fmt.Errorf("invalid route point %d: a point needs a longitude and a latitude", i)
+		}
+		if err := checkLongitude(point[0]); err != nil {
+			return nil, fmt.Errorf("invalid route point %d: %v", i, err)
+		}
+		if err := checkLatitude(point[1]); err != nil {
+			return nil, fmt.Errorf("invalid route point %d: %v", i, err)
+		}
+		route[i] = point[:2]
+	}
+	return route, nil
+}
+
+func (h *Handler) corridorError(c echo.Context, span trace.Span, status int, err error) error {
+	tracing.RecordError(span, err)
+	if status == http.StatusBadRequest {
+		h.logger.WithContext(c.Request().Context()).Errorf("failed to validate the corridor request, err: %s", err.Error())
+	} else {
+		h.logger.WithContext(c.Request().Context()).Errorf("failed to find vehicle locations along the route, err: %s", err.Error())
+	}
+	return c.JSON(status, FindCorridorResponse{
+		Data:    nil,
+		Success: false,
+		Error: ErrorResponse{
+			Code:    strconv.Itoa(status),
+			Message: err.Error(),
+		},
+	})
+}
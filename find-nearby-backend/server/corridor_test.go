@@ -0,0 +1,112 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/server"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func findAlongRoute(t *testing.T, body string, locationsUsecaseMock *usecaseMocks.LocationUsecase) (*httptest.ResponseRecorder, server.FindCorridorResponse) {
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/locations/find/corridor", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocationsAlongRoute(c)
+
+	resp := server.FindCorridorResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec, resp
+}
+
+func TestHandler_FindLocationsAlongRoute_WhenRouteIsAPolyline_ShouldDecodeIt(t *testing.T) {
+	body := `{"polyline": "_p~iF~ps|U_ulLnnqC_mqNvxq` + "`" + `@", "buffer": 0.75, "units": "km", "order": "start"}`
+	limits := config.LoadConfig().QueryLimits()
+	expectedCorridor := model.Corridor{
+		Route:  [][]float64{{-120.2, 38.5}, {-120.95, 40.7}, {-126.453, 43.252}},
+		Buffer: 750,
+		Limit:  limits.DefaultLimit,
+		Order:  model.OrderByStart,
+	}
+	along := 1500.0
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocationsAlongRoute", mock.Anything, expectedCorridor).
+		Return(model.NearbyLocations{Locations: []model.Location{{VehicleID: 1, Distance: 300, Along: &along}}, Total: 1}, nil)
+	rec, resp := findAlongRoute(t, body, locationsUsecaseMock)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedAlong := 1.5
+	assert.Equal(t, []model.Location{{VehicleID: 1, Distance: 0.3, Along: &expectedAlong}}, resp.Data)
+	assert.Equal(t, server.CorridorQuery{Points: 3, Buffer: 0.75, Limit: limits.DefaultLimit, Order: "start", Units: "km"}, resp.Meta.Query)
+	// converting the units mustn't change the result of the usecase in place
+	assert.Equal(t, 1500.0, along)
+	locationsUsecaseMock.AssertExpectations(t)
+}
+
+func TestHandler_FindLocationsAlongRoute_WhenRouteIsALineString_ShouldOrderByDistance(t *testing.T) {
+	body := `{"line": {"type": "LineString", "coordinates": [[103.9, 1.3], [103.91, 1.31, 12.5]]}, "buffer": 300, "limit": 5}`
+	expectedCorridor := model.Corridor{Route: [][]float64{{103.9, 1.3}, {103.91, 1.31}}, Buffer: 300, Limit: 5, Order: model.OrderByDistance}
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocationsAlongRoute", mock.Anything, expectedCorridor).Return(model.NearbyLocations{Total: 7}, nil)
+	rec, resp := findAlongRoute(t, body, locationsUsecaseMock)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 7, resp.Meta.Total)
+	assert.True(t, resp.Meta.HasMore)
+	locationsUsecaseMock.AssertExpectations(t)
+}
+
+func TestHandler_FindLocationsAlongRoute_WhenBufferIsAboveCap_ShouldClampIt(t *testing.T) {
+	limits := config.LoadConfig().QueryLimits()
+	body := fmt.Sprintf(`{"line": {"type": "LineString", "coordinates": [[103.9, 1.3], [103.91, 1.31]]}, "buffer": %d}`, limits.MaxRadius+1)
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocationsAlongRoute", mock.Anything, mock.Anything).Return(model.NearbyLocations{}, nil)
+	rec, resp := findAlongRoute(t, body, locationsUsecaseMock)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []server.Clamp{{Param: "buffer", Requested: float64(limits.MaxRadius + 1), Applied: float64(limits.MaxRadius)}}, resp.Meta.Clamped)
+}
+
+func TestHandler_FindLocationsAlongRoute_WhenRequestIsInvalid_ShouldReturn400(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		message string
+	}{
+		{"no route", `{"buffer": 300}`, "polyline or line is a required param"},
+		{"both routes", `{"polyline": "_p~iF~ps|U", "line": {"type": "LineString", "coordinates": [[1, 1], [2, 2]]}, "buffer": 300}`, "polyline and line are mutually exclusive"},
+		{"not a line", `{"line": {"type": "Point", "coordinates": [1, 1]}, "buffer": 300}`, "invalid line: Point; line must be a GeoJSON LineString"},
+		{"single point", `{"polyline": "_p~iF~ps|U", "buffer": 300}`, "invalid route: a route needs at least 2 points"},
+		{"truncated polyline", `{"polyline": "_p~iF~ps|", "buffer": 300}`, "invalid polyline: it ends in the middle of a value"},
+		{"out of range", `{"line": {"type": "LineString", "coordinates": [[1, 1], [2, 95]]}, "buffer": 300}`, "invalid route point 1: invalid latitude: 95.000000; latitude must be between -/+ 90"},
+		{"no buffer", `{"line": {"type": "LineString", "coordinates": [[1, 1], [2, 2]]}}`, "buffer is a required param"},
+		{"negative buffer", `{"line": {"type": "LineString", "coordinates": [[1, 1], [2, 2]]}, "buffer": -2.5}`, "invalid buffer: -2.5; buffer must be a positive number"},
+		{"bad order", `{"line": {"type": "LineString", "coordinates": [[1, 1], [2, 2]]}, "buffer": 300, "order": "eta"}`, `invalid order: "eta"; order must be one of distance, start`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+			rec, resp := findAlongRoute(t, tt.body, locationsUsecaseMock)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, server.ErrorResponse{Code: "400", Message: tt.message}, resp.Error)
+			locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocationsAlongRoute", mock.Anything, mock.Anything)
+		})
+	}
+}
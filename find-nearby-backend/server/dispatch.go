@@ -0,0 +1,167 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+	"find-nearby-backend/usecase"
+
+	"github.com/labstack/echo"
+)
+
+// DispatchRequest is a request message of a dispatch assignment. MaxPickupDistance is in meters and optional;
+// it can lower the configured max pickup distance but not raise it
+type DispatchRequest struct {
+	MaxPickupDistance *int          `json:"max_pickup_distance"`
+	Requests          []RideRequest `json:"requests"`
+}
+
+// RideRequest is a rider waiting to be picked up. ID is chosen by the caller and must be unique within the request
+type RideRequest struct {
+	ID        string   `json:"id"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// DispatchHandler parses and validates dispatch requests and asks the dispatch usecase to assign vehicles
+type DispatchHandler struct {
+	logger            logger.Logger
+	dispatchUsecase   usecase.DispatchUsecase
+	maxPickupDistance int
+	maxRequests       int
+}
+
+// NewDispatchHandler is a constructor for DispatchHandler
+func NewDispatchHandler(logger logger.Logger, dispatchUsecase usecase.DispatchUsecase, cfg config.Config) *DispatchHandler {
+	return &DispatchHandler{
+		logger:            logger,
+		dispatchUsecase:   dispatchUsecase,
+		maxPickupDistance: cfg.DispatchMaxPickupDistance(),
+		maxRequests:       cfg.DispatchMaxRequests(),
+	}
+}
+
+// Assign assigns available vehicles to ride requests, one vehicle per request, minimising the total pickup distance,
+// and holds the assigned vehicles for the caller
+func (h *DispatchHandler) Assign(c echo.Context) error {
+	c.Response().Header().Set("Access-Control-Allow-Origin", "*")
+	ctx, span := tracer.Start(c.Request().Context(), "DispatchHandler.Assign")
+	defer span.End()
+
+	holder, err := holderOf(c)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return c.JSON(http.StatusUnauthorized, DispatchResponse{
+			Data:    nil,
+			Success: false,
+			Error: ErrorResponse{
+				Code:    "401",
+				Message: err.Error(),
+			},
+		})
+	}
+	requests, maxPickupDistance, err := h.getRequest(c)
+	if err != nil {
+		tracing.RecordError(span, err)
+		h.logger.WithContext(ctx).Errorf("failed to validate the dispatch request, err: %s", err.Error())
+		return c.JSON(http.StatusBadRequest, DispatchResponse{
+			Data:    nil,
+			Success: false,
+			Error: ErrorResponse{
+				Code:    "400",
+				Message: err.Error(),
+			},
+		})
+	}
+	plan, err := h.dispatchUsecase.Assign(ctx, requests, maxPickupDistance, holder)
+	if err != nil {
+		tracing.RecordError(span, err)
+		h.logger.WithContext(ctx).ErrorWithTag(err, logger.Fields{
+			"msg":      "failed to assign vehicles",
+			"requests": len(requests),
+		})
+		return c.JSON(http.StatusInternalServerError, DispatchResponse{
+			Data:    nil,
+			Success: false,
+			Error: ErrorResponse{
+				Code:    "500",
+				Message: err.Error(),
+			},
+		})
+	}
+	// vehicles hidden behind tokens are noted by their tokens, like in vehicleParam
+	vehicleIDs := []int64{}
+	var vehicleTokens []string
+	for _, assignment := range plan.Assignments {
+		if assignment.VehicleToken != "" {
+			vehicleTokens = append(vehicleTokens, assignment.VehicleToken)
+		} else {
+			vehicleIDs = append(vehicleIDs, assignment.VehicleID)
+		}
+	}
+	params := map[string]interface{}{"vehicle_ids": vehicleIDs}
+	if len(vehicleTokens) > 0 {
+		params["vehicle_tokens"] = vehicleTokens
+	}
+	auditParams(c, params)
+	auditResults(c, len(plan.Assignments))
+	return c.JSON(http.StatusOK, DispatchResponse{
+		Data:    &plan,
+		Success: true,
+		Error:   ErrorResponse{},
+	})
+}
+
+func (h *DispatchHandler) getRequest(c echo.Context) ([]model.RideRequest, int, error) {
+	var req DispatchRequest
+	if err := c.Bind(&req); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse the request body: %v", err)
+	}
+	auditParams(c, req)
+	if len(req.Requests) == 0 {
+		return nil, 0, errors.New("requests is a required param")
+	}
+	if len(req.Requests) > h.maxRequests {
+		return nil, 0, fmt.Errorf("too many requests: %d; at most %d ride requests are allowed per assignment", len(req.Requests), h.maxRequests)
+	}
+	maxPickupDistance := h.maxPickupDistance
+	if req.MaxPickupDistance != nil {
+		if *req.MaxPickupDistance <= 0 {
+			return nil, 0, fmt.Errorf("invalid max_pickup_distance: %d; max_pickup_distance must be positive", *req.MaxPickupDistance)
+		}
+		if *req.MaxPickupDistance < maxPickupDistance {
+			maxPickupDistance = *req.MaxPickupDistance
+		}
+	}
+
+	requests := make([]model.RideRequest, len(req.Requests))
+	ids := make(map[string]bool, len(req.Requests))
+	for i, r := range req.Requests {
+		if r.ID == "" {
+			return nil, 0, fmt.Errorf("requests[%d]: id is a required param", i)
+		}
+		if ids[r.ID] {
+			return nil, 0, fmt.Errorf("requests[%d]: duplicate id %q", i, r.ID)
+		}
+		ids[r.ID] = true
+		if r.Latitude == nil {
+			return nil, 0, fmt.Errorf("requests[%d]: latitude is a required param", i)
+		}
+		if err := checkLatitude(*r.Latitude); err != nil {
+			return nil, 0, fmt.Errorf("requests[%d]: %v", i, err)
+		}
+		if r.Longitude == nil {
+			return nil, 0, fmt.Errorf("requests[%d]: longitude is a required param", i)
+		}
+		if err := checkLongitude(*r.Longitude); err != nil {
+			return nil, 0, fmt.Errorf("requests[%d]: %v", i, err)
+		}
+		requests[i] = model.RideRequest{ID: r.ID, Latitude: *r.Latitude, Longitude: *r.Longitude}
+	}
+	return requests, maxPickupDistance, nil
+}
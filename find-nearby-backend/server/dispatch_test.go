@@ -0,0 +1,110 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/server"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDispatchHandler_Assign_Success(t *testing.T) {
+	body := `{"max_pickup_distance": 1000, "requests": [{"id": "a", "latitude": 1.3, "longitude": 103.9}]}`
+	expectedPlan := model.DispatchPlan{
+		Assignments:       []model.Assignment{{RequestID: "a", VehicleID: 7, Distance: 250, Reservation: model.Reservation{ID: 1, VehicleID: 7, Holder: holder("dispatcher-key"), Status: model.ReservationStatusHeld, Version: 1}}},
+		Unassigned:        []string{},
+		TotalDistance:     250,
+		MaxPickupDistance: 1000,
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/dispatch/assign", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(server.HeaderAPIKey, "dispatcher-key")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	dispatchUsecaseMock := new(usecaseMocks.DispatchUsecase)
+	dispatchUsecaseMock.On("Assign", mock.Anything, []model.RideRequest{{ID: "a", Latitude: 1.3, Longitude: 103.9}}, 1000, holder("dispatcher-key")).Return(expectedPlan, nil)
+	server.NewDispatchHandler(log, dispatchUsecaseMock, cfg).Assign(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.DispatchResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, server.DispatchResponse{Data: &expectedPlan, Success: true}, resp)
+	dispatchUsecaseMock.AssertExpectations(t)
+}
+
+func TestDispatchHandler_Assign_WhenMaxPickupDistanceIsAboveConfig_ShouldUseConfig(t *testing.T) {
+	body := `{"max_pickup_distance": 999999, "requests": [{"id": "a", "latitude": 1.3, "longitude": 103.9}]}`
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/dispatch/assign", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(server.HeaderAPIKey, "dispatcher-key")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	dispatchUsecaseMock := new(usecaseMocks.DispatchUsecase)
+	dispatchUsecaseMock.On("Assign", mock.Anything, mock.Anything, cfg.DispatchMaxPickupDistance(), mock.Anything).Return(model.DispatchPlan{}, nil)
+	server.NewDispatchHandler(log, dispatchUsecaseMock, cfg).Assign(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	dispatchUsecaseMock.AssertExpectations(t)
+}
+
+func TestDispatchHandler_Assign_WhenIDsAreDuplicated_ShouldReturn400(t *testing.T) {
+	body := `{"requests": [{"id": "a", "latitude": 1.3, "longitude": 103.9}, {"id": "a", "latitude": 1.4, "longitude": 103.8}]}`
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/dispatch/assign", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(server.HeaderAPIKey, "dispatcher-key")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	dispatchUsecaseMock := new(usecaseMocks.DispatchUsecase)
+	server.NewDispatchHandler(log, dispatchUsecaseMock, cfg).Assign(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	resp := server.DispatchResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, server.ErrorResponse{Code: "400", Message: `requests[1]: duplicate id "a"`}, resp.Error)
+	dispatchUsecaseMock.AssertNotCalled(t, "Assign", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDispatchHandler_Assign_WhenAPIKeyIsMissing_ShouldReturn401(t *testing.T) {
+	body := `{"requests": [{"id": "a", "latitude": 1.3, "longitude": 103.9}]}`
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/dispatch/assign", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	dispatchUsecaseMock := new(usecaseMocks.DispatchUsecase)
+	server.NewDispatchHandler(log, dispatchUsecaseMock, cfg).Assign(c)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	dispatchUsecaseMock.AssertNotCalled(t, "Assign", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -3,26 +3,40 @@ package server
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
 	"find-nearby-backend/usecase"
 
 	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("find-nearby-backend/server")
+
+const (
+	sortDistance = "distance"
+	sortETA      = "eta"
 )
 
 // Handler parses and validates the incoming requests, asks Usecase layer to perform business logic and constructs the responses
 type Handler struct {
 	logger           logger.Logger
 	locationsUsecase usecase.LocationUsecase
+	queryPolicy      *QueryPolicy
 }
 
 // NewHandler is a constructor for Handler
-func NewHandler(logger logger.Logger, locationsUsecase usecase.LocationUsecase) *Handler {
+func NewHandler(logger logger.Logger, locationsUsecase usecase.LocationUsecase, queryPolicy *QueryPolicy) *Handler {
 	return &Handler{
 		logger:           logger,
 		locationsUsecase: locationsUsecase,
+		queryPolicy:      queryPolicy,
 	}
 }
 
@@ -31,12 +45,22 @@ func (h *Handler) Ping(c echo.Context) error {
 	return c.String(http.StatusOK, "pong")
 }
 
-// FindLocations returns nearby vehicle locations
+// FindLocations returns nearby vehicle locations. radius and limit are optional; missing values get the defaults
+// and values above the caps of the caller's API key are lowered to them, which the response meta reports.
+// units (m, km or mi; m by default) applies to the radius and to every distance in the response.
+// sort=eta ranks the vehicles by drive time over the road graph instead of by straight-line distance
 func (h *Handler) FindLocations(c echo.Context) error {
 	c.Response().Header().Set("Access-Control-Allow-Origin", "*")
-	lat, lng, radius, limit, err := h.getRequestParams(c)
+	ctx, span := tracer.Start(c.Request().Context(), "Handler.FindLocations")
+	defer span.End()
+	lat, lng, radius, limit, unit, err := h.getRequestParams(c)
+	var byETA bool
+	if err == nil {
+		byETA, err = h.validateSort(c.QueryParam("sort"))
+	}
 	if err != nil {
-		h.logger.Errorf("failed to validate the request, err: %s", err.Error())
+		tracing.RecordError(span, err)
+		h.logger.WithContext(ctx).Errorf("failed to validate the request, err: %s", err.Error())
 		return c.JSON(http.StatusBadRequest, FindLocationsResponse{
 			Data:    nil,
 			Success: false,
@@ -46,49 +70,129 @@ func (h *Handler) FindLocations(c echo.Context) error {
 			},
 		})
 	}
-	locations, err := h.locationsUsecase.FindVehicleLocations(lat, lng, radius, limit)
+	meters, limit, clamped := h.queryPolicy.Apply(c.Request().Header.Get(HeaderAPIKey), toMeters(unit, radius), limit)
+	if len(clamped) > 0 {
+		h.logger.WithContext(ctx).Debugf("clamped the request params: %+v", clamped)
+	}
+	span.SetAttributes(tracing.QueryAttributes(lat, lng, meters, limit)...)
+	span.SetAttributes(tracing.ClampedKey.Bool(len(clamped) > 0))
+	find := h.locationsUsecase.FindVehicleLocations
+	if byETA {
+		find = h.locationsUsecase.FindVehicleLocationsByETA
+	}
+	started := time.Now()
+	nearby, err := find(ctx, lat, lng, meters, limit)
+	queryTime := time.Since(started)
 	if err != nil {
-		h.logger.ErrorWithTag(err, logger.Fields{
+		tracing.RecordError(span, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, usecase.ErrRoutingUnavailable) {
+			status = http.StatusBadRequest
+		}
+		h.logger.WithContext(ctx).ErrorWithTag(err, logger.Fields{
 			"msg":    "failed to find vehicle locations",
 			"lat":    lat,
 			"lng":    lng,
-			"radius": radius,
+			"radius": meters,
 			"limit":  limit,
 		})
-		return c.JSON(http.StatusInternalServerError, FindLocationsResponse{
+		return c.JSON(status, FindLocationsResponse{
 			Data:    nil,
 			Success: false,
 			Error: ErrorResponse{
-				Code:    "500",
+				Code:    strconv.Itoa(status),
 				Message: err.Error(),
 			},
 		})
 	}
+	span.SetAttributes(tracing.ResultCountKey.Int(len(nearby.Locations)))
+	auditResults(c, len(nearby.Locations))
+
+	meta := newMeta(lat, lng, meters, limit, unit, nearby, queryTime, clamped)
+	if byETA {
+		meta.Query.Sort = sortETA
+	}
+
+	_, encodeSpan := tracer.Start(ctx, "Handler.FindLocations.encodeResponse")
+	defer encodeSpan.End()
 	return c.JSON(http.StatusOK, FindLocationsResponse{
-		Data:    locations,
+		Data:    locationsIn(unit, nearby.Locations),
+		Meta:    meta,
 		Success: true,
 		Error:   ErrorResponse{},
 	})
 }
 
-func (h *Handler) getRequestParams(c echo.Context) (float64, float64, int, int, error) {
+// newMeta describes a search that ran with radius in meters, converting the radius and any clamped radius to unit
+func newMeta(lat, lng float64, radius, limit int, unit model.Unit, nearby model.NearbyLocations, queryTime time.Duration, clamped []Clamp) *Meta {
+	for i := range clamped {
+		if clamped[i].Param == "radius" {
+			clamped[i].Requested = unit.FromMeters(clamped[i].Requested)
+			clamped[i].Applied = unit.FromMeters(clamped[i].Applied)
+		}
+	}
+	return &Meta{
+		Query: Query{
+			Latitude:  lat,
+			Longitude: lng,
+			Radius:    unit.FromMeters(float64(radius)),
+			Limit:     limit,
+			Units:     string(unit),
+		},
+		Total:       nearby.Total,
+		HasMore:     nearby.Total > len(nearby.Locations),
+		QueryTimeMs: float64(queryTime) / float64(time.Millisecond),
+		Clamped:     clamped,
+	}
+}
+
+// toMeters converts a radius given in unit to whole meters, leaving the -1 of a missing radius as is
+func toMeters(unit model.Unit, radius float64) int {
+	if radius < 0 {
+		return -1
+	}
+	return int(math.Round(unit.ToMeters(radius)))
+}
+
+// locationsIn returns locations with their distances converted from meters to unit
+func locationsIn(unit model.Unit, locations []model.Location) []model.Location {
+	if unit == model.Meters {
+		return locations
+	}
+	converted := make([]model.Location, len(locations))
+	for i, location := range locations {
+		location.Distance = unit.FromMeters(location.Distance)
+		if location.RouteDistance != nil {
+			routeDistance := unit.FromMeters(*location.RouteDistance)
+			location.RouteDistance = &routeDistance
+		}
+		converted[i] = location
+	}
+	return converted
+}
+
+func (h *Handler) getRequestParams(c echo.Context) (float64, float64, float64, int, model.Unit, error) {
 	lat, err := h.validateLatitude(c.QueryParam("latitude"))
 	if err != nil {
-		return 0, 0, 0, 0, err
+		return 0, 0, 0, 0, "", err
 	}
 	lng, err := h.validateLongitude(c.QueryParam("longitude"))
 	if err != nil {
-		return 0, 0, 0, 0, err
+		return 0, 0, 0, 0, "", err
 	}
 	radius, err := h.validateRadius(c.QueryParam("radius"))
 	if err != nil {
-		return 0, 0, 0, 0, err
+		return 0, 0, 0, 0, "", err
 	}
 	limit, err := h.validateLimit(c.QueryParam("limit"))
 	if err != nil {
-		return 0, 0, 0, 0, err
+		return 0, 0, 0, 0, "", err
+	}
+	unit, err := model.ParseUnit(c.QueryParam("units"))
+	if err != nil {
+		return 0, 0, 0, 0, "", err
 	}
-	return lat, lng, radius, limit, nil
+	return lat, lng, radius, limit, unit, nil
 }
 
 func (h *Handler) validateLatitude(latitude string) (float64, error) {
@@ -99,10 +203,14 @@ func (h *Handler) validateLatitude(latitude string) (float64, error) {
 	if err != nil {
 		return 0, fmt.Errorf("failed to parse the latitude value: %v", lat)
 	}
+	return lat, checkLatitude(lat)
+}
+
+func checkLatitude(lat float64) error {
 	if lat < -90 || lat > 90 {
-		return 0, fmt.Errorf("invalid latitude: %f; latitude must be between -/+ 90", lat)
+		return fmt.Errorf("invalid latitude: %f; latitude must be between -/+ 90", lat)
 	}
-	return lat, nil
+	return nil
 }
 
 func (h *Handler) validateLongitude(longitude string) (float64, error) {
@@ -113,36 +221,63 @@ func (h *Handler) validateLongitude(longitude string) (float64, error) {
 	if err != nil {
 		return 0, fmt.Errorf("failed to parse the longitude value: %v", lng)
 	}
+	return lng, checkLongitude(lng)
+}
+
+func checkLongitude(lng float64) error {
 	if lng < -180 || lng > 180 {
-		return 0, fmt.Errorf("invalid longitude: %f; longitude must be between -/+ 180", lng)
+		return fmt.Errorf("invalid longitude: %f; longitude must be between -/+ 180", lng)
+	}
+	return nil
+}
+
+// validateSort reports whether the results are to be ranked by eta; distance, the default, is the other option
+func (h *Handler) validateSort(sort string) (bool, error) {
+	switch sort {
+	case "", sortDistance:
+		return false, nil
+	case sortETA:
+		return true, nil
 	}
-	return lng, nil
+	return false, fmt.Errorf("invalid sort: %s; sort must be %s or %s", sort, sortDistance, sortETA)
 }
 
-func (h *Handler) validateRadius(radius string) (int, error) {
+// validateRadius returns -1 when radius isn't given so that the default applies. The radius is in the units of the
+// request, so it may have a fraction, like 1.5km
+func (h *Handler) validateRadius(radius string) (float64, error) {
 	if radius == "" {
-		return 0, errors.New("radius is a required param")
+		return -1, nil
 	}
-	rad, err := strconv.ParseInt(radius, 10, 32)
+	rad, err := strconv.ParseFloat(radius, 64)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse the latitude value: %s", radius)
+		return 0, fmt.Errorf("failed to parse the radius value: %s", radius)
 	}
-	if rad < 0 {
-		return 0, fmt.Errorf("invalid radius: %d; radius must be a positive int32", rad)
+	return rad, checkRadius(rad)
+}
+
+func checkRadius(rad float64) error {
+	if math.IsNaN(rad) || rad < 0 || rad > math.MaxInt32 {
+		return fmt.Errorf("invalid radius: %g; radius must be a positive number", rad)
 	}
-	return int(rad), nil
+	return nil
 }
 
+// validateLimit returns -1 when limit isn't given so that the default applies
 func (h *Handler) validateLimit(limit string) (int, error) {
 	if limit == "" {
-		return 0, errors.New("limit is a required param")
+		return -1, nil
 	}
 	lim, err := strconv.ParseInt(limit, 10, 32)
 	if err != nil {
 		return 0, fmt.Errorf("failed to parse the limit value: %s", limit)
 	}
-	if lim < 0 {
-		return 0, fmt.Errorf("invalid limit: %d; limit must be a positive int32", lim)
+	return int(lim), checkLimit(int(lim))
+}
+
+// checkLimit rejects a limit of 0 too: the search would find nothing to count the vehicles within the radius by
+func checkLimit(lim int) error {
+	if lim < 1 || lim > math.MaxInt32 {
+		return fmt.Errorf("invalid limit: %d; limit must be a positive int32", lim)
 	}
-	return int(lim), nil
+	return nil
 }
@@ -8,18 +8,31 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"find-nearby-backend/logger"
 	"find-nearby-backend/model"
 	"find-nearby-backend/server"
+	"find-nearby-backend/usecase"
 	usecaseMocks "find-nearby-backend/usecase/mocks"
 
 	"github.com/labstack/echo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
+// spanRecorder collects the spans of every test in this package; tracers bind to the first global provider
+// so the provider is installed once and tests only look at the spans they produced
+var spanRecorder = tracetest.NewSpanRecorder()
+
+func init() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+}
+
 func TestHandler_Ping(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(echo.GET, "/ping", bytes.NewReader(nil))
@@ -28,7 +41,7 @@ func TestHandler_Ping(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	err := server.NewHandler(nil, nil).Ping(c)
+	err := server.NewHandler(nil, nil, nil).Ping(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, echo.MIMETextPlainCharsetUTF8, rec.Header().Get("Content-Type"))
@@ -45,7 +58,12 @@ func TestHandler_FindLocations_Success(t *testing.T) {
 		{VehicleID: 2, Latitude: 22.22, Longitude: 22.22, Distance: 20},
 	}
 	expectedResponse := server.FindLocationsResponse{
-		Data:    expectedLocations,
+		Data: expectedLocations,
+		Meta: &server.Meta{
+			Query:   server.Query{Latitude: lat, Longitude: lng, Radius: float64(radius), Limit: limit, Units: "m"},
+			Total:   3,
+			HasMore: true,
+		},
 		Success: true,
 		Error:   server.ErrorResponse{},
 	}
@@ -62,16 +80,55 @@ func TestHandler_FindLocations_Success(t *testing.T) {
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
 
 	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
-	locationsUsecaseMock.On("FindVehicleLocations", lat, lng, radius, limit).Return(expectedLocations, nil)
-	server.NewHandler(log, locationsUsecaseMock).FindLocations(c)
+	locationsUsecaseMock.On("FindVehicleLocations", mock.Anything, lat, lng, radius, limit).Return(model.NearbyLocations{Locations: expectedLocations, Total: 3}, nil)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	resp := server.FindLocationsResponse{}
 	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.GreaterOrEqual(t, resp.Meta.QueryTimeMs, 0.0)
+	resp.Meta.QueryTimeMs = 0
 	assert.Equal(t, expectedResponse, resp)
 	locationsUsecaseMock.AssertExpectations(t)
 }
 
+func TestHandler_FindLocations_ShouldRecordSpans(t *testing.T) {
+	lat := 45.13
+	lng := 23.23
+	radius := 10
+	limit := 20
+	expectedLocations := []model.Location{
+		{VehicleID: 1, Latitude: 12.12, Longitude: 12.12, Distance: 10},
+	}
+	recordedBefore := len(spanRecorder.Ended())
+
+	e := echo.New()
+	url := fmt.Sprintf("/locations/find?latitude=%f&longitude=%f&radius=%d&limit=%d", lat, lng, radius, limit)
+	req := httptest.NewRequest(echo.GET, url, bytes.NewReader(nil))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocations", mock.Anything, lat, lng, radius, limit).Return(model.NearbyLocations{Locations: expectedLocations, Total: 1}, nil)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	spans := spanRecorder.Ended()[recordedBefore:]
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "Handler.FindLocations.encodeResponse", spans[0].Name())
+	assert.Equal(t, "Handler.FindLocations", spans[1].Name())
+	attributes := map[string]interface{}{}
+	for _, kv := range spans[1].Attributes() {
+		attributes[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	assert.Equal(t, int64(radius), attributes["nearby.radius"])
+	assert.Equal(t, int64(limit), attributes["nearby.limit"])
+	assert.Equal(t, int64(1), attributes["nearby.result_count"])
+}
+
 func TestHandler_FindLocations_WhenNoLatitudeParam_ShouldReturn400(t *testing.T) {
 	lng := 23.23
 	radius := 10
@@ -98,13 +155,13 @@ func TestHandler_FindLocations_WhenNoLatitudeParam_ShouldReturn400(t *testing.T)
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
 
 	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
-	server.NewHandler(log, locationsUsecaseMock).FindLocations(c)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	resp := server.FindLocationsResponse{}
 	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
 	assert.Equal(t, expectedResponse, resp)
-	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestHandler_FindLocations_WhenNoLongitudeParam_ShouldReturn400(t *testing.T) {
@@ -133,66 +190,276 @@ func TestHandler_FindLocations_WhenNoLongitudeParam_ShouldReturn400(t *testing.T
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
 
 	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
-	server.NewHandler(log, locationsUsecaseMock).FindLocations(c)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	resp := server.FindLocationsResponse{}
 	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
 	assert.Equal(t, expectedResponse, resp)
-	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
-func TestHandler_FindLocations_WhenNoRadiusParam_ShouldReturn400(t *testing.T) {
+func TestHandler_FindLocations_WhenNoRadiusAndLimitParams_ShouldUseDefaults(t *testing.T) {
 	lat := 23.22
 	lng := 23.22
-	limit := 20
-	expectedErr := errors.New("radius is a required param")
-	expectedResponse := server.FindLocationsResponse{
-		Data:    nil,
-		Success: false,
-		Error: server.ErrorResponse{
-			Code:    "400",
-			Message: expectedErr.Error(),
-		},
+	cfg := config.LoadConfig()
+	limits := cfg.QueryLimits()
+	expectedQuery := server.Query{Latitude: lat, Longitude: lng, Radius: float64(limits.DefaultRadius), Limit: limits.DefaultLimit, Units: "m"}
+
+	e := echo.New()
+	url := fmt.Sprintf("/locations/find?latitude=%f&longitude=%f", lat, lng)
+	req := httptest.NewRequest(echo.GET, url, bytes.NewReader(nil))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocations", mock.Anything, lat, lng, limits.DefaultRadius, limits.DefaultLimit).Return(model.NearbyLocations{}, nil)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.FindLocationsResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, expectedQuery, resp.Meta.Query)
+	locationsUsecaseMock.AssertExpectations(t)
+}
+
+func TestHandler_FindLocations_WhenParamsAboveCaps_ShouldClamp(t *testing.T) {
+	lat := 23.22
+	lng := 23.22
+	radius := 2000000000
+	limit := 2000000000
+	cfg := config.LoadConfig()
+	limits := cfg.QueryLimits()
+	expectedQuery := server.Query{Latitude: lat, Longitude: lng, Radius: float64(limits.MaxRadius), Limit: limits.MaxLimit, Units: "m"}
+	expectedClamped := []server.Clamp{
+		{Param: "radius", Requested: float64(radius), Applied: float64(limits.MaxRadius)},
+		{Param: "limit", Requested: float64(limit), Applied: float64(limits.MaxLimit)},
 	}
 
 	e := echo.New()
-	url := fmt.Sprintf("/locations/find?latitude=%f&longitude=%f&limit=%d", lat, lng, limit)
+	url := fmt.Sprintf("/locations/find?latitude=%f&longitude=%f&radius=%d&limit=%d", lat, lng, radius, limit)
 	req := httptest.NewRequest(echo.GET, url, bytes.NewReader(nil))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
 
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocations", mock.Anything, lat, lng, limits.MaxRadius, limits.MaxLimit).Return(model.NearbyLocations{}, nil)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.FindLocationsResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, expectedQuery, resp.Meta.Query)
+	assert.Equal(t, expectedClamped, resp.Meta.Clamped)
+	locationsUsecaseMock.AssertExpectations(t)
+}
+
+func TestHandler_FindLocations_WhenAPIKeyHasOverrides_ShouldApplyItsCaps(t *testing.T) {
+	os.Setenv("QUERY_API_KEY_LIMITS", "partner-key:200000:1000,small-key:100:5")
+	defer os.Unsetenv("QUERY_API_KEY_LIMITS")
+	lat := 23.22
+	lng := 23.22
+	radius := 150000
+	limit := 800
 	cfg := config.LoadConfig()
+
+	e := echo.New()
+	url := fmt.Sprintf("/locations/find?latitude=%f&longitude=%f&radius=%d&limit=%d", lat, lng, radius, limit)
+	req := httptest.NewRequest(echo.GET, url, bytes.NewReader(nil))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	req.Header.Set(server.HeaderAPIKey, "partner-key")
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
 
 	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
-	server.NewHandler(log, locationsUsecaseMock).FindLocations(c)
+	locationsUsecaseMock.On("FindVehicleLocations", mock.Anything, lat, lng, radius, limit).Return(model.NearbyLocations{}, nil)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.FindLocationsResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, float64(radius), resp.Meta.Query.Radius)
+	assert.Equal(t, limit, resp.Meta.Query.Limit)
+	assert.Empty(t, resp.Meta.Clamped)
+	locationsUsecaseMock.AssertExpectations(t)
+}
+
+func TestHandler_FindLocations_WhenUnitsAreKilometers_ShouldConvertRadiusAndDistances(t *testing.T) {
+	lat := 23.22
+	lng := 23.22
+	cfg := config.LoadConfig()
+
+	e := echo.New()
+	url := fmt.Sprintf("/locations/find?latitude=%f&longitude=%f&radius=2&limit=10&units=km", lat, lng)
+	req := httptest.NewRequest(echo.GET, url, bytes.NewReader(nil))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocations", mock.Anything, lat, lng, 2000, 10).
+		Return(model.NearbyLocations{Locations: []model.Location{{VehicleID: 1, Distance: 1500}}, Total: 1}, nil)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.FindLocationsResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, []model.Location{{VehicleID: 1, Distance: 1.5}}, resp.Data)
+	assert.Equal(t, server.Query{Latitude: lat, Longitude: lng, Radius: 2, Limit: 10, Units: "km"}, resp.Meta.Query)
+	assert.Equal(t, 1, resp.Meta.Total)
+	assert.False(t, resp.Meta.HasMore)
+	locationsUsecaseMock.AssertExpectations(t)
+}
+
+func TestHandler_FindLocations_WhenRadiusHasAFraction_ShouldConvertItToMeters(t *testing.T) {
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+	for _, tc := range []struct {
+		query  string
+		meters int
+	}{
+		{query: "radius=1.5&units=km", meters: 1500},
+		{query: "radius=0.5&units=mi", meters: 805},
+		{query: "radius=250.4", meters: 250},
+	} {
+		e := echo.New()
+		req := httptest.NewRequest(echo.GET, "/locations/find?latitude=1.3&longitude=103.9&limit=10&"+tc.query, bytes.NewReader(nil))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+		locationsUsecaseMock.On("FindVehicleLocations", mock.Anything, 1.3, 103.9, tc.meters, 10).Return(model.NearbyLocations{}, nil)
+		server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
+		assert.Equal(t, http.StatusOK, rec.Code, tc.query)
+		locationsUsecaseMock.AssertExpectations(t)
+	}
+}
+
+func TestHandler_FindLocations_WhenRadiusOrLimitAreMalformed_ShouldReturn400(t *testing.T) {
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+	for query, message := range map[string]string{
+		"radius=abc&limit=10": "failed to parse the radius value: abc",
+		"radius=NaN&limit=10": "invalid radius: NaN; radius must be a positive number",
+		"radius=100&limit=0":  "invalid limit: 0; limit must be a positive int32",
+	} {
+		e := echo.New()
+		req := httptest.NewRequest(echo.GET, "/locations/find?latitude=1.3&longitude=103.9&"+query, bytes.NewReader(nil))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+		server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		resp := server.FindLocationsResponse{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, message, resp.Error.Message)
+		locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func TestHandler_FindLocations_WhenSortIsETA_ShouldRankByETA(t *testing.T) {
+	lat := 23.22
+	lng := 23.22
+	cfg := config.LoadConfig()
+
+	e := echo.New()
+	url := fmt.Sprintf("/locations/find?latitude=%f&longitude=%f&radius=2&limit=10&units=km&sort=eta", lat, lng)
+	req := httptest.NewRequest(echo.GET, url, bytes.NewReader(nil))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	eta, routeDistance := 240.0, 2500.0
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocationsByETA", mock.Anything, lat, lng, 2000, 10).
+		Return(model.NearbyLocations{Locations: []model.Location{{VehicleID: 1, Distance: 1500, ETA: &eta, RouteDistance: &routeDistance}}, Total: 1}, nil)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.FindLocationsResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	expectedRouteDistance := 2.5
+	assert.Equal(t, []model.Location{{VehicleID: 1, Distance: 1.5, ETA: &eta, RouteDistance: &expectedRouteDistance}}, resp.Data)
+	assert.Equal(t, "eta", resp.Meta.Query.Sort)
+	locationsUsecaseMock.AssertExpectations(t)
+}
+
+func TestHandler_FindLocations_WhenRoutingIsUnavailable_ShouldReturn400(t *testing.T) {
+	lat := 23.22
+	lng := 23.22
+	cfg := config.LoadConfig()
+
+	e := echo.New()
+	url := fmt.Sprintf("/locations/find?latitude=%f&longitude=%f&sort=eta", lat, lng)
+	req := httptest.NewRequest(echo.GET, url, bytes.NewReader(nil))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	locationsUsecaseMock.On("FindVehicleLocationsByETA", mock.Anything, lat, lng, mock.Anything, mock.Anything).
+		Return(model.NearbyLocations{}, usecase.ErrRoutingUnavailable)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	resp := server.FindLocationsResponse{}
 	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
-	assert.Equal(t, expectedResponse, resp)
-	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, server.ErrorResponse{Code: "400", Message: usecase.ErrRoutingUnavailable.Error()}, resp.Error)
+}
+
+func TestHandler_FindLocations_WhenInvalidSort_ShouldReturn400(t *testing.T) {
+	cfg := config.LoadConfig()
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.GET, "/locations/find?latitude=23.22&longitude=23.22&sort=price", bytes.NewReader(nil))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	resp := server.FindLocationsResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid sort: price; sort must be distance or eta", resp.Error.Message)
+	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
-func TestHandler_FindLocations_WhenNoLimitParam_ShouldReturn400(t *testing.T) {
+func TestHandler_FindLocations_WhenInvalidUnits_ShouldReturn400(t *testing.T) {
 	lat := 23.22
 	lng := 23.22
-	radius := 20
-	expectedErr := errors.New("limit is a required param")
 	expectedResponse := server.FindLocationsResponse{
 		Data:    nil,
 		Success: false,
 		Error: server.ErrorResponse{
 			Code:    "400",
-			Message: expectedErr.Error(),
+			Message: `invalid units: "ft"; units must be one of m, km, mi`,
 		},
 	}
 
 	e := echo.New()
-	url := fmt.Sprintf("/locations/find?latitude=%f&longitude=%f&radius=%d", lat, lng, radius)
+	url := fmt.Sprintf("/locations/find?latitude=%f&longitude=%f&units=ft", lat, lng)
 	req := httptest.NewRequest(echo.GET, url, bytes.NewReader(nil))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
 
@@ -203,13 +470,13 @@ func TestHandler_FindLocations_WhenNoLimitParam_ShouldReturn400(t *testing.T) {
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
 
 	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
-	server.NewHandler(log, locationsUsecaseMock).FindLocations(c)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	resp := server.FindLocationsResponse{}
 	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
 	assert.Equal(t, expectedResponse, resp)
-	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestHandler_FindLocations_WhenInvalidLatitude_ShouldReturn400(t *testing.T) {
@@ -239,13 +506,13 @@ func TestHandler_FindLocations_WhenInvalidLatitude_ShouldReturn400(t *testing.T)
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
 
 	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
-	server.NewHandler(log, locationsUsecaseMock).FindLocations(c)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	resp := server.FindLocationsResponse{}
 	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
 	assert.Equal(t, expectedResponse, resp)
-	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestHandler_FindLocations_WhenInvalidLongitude_ShouldReturn400(t *testing.T) {
@@ -275,13 +542,13 @@ func TestHandler_FindLocations_WhenInvalidLongitude_ShouldReturn400(t *testing.T
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
 
 	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
-	server.NewHandler(log, locationsUsecaseMock).FindLocations(c)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	resp := server.FindLocationsResponse{}
 	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
 	assert.Equal(t, expectedResponse, resp)
-	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestHandler_FindLocations_WhenInvalidRadius_ShouldReturn400(t *testing.T) {
@@ -289,7 +556,7 @@ func TestHandler_FindLocations_WhenInvalidRadius_ShouldReturn400(t *testing.T) {
 	lng := -23.1
 	radius := -10
 	limit := 20
-	expectedErr := fmt.Errorf("invalid radius: %d; radius must be a positive int32", radius)
+	expectedErr := fmt.Errorf("invalid radius: %d; radius must be a positive number", radius)
 	expectedResponse := server.FindLocationsResponse{
 		Data:    nil,
 		Success: false,
@@ -311,13 +578,13 @@ func TestHandler_FindLocations_WhenInvalidRadius_ShouldReturn400(t *testing.T) {
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
 
 	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
-	server.NewHandler(log, locationsUsecaseMock).FindLocations(c)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	resp := server.FindLocationsResponse{}
 	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
 	assert.Equal(t, expectedResponse, resp)
-	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestHandler_FindLocations_WhenInvalidLimit_ShouldReturn400(t *testing.T) {
@@ -347,13 +614,13 @@ func TestHandler_FindLocations_WhenInvalidLimit_ShouldReturn400(t *testing.T) {
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
 
 	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
-	server.NewHandler(log, locationsUsecaseMock).FindLocations(c)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	resp := server.FindLocationsResponse{}
 	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
 	assert.Equal(t, expectedResponse, resp)
-	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	locationsUsecaseMock.AssertNotCalled(t, "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestHandler_FindLocations_WhenUsecaseReturnsError_ShouldReturn500(t *testing.T) {
@@ -383,8 +650,8 @@ func TestHandler_FindLocations_WhenUsecaseReturnsError_ShouldReturn500(t *testin
 	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
 
 	locationsUsecaseMock := new(usecaseMocks.LocationUsecase)
-	locationsUsecaseMock.On("FindVehicleLocations", lat, lng, radius, limit).Return(nil, expectedErr)
-	server.NewHandler(log, locationsUsecaseMock).FindLocations(c)
+	locationsUsecaseMock.On("FindVehicleLocations", mock.Anything, lat, lng, radius, limit).Return(model.NearbyLocations{}, expectedErr)
+	server.NewHandler(log, locationsUsecaseMock, server.NewQueryPolicy(cfg)).FindLocations(c)
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
 
 	resp := server.FindLocationsResponse{}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/tracing"
+	"find-nearby-backend/usecase"
+
+	"github.com/labstack/echo"
+)
+
+// maxClockSkew is how far past the time a request is received its pings may be recorded, for trackers whose clocks
+// run a little fast. A ping from further ahead would outdate every real ping of its vehicle until that time
+const maxClockSkew = time.Minute
+
+// IngestRequest is a request message carrying the GPS pings of one or more vehicles
+type IngestRequest struct {
+	Pings []PingRequest `json:"pings"`
+}
+
+// PingRequest is a GPS fix of a vehicle. RecordedAt is optional and defaults to when the request was received, which
+// it can't be more than maxClockSkew past
+type PingRequest struct {
+	VehicleID  int64      `json:"vehicle_id"`
+	Latitude   *float64   `json:"latitude"`
+	Longitude  *float64   `json:"longitude"`
+	RecordedAt *time.Time `json:"recorded_at"`
+}
+
+// IngestHandler parses and validates pings and asks the ingest usecase to store them
+type IngestHandler struct {
+	logger        logger.Logger
+	ingestUsecase usecase.IngestUsecase
+	maxBatch      int
+}
+
+// NewIngestHandler is a constructor for IngestHandler
+func NewIngestHandler(logger logger.Logger, ingestUsecase usecase.IngestUsecase, cfg config.Config) *IngestHandler {
+	return &IngestHandler{logger: logger, ingestUsecase: ingestUsecase, maxBatch: cfg.IngestMaxBatch()}
+}
+
+// Ingest stores the latest position of every vehicle in the request, snapped to the roads when snapping is enabled
+func (h *IngestHandler) Ingest(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "IngestHandler.Ingest")
+	defer span.End()
+
+	pings, err := h.getPings(c, time.Now())
+	if err != nil {
+		tracing.RecordError(span, err)
+		h.logger.WithContext(ctx).Debugf("rejected the ingest request, err: %s", err.Error())
+		return c.JSON(http.StatusBadRequest, IngestResponse{
+			Data:    nil,
+			Success: false,
+			Error: ErrorResponse{
+				Code:    "400",
+				Message: err.Error(),
+			},
+		})
+	}
+	auditParams(c, pingParams(pings))
+	result, err := h.ingestUsecase.Ingest(ctx, pings)
+	if err != nil {
+		tracing.RecordError(span, err)
+		h.logger.WithContext(ctx).ErrorWithTag(err, logger.Fields{
+			"msg":   "failed to ingest pings",
+			"pings": len(pings),
+		})
+		return c.JSON(http.StatusInternalServerError, IngestResponse{
+			Data:    nil,
+			Success: false,
+			Error: ErrorResponse{
+				Code:    "500",
+				Message: err.Error(),
+			},
+		})
+	}
+	auditResults(c, result.Stored)
+	return c.JSON(http.StatusOK, IngestResponse{
+		Data:    &result,
+		Success: true,
+		Error:   ErrorResponse{},
+	})
+}
+
+// pingParams sums pings up for the audit log: a request can carry thousands of them, so only which vehicles they
+// moved is recorded, not every fix
+func pingParams(pings []model.Ping) map[string]interface{} {
+	vehicleIDs := []int64{}
+	seen := map[int64]bool{}
+	for _, ping := range pings {
+		if !seen[ping.VehicleID] {
+			seen[ping.VehicleID] = true
+			vehicleIDs = append(vehicleIDs, ping.VehicleID)
+		}
+	}
+	return map[string]interface{}{"pings": len(pings), "vehicle_ids": vehicleIDs}
+}
+
+func (h *IngestHandler) getPings(c echo.Context, received time.Time) ([]model.Ping, error) {
+	var req IngestRequest
+	if err := c.Bind(&req); err != nil {
+		return nil, fmt.Errorf("failed to parse the request body: %v", err)
+	}
+	if len(req.Pings) == 0 {
+		return nil, errors.New("pings is a required param")
+	}
+	if len(req.Pings) > h.maxBatch {
+		return nil, fmt.Errorf("too many pings: %d; at most %d pings are allowed per request", len(req.Pings), h.maxBatch)
+	}
+
+	pings := make([]model.Ping, len(req.Pings))
+	for i, p := range req.Pings {
+		if p.VehicleID <= 0 {
+			return nil, fmt.Errorf("pings[%d]: vehicle_id is a required param", i)
+		}
+		if p.Latitude == nil {
+			return nil, fmt.Errorf("pings[%d]: latitude is a required param", i)
+		}
+		if err := checkLatitude(*p.Latitude); err != nil {
+			return nil, fmt.Errorf("pings[%d]: %v", i, err)
+		}
+		if p.Longitude == nil {
+			return nil, fmt.Errorf("pings[%d]: longitude is a required param", i)
+		}
+		if err := checkLongitude(*p.Longitude); err != nil {
+			return nil, fmt.Errorf("pings[%d]: %v", i, err)
+		}
+		recordedAt := received
+		if p.RecordedAt != nil {
+			recordedAt = *p.RecordedAt
+		}
+		if recordedAt.After(received.Add(maxClockSkew)) {
+			return nil, fmt.Errorf("pings[%d]: invalid recorded_at: %s; pings can't be recorded more than %s after they are sent", i, recordedAt.Format(time.RFC3339), maxClockSkew)
+		}
+		pings[i] = model.Ping{VehicleID: p.VehicleID, Latitude: *p.Latitude, Longitude: *p.Longitude, RecordedAt: recordedAt}
+	}
+	return pings, nil
+}
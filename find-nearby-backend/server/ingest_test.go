@@ -0,0 +1,97 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/server"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIngestHandler_Ingest_Success(t *testing.T) {
+	body := `{"pings": [{"vehicle_id": 7, "latitude": 1.3, "longitude": 103.9, "recorded_at": "2021-10-03T08:00:00Z"}]}`
+	expectedResult := model.IngestResult{Received: 1, Stored: 1, Snapped: 1}
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/locations/ingest", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	pings := []model.Ping{{VehicleID: 7, Latitude: 1.3, Longitude: 103.9, RecordedAt: time.Date(2021, 10, 3, 8, 0, 0, 0, time.UTC)}}
+	ingestUsecaseMock := new(usecaseMocks.IngestUsecase)
+	ingestUsecaseMock.On("Ingest", mock.Anything, pings).Return(expectedResult, nil)
+	server.NewIngestHandler(log, ingestUsecaseMock, cfg).Ingest(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.IngestResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, server.IngestResponse{Data: &expectedResult, Success: true}, resp)
+	ingestUsecaseMock.AssertExpectations(t)
+}
+
+func TestIngestHandler_Ingest_WhenRecordedAtIsMissing_ShouldUseTheTimeOfTheRequest(t *testing.T) {
+	body := `{"pings": [{"vehicle_id": 7, "latitude": 1.3, "longitude": 103.9}]}`
+
+	e := echo.New()
+	req := httptest.NewRequest(echo.POST, "/locations/ingest", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	before := time.Now()
+	ingestUsecaseMock := new(usecaseMocks.IngestUsecase)
+	ingestUsecaseMock.On("Ingest", mock.Anything, mock.MatchedBy(func(pings []model.Ping) bool {
+		return len(pings) == 1 && !pings[0].RecordedAt.Before(before) && !pings[0].RecordedAt.After(time.Now())
+	})).Return(model.IngestResult{Received: 1, Stored: 1}, nil)
+	server.NewIngestHandler(log, ingestUsecaseMock, cfg).Ingest(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	ingestUsecaseMock.AssertExpectations(t)
+}
+
+func TestIngestHandler_Ingest_WhenPingIsInvalid_ShouldReturn400(t *testing.T) {
+	cases := map[string]string{
+		`{"pings": []}`: "pings is a required param",
+		`{"pings": [{"latitude": 1.3, "longitude": 103.9}]}`:                                                         "pings[0]: vehicle_id is a required param",
+		`{"pings": [{"vehicle_id": 7, "longitude": 103.9}]}`:                                                         "pings[0]: latitude is a required param",
+		`{"pings": [{"vehicle_id": 7, "latitude": 91, "longitude": 103.9}]}`:                                         "pings[0]: invalid latitude: 91.000000; latitude must be between -/+ 90",
+		`{"pings": [{"vehicle_id": 7, "latitude": 1.3, "longitude": -181}]}`:                                         "pings[0]: invalid longitude: -181.000000; longitude must be between -/+ 180",
+		`{"pings": [{"vehicle_id": 7, "latitude": 1.3, "longitude": "east"}]}`:                                       "failed to parse the request body",
+		`{"pings": [{"vehicle_id": 7, "latitude": 1.3, "longitude": 103.9, "recorded_at": "2999-01-01T00:00:00Z"}]}`: "pings[0]: invalid recorded_at: 2999-01-01T00:00:00Z",
+	}
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+	for body, message := range cases {
+		e := echo.New()
+		req := httptest.NewRequest(echo.POST, "/locations/ingest", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		ingestUsecaseMock := new(usecaseMocks.IngestUsecase)
+		server.NewIngestHandler(log, ingestUsecaseMock, cfg).Ingest(c)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+
+		resp := server.IngestResponse{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Contains(t, resp.Error.Message, message, body)
+		ingestUsecaseMock.AssertNotCalled(t, "Ingest", mock.Anything, mock.Anything)
+	}
+}
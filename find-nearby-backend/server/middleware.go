@@ -0,0 +1,183 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"find-nearby-backend/audit"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/privacy"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/tracing"
+
+	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const maxRequestIDLength = 128
+
+// RequestLogger assigns every request an ID, propagating the X-Request-ID header when the client sends one,
+// stores it in the request context and writes one structured log line per request once it completes
+func RequestLogger(log logger.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			requestID := req.Header.Get(echo.HeaderXRequestID)
+			if !isValidRequestID(requestID) {
+				requestID = newRequestID()
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+			ctx := logger.ContextWithRequestID(req.Context(), requestID)
+			c.SetRequest(req.WithContext(ctx))
+
+			start := time.Now()
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			log.WithContext(ctx).WithFields(logger.Fields{
+				"method":     req.Method,
+				"route":      c.Path(),
+				"uri":        req.RequestURI,
+				"status":     c.Response().Status,
+				"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+				"client":     c.RealIP(),
+				"user_agent": req.UserAgent(),
+			}).Infof("request completed")
+			return nil
+		}
+	}
+}
+
+// Tracing starts a server span for every request, continuing the trace from the W3C traceparent header when present.
+// The trace context is written back to the response headers so clients can look the trace up
+func Tracing() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			propagator := otel.GetTextMapPropagator()
+			ctx := propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			ctx, span := tracer.Start(ctx, req.Method+" "+c.Path(),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(tracing.ServiceName, c.Path(), req)...),
+			)
+			defer span.End()
+			c.SetRequest(req.WithContext(ctx))
+			propagator.Inject(ctx, propagation.HeaderCarrier(c.Response().Header()))
+
+			if err := next(c); err != nil {
+				span.RecordError(err)
+				c.Error(err)
+			}
+			status := c.Response().Status
+			span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+			if status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			return nil
+		}
+	}
+}
+
+// CallerScope stores the scope of the caller, decided by policy from its API key, in the request context
+func CallerScope(policy *privacy.Policy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			scope := policy.ScopeOf(req.Header.Get(HeaderAPIKey))
+			c.SetRequest(req.WithContext(privacy.ContextWithScope(req.Context(), scope)))
+			return next(c)
+		}
+	}
+}
+
+// CallerHolder stores the holder of the caller in the request context when its API key is one of apiKeys, the keys
+// that may hold vehicles, so that its searches find the vehicles it holds
+func CallerHolder(apiKeys []string) echo.MiddlewareFunc {
+	holders := make(map[string]bool, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		holders[apiKey] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if apiKey := req.Header.Get(HeaderAPIKey); holders[apiKey] {
+				c.SetRequest(req.WithContext(repository.ContextWithHolder(req.Context(), callerFingerprint(apiKey))))
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireAPIKey lets through only the requests whose X-API-Key is one of apiKeys, which may do what describes.
+// Requests without a key get a 401 and the others a 403, which is every request when there are no apiKeys
+func RequireAPIKey(apiKeys []string, what string) echo.MiddlewareFunc {
+	allowed := make(map[string]bool, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		allowed[apiKey] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			apiKey := c.Request().Header.Get(HeaderAPIKey)
+			switch {
+			case apiKey == "":
+				return deny(c, http.StatusUnauthorized, fmt.Sprintf("an API key is needed to %s; send one in %s", what, HeaderAPIKey))
+			case !allowed[apiKey]:
+				return deny(c, http.StatusForbidden, fmt.Sprintf("this API key may not %s", what))
+			}
+			return next(c)
+		}
+	}
+}
+
+func deny(c echo.Context, status int, message string) error {
+	return c.JSON(status, DeniedResponse{Success: false, Error: ErrorResponse{Code: strconv.Itoa(status), Message: message}})
+}
+
+// Audit records every request of the routes it wraps to writer once it completes: who made it, with which params
+// and how many results, as noted by the handler with auditParams and auditResults. Recording never blocks the request
+func Audit(writer *audit.Writer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			occurredAt := time.Now()
+			err := next(c)
+			status := c.Response().Status
+			if err != nil {
+				status = http.StatusInternalServerError
+				if httpErr, ok := err.(*echo.HTTPError); ok {
+					status = httpErr.Code
+				}
+			}
+			writer.Record(auditRecord(c, occurredAt, status))
+			return err
+		}
+	}
+}
+
+// isValidRequestID accepts client supplied IDs only if they are short and printable, so they can't be used to forge log lines
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
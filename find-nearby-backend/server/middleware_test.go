@@ -0,0 +1,167 @@
+package server_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/privacy"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/server"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestLogger_WhenHeaderIsPresent_ShouldPropagateRequestID(t *testing.T) {
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	var seenRequestID string
+	e := echo.New()
+	e.Use(server.RequestLogger(log))
+	e.GET("/ping", func(c echo.Context) error {
+		seenRequestID = logger.RequestIDFromContext(c.Request().Context())
+		return c.String(http.StatusOK, "pong")
+	})
+
+	req := httptest.NewRequest(echo.GET, "/ping", bytes.NewReader(nil))
+	req.Header.Set(echo.HeaderXRequestID, "client-request-id")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "client-request-id", seenRequestID)
+	assert.Equal(t, "client-request-id", rec.Header().Get(echo.HeaderXRequestID))
+}
+
+func TestRequestLogger_WhenHeaderIsMissingOrInvalid_ShouldAssignRequestID(t *testing.T) {
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	var seenRequestID string
+	e := echo.New()
+	e.Use(server.RequestLogger(log))
+	e.GET("/ping", func(c echo.Context) error {
+		seenRequestID = logger.RequestIDFromContext(c.Request().Context())
+		return c.String(http.StatusOK, "pong")
+	})
+
+	for _, header := range []string{"", "bad id\nwith newline"} {
+		req := httptest.NewRequest(echo.GET, "/ping", bytes.NewReader(nil))
+		req.Header.Set(echo.HeaderXRequestID, header)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, seenRequestID, 32)
+		assert.Equal(t, seenRequestID, rec.Header().Get(echo.HeaderXRequestID))
+	}
+}
+
+func TestRequestLogger_WhenHandlerReturnsError_ShouldWriteErrorResponse(t *testing.T) {
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	e := echo.New()
+	e.Use(server.RequestLogger(log))
+	e.GET("/fail", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusTeapot, "teapot")
+	})
+
+	req := httptest.NewRequest(echo.GET, "/fail", bytes.NewReader(nil))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+}
+
+func TestTracing_WhenTraceparentIsPresent_ShouldContinueTrace(t *testing.T) {
+	recordedBefore := len(spanRecorder.Ended())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var handlerSpan trace.SpanContext
+	e := echo.New()
+	e.Use(server.Tracing())
+	e.GET("/ping", func(c echo.Context) error {
+		handlerSpan = trace.SpanContextFromContext(c.Request().Context())
+		return c.String(http.StatusOK, "pong")
+	})
+
+	req := httptest.NewRequest(echo.GET, "/ping", bytes.NewReader(nil))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", handlerSpan.TraceID().String())
+	assert.Contains(t, rec.Header().Get("traceparent"), "4bf92f3577b34da6a3ce929d0e0e4736")
+
+	spans := spanRecorder.Ended()[recordedBefore:]
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET /ping", spans[0].Name())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+}
+
+func TestCallerScope_ShouldTrustOnlyTrustedAPIKeys(t *testing.T) {
+	os.Setenv("PRIVACY_ENABLED", "true")
+	os.Setenv("PRIVACY_SECRET", "0123456789abcdef")
+	os.Setenv("PRIVACY_TRUSTED_API_KEYS", "ops-key")
+	defer os.Unsetenv("PRIVACY_ENABLED")
+	defer os.Unsetenv("PRIVACY_SECRET")
+	defer os.Unsetenv("PRIVACY_TRUSTED_API_KEYS")
+
+	var seenScope privacy.Scope
+	e := echo.New()
+	e.Use(server.CallerScope(privacy.NewPolicy(config.LoadConfig())))
+	e.GET("/ping", func(c echo.Context) error {
+		seenScope = privacy.ScopeFromContext(c.Request().Context())
+		return c.String(http.StatusOK, "pong")
+	})
+
+	for apiKey, scope := range map[string]privacy.Scope{"ops-key": privacy.ScopeTrusted, "partner-key": privacy.ScopePublic, "": privacy.ScopePublic} {
+		req := httptest.NewRequest(echo.GET, "/ping", bytes.NewReader(nil))
+		req.Header.Set(server.HeaderAPIKey, apiKey)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, scope, seenScope, apiKey)
+	}
+}
+
+func TestCallerHolder_ShouldOnlyIdentifyTheAPIKeysThatMayHoldVehicles(t *testing.T) {
+	var seenHolder string
+	e := echo.New()
+	e.Use(server.CallerHolder([]string{"fleet-key"}))
+	e.GET("/ping", func(c echo.Context) error {
+		seenHolder = repository.HolderFromContext(c.Request().Context())
+		return c.String(http.StatusOK, "pong")
+	})
+
+	for apiKey, expected := range map[string]string{"fleet-key": holder("fleet-key"), "partner-key": "", "": ""} {
+		req := httptest.NewRequest(echo.GET, "/ping", bytes.NewReader(nil))
+		req.Header.Set(server.HeaderAPIKey, apiKey)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, expected, seenHolder, apiKey)
+	}
+}
+
+func TestRequireAPIKey_ShouldLetOnlyTheGivenAPIKeysThrough(t *testing.T) {
+	e := echo.New()
+	e.GET("/debug/vars", func(c echo.Context) error {
+		return c.String(http.StatusOK, "{}")
+	}, server.RequireAPIKey([]string{"ops-key"}, "read the server vars"))
+
+	for apiKey, status := range map[string]int{"ops-key": http.StatusOK, "partner-key": http.StatusForbidden, "": http.StatusUnauthorized} {
+		req := httptest.NewRequest(echo.GET, "/debug/vars", bytes.NewReader(nil))
+		req.Header.Set(server.HeaderAPIKey, apiKey)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code, apiKey)
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"sync"
+
+	"find-nearby-backend/config"
+)
+
+// HeaderAPIKey is the request header carrying the caller's API key
+const HeaderAPIKey = "X-API-Key"
+
+// QueryPolicy holds the defaults and caps of the radius and limit of nearby queries, per API key.
+// Callers without an API key, or with a key that has no overrides, get the global limits
+type QueryPolicy struct {
+	mu             sync.RWMutex
+	limits         config.QueryLimits
+	apiKeys        map[string]config.QueryLimits
+	maxBatchSize   int
+	maxRoutePoints int
+}
+
+// NewQueryPolicy is a constructor for QueryPolicy
+func NewQueryPolicy(cfg config.Config) *QueryPolicy {
+	p := &QueryPolicy{}
+	p.Update(cfg)
+	return p
+}
+
+// Update replaces the limits with the ones in cfg. It is called when the config is reloaded
+func (p *QueryPolicy) Update(cfg config.Config) {
+	limits, apiKeys, maxBatchSize, maxRoutePoints := cfg.QueryLimits(), cfg.QueryAPIKeyLimits(), cfg.QueryMaxBatchSize(), cfg.QueryMaxRoutePoints()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limits = limits
+	p.apiKeys = apiKeys
+	p.maxBatchSize = maxBatchSize
+	p.maxRoutePoints = maxRoutePoints
+}
+
+// MaxRoutePoints returns the max number of points of the route of a corridor search
+func (p *QueryPolicy) MaxRoutePoints() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.maxRoutePoints
+}
+
+// MaxBatchSize returns the max number of origins of a batch search
+func (p *QueryPolicy) MaxBatchSize() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.maxBatchSize
+}
+
+// Limits returns the limits that apply to the given API key
+func (p *QueryPolicy) Limits(apiKey string) config.QueryLimits {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if limits, ok := p.apiKeys[apiKey]; ok && apiKey != "" {
+		return limits
+	}
+	return p.limits
+}
+
+// Apply fills in the default radius and limit when they weren't given (a negative value) and lowers them to the caps.
+// It returns the values to query with and the params that were clamped; radius is in meters
+func (p *QueryPolicy) Apply(apiKey string, radius, limit int) (int, int, []Clamp) {
+	limits := p.Limits(apiKey)
+	var clamped []Clamp
+	if radius < 0 {
+		radius = limits.DefaultRadius
+	}
+	if radius > limits.MaxRadius {
+		clamped = append(clamped, Clamp{Param: "radius", Requested: float64(radius), Applied: float64(limits.MaxRadius)})
+		radius = limits.MaxRadius
+	}
+	if limit < 0 {
+		limit = limits.DefaultLimit
+	}
+	if limit > limits.MaxLimit {
+		clamped = append(clamped, Clamp{Param: "limit", Requested: float64(limit), Applied: float64(limits.MaxLimit)})
+		limit = limits.MaxLimit
+	}
+	return radius, limit, clamped
+}
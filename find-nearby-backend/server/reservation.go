@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/tracing"
+	"find-nearby-backend/usecase"
+
+	"github.com/labstack/echo"
+	pkgerrors "github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ReserveRequest is a request message to hold a vehicle, given by its ID or, for callers who only see tokens, by the
+// token of a search result, which is the only way they can give it. Minutes is optional and can't exceed the max hold
+type ReserveRequest struct {
+	VehicleID    *int64 `json:"vehicle_id"`
+	VehicleToken string `json:"vehicle_token"`
+	Minutes      *int   `json:"minutes"`
+}
+
+// TransitionRequest is a request message to cancel, confirm or complete a reservation. Version is the version of
+// the reservation the caller last saw
+type TransitionRequest struct {
+	Version int `json:"version"`
+}
+
+// ReservationHandler parses and validates reservation requests and asks the reservation usecase to carry them out
+type ReservationHandler struct {
+	logger             logger.Logger
+	reservationUsecase usecase.ReservationUsecase
+	privacyPolicy      *privacy.Policy
+	defaultHold        time.Duration
+	maxHold            time.Duration
+}
+
+// NewReservationHandler is a constructor for ReservationHandler. privacyPolicy resolves the vehicle tokens callers
+// reserve vehicles by
+func NewReservationHandler(logger logger.Logger, reservationUsecase usecase.ReservationUsecase, privacyPolicy *privacy.Policy, cfg config.Config) *ReservationHandler {
+	return &ReservationHandler{
+		logger:             logger,
+		reservationUsecase: reservationUsecase,
+		privacyPolicy:      privacyPolicy,
+		defaultHold:        cfg.ReservationDefaultHold(),
+		maxHold:            cfg.ReservationMaxHold(),
+	}
+}
+
+// Reserve holds a vehicle for the caller. Only one caller can hold a vehicle at a time; the others get a 409
+func (h *ReservationHandler) Reserve(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ReservationHandler.Reserve")
+	defer span.End()
+
+	holder, err := holderOf(c)
+	if err != nil {
+		return h.respondError(c, span, http.StatusUnauthorized, err)
+	}
+	var req ReserveRequest
+	if err = c.Bind(&req); err != nil {
+		return h.respondError(c, span, http.StatusBadRequest, fmt.Errorf("failed to parse the request body: %v", err))
+	}
+	auditParams(c, req)
+	vehicleID, err := h.vehicleID(req, privacy.ScopeFromContext(ctx))
+	if err != nil {
+		return h.respondError(c, span, http.StatusBadRequest, err)
+	}
+	hold := h.defaultHold
+	if req.Minutes != nil {
+		hold = time.Duration(*req.Minutes) * time.Minute
+		if hold <= 0 || hold > h.maxHold {
+			return h.respondError(c, span, http.StatusBadRequest, fmt.Errorf("invalid minutes: %d; minutes must be between 1 and %d", *req.Minutes, int(h.maxHold/time.Minute)))
+		}
+	}
+	reservation, err := h.reservationUsecase.Reserve(ctx, vehicleID, holder, hold)
+	if err != nil {
+		return h.respondError(c, span, statusOf(err), err)
+	}
+	auditResults(c, 1)
+	return c.JSON(http.StatusCreated, ReservationResponse{Data: &reservation, Success: true, Error: ErrorResponse{}})
+}
+
+// vehicleID returns the ID of the vehicle to reserve, resolving its token if it was given by one. Callers of the
+// public scope can only give a token: reserving by ID would tell them whether a vehicle ID is in use and available
+func (h *ReservationHandler) vehicleID(req ReserveRequest, scope privacy.Scope) (int64, error) {
+	switch {
+	case req.VehicleID != nil && req.VehicleToken != "":
+		return 0, errors.New("vehicle_id and vehicle_token are mutually exclusive")
+	case req.VehicleID != nil && scope == privacy.ScopePublic:
+		return 0, errors.New("vehicle_id is only accepted from trusted API keys; reserve by vehicle_token instead")
+	case req.VehicleToken != "":
+		vehicleID, err := h.privacyPolicy.ResolveToken(req.VehicleToken, time.Now())
+		if err != nil {
+			return 0, fmt.Errorf("invalid vehicle_token: %v", err)
+		}
+		return vehicleID, nil
+	case req.VehicleID == nil:
+		return 0, errors.New("vehicle_id or vehicle_token is a required param")
+	case *req.VehicleID < 0:
+		return 0, fmt.Errorf("invalid vehicle_id: %d", *req.VehicleID)
+	}
+	return *req.VehicleID, nil
+}
+
+// Get returns a reservation of the caller; the reservations of others get a 403
+func (h *ReservationHandler) Get(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "ReservationHandler.Get")
+	defer span.End()
+
+	holder, err := holderOf(c)
+	if err != nil {
+		return h.respondError(c, span, http.StatusUnauthorized, err)
+	}
+	id, err := reservationID(c)
+	if err != nil {
+		return h.respondError(c, span, http.StatusBadRequest, err)
+	}
+	reservation, err := h.reservationUsecase.Get(ctx, id, holder)
+	if err != nil {
+		return h.respondError(c, span, statusOf(err), err)
+	}
+	auditParams(c, vehicleParam(reservation.VehicleID, reservation.VehicleToken))
+	auditResults(c, 1)
+	return c.JSON(http.StatusOK, ReservationResponse{Data: &reservation, Success: true, Error: ErrorResponse{}})
+}
+
+// Cancel releases a hold, or a confirmed reservation
+func (h *ReservationHandler) Cancel(c echo.Context) error {
+	return h.transition(c, "ReservationHandler.Cancel", h.reservationUsecase.Cancel)
+}
+
+// Confirm turns a hold into a ride
+func (h *ReservationHandler) Confirm(c echo.Context) error {
+	return h.transition(c, "ReservationHandler.Confirm", h.reservationUsecase.Confirm)
+}
+
+// Complete ends the ride of a confirmed reservation and hands the vehicle back
+func (h *ReservationHandler) Complete(c echo.Context) error {
+	return h.transition(c, "ReservationHandler.Complete", h.reservationUsecase.Complete)
+}
+
+type transitionFunc func(ctx context.Context, id int64, holder string, version int) (model.Reservation, error)
+
+func (h *ReservationHandler) transition(c echo.Context, spanName string, fn transitionFunc) error {
+	ctx, span := tracer.Start(c.Request().Context(), spanName)
+	defer span.End()
+
+	holder, err := holderOf(c)
+	if err != nil {
+		return h.respondError(c, span, http.StatusUnauthorized, err)
+	}
+	id, err := reservationID(c)
+	if err != nil {
+		return h.respondError(c, span, http.StatusBadRequest, err)
+	}
+	var req TransitionRequest
+	if err = c.Bind(&req); err != nil {
+		return h.respondError(c, span, http.StatusBadRequest, fmt.Errorf("failed to parse the request body: %v", err))
+	}
+	auditParams(c, req)
+	if req.Version <= 0 {
+		return h.respondError(c, span, http.StatusBadRequest, errors.New("version is a required param"))
+	}
+	reservation, err := fn(ctx, id, holder, req.Version)
+	if err != nil {
+		return h.respondError(c, span, statusOf(err), err)
+	}
+	auditParams(c, vehicleParam(reservation.VehicleID, reservation.VehicleToken))
+	auditResults(c, 1)
+	return c.JSON(http.StatusOK, ReservationResponse{Data: &reservation, Success: true, Error: ErrorResponse{}})
+}
+
+// holderOf identifies the caller as a reservation holder by its API key, the way the audit log does
+func holderOf(c echo.Context) (string, error) {
+	holder := callerFingerprint(c.Request().Header.Get(HeaderAPIKey))
+	if holder == "" {
+		return "", fmt.Errorf("reservations are held by API key; send one in %s", HeaderAPIKey)
+	}
+	return holder, nil
+}
+
+func (h *ReservationHandler) respondError(c echo.Context, span trace.Span, status int, err error) error {
+	tracing.RecordError(span, err)
+	if status == http.StatusInternalServerError {
+		h.logger.WithContext(c.Request().Context()).Errorf("failed to handle the reservation request, err: %s", err.Error())
+	} else {
+		h.logger.WithContext(c.Request().Context()).Debugf("rejected the reservation request, err: %s", err.Error())
+	}
+	return c.JSON(status, ReservationResponse{
+		Data:    nil,
+		Success: false,
+		Error: ErrorResponse{
+			Code:    strconv.Itoa(status),
+			Message: err.Error(),
+		},
+	})
+}
+
+func reservationID(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid reservation id: %s", c.Param("id"))
+	}
+	return id, nil
+}
+
+// statusOf maps the errors of the reservation repository to HTTP statuses
+func statusOf(err error) int {
+	switch pkgerrors.Cause(err) {
+	case repository.ErrReservationNotFound:
+		return http.StatusNotFound
+	case repository.ErrNotReservationHolder:
+		return http.StatusForbidden
+	case repository.ErrVehicleUnavailable, repository.ErrStaleReservation, repository.ErrInvalidTransition:
+		return http.StatusConflict
+	case repository.ErrTooManyHolds:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
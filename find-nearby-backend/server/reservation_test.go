@@ -0,0 +1,272 @@
+package server_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/server"
+	usecaseMocks "find-nearby-backend/usecase/mocks"
+
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newReservationContext makes a request of a trusted caller, as every caller is while privacy is disabled
+func newReservationContext(method, path, apiKey, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req = req.WithContext(privacy.ContextWithScope(req.Context(), privacy.ScopeTrusted))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if apiKey != "" {
+		req.Header.Set(server.HeaderAPIKey, apiKey)
+	}
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+// holder is the holder of the reservations made with apiKey
+func holder(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+func TestReservationHandler_Reserve_Success(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations", "alice-key", `{"vehicle_id": 7, "minutes": 5}`)
+	expected := model.Reservation{ID: 1, VehicleID: 7, Holder: holder("alice-key"), Status: model.ReservationStatusHeld, Version: 1}
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Reserve", mock.Anything, int64(7), holder("alice-key"), 5*time.Minute).Return(expected, nil)
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Reserve(c)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	resp := server.ReservationResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, server.ReservationResponse{Data: &expected, Success: true}, resp)
+	reservationUsecaseMock.AssertExpectations(t)
+}
+
+func TestReservationHandler_Reserve_WhenVehicleIsGivenByToken_ShouldReserveItsVehicle(t *testing.T) {
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+	policy := privacy.NewPolicy(cfg)
+	_, token := policy.HideVehicle(privacy.ScopePublic, 0, time.Now())
+	c, rec := newReservationContext(echo.POST, "/reservations", "alice-key", `{"vehicle_token": "`+token+`"}`)
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Reserve", mock.Anything, int64(0), holder("alice-key"), cfg.ReservationDefaultHold()).Return(model.Reservation{ID: 1}, nil)
+	server.NewReservationHandler(log, reservationUsecaseMock, policy, cfg).Reserve(c)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	reservationUsecaseMock.AssertExpectations(t)
+}
+
+func TestReservationHandler_Reserve_WhenVehicleTokenIsInvalid_ShouldReturn400(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations", "alice-key", `{"vehicle_token": "bm90LWEtdG9rZW4"}`)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Reserve(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	resp := server.ReservationResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "invalid vehicle_token: invalid or expired vehicle token", resp.Error.Message)
+	reservationUsecaseMock.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReservationHandler_Reserve_WhenPublicCallerGivesVehicleID_ShouldReturn400(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations", "alice-key", `{"vehicle_id": 7}`)
+	c.SetRequest(c.Request().WithContext(privacy.ContextWithScope(c.Request().Context(), privacy.ScopePublic)))
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Reserve(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	resp := server.ReservationResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "vehicle_id is only accepted from trusted API keys; reserve by vehicle_token instead", resp.Error.Message)
+	reservationUsecaseMock.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReservationHandler_Reserve_WhenAPIKeyIsMissing_ShouldReturn401(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations", "", `{"vehicle_id": 7}`)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Reserve(c)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	reservationUsecaseMock.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReservationHandler_Reserve_WhenMinutesAreMissing_ShouldUseDefaultHold(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations", "alice-key", `{"vehicle_id": 7}`)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Reserve", mock.Anything, int64(7), holder("alice-key"), cfg.ReservationDefaultHold()).Return(model.Reservation{ID: 1}, nil)
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Reserve(c)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	reservationUsecaseMock.AssertExpectations(t)
+}
+
+func TestReservationHandler_Reserve_WhenMinutesExceedMaxHold_ShouldReturn400(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations", "alice-key", `{"vehicle_id": 7, "minutes": 100000}`)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Reserve(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	reservationUsecaseMock.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReservationHandler_Reserve_WhenVehicleIsUnavailable_ShouldReturn409(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations", "bob-key", `{"vehicle_id": 7}`)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Reserve", mock.Anything, int64(7), holder("bob-key"), mock.Anything).
+		Return(model.Reservation{}, errors.Wrap(repository.ErrVehicleUnavailable, "failed to reserve vehicle 7"))
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Reserve(c)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	resp := server.ReservationResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.False(t, resp.Success)
+	assert.Equal(t, "409", resp.Error.Code)
+}
+
+func TestReservationHandler_Reserve_WhenCallerHoldsTooManyVehicles_ShouldReturn429(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations", "bob-key", `{"vehicle_id": 7}`)
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Reserve", mock.Anything, int64(7), holder("bob-key"), mock.Anything).
+		Return(model.Reservation{}, errors.Wrap(repository.ErrTooManyHolds, "failed to reserve vehicle 7"))
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Reserve(c)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func TestReservationHandler_Confirm_WhenVersionIsStale_ShouldReturn409(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations/1/confirm", "alice-key", `{"version": 1}`)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Confirm", mock.Anything, int64(1), holder("alice-key"), 1).
+		Return(model.Reservation{}, errors.Wrap(repository.ErrStaleReservation, "failed to move reservation 1 to confirmed"))
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Confirm(c)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	reservationUsecaseMock.AssertExpectations(t)
+}
+
+func TestReservationHandler_Cancel_WhenCallerIsNotTheHolder_ShouldReturn403(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations/1/cancel", "bob-key", `{"version": 1}`)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Cancel", mock.Anything, int64(1), holder("bob-key"), 1).
+		Return(model.Reservation{}, errors.Wrap(repository.ErrNotReservationHolder, "failed to move reservation 1 to cancelled"))
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Cancel(c)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	reservationUsecaseMock.AssertExpectations(t)
+}
+
+func TestReservationHandler_Complete_ShouldCompleteTheCallersReservation(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations/1/complete", "alice-key", `{"version": 2}`)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+	expected := model.Reservation{ID: 1, VehicleID: 7, Holder: holder("alice-key"), Status: model.ReservationStatusCompleted, Version: 3}
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Complete", mock.Anything, int64(1), holder("alice-key"), 2).Return(expected, nil)
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Complete(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp := server.ReservationResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, server.ReservationResponse{Data: &expected, Success: true}, resp)
+	reservationUsecaseMock.AssertExpectations(t)
+}
+
+func TestReservationHandler_Complete_WhenReservationIsOnlyHeld_ShouldReturn409(t *testing.T) {
+	c, rec := newReservationContext(echo.POST, "/reservations/1/complete", "alice-key", `{"version": 1}`)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Complete", mock.Anything, int64(1), holder("alice-key"), 1).
+		Return(model.Reservation{}, errors.Wrap(repository.ErrInvalidTransition, "failed to move reservation 1 to completed"))
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Complete(c)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	reservationUsecaseMock.AssertExpectations(t)
+}
+
+func TestReservationHandler_Get_WhenIDIsInvalid_ShouldReturn400(t *testing.T) {
+	c, rec := newReservationContext(echo.GET, "/reservations/abc", "alice-key", "")
+	c.SetParamNames("id")
+	c.SetParamValues("abc")
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	server.NewReservationHandler(log, new(usecaseMocks.ReservationUsecase), privacy.NewPolicy(cfg), cfg).Get(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestReservationHandler_Get_WhenCallerIsNotTheHolder_ShouldReturn403(t *testing.T) {
+	c, rec := newReservationContext(echo.GET, "/reservations/1", "bob-key", "")
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	cfg := config.LoadConfig()
+	log := logger.New(cfg.LogLevel(), cfg.LogFormat())
+
+	reservationUsecaseMock := new(usecaseMocks.ReservationUsecase)
+	reservationUsecaseMock.On("Get", mock.Anything, int64(1), holder("bob-key")).
+		Return(model.Reservation{}, errors.Wrap(repository.ErrNotReservationHolder, "failed to get reservation 1"))
+	server.NewReservationHandler(log, reservationUsecaseMock, privacy.NewPolicy(cfg), cfg).Get(c)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -5,12 +5,129 @@ import "find-nearby-backend/model"
 // FindLocationsResponse is a response message
 type FindLocationsResponse struct {
 	Data    []model.Location `json:"data"`
+	Meta    *Meta            `json:"meta,omitempty"`
 	Success bool             `json:"success"`
 	Error   ErrorResponse    `json:"error"`
 }
 
+// Meta describes how the server interpreted a request and what it found. Total counts every vehicle within the radius,
+// so HasMore is set when the limit cut the results; the params lowered to the caps of the caller are listed in Clamped
+type Meta struct {
+	Query       Query   `json:"query"`
+	Total       int     `json:"total"`
+	HasMore     bool    `json:"has_more"`
+	QueryTimeMs float64 `json:"query_time_ms"`
+	Clamped     []Clamp `json:"clamped,omitempty"`
+}
+
+// Query is the search the server ran, after the defaults and caps were applied. Radius is in Units
+type Query struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Radius    float64 `json:"radius"`
+	Limit     int     `json:"limit"`
+	Units     string  `json:"units"`
+	Sort      string  `json:"sort,omitempty"`
+}
+
+// Clamp is a request param that was above its cap. A clamped radius is in the units of the request
+type Clamp struct {
+	Param     string  `json:"param"`
+	Requested float64 `json:"requested"`
+	Applied   float64 `json:"applied"`
+}
+
 // ErrorResponse is an error response message
 type ErrorResponse struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 }
+
+// DeniedResponse is the response message of a request turned away before it reached its handler
+type DeniedResponse struct {
+	Success bool          `json:"success"`
+	Error   ErrorResponse `json:"error"`
+}
+
+// FindLocationsBatchResponse is a response message of a batch search. Data holds one result per origin, in the order of the request
+type FindLocationsBatchResponse struct {
+	Data    []OriginResult `json:"data"`
+	Success bool           `json:"success"`
+	Error   ErrorResponse  `json:"error"`
+}
+
+// OriginResult is the result of one origin of a batch search. Error is set instead of Data and Meta when the origin was rejected
+type OriginResult struct {
+	ID    string           `json:"id"`
+	Data  []model.Location `json:"data"`
+	Meta  *Meta            `json:"meta,omitempty"`
+	Error *ErrorResponse   `json:"error,omitempty"`
+}
+
+// DispatchResponse is a response message of a dispatch assignment
+type DispatchResponse struct {
+	Data    *model.DispatchPlan `json:"data"`
+	Success bool                `json:"success"`
+	Error   ErrorResponse       `json:"error"`
+}
+
+// ReservationResponse is a response message of the reservation endpoints
+type ReservationResponse struct {
+	Data    *model.Reservation `json:"data"`
+	Success bool               `json:"success"`
+	Error   ErrorResponse      `json:"error"`
+}
+
+// FindCorridorResponse is a response message of a corridor search
+type FindCorridorResponse struct {
+	Data    []model.Location `json:"data"`
+	Meta    *CorridorMeta    `json:"meta,omitempty"`
+	Success bool             `json:"success"`
+	Error   ErrorResponse    `json:"error"`
+}
+
+// CorridorMeta is like Meta for a corridor search. Total counts every vehicle within the buffer of the route
+type CorridorMeta struct {
+	Query       CorridorQuery `json:"query"`
+	Total       int           `json:"total"`
+	HasMore     bool          `json:"has_more"`
+	QueryTimeMs float64       `json:"query_time_ms"`
+	Clamped     []Clamp       `json:"clamped,omitempty"`
+}
+
+// CorridorQuery is the corridor search the server ran, after the defaults and caps were applied. Buffer is in Units
+type CorridorQuery struct {
+	Points int     `json:"points"`
+	Buffer float64 `json:"buffer"`
+	Limit  int     `json:"limit"`
+	Order  string  `json:"order"`
+	Units  string  `json:"units"`
+}
+
+// IngestResponse is a response message of an ingested batch of pings
+type IngestResponse struct {
+	Data    *model.IngestResult `json:"data"`
+	Success bool                `json:"success"`
+	Error   ErrorResponse       `json:"error"`
+}
+
+// AnomaliesResponse is a response message of a list of anomalies
+type AnomaliesResponse struct {
+	Data    []model.Anomaly `json:"data"`
+	Success bool            `json:"success"`
+	Error   ErrorResponse   `json:"error"`
+}
+
+// AnomalyResponse is a response message of a reviewed anomaly
+type AnomalyResponse struct {
+	Data    *model.Anomaly `json:"data"`
+	Success bool           `json:"success"`
+	Error   ErrorResponse  `json:"error"`
+}
+
+// AuditRecordsResponse is a response message of a list of audit records
+type AuditRecordsResponse struct {
+	Data    []model.AuditRecord `json:"data"`
+	Success bool                `json:"success"`
+	Error   ErrorResponse       `json:"error"`
+}
@@ -2,45 +2,261 @@ package server
 
 import (
 	"context"
+	"expvar"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
+	"find-nearby-backend/audit"
+	"find-nearby-backend/cache"
+	"find-nearby-backend/config"
+	"find-nearby-backend/database"
 	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/privacy"
 	"find-nearby-backend/repository"
 	"find-nearby-backend/usecase"
 
-	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo"
 )
 
+var (
+	varsMu sync.Mutex
+	vars   = map[string]func() interface{}{}
+)
+
 // Server represents the HTTP Server. Echo is used as the implementation.
 type Server struct {
+	cfg         config.Config
 	address     string
 	apiServer   *echo.Echo
-	db          *sqlx.DB
+	db          *database.Cluster
 	log         logger.Logger
 	serverReady chan bool
+	onShutdown  []func(ctx context.Context) error
+	watcher     *config.Watcher
+
+	cachedLocationsRepo *cache.LocationRepository
+	queryPolicy         *QueryPolicy
+	privacyPolicy       *privacy.Policy
+	router              usecase.Router
+	snapper             usecase.Snapper
+	serviceArea         *model.Area
 }
 
 // Start starts HTTP Server
 func (s *Server) Start() {
-	locationsRepo := repository.NewPostgresLocationRepository(s.db)
-	locationsUsecase := usecase.NewLocationUsecase(locationsRepo)
-	handler := NewHandler(s.log, locationsUsecase)
+	locationsRepo := repository.NewPostgresLocationRepository(s.log, s.db)
+	if s.cfg.CacheEnabled() {
+		s.cachedLocationsRepo = cache.NewLocationRepository(s.log, locationsRepo, cache.NewLRUStore(s.cfg.CacheSize()), s.cfg.CacheGridSize(), s.cfg.CacheTTL(), maxLimit(s.cfg))
+		cached := s.cachedLocationsRepo
+		publishVar("nearby_cache", func() interface{} { return cached.Stats() })
+		locationsRepo = s.cachedLocationsRepo
+	}
+	var invalidator usecase.Invalidator
+	if s.cachedLocationsRepo != nil {
+		invalidator = s.cachedLocationsRepo
+	}
+	reservationRepo := repository.NewPostgresReservationRepository(s.log, s.db)
+	reservationUsecase := usecase.NewReservationUsecase(s.log, reservationRepo, repository.NewPostgresVehicleRepository(s.log, s.db), invalidator, s.cfg.ReservationMaxHolds())
+	// dispatch needs exact distances and fresh results, so it bypasses the nearby cache and reads its candidates from
+	// the primary, where the vehicle statuses and the holds it places are
+	dispatchUsecase := usecase.NewDispatchUsecase(s.log, repository.NewPostgresLocationRepository(s.log, s.db.PrimaryOnly()), repository.NewPostgresVehicleRepository(s.log, s.db),
+		reservationRepo, invalidator, s.cfg.DispatchCandidates(), s.cfg.ReservationDefaultHold(), s.cfg.ReservationMaxHolds())
+	locationsUsecase := privacy.NewLocationUsecase(usecase.NewLocationUsecase(s.log, locationsRepo, s.router, s.cfg.RoutingCandidateFactor()), s.privacyPolicy)
+	anomalyRepo := repository.NewPostgresAnomalyRepository(s.log, s.db)
+	ingestUsecase := usecase.NewIngestUsecase(s.log, repository.NewPostgresIngestRepository(s.log, s.db), anomalyRepo, invalidator, s.anomalyChecks(), s.snapper, s.cfg.IngestServe() == config.IngestServeSnapped, s.cfg.HistoryEnabled())
+	handler := NewHandler(s.log, locationsUsecase, s.queryPolicy)
+	dispatchHandler := NewDispatchHandler(s.log, privacy.NewDispatchUsecase(dispatchUsecase, s.privacyPolicy), s.cfg)
+	reservationHandler := NewReservationHandler(s.log, privacy.NewReservationUsecase(reservationUsecase, s.privacyPolicy), s.privacyPolicy, s.cfg)
+	ingestHandler := NewIngestHandler(s.log, ingestUsecase, s.cfg)
+	anomalyHandler := NewAnomalyHandler(s.log, privacy.NewAnomalyUsecase(usecase.NewAnomalyUsecase(s.log, anomalyRepo), s.privacyPolicy), s.privacyPolicy)
+	auditRepo := repository.NewPostgresAuditRepository(s.log, s.db)
+	auditHandler := NewAuditHandler(s.log, usecase.NewAuditUsecase(s.log, auditRepo), s.cfg)
+	s.apiServer.Use(Tracing(), RequestLogger(s.log), CallerScope(s.privacyPolicy), CallerHolder(s.cfg.ReservationAPIKeys()))
+	audited := s.auditMiddleware(auditRepo)
+	// restricted wraps a route in the audit middleware and lets only apiKeys through; denied requests are audited too
+	restricted := func(apiKeys []string, what string) []echo.MiddlewareFunc {
+		return append(audited[:len(audited):len(audited)], RequireAPIKey(apiKeys, what))
+	}
 	s.apiServer.GET("/ping", handler.Ping)
-	s.apiServer.GET("/locations/find", handler.FindLocations)
+	s.apiServer.GET("/locations/find", handler.FindLocations, audited...)
+	s.apiServer.POST("/locations/find/batch", handler.FindLocationsBatch, audited...)
+	s.apiServer.POST("/locations/find/corridor", handler.FindLocationsAlongRoute, audited...)
+	// ingested positions move vehicles on everyone's map, so only the trackers' keys may send them
+	s.apiServer.POST("/locations/ingest", ingestHandler.Ingest, restricted(s.cfg.IngestAPIKeys(), "report vehicle positions")...)
+	s.apiServer.GET("/anomalies", anomalyHandler.FindAnomalies, audited...)
+	s.apiServer.POST("/anomalies/:id/review", anomalyHandler.Review, restricted(s.cfg.AuditReaderAPIKeys(), "review anomalies")...)
+	// holding vehicles takes them off the map for everyone else, so only the configured keys may
+	holders := restricted(s.cfg.ReservationAPIKeys(), "hold vehicles")
+	s.apiServer.POST("/dispatch/assign", dispatchHandler.Assign, holders...)
+	s.apiServer.POST("/reservations", reservationHandler.Reserve, holders...)
+	s.apiServer.GET("/reservations/:id", reservationHandler.Get, holders...)
+	s.apiServer.POST("/reservations/:id/cancel", reservationHandler.Cancel, holders...)
+	s.apiServer.POST("/reservations/:id/confirm", reservationHandler.Confirm, holders...)
+	s.apiServer.POST("/reservations/:id/complete", reservationHandler.Complete, holders...)
+	s.apiServer.GET("/audit", auditHandler.FindAuditRecords, audited...)
+	// the vars include the command line and memory stats of the process, so only the audit readers see them
+	s.apiServer.GET("/debug/vars", echo.WrapHandler(expvar.Handler()), RequireAPIKey(s.cfg.AuditReaderAPIKeys(), "read the server vars"))
+	if s.watcher != nil {
+		s.watcher.OnReload(s.applyReload)
+		s.watcher.Start()
+	}
+	s.expireHolds(reservationUsecase, s.cfg.ReservationExpiryInterval())
+	if s.cfg.RetentionSchedule() > 0 {
+		s.compactHistory(usecase.NewRetentionUsecase(s.log, repository.NewPostgresHistoryRepository(s.log, s.db), s.retentionPolicy()), s.cfg.RetentionSchedule())
+	}
 	go s.waitForShutdown(s.apiServer)
 	go s.listenServer(s.apiServer)
 	s.serverReady <- true
 }
 
+// WatchConfig makes the server apply the reloadable settings whenever the watcher reloads the config file
+func (s *Server) WatchConfig(watcher *config.Watcher) {
+	s.watcher = watcher
+}
+
+// RouteWith makes the server rank results by drive time over router when requests ask for sort=eta
+func (s *Server) RouteWith(router usecase.Router) {
+	s.router = router
+}
+
+// SnapWith makes the server snap ingested pings onto the roads with snapper
+func (s *Server) SnapWith(snapper usecase.Snapper) {
+	s.snapper = snapper
+}
+
+// RestrictTo makes the server reject or flag ingested pings outside area
+func (s *Server) RestrictTo(area *model.Area) {
+	s.serviceArea = area
+}
+
+// OnShutdown registers a function that is called once the API server has stopped serving requests.
+// Like deferred calls, the functions run in the reverse order of registration
+func (s *Server) OnShutdown(fn func(ctx context.Context) error) {
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
 // ServerReady is a channel that signals whether a server is ready to serve the requests
 func (s *Server) ServerReady() chan bool {
 	return s.serverReady
 }
 
+// auditMiddleware starts the audit writer and returns the middleware recording the requests of a route to it, or
+// none when auditing is disabled. The writer is closed, writing what is left in its buffer, on shutdown
+func (s *Server) auditMiddleware(auditRepo repository.AuditRepository) []echo.MiddlewareFunc {
+	if !s.cfg.AuditEnabled() {
+		return nil
+	}
+	writer := audit.NewWriter(s.log, auditRepo, s.cfg.AuditBufferSize(), s.cfg.AuditBatchSize(), s.cfg.AuditFlushInterval())
+	publishVar("audit", func() interface{} { return writer.Stats() })
+	s.OnShutdown(writer.Close)
+	return []echo.MiddlewareFunc{Audit(writer)}
+}
+
+// expireHolds expires lapsed holds every interval until the server shuts down
+func (s *Server) expireHolds(reservationUsecase usecase.ReservationUsecase, interval time.Duration) {
+	s.every(interval, func(ctx context.Context) error {
+		_, err := reservationUsecase.ExpireHolds(ctx)
+		return err
+	})
+}
+
+// compactHistory compacts the location history every interval until the server shuts down. A compaction that is
+// still running at shutdown is cancelled; the next one carries on where it stopped
+func (s *Server) compactHistory(retentionUsecase usecase.RetentionUsecase, interval time.Duration) {
+	s.every(interval, func(ctx context.Context) error {
+		_, err := retentionUsecase.Compact(ctx, time.Now())
+		return err
+	})
+}
+
+// every runs fn every interval, each run bounded by the interval, until the server shuts down. Errors are logged
+func (s *Server) every(interval time.Duration, fn func(ctx context.Context) error) {
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.OnShutdown(func(context.Context) error {
+		stop()
+		<-done
+		return nil
+	})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runCtx, cancel := context.WithTimeout(ctx, interval)
+				if err := fn(runCtx); err != nil {
+					s.log.Errorf(err.Error())
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+func (s *Server) retentionPolicy() usecase.RetentionPolicy {
+	return usecase.RetentionPolicy{
+		DownsampleAfter:    s.cfg.RetentionDownsampleAfter(),
+		DownsampleInterval: s.cfg.RetentionDownsampleInterval(),
+		Horizon:            s.cfg.RetentionHorizon(),
+		BatchSize:          s.cfg.RetentionBatchSize(),
+	}
+}
+
+func (s *Server) anomalyChecks() usecase.AnomalyChecks {
+	maxSpeeds := make(map[string]float64)
+	for vehicleType, speed := range s.cfg.IngestMaxSpeeds() {
+		maxSpeeds[vehicleType] = float64(speed)
+	}
+	return usecase.AnomalyChecks{
+		MaxSpeeds:   maxSpeeds,
+		MaxSpeed:    float64(s.cfg.IngestMaxSpeed()),
+		ServiceArea: s.serviceArea,
+		Flag:        s.cfg.IngestAnomalyAction() == config.IngestAnomalyFlag,
+	}
+}
+
+func (s *Server) applyReload(reload config.Reload) {
+	diff := make([]string, 0, len(reload.Changes))
+	var needRestart []string
+	for _, change := range reload.Changes {
+		diff = append(diff, fmt.Sprintf("%s: %q -> %q", change.Key, change.Old, change.New))
+		if !change.Reloadable {
+			needRestart = append(needRestart, change.Key)
+		}
+	}
+	if reload.Err != nil {
+		s.log.Errorf("config reload rejected, the previous config stays in effect; changes: %s; err: %s", strings.Join(diff, ", "), reload.Err.Error())
+		return
+	}
+	s.log.Infof("config reloaded; changes: %s", strings.Join(diff, ", "))
+
+	cfg := reload.Config
+	if err := s.log.SetLevel(cfg.LogLevel()); err != nil {
+		s.log.Errorf("failed to apply LOG_LEVEL, err: %s", err.Error())
+	}
+	s.log.SetFormat(cfg.LogFormat())
+	if s.cachedLocationsRepo != nil {
+		s.cachedLocationsRepo.SetTTL(cfg.CacheTTL())
+		s.cachedLocationsRepo.SetMaxLimit(maxLimit(cfg))
+	}
+	s.queryPolicy.Update(cfg)
+	s.privacyPolicy.Update(cfg)
+	if len(needRestart) > 0 {
+		s.log.Warnf("config changes to %s need a restart to take effect", strings.Join(needRestart, ", "))
+	}
+}
+
 func (s *Server) listenServer(apiServer *echo.Echo) {
 	err := apiServer.Start(s.address)
 	if err != http.ErrServerClosed {
@@ -60,17 +276,52 @@ func (s *Server) waitForShutdown(apiServer *echo.Echo) {
 		// Error from closing listeners, or context timeout:
 		s.log.Errorf(err.Error())
 	}
+	for i := len(s.onShutdown) - 1; i >= 0; i-- {
+		if err := s.onShutdown[i](context.Background()); err != nil {
+			s.log.Errorf(err.Error())
+		}
+	}
 	s.log.Infof("API server shutdown complete")
 }
 
 // NewServer is a constructor for a Server
-func NewServer(addr string, db *sqlx.DB, logger logger.Logger) *Server {
+func NewServer(cfg config.Config, db *database.Cluster, logger logger.Logger) *Server {
 	srv := Server{
-		address:     addr,
-		apiServer:   echo.New(),
-		db:          db,
-		log:         logger,
-		serverReady: make(chan bool),
+		cfg:           cfg,
+		address:       cfg.Addr(),
+		apiServer:     echo.New(),
+		db:            db,
+		log:           logger,
+		serverReady:   make(chan bool),
+		queryPolicy:   NewQueryPolicy(cfg),
+		privacyPolicy: privacy.NewPolicy(cfg),
 	}
 	return &srv
 }
+
+// publishVar publishes the value of f under name at /debug/vars. expvar panics when a name is published twice, so a
+// server started again, in tests for instance, takes the name over instead
+func publishVar(name string, f func() interface{}) {
+	varsMu.Lock()
+	defer varsMu.Unlock()
+	if _, published := vars[name]; !published {
+		expvar.Publish(name, expvar.Func(func() interface{} {
+			varsMu.Lock()
+			current := vars[name]
+			varsMu.Unlock()
+			return current()
+		}))
+	}
+	vars[name] = f
+}
+
+// maxLimit is the largest limit any caller can search with, that of an API key if it is larger than the default
+func maxLimit(cfg config.Config) int {
+	limit := cfg.QueryLimits().MaxLimit
+	for _, limits := range cfg.QueryAPIKeyLimits() {
+		if limits.MaxLimit > limit {
+			limit = limits.MaxLimit
+		}
+	}
+	return limit
+}
@@ -0,0 +1,147 @@
+package snapshot
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"find-nearby-backend/model"
+)
+
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+	line    int
+}
+
+func newCSVReader(r io.Reader, columns []string) (*csvReader, error) {
+	reader := &csvReader{r: csv.NewReader(r)}
+	reader.r.FieldsPerRecord = -1
+	reader.r.ReuseRecord = true
+	if len(columns) == 0 {
+		header, err := reader.r.Read()
+		if err == io.EOF {
+			return nil, errors.New("line 1: the header row is missing")
+		}
+		if err != nil {
+			return nil, err
+		}
+		reader.line++
+		for _, column := range header {
+			columns = append(columns, strings.TrimSpace(column))
+		}
+		if err = checkColumns(columns); err != nil {
+			return nil, fmt.Errorf("line 1: %v", err)
+		}
+	} else if err := checkColumns(columns); err != nil {
+		return nil, err
+	}
+	reader.columns = columns
+	return reader, nil
+}
+
+// Read returns the next record. Lines are counted by rows, so a quoted field with line breaks throws the line
+// numbers of the rows after it off
+func (c *csvReader) Read() (model.SnapshotRecord, error) {
+	fields, err := c.r.Read()
+	c.line++
+	if err == io.EOF {
+		return model.SnapshotRecord{}, io.EOF
+	}
+	if parseErr, ok := err.(*csv.ParseError); ok {
+		c.line = parseErr.Line
+		return model.SnapshotRecord{}, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return model.SnapshotRecord{}, err
+	}
+	if len(fields) != len(c.columns) {
+		return model.SnapshotRecord{}, &RowError{Line: c.line, Err: fmt.Errorf("expected %d fields, got %d", len(c.columns), len(fields))}
+	}
+	var record model.SnapshotRecord
+	for i, column := range c.columns {
+		if err = setField(&record, column, strings.TrimSpace(fields[i])); err != nil {
+			return model.SnapshotRecord{}, &RowError{Line: c.line, Err: err}
+		}
+	}
+	if err = validate(record); err != nil {
+		return model.SnapshotRecord{}, &RowError{Line: c.line, Err: err}
+	}
+	return record, nil
+}
+
+func setField(record *model.SnapshotRecord, column, value string) error {
+	var err error
+	switch column {
+	case ColumnVehicleID:
+		record.VehicleID, err = strconv.ParseInt(value, 10, 64)
+	case ColumnLatitude:
+		record.Latitude, err = strconv.ParseFloat(value, 64)
+	case ColumnLongitude:
+		record.Longitude, err = strconv.ParseFloat(value, 64)
+	case ColumnType:
+		record.Type = value
+	case ColumnCity:
+		record.City = value
+	case ColumnStatus:
+		record.Status = value
+	case ColumnRecordedAt:
+		if value != "" {
+			var recordedAt time.Time
+			recordedAt, err = time.Parse(time.RFC3339Nano, value)
+			record.RecordedAt = &recordedAt
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %q", column, value)
+	}
+	return nil
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	fields  []string
+}
+
+func newCSVWriter(w io.Writer, columns []string) (*csvWriter, error) {
+	if len(columns) == 0 {
+		columns = Columns
+	}
+	writer := &csvWriter{w: csv.NewWriter(w), columns: columns, fields: make([]string, len(columns))}
+	return writer, writer.w.Write(columns)
+}
+
+func (c *csvWriter) Write(record model.SnapshotRecord) error {
+	for i, column := range c.columns {
+		c.fields[i] = ""
+		switch column {
+		case ColumnVehicleID:
+			c.fields[i] = strconv.FormatInt(record.VehicleID, 10)
+		case ColumnLatitude:
+			c.fields[i] = strconv.FormatFloat(record.Latitude, 'f', -1, 64)
+		case ColumnLongitude:
+			c.fields[i] = strconv.FormatFloat(record.Longitude, 'f', -1, 64)
+		case ColumnType:
+			c.fields[i] = record.Type
+		case ColumnCity:
+			c.fields[i] = record.City
+		case ColumnStatus:
+			c.fields[i] = record.Status
+		case ColumnRecordedAt:
+			if record.RecordedAt != nil {
+				c.fields[i] = record.RecordedAt.UTC().Format(time.RFC3339Nano)
+			}
+		}
+	}
+	return c.w.Write(c.fields)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
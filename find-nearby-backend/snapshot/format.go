@@ -0,0 +1,170 @@
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"find-nearby-backend/model"
+)
+
+// Formats of a snapshot file
+const (
+	FormatCSV     = "csv"
+	FormatGeoJSON = "geojson"
+	FormatNDJSON  = "ndjson"
+)
+
+// Columns of a snapshot record, as they are named in CSV headers and JSON objects
+const (
+	ColumnVehicleID  = "vehicle_id"
+	ColumnLatitude   = "latitude"
+	ColumnLongitude  = "longitude"
+	ColumnType       = "type"
+	ColumnCity       = "city"
+	ColumnStatus     = "status"
+	ColumnRecordedAt = "recorded_at"
+)
+
+// Columns are every column of a snapshot record, in the order they are exported by default
+var Columns = []string{ColumnVehicleID, ColumnLatitude, ColumnLongitude, ColumnType, ColumnCity, ColumnStatus, ColumnRecordedAt}
+
+// Reader reads the records of a snapshot one at a time. Read returns io.EOF after the last record, and a *RowError
+// for a malformed record, after which it can be called again for the next one. Any other error ends the snapshot
+type Reader interface {
+	Read() (model.SnapshotRecord, error)
+}
+
+// Writer writes the records of a snapshot one at a time. Close finishes the snapshot but doesn't close the
+// underlying writer
+type Writer interface {
+	Write(record model.SnapshotRecord) error
+	Close() error
+}
+
+// RowError is a malformed record and the line of the file it starts on
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// NewReader returns a reader of a snapshot in the given format. columns only applies to CSV: they name the columns
+// of a file without a header row, and when they are empty the first row of the file names them
+func NewReader(format string, r io.Reader, columns []string) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r, columns)
+	case FormatGeoJSON:
+		return newGeoJSONReader(r)
+	case FormatNDJSON:
+		return newNDJSONReader(r), nil
+	default:
+		return nil, formatError(format)
+	}
+}
+
+// NewWriter returns a writer of a snapshot in the given format. columns only applies to CSV, where they are the
+// columns written, in order, after a header row; JSON formats have every field that is set
+func NewWriter(format string, w io.Writer, columns []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, columns)
+	case FormatGeoJSON:
+		return newGeoJSONWriter(w), nil
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	default:
+		return nil, formatError(format)
+	}
+}
+
+// ParseColumns reads a list of columns like vehicle_id,latitude,longitude
+func ParseColumns(list string) ([]string, error) {
+	var columns []string
+	for _, column := range strings.Split(list, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns, checkColumns(columns)
+}
+
+func checkColumns(columns []string) error {
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known := false
+		for _, c := range Columns {
+			known = known || c == column
+		}
+		if !known {
+			return fmt.Errorf("unknown column %q; columns must be among %s", column, strings.Join(Columns, ", "))
+		}
+		if seen[column] {
+			return fmt.Errorf("column %q is given twice", column)
+		}
+		seen[column] = true
+	}
+	for _, required := range []string{ColumnVehicleID, ColumnLatitude, ColumnLongitude} {
+		if !seen[required] {
+			return fmt.Errorf("the %s column is required", required)
+		}
+	}
+	return nil
+}
+
+func formatError(format string) error {
+	return fmt.Errorf("invalid format: %s; format must be %s, %s or %s", format, FormatCSV, FormatGeoJSON, FormatNDJSON)
+}
+
+// validate checks what the database would reject, or store but never find
+func validate(record model.SnapshotRecord) error {
+	if record.VehicleID <= 0 {
+		return fmt.Errorf("invalid vehicle_id: %d; vehicle_id must be a positive integer", record.VehicleID)
+	}
+	if math.IsNaN(record.Latitude) || record.Latitude < -90 || record.Latitude > 90 {
+		return fmt.Errorf("invalid latitude: %v; latitude must be between -90 and 90", record.Latitude)
+	}
+	if math.IsNaN(record.Longitude) || record.Longitude < -180 || record.Longitude > 180 {
+		return fmt.Errorf("invalid longitude: %v; longitude must be between -180 and 180", record.Longitude)
+	}
+	switch record.Status {
+	case "", model.VehicleStatusAvailable, model.VehicleStatusBusy, model.VehicleStatusOffline, model.VehicleStatusMaintenance:
+	default:
+		return fmt.Errorf("invalid status: %s; status must be one of %s, %s, %s or %s", record.Status,
+			model.VehicleStatusAvailable, model.VehicleStatusBusy, model.VehicleStatusOffline, model.VehicleStatusMaintenance)
+	}
+	return nil
+}
+
+// lineCounter tells the line of an offset of what is read through it. Offsets must not decrease from one call of
+// lineAt to the next, which lets it forget every newline before the last offset asked about
+type lineCounter struct {
+	r        io.Reader
+	read     int64
+	newlines []int64
+	line     int
+}
+
+func (l *lineCounter) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == '\n' {
+			l.newlines = append(l.newlines, l.read+int64(i))
+		}
+	}
+	l.read += int64(n)
+	return n, err
+}
+
+func (l *lineCounter) lineAt(offset int64) int {
+	for len(l.newlines) > 0 && l.newlines[0] < offset {
+		l.newlines = l.newlines[1:]
+		l.line++
+	}
+	return l.line + 1
+}
@@ -0,0 +1,126 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"find-nearby-backend/model"
+	"find-nearby-backend/snapshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecords() []model.SnapshotRecord {
+	recordedAt := time.Date(2021, 10, 3, 8, 0, 0, 500, time.UTC)
+	return []model.SnapshotRecord{
+		{VehicleID: 1, Latitude: 1.306002, Longitude: 103.927337, Type: "scooter", City: "Singapore", Status: model.VehicleStatusAvailable, RecordedAt: &recordedAt},
+		{VehicleID: 2, Latitude: -33.8688, Longitude: 151.2093, Type: "car, large", Status: model.VehicleStatusBusy},
+	}
+}
+
+// readAll returns the records of a snapshot and the lines of its malformed records
+func readAll(t *testing.T, reader snapshot.Reader) ([]model.SnapshotRecord, []int) {
+	var records []model.SnapshotRecord
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, lines
+		}
+		if rowErr, ok := err.(*snapshot.RowError); ok {
+			lines = append(lines, rowErr.Line)
+			continue
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func TestSnapshot_ShouldReadBackWhatItWrites(t *testing.T) {
+	for _, format := range []string{snapshot.FormatCSV, snapshot.FormatGeoJSON, snapshot.FormatNDJSON} {
+		var buf bytes.Buffer
+		writer, err := snapshot.NewWriter(format, &buf, nil)
+		require.NoError(t, err)
+		for _, record := range testRecords() {
+			require.NoError(t, writer.Write(record))
+		}
+		require.NoError(t, writer.Close())
+
+		reader, err := snapshot.NewReader(format, &buf, nil)
+		require.NoError(t, err, format)
+		records, malformed := readAll(t, reader)
+		assert.Empty(t, malformed, format)
+		assert.Equal(t, testRecords(), records, format)
+	}
+}
+
+func TestSnapshot_WhenCSVColumnsAreGiven_ShouldWriteOnlyThem(t *testing.T) {
+	columns, err := snapshot.ParseColumns("longitude, latitude,vehicle_id")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	writer, err := snapshot.NewWriter(snapshot.FormatCSV, &buf, columns)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(testRecords()[0]))
+	require.NoError(t, writer.Close())
+	assert.Equal(t, "longitude,latitude,vehicle_id\n103.927337,1.306002,1\n", buf.String())
+
+	_, err = snapshot.ParseColumns("vehicle_id,latitude")
+	assert.EqualError(t, err, "the longitude column is required")
+	_, err = snapshot.ParseColumns("vehicle_id,latitude,longitude,speed")
+	assert.EqualError(t, err, `unknown column "speed"; columns must be among vehicle_id, latitude, longitude, type, city, status, recorded_at`)
+}
+
+func TestSnapshot_WhenRowsAreMalformed_ShouldReportTheirLinesAndReadTheRest(t *testing.T) {
+	csv := "vehicle_id,latitude,longitude,status\n" +
+		"1,1.3,103.9,available\n" +
+		"2,north,103.9,available\n" +
+		"3,1.3,103.9\n" +
+		"4,1.3,103.9,parked\n" +
+		"5,1.3,103.9,\n"
+	ndjson := `{"vehicle_id": 1, "latitude": 1.3, "longitude": 103.9}` + "\n" +
+		"\n" +
+		`{"vehicle_id": 2, "latitude": 1.3}` + "\n" +
+		`{"vehicle_id": 3, "latitude": 1.3, "longitude": 203.9}` + "\n" +
+		`{"vehicle_id": 4, "latitude": 1.3, "longitude": 103.9` + "\n" +
+		`{"vehicle_id": 5, "latitude": 1.3, "longitude": 103.9}` + "\n"
+	geojson := `{"type": "FeatureCollection", "features": [` + "\n" +
+		`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [103.9, 1.3]}, "properties": {"vehicle_id": 1}},` + "\n" +
+		`{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[103.9, 1.3], [103.9, 1.4]]}, "properties": {"vehicle_id": 2}},` + "\n" +
+		"{\n" +
+		`  "type": "Feature", "geometry": {"type": "Point", "coordinates": [103.9, 1.3]}, "properties": {"vehicle_id": -3}` + "\n" +
+		"},\n" +
+		`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [103.9]}, "properties": {"vehicle_id": 4}},` + "\n" +
+		`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [103.9, 1.3]}, "properties": {"vehicle_id": 5, "status": "busy"}}` + "\n" +
+		"]}\n"
+
+	for _, tc := range []struct {
+		format string
+		data   string
+		lines  []int
+	}{
+		{format: snapshot.FormatCSV, data: csv, lines: []int{3, 4, 5}},
+		{format: snapshot.FormatNDJSON, data: ndjson, lines: []int{3, 4, 5}},
+		{format: snapshot.FormatGeoJSON, data: geojson, lines: []int{3, 4, 7}},
+	} {
+		format := tc.format
+		reader, err := snapshot.NewReader(format, strings.NewReader(tc.data), nil)
+		require.NoError(t, err, format)
+		records, malformed := readAll(t, reader)
+		assert.Equal(t, tc.lines, malformed, format)
+		require.Len(t, records, 2, format)
+		assert.Equal(t, int64(1), records[0].VehicleID, format)
+		assert.Equal(t, int64(5), records[1].VehicleID, format)
+	}
+}
+
+func TestSnapshot_WhenGeoJSONIsNotACollection_ShouldReturnError(t *testing.T) {
+	_, err := snapshot.NewReader(snapshot.FormatGeoJSON, strings.NewReader(`{"type": "Polygon", "coordinates": []}`), nil)
+	assert.EqualError(t, err, "not a GeoJSON FeatureCollection: it has no features")
+
+	_, err = snapshot.NewReader(snapshot.FormatGeoJSON, strings.NewReader("{\n\"features\": {}}"), nil)
+	assert.EqualError(t, err, "line 2: not a GeoJSON FeatureCollection: expected [, got {")
+}
@@ -0,0 +1,246 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"find-nearby-backend/model"
+)
+
+// maxLineSize is the longest NDJSON line read; longer ones end the snapshot
+const maxLineSize = 1 << 20
+
+// jsonRecord is a record as an NDJSON line and, without its coordinates, as the properties of a GeoJSON feature
+type jsonRecord struct {
+	VehicleID  int64      `json:"vehicle_id"`
+	Latitude   *float64   `json:"latitude,omitempty"`
+	Longitude  *float64   `json:"longitude,omitempty"`
+	Type       string     `json:"type,omitempty"`
+	City       string     `json:"city,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	RecordedAt *time.Time `json:"recorded_at,omitempty"`
+}
+
+func (j jsonRecord) record() model.SnapshotRecord {
+	record := model.SnapshotRecord{VehicleID: j.VehicleID, Type: j.Type, City: j.City, Status: j.Status, RecordedAt: j.RecordedAt}
+	if j.Latitude != nil {
+		record.Latitude = *j.Latitude
+	}
+	if j.Longitude != nil {
+		record.Longitude = *j.Longitude
+	}
+	return record
+}
+
+func newJSONRecord(record model.SnapshotRecord) jsonRecord {
+	j := jsonRecord{VehicleID: record.VehicleID, Type: record.Type, City: record.City, Status: record.Status}
+	if record.RecordedAt != nil {
+		recordedAt := record.RecordedAt.UTC()
+		j.RecordedAt = &recordedAt
+	}
+	return j
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &ndjsonReader{scanner: scanner}
+}
+
+// Read returns the record of the next line that isn't blank
+func (n *ndjsonReader) Read() (model.SnapshotRecord, error) {
+	for n.scanner.Scan() {
+		n.line++
+		line := bytes.TrimSpace(n.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var j jsonRecord
+		if err := json.Unmarshal(line, &j); err != nil {
+			return model.SnapshotRecord{}, &RowError{Line: n.line, Err: err}
+		}
+		if j.Latitude == nil || j.Longitude == nil {
+			return model.SnapshotRecord{}, &RowError{Line: n.line, Err: errors.New("latitude and longitude are required")}
+		}
+		record := j.record()
+		if err := validate(record); err != nil {
+			return model.SnapshotRecord{}, &RowError{Line: n.line, Err: err}
+		}
+		return record, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return model.SnapshotRecord{}, fmt.Errorf("line %d: %v", n.line+1, err)
+	}
+	return model.SnapshotRecord{}, io.EOF
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{encoder: json.NewEncoder(w)}
+}
+
+func (n *ndjsonWriter) Write(record model.SnapshotRecord) error {
+	j := newJSONRecord(record)
+	j.Latitude, j.Longitude = &record.Latitude, &record.Longitude
+	return n.encoder.Encode(j)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+type feature struct {
+	Type       string          `json:"type"`
+	Geometry   *point          `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+type point struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// geoJSONReader streams the features of a FeatureCollection, so that a collection of millions of vehicles never
+// has to be held in memory. Members of the collection other than features are skipped
+type geoJSONReader struct {
+	lines   *lineCounter
+	decoder *json.Decoder
+	done    bool
+}
+
+func newGeoJSONReader(r io.Reader) (*geoJSONReader, error) {
+	lines := &lineCounter{r: r}
+	reader := &geoJSONReader{lines: lines, decoder: json.NewDecoder(lines)}
+	if err := reader.expect(json.Delim('{')); err != nil {
+		return nil, err
+	}
+	for reader.decoder.More() {
+		key, err := reader.decoder.Token()
+		if err != nil {
+			return nil, reader.syntaxError(err)
+		}
+		if key == "features" {
+			return reader, reader.expect(json.Delim('['))
+		}
+		if err = reader.decoder.Decode(&json.RawMessage{}); err != nil {
+			return nil, reader.syntaxError(err)
+		}
+	}
+	return nil, errors.New("not a GeoJSON FeatureCollection: it has no features")
+}
+
+func (g *geoJSONReader) Read() (model.SnapshotRecord, error) {
+	if g.done || !g.decoder.More() {
+		g.done = true
+		return model.SnapshotRecord{}, io.EOF
+	}
+	var raw json.RawMessage
+	if err := g.decoder.Decode(&raw); err != nil {
+		return model.SnapshotRecord{}, g.syntaxError(err)
+	}
+	line := g.lines.lineAt(g.decoder.InputOffset() - int64(len(raw)))
+	record, err := featureRecord(raw)
+	if err != nil {
+		return model.SnapshotRecord{}, &RowError{Line: line, Err: err}
+	}
+	return record, nil
+}
+
+func featureRecord(raw json.RawMessage) (model.SnapshotRecord, error) {
+	var f feature
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return model.SnapshotRecord{}, err
+	}
+	if f.Type != "Feature" {
+		return model.SnapshotRecord{}, fmt.Errorf("expected a Feature, got %q", f.Type)
+	}
+	if f.Geometry == nil || f.Geometry.Type != "Point" {
+		return model.SnapshotRecord{}, errors.New("the geometry of a feature must be a Point")
+	}
+	var coordinates []float64
+	if err := json.Unmarshal(f.Geometry.Coordinates, &coordinates); err != nil || len(coordinates) < 2 {
+		return model.SnapshotRecord{}, errors.New("the coordinates of a Point must be [longitude, latitude]")
+	}
+	var j jsonRecord
+	if len(f.Properties) > 0 {
+		if err := json.Unmarshal(f.Properties, &j); err != nil {
+			return model.SnapshotRecord{}, fmt.Errorf("invalid properties: %v", err)
+		}
+	}
+	record := j.record()
+	record.Longitude, record.Latitude = coordinates[0], coordinates[1]
+	return record, validate(record)
+}
+
+func (g *geoJSONReader) expect(delim json.Delim) error {
+	token, err := g.decoder.Token()
+	if err != nil {
+		return g.syntaxError(err)
+	}
+	if token != delim {
+		return fmt.Errorf("line %d: not a GeoJSON FeatureCollection: expected %v, got %v", g.lines.lineAt(g.decoder.InputOffset()), delim, token)
+	}
+	return nil
+}
+
+// syntaxError reports where the JSON broke. The decoder can't go on after it, so it ends the snapshot
+func (g *geoJSONReader) syntaxError(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("line %d: %v", g.lines.lineAt(g.decoder.InputOffset()), err)
+}
+
+type geoJSONWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newGeoJSONWriter(w io.Writer) *geoJSONWriter {
+	return &geoJSONWriter{w: w}
+}
+
+// Write writes a feature per line, so that the collection can be read line by line as well
+func (g *geoJSONWriter) Write(record model.SnapshotRecord) error {
+	properties, err := json.Marshal(newJSONRecord(record))
+	if err != nil {
+		return err
+	}
+	coordinates, err := json.Marshal([]float64{record.Longitude, record.Latitude})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(feature{Type: "Feature", Geometry: &point{Type: "Point", Coordinates: coordinates}, Properties: properties})
+	if err != nil {
+		return err
+	}
+	prefix := ",\n"
+	if g.count == 0 {
+		prefix = `{"type":"FeatureCollection","features":[` + "\n"
+	}
+	g.count++
+	_, err = io.WriteString(g.w, prefix+string(data))
+	return err
+}
+
+func (g *geoJSONWriter) Close() error {
+	suffix := "\n]}\n"
+	if g.count == 0 {
+		suffix = `{"type":"FeatureCollection","features":[]}` + "\n"
+	}
+	_, err := io.WriteString(g.w, suffix)
+	return err
+}
@@ -0,0 +1,126 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+)
+
+// exportProgressEvery is how many records are exported between two progress reports
+const exportProgressEvery = 10000
+
+// ImportReport is how an import went
+type ImportReport struct {
+	Imported  int
+	Malformed int
+}
+
+// Importer imports snapshots in batches, in a single transaction, so that an import that fails leaves the
+// vehicles as they were
+type Importer struct {
+	logger    logger.Logger
+	repo      repository.SnapshotRepository
+	batchSize int
+	maxErrors int
+}
+
+// NewImporter is a constructor for Importer. An import stops and stores nothing once more than maxErrors records
+// are malformed; a negative maxErrors lets any number through
+func NewImporter(logger logger.Logger, repo repository.SnapshotRepository, batchSize, maxErrors int) *Importer {
+	return &Importer{logger: logger, repo: repo, batchSize: batchSize, maxErrors: maxErrors}
+}
+
+// Import stores the records of r in the given mode. Malformed records are skipped and passed to malformed, and
+// progress gets the number of records read so far after every batch. When a vehicle has more than one record,
+// the last one wins
+func (i *Importer) Import(ctx context.Context, r Reader, mode string, progress func(read int), malformed func(err *RowError)) (ImportReport, error) {
+	switch mode {
+	case model.SnapshotModeUpsert, model.SnapshotModeReplace:
+	default:
+		return ImportReport{}, fmt.Errorf("invalid mode: %s; mode must be %s or %s", mode, model.SnapshotModeUpsert, model.SnapshotModeReplace)
+	}
+	var report ImportReport
+	read := 0
+	eof := false
+	next := func() ([]model.SnapshotRecord, error) {
+		batch := make([]model.SnapshotRecord, 0, i.batchSize)
+		// a batch can't have two records of a vehicle, since one statement can't update a row twice
+		index := make(map[int64]int, i.batchSize)
+		for !eof && len(batch) < i.batchSize {
+			record, err := r.Read()
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			if rowErr, ok := err.(*RowError); ok {
+				report.Malformed++
+				malformed(rowErr)
+				if i.maxErrors >= 0 && report.Malformed > i.maxErrors {
+					return nil, fmt.Errorf("more than %d malformed records; nothing was imported", i.maxErrors)
+				}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			read++
+			if at, ok := index[record.VehicleID]; ok {
+				batch[at] = record
+				continue
+			}
+			index[record.VehicleID] = len(batch)
+			batch = append(batch, record)
+		}
+		if len(batch) > 0 {
+			progress(read)
+		}
+		return batch, nil
+	}
+	imported, err := i.repo.ImportSnapshot(ctx, mode, next)
+	if err != nil {
+		return report, err
+	}
+	report.Imported = imported
+	i.logger.WithContext(ctx).Infof("imported %d records in %s mode, skipped %d malformed ones", report.Imported, mode, report.Malformed)
+	return report, nil
+}
+
+// Exporter exports snapshots
+type Exporter struct {
+	logger logger.Logger
+	repo   repository.SnapshotRepository
+}
+
+// NewExporter is a constructor for Exporter
+func NewExporter(logger logger.Logger, repo repository.SnapshotRepository) *Exporter {
+	return &Exporter{logger: logger, repo: repo}
+}
+
+// Export writes every location and its vehicle to w and closes it, and returns how many it wrote. progress gets the
+// number of records written so far every 10000 records and at the end
+func (e *Exporter) Export(ctx context.Context, w Writer, progress func(written int)) (int, error) {
+	written := 0
+	err := e.repo.ExportSnapshot(ctx, func(record model.SnapshotRecord) error {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+		written++
+		if written%exportProgressEvery == 0 {
+			progress(written)
+		}
+		return nil
+	})
+	if err != nil {
+		return written, err
+	}
+	if err = w.Close(); err != nil {
+		return written, err
+	}
+	progress(written)
+	e.logger.WithContext(ctx).Infof("exported %d records", written)
+	return written, nil
+}
@@ -0,0 +1,118 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository/mocks"
+	"find-nearby-backend/snapshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// importInto makes the mock repository pull every batch, the way the Postgres one does within its transaction
+func importInto(repo *mocks.SnapshotRepository, mode string, batches *[][]model.SnapshotRecord) {
+	var err error
+	repo.On("ImportSnapshot", mock.Anything, mode, mock.Anything).Return(
+		func(_ context.Context, _ string, next func() ([]model.SnapshotRecord, error)) int {
+			imported := 0
+			for {
+				var batch []model.SnapshotRecord
+				if batch, err = next(); err != nil {
+					*batches = nil
+					return 0
+				}
+				if len(batch) == 0 {
+					return imported
+				}
+				*batches = append(*batches, batch)
+				imported += len(batch)
+			}
+		},
+		func(context.Context, string, func() ([]model.SnapshotRecord, error)) error {
+			return err
+		},
+	)
+}
+
+func TestImporter_ShouldImportInBatchesInTheOrderOfTheFile(t *testing.T) {
+	data := "vehicle_id,latitude,longitude\n1,1.3,103.9\n2,1.3,103.9\n1,1.4,103.8\n3,1.3,103.9\n"
+	reader, err := snapshot.NewReader(snapshot.FormatCSV, strings.NewReader(data), nil)
+	require.NoError(t, err)
+	repo := &mocks.SnapshotRepository{}
+	var batches [][]model.SnapshotRecord
+	importInto(repo, model.SnapshotModeReplace, &batches)
+
+	var progress []int
+	report, err := snapshot.NewImporter(logger.New("debug", "plaintext"), repo, 2, 0).Import(context.Background(), reader, model.SnapshotModeReplace,
+		func(read int) { progress = append(progress, read) },
+		func(err *snapshot.RowError) { t.Fatalf("unexpected malformed record: %v", err) })
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot.ImportReport{Imported: 4}, report)
+	assert.Equal(t, [][]model.SnapshotRecord{
+		{{VehicleID: 1, Latitude: 1.3, Longitude: 103.9}, {VehicleID: 2, Latitude: 1.3, Longitude: 103.9}},
+		{{VehicleID: 1, Latitude: 1.4, Longitude: 103.8}, {VehicleID: 3, Latitude: 1.3, Longitude: 103.9}},
+	}, batches)
+	assert.Equal(t, []int{2, 4}, progress)
+}
+
+func TestImporter_WhenTooManyRecordsAreMalformed_ShouldStop(t *testing.T) {
+	data := `{"vehicle_id": 1, "latitude": 1.3, "longitude": 103.9}` + "\nnope\n" + `{"vehicle_id": 0, "latitude": 1.3, "longitude": 103.9}` + "\n"
+	reader, err := snapshot.NewReader(snapshot.FormatNDJSON, strings.NewReader(data), nil)
+	require.NoError(t, err)
+	repo := &mocks.SnapshotRepository{}
+	var batches [][]model.SnapshotRecord
+	importInto(repo, model.SnapshotModeUpsert, &batches)
+
+	var lines []int
+	report, err := snapshot.NewImporter(logger.New("debug", "plaintext"), repo, 10, 1).Import(context.Background(), reader, model.SnapshotModeUpsert,
+		func(int) {},
+		func(err *snapshot.RowError) { lines = append(lines, err.Line) })
+	assert.EqualError(t, err, "more than 1 malformed records; nothing was imported")
+	assert.Equal(t, 2, report.Malformed)
+	assert.Equal(t, []int{2, 3}, lines)
+	assert.Empty(t, batches)
+}
+
+func TestExporter_ShouldWriteEveryRecordAndCloseTheWriter(t *testing.T) {
+	repo := &mocks.SnapshotRepository{}
+	repo.On("ExportSnapshot", mock.Anything, mock.Anything).Return(func(_ context.Context, fn func(model.SnapshotRecord) error) error {
+		for _, record := range testRecords() {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	var buf bytes.Buffer
+	writer, err := snapshot.NewWriter(snapshot.FormatGeoJSON, &buf, nil)
+	require.NoError(t, err)
+
+	written, err := snapshot.NewExporter(logger.New("debug", "plaintext"), repo).Export(context.Background(), writer, func(int) {})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, written)
+	assert.True(t, strings.HasSuffix(buf.String(), "\n]}\n"))
+}
+
+func TestImporter_WhenABatchHasTwoRecordsOfAVehicle_ShouldKeepTheLast(t *testing.T) {
+	data := "vehicle_id,latitude,longitude,status\n1,1.3,103.9,available\n2,1.3,103.9,\n1,1.4,103.8,busy\n"
+	reader, err := snapshot.NewReader(snapshot.FormatCSV, strings.NewReader(data), nil)
+	require.NoError(t, err)
+	repo := &mocks.SnapshotRepository{}
+	var batches [][]model.SnapshotRecord
+	importInto(repo, model.SnapshotModeUpsert, &batches)
+
+	report, err := snapshot.NewImporter(logger.New("debug", "plaintext"), repo, 10, 0).Import(context.Background(), reader, model.SnapshotModeUpsert, func(int) {}, func(*snapshot.RowError) {})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, [][]model.SnapshotRecord{{
+		{VehicleID: 1, Latitude: 1.4, Longitude: 103.8, Status: model.VehicleStatusBusy},
+		{VehicleID: 2, Latitude: 1.3, Longitude: 103.9},
+	}}, batches)
+}
@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is reported as service.name on every exported span
+const ServiceName = "find-nearby-backend"
+
+// Supported span exporters
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterNone   = "none"
+)
+
+// ShutdownFunc flushes the pending spans and stops the exporter
+type ShutdownFunc func(ctx context.Context) error
+
+// Setup installs the global tracer provider and the W3C trace context propagator.
+// Spans go to an OTLP/HTTP collector, stdout or a file depending on the configuration
+func Setup(cfg config.Config, log logger.Logger) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporterName := resolveExporter(cfg.TracingExporter(), cfg.TracingOTLPEndpoint(), cfg.TracingFilePath())
+	if exporterName == ExporterNone {
+		log.Infof("tracing disabled, spans are not exported")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closeOutput, err := newExporter(exporterName, cfg.TracingOTLPEndpoint(), cfg.TracingFilePath())
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	log.Infof("tracing initialized with %s exporter", exporterName)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeErr := closeOutput(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+func resolveExporter(exporter, otlpEndpoint, filePath string) string {
+	if exporter != "" {
+		return exporter
+	}
+	if otlpEndpoint != "" {
+		return ExporterOTLP
+	}
+	if filePath != "" {
+		return ExporterFile
+	}
+	return ExporterStdout
+}
+
+func newExporter(name, otlpEndpoint, filePath string) (sdktrace.SpanExporter, func() error, error) {
+	noop := func() error { return nil }
+	switch name {
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithInsecure()}
+		if otlpEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(otlpEndpoint))
+		}
+		exporter, err := otlptracehttp.New(context.Background(), opts...)
+		return exporter, noop, err
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, noop, err
+	case ExporterFile:
+		if filePath == "" {
+			return nil, nil, fmt.Errorf("tracing exporter %q requires TRACING_FILE_PATH", name)
+		}
+		f, err := os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exporter, f.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown tracing exporter %q; valid exporters are otlp, stdout, file and none", name)
+	}
+}
+
+// Attribute keys shared by the spans of a nearby search
+const (
+	LatitudeKey    = attribute.Key("nearby.latitude")
+	LongitudeKey   = attribute.Key("nearby.longitude")
+	RadiusKey      = attribute.Key("nearby.radius")
+	LimitKey       = attribute.Key("nearby.limit")
+	ResultCountKey = attribute.Key("nearby.result_count")
+	ClampedKey     = attribute.Key("nearby.clamped")
+	BatchSizeKey   = attribute.Key("nearby.batch_size")
+	BufferKey      = attribute.Key("nearby.buffer")
+	RoutePointsKey = attribute.Key("nearby.route_points")
+)
+
+// QueryAttributes returns the attributes describing a nearby search
+func QueryAttributes(latitude, longitude float64, radius, limit int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		LatitudeKey.Float64(latitude),
+		LongitudeKey.Float64(longitude),
+		RadiusKey.Int(radius),
+		LimitKey.Int(limit),
+	}
+}
+
+// RecordError marks the span as failed
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
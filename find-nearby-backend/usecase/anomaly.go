@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"context"
+	"math"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/tracing"
+
+	"github.com/pkg/errors"
+)
+
+// nullIslandTolerance is how close, in degrees, a fix must be to (0, 0) to count as a tracker with no fix
+const nullIslandTolerance = 0.0001
+
+// AnomalyChecks are the checks pings go through on ingest. MaxSpeeds are the speed limits in km/h by vehicle type
+// and MaxSpeed the limit of the other types; zero turns the speed check off for them. A nil ServiceArea lets pings be
+// anywhere. Pings over the speed limit or outside the service area are stored and recorded as flagged when Flag is set
+// and dropped otherwise; pings at (0, 0) are always dropped, since a tracker without a fix is never right
+type AnomalyChecks struct {
+	MaxSpeeds   map[string]float64
+	MaxSpeed    float64
+	ServiceArea *model.Area
+	Flag        bool
+}
+
+// check returns the pings of a vehicle, oldest first, that are to be stored and the anomalies found among them.
+// Speeds are measured from the last fix that passed every check, starting with the one stored before the batch.
+// Pings recorded before that one, such as fixes a tracker buffered while offline, are measured back from it instead,
+// and aren't measured from in turn, so that a back-dated ping can't vouch for a jump of the pings after it
+func (a AnomalyChecks) check(track model.Track, pings []model.Ping) ([]model.Ping, []model.Anomaly) {
+	var accepted []model.Ping
+	var anomalies []model.Anomaly
+	var previous *model.Ping
+	if track.RecordedAt != nil {
+		previous = &model.Ping{VehicleID: track.VehicleID, Latitude: track.Latitude, Longitude: track.Longitude, RecordedAt: *track.RecordedAt}
+	}
+	action := model.AnomalyActionRejected
+	if a.Flag {
+		action = model.AnomalyActionFlagged
+	}
+	maxSpeed, ok := a.MaxSpeeds[track.Type]
+	if !ok {
+		maxSpeed = a.MaxSpeed
+	}
+	for i := range pings {
+		ping := pings[i]
+		backdated := previous != nil && ping.RecordedAt.Before(previous.RecordedAt)
+		anomaly := model.Anomaly{VehicleID: ping.VehicleID, Action: action, Latitude: ping.Latitude, Longitude: ping.Longitude, RecordedAt: ping.RecordedAt}
+		switch {
+		case math.Abs(ping.Latitude) < nullIslandTolerance && math.Abs(ping.Longitude) < nullIslandTolerance:
+			anomaly.Kind, anomaly.Action = model.AnomalyKindNullIsland, model.AnomalyActionRejected
+		case a.ServiceArea != nil && !a.ServiceArea.Contains(ping.Latitude, ping.Longitude):
+			anomaly.Kind = model.AnomalyKindOutOfArea
+		case previous != nil && maxSpeed > 0:
+			// fixes taken within the same second are measured as a second apart, so jitter between them isn't a teleport
+			seconds := math.Max(math.Abs(ping.RecordedAt.Sub(previous.RecordedAt).Seconds()), 1)
+			speed := greatCircle(previous.Latitude, previous.Longitude, ping.Latitude, ping.Longitude) / seconds * 3.6
+			if speed > maxSpeed {
+				anomaly.Kind, anomaly.Speed, anomaly.MaxSpeed = model.AnomalyKindSpeed, &speed, &maxSpeed
+			}
+		}
+		if anomaly.Kind == "" {
+			accepted = append(accepted, ping)
+			if !backdated {
+				previous = &pings[i]
+			}
+			continue
+		}
+		anomalies = append(anomalies, anomaly)
+		if anomaly.Action == model.AnomalyActionFlagged {
+			accepted = append(accepted, ping)
+		}
+	}
+	return accepted, anomalies
+}
+
+// greatCircle is the distance between two points in meters
+func greatCircle(lat1, lng1, lat2, lng2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * 6371008.8 * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// AnomalyUsecase lists the anomalies found on ingest for review and marks them as reviewed
+type AnomalyUsecase interface {
+	FindAnomalies(ctx context.Context, filter model.AnomalyFilter) ([]model.Anomaly, error)
+	Review(ctx context.Context, id int64, reviewer string) (model.Anomaly, error)
+}
+
+type anomalyUsecase struct {
+	logger            logger.Logger
+	anomalyRepository repository.AnomalyRepository
+}
+
+// NewAnomalyUsecase is a constructor for anomalyUsecase
+func NewAnomalyUsecase(logger logger.Logger, anomalyRepository repository.AnomalyRepository) AnomalyUsecase {
+	return &anomalyUsecase{logger: logger, anomalyRepository: anomalyRepository}
+}
+
+// FindAnomalies returns the anomalies matching filter, newest first
+func (a anomalyUsecase) FindAnomalies(ctx context.Context, filter model.AnomalyFilter) ([]model.Anomaly, error) {
+	ctx, span := tracer.Start(ctx, "anomalyUsecase.FindAnomalies")
+	defer span.End()
+	span.SetAttributes(tracing.LimitKey.Int(filter.Limit))
+
+	anomalies, err := a.anomalyRepository.FindAnomalies(ctx, filter)
+	if err != nil {
+		err = errors.Wrap(err, "failed to find anomalies")
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(tracing.ResultCountKey.Int(len(anomalies)))
+	return anomalies, nil
+}
+
+// Review marks an anomaly as reviewed
+func (a anomalyUsecase) Review(ctx context.Context, id int64, reviewer string) (model.Anomaly, error) {
+	ctx, span := tracer.Start(ctx, "anomalyUsecase.Review")
+	defer span.End()
+
+	anomaly, err := a.anomalyRepository.Review(ctx, id, reviewer)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to review anomaly %d", id)
+		tracing.RecordError(span, err)
+		return model.Anomaly{}, err
+	}
+	return anomaly, nil
+}
@@ -0,0 +1,92 @@
+package usecase
+
+import "math"
+
+// minCostAssignment solves the assignment problem for a rectangular cost matrix with the Hungarian algorithm
+// in O(n²m). It returns, for every row, the column assigned to it, or -1 when there are more rows than columns
+// and the row was left out. Ties are broken towards lower row and column indexes, so the result is deterministic
+func minCostAssignment(cost [][]float64) []int {
+	n := len(cost)
+	if n == 0 || len(cost[0]) == 0 {
+		assignment := make([]int, n)
+		for i := range assignment {
+			assignment[i] = -1
+		}
+		return assignment
+	}
+	m := len(cost[0])
+	if n > m {
+		transposed := make([][]float64, m)
+		for j := range transposed {
+			transposed[j] = make([]float64, n)
+			for i := range cost {
+				transposed[j][i] = cost[i][j]
+			}
+		}
+		assignment := make([]int, n)
+		for i := range assignment {
+			assignment[i] = -1
+		}
+		for j, i := range minCostAssignment(transposed) {
+			assignment[i] = j
+		}
+		return assignment
+	}
+
+	// potentials u and v, and p[j] is the row matched to column j; everything is 1-indexed with column 0 as a sentinel
+	u := make([]float64, n+1)
+	v := make([]float64, m+1)
+	p := make([]int, m+1)
+	way := make([]int, m+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, m+1)
+		used := make([]bool, m+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				if cur := cost[i0-1][j-1] - u[i0] - v[j]; cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	assignment := make([]int, n)
+	for j := 1; j <= m; j++ {
+		if p[j] != 0 {
+			assignment[p[j]-1] = j - 1
+		}
+	}
+	return assignment
+}
@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"context"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/tracing"
+
+	"github.com/pkg/errors"
+)
+
+// AuditUsecase reads the audit log
+type AuditUsecase interface {
+	FindAuditRecords(ctx context.Context, filter model.AuditFilter) ([]model.AuditRecord, error)
+}
+
+type auditUsecase struct {
+	logger          logger.Logger
+	auditRepository repository.AuditRepository
+}
+
+// NewAuditUsecase is a constructor for auditUsecase
+func NewAuditUsecase(logger logger.Logger, auditRepository repository.AuditRepository) AuditUsecase {
+	return &auditUsecase{logger: logger, auditRepository: auditRepository}
+}
+
+// FindAuditRecords returns the audit records matching filter, newest first
+func (a auditUsecase) FindAuditRecords(ctx context.Context, filter model.AuditFilter) ([]model.AuditRecord, error) {
+	ctx, span := tracer.Start(ctx, "auditUsecase.FindAuditRecords")
+	defer span.End()
+	span.SetAttributes(tracing.LimitKey.Int(filter.Limit))
+
+	records, err := a.auditRepository.FindAuditRecords(ctx, filter)
+	if err != nil {
+		err = errors.Wrap(err, "failed to find audit records")
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(tracing.ResultCountKey.Int(len(records)))
+	return records, nil
+}
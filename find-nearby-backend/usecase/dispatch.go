@@ -0,0 +1,193 @@
+package usecase
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/tracing"
+
+	"github.com/pkg/errors"
+)
+
+// DispatchUsecase assigns vehicles to ride requests
+type DispatchUsecase interface {
+	Assign(ctx context.Context, requests []model.RideRequest, maxPickupDistance int, holder string) (model.DispatchPlan, error)
+}
+
+type dispatchUsecase struct {
+	logger                logger.Logger
+	locationRepository    repository.LocationRepository
+	vehicleRepository     repository.VehicleRepository
+	reservationRepository repository.ReservationRepository
+	invalidator           Invalidator
+	candidates            int
+	hold                  time.Duration
+	maxHolds              int
+}
+
+// NewDispatchUsecase is a constructor for dispatchUsecase. candidates is how many of the nearest vehicles
+// are considered for every ride request, before vehicles that aren't available are filtered out, and hold is how
+// long an assigned vehicle is held, and maxHolds how many vehicles a holder can hold at a time. locationRepository and vehicleRepository should read from the same node, so
+// that candidates and their statuses agree. invalidator may be nil when nearby results aren't cached
+func NewDispatchUsecase(logger logger.Logger, locationRepository repository.LocationRepository, vehicleRepository repository.VehicleRepository,
+	reservationRepository repository.ReservationRepository, invalidator Invalidator, candidates int, hold time.Duration, maxHolds int) DispatchUsecase {
+	return &dispatchUsecase{
+		logger:                logger,
+		locationRepository:    locationRepository,
+		vehicleRepository:     vehicleRepository,
+		reservationRepository: reservationRepository,
+		invalidator:           invalidator,
+		candidates:            candidates,
+		hold:                  hold,
+		maxHolds:              maxHolds,
+	}
+}
+
+// Assign finds the available vehicles within maxPickupDistance meters of every ride request and assigns at most
+// one vehicle to every request so that as many requests as possible are served with the least total pickup distance.
+// Every assigned vehicle is held for holder, so that it can't be reserved or dispatched again until the hold is
+// confirmed, cancelled or lapses; a request whose vehicle was taken in the meantime is left unassigned, and so are
+// the requests left once holder holds as many vehicles as it may
+func (d dispatchUsecase) Assign(ctx context.Context, requests []model.RideRequest, maxPickupDistance int, holder string) (model.DispatchPlan, error) {
+	ctx, span := tracer.Start(ctx, "dispatchUsecase.Assign")
+	defer span.End()
+	span.SetAttributes(tracing.BatchSizeKey.Int(len(requests)))
+
+	plan := model.DispatchPlan{Assignments: []model.Assignment{}, Unassigned: []string{}, MaxPickupDistance: maxPickupDistance}
+	if len(requests) == 0 {
+		return plan, nil
+	}
+	queries := make([]model.NearbyQuery, len(requests))
+	for i, r := range requests {
+		queries[i] = model.NearbyQuery{Latitude: r.Latitude, Longitude: r.Longitude, Radius: maxPickupDistance, Limit: d.candidates}
+	}
+	// the vehicles the caller holds already can't be held again, so they aren't candidates either
+	candidates, err := d.locationRepository.FindVehicleLocationsBatch(repository.ContextWithHolder(ctx, ""), queries)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to find the candidate vehicles")
+		tracing.RecordError(span, err)
+		return model.DispatchPlan{}, err
+	}
+
+	var vehicleIDs []int64
+	located := make(map[int64]model.Location)
+	for _, c := range candidates {
+		for _, location := range c.Locations {
+			if _, ok := located[location.VehicleID]; !ok {
+				located[location.VehicleID] = location
+				vehicleIDs = append(vehicleIDs, location.VehicleID)
+			}
+		}
+	}
+	statuses, err := d.vehicleRepository.FindVehicleStatuses(ctx, vehicleIDs)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to find the statuses of the candidate vehicles")
+		tracing.RecordError(span, err)
+		return model.DispatchPlan{}, err
+	}
+	available := vehicleIDs[:0]
+	for _, id := range vehicleIDs {
+		if assignable(statuses, id) {
+			available = append(available, id)
+		}
+	}
+	sort.Slice(available, func(i, j int) bool { return available[i] < available[j] })
+
+	cost, infeasible := costMatrix(requests, candidates, available, statuses)
+	full := false
+	for i, j := range minCostAssignment(cost) {
+		if j < 0 || cost[i][j] >= infeasible || full {
+			plan.Unassigned = append(plan.Unassigned, requests[i].ID)
+			continue
+		}
+		reservation, err := d.reservationRepository.Create(ctx, available[j], holder, d.hold, d.maxHolds)
+		if err == repository.ErrVehicleUnavailable || err == repository.ErrTooManyHolds {
+			full = err == repository.ErrTooManyHolds
+			plan.Unassigned = append(plan.Unassigned, requests[i].ID)
+			continue
+		}
+		if err != nil {
+			d.release(ctx, plan.Assignments, holder, located)
+			err = errors.Wrapf(err, "failed to hold vehicle %d", available[j])
+			tracing.RecordError(span, err)
+			return model.DispatchPlan{}, err
+		}
+		plan.Assignments = append(plan.Assignments, model.Assignment{RequestID: requests[i].ID, VehicleID: available[j], Distance: cost[i][j], Reservation: reservation})
+		plan.TotalDistance += cost[i][j]
+	}
+	if len(plan.Assignments) > 0 {
+		invalidate(ctx, d.logger, d.invalidator, heldVehicles(plan.Assignments), nil)
+	}
+	span.SetAttributes(tracing.ResultCountKey.Int(len(plan.Assignments)))
+	d.logger.WithContext(ctx).Debugf("assigned %d of %d ride requests to %d available vehicles", len(plan.Assignments), len(requests), len(available))
+	return plan, nil
+}
+
+// release cancels the holds of assignments made before an assignment failed. A failure is only logged: the holds lapse
+// on their own. located has where the vehicles are, for them to show up again in the cached nearby results there
+func (d dispatchUsecase) release(ctx context.Context, assignments []model.Assignment, holder string, located map[int64]model.Location) {
+	for _, assignment := range assignments {
+		reservation := assignment.Reservation
+		if _, err := d.reservationRepository.Transition(ctx, reservation.ID, holder, reservation.Version, model.ReservationStatusCancelled); err != nil {
+			d.logger.WithContext(ctx).Warnf("failed to release the hold on vehicle %d, err: %s", assignment.VehicleID, err.Error())
+		}
+	}
+	if len(assignments) == 0 {
+		return
+	}
+	vehicleIDs := heldVehicles(assignments)
+	positions := make([]model.Location, len(vehicleIDs))
+	for i, id := range vehicleIDs {
+		positions[i] = located[id]
+	}
+	invalidate(ctx, d.logger, d.invalidator, vehicleIDs, positions)
+}
+
+// heldVehicles returns the vehicles of assignments, whose cached nearby results still show them as available
+func heldVehicles(assignments []model.Assignment) []int64 {
+	vehicleIDs := make([]int64, len(assignments))
+	for i, assignment := range assignments {
+		vehicleIDs[i] = assignment.VehicleID
+	}
+	return vehicleIDs
+}
+
+// assignable tells whether a vehicle is available. Like in nearby searches, a vehicle without a status, such as one
+// that was imported with its location only, counts as available
+func assignable(statuses map[int64]string, vehicleID int64) bool {
+	status, ok := statuses[vehicleID]
+	return !ok || status == model.VehicleStatusAvailable
+}
+
+// costMatrix returns the pickup distance of every available vehicle to every request. Pairs where the vehicle isn't
+// a candidate of the request cost more than any set of feasible pairs together, which makes the solver serve as many
+// requests as it can before it minimises the distance
+func costMatrix(requests []model.RideRequest, candidates []model.NearbyLocations, available []int64, statuses map[int64]string) ([][]float64, float64) {
+	column := make(map[int64]int, len(available))
+	for j, id := range available {
+		column[id] = j
+	}
+	infeasible := 1.0
+	for _, c := range candidates {
+		for _, location := range c.Locations {
+			infeasible += location.Distance
+		}
+	}
+	cost := make([][]float64, len(requests))
+	for i := range requests {
+		cost[i] = make([]float64, len(available))
+		for j := range cost[i] {
+			cost[i][j] = infeasible
+		}
+		for _, location := range candidates[i].Locations {
+			if assignable(statuses, location.VehicleID) {
+				cost[i][column[location.VehicleID]] = location.Distance
+			}
+		}
+	}
+	return cost, infeasible
+}
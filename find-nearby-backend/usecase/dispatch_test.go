@@ -0,0 +1,195 @@
+package usecase_test
+
+import (
+	"context"
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	repositoryMock "find-nearby-backend/repository/mocks"
+	"find-nearby-backend/usecase"
+
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type DispatchTestSuite struct {
+	suite.Suite
+	usecase      usecase.DispatchUsecase
+	locations    *repositoryMock.LocationRepository
+	vehicles     *repositoryMock.VehicleRepository
+	reservations *repositoryMock.ReservationRepository
+	invalidator  *countingInvalidator
+}
+
+func (suite *DispatchTestSuite) SetupTest() {
+	cfg := config.LoadConfig()
+	suite.locations = &repositoryMock.LocationRepository{}
+	suite.vehicles = &repositoryMock.VehicleRepository{}
+	suite.reservations = &repositoryMock.ReservationRepository{}
+	suite.invalidator = &countingInvalidator{}
+	suite.usecase = usecase.NewDispatchUsecase(logger.New(cfg.LogLevel(), cfg.LogFormat()), suite.locations, suite.vehicles, suite.reservations, suite.invalidator, 5, 2*time.Minute, 3)
+}
+
+// hold makes the reservation repository hold every vehicle it is asked for
+func (suite *DispatchTestSuite) hold() {
+	suite.reservations.On("Create", mock.Anything, mock.Anything, "dispatcher", 2*time.Minute, 3).Return(
+		func(_ context.Context, vehicleID int64, holder string, _ time.Duration, _ int) model.Reservation {
+			return held(vehicleID)
+		}, nil)
+}
+
+func held(vehicleID int64) model.Reservation {
+	return model.Reservation{ID: 100 + vehicleID, VehicleID: vehicleID, Holder: "dispatcher", Status: model.ReservationStatusHeld, Version: 1}
+}
+
+func (suite *DispatchTestSuite) TestAssign_ShouldMinimiseTotalPickupDistance() {
+	// assigning greedily by distance sends vehicle 1 to "a" and vehicle 2 to "b" for a total of 1100m
+	requests := []model.RideRequest{{ID: "a", Latitude: 1.30, Longitude: 103.90}, {ID: "b", Latitude: 1.31, Longitude: 103.91}}
+	suite.locations.On("FindVehicleLocationsBatch", mock.Anything, []model.NearbyQuery{
+		{Latitude: 1.30, Longitude: 103.90, Radius: 3000, Limit: 5},
+		{Latitude: 1.31, Longitude: 103.91, Radius: 3000, Limit: 5},
+	}).Return([]model.NearbyLocations{
+		{Locations: []model.Location{{VehicleID: 1, Distance: 100}, {VehicleID: 2, Distance: 120}}, Total: 2},
+		{Locations: []model.Location{{VehicleID: 1, Distance: 110}, {VehicleID: 2, Distance: 1000}}, Total: 2},
+	}, nil)
+	suite.vehicles.On("FindVehicleStatuses", mock.Anything, []int64{1, 2}).Return(map[int64]string{1: "available", 2: "available"}, nil)
+
+	suite.hold()
+
+	plan, err := suite.usecase.Assign(context.Background(), requests, 3000, "dispatcher")
+	suite.NoError(err)
+	suite.Equal(model.DispatchPlan{
+		Assignments: []model.Assignment{
+			{RequestID: "a", VehicleID: 2, Distance: 120, Reservation: held(2)},
+			{RequestID: "b", VehicleID: 1, Distance: 110, Reservation: held(1)},
+		},
+		Unassigned:        []string{},
+		TotalDistance:     230,
+		MaxPickupDistance: 3000,
+	}, plan)
+	suite.Equal(1, suite.invalidator.calls)
+	suite.Equal([]int64{2, 1}, suite.invalidator.vehicleIDs)
+}
+
+func (suite *DispatchTestSuite) TestAssign_ShouldSkipVehiclesThatAreNotAvailable() {
+	requests := []model.RideRequest{{ID: "a", Latitude: 1.30, Longitude: 103.90}, {ID: "b", Latitude: 1.31, Longitude: 103.91}}
+	suite.locations.On("FindVehicleLocationsBatch", mock.Anything, mock.Anything).Return([]model.NearbyLocations{
+		{Locations: []model.Location{{VehicleID: 3, Distance: 50}, {VehicleID: 1, Distance: 100}}, Total: 2},
+		{Locations: []model.Location{{VehicleID: 2, Distance: 10}}, Total: 1},
+	}, nil)
+	// vehicle 3 is busy and vehicle 2 is in maintenance
+	suite.vehicles.On("FindVehicleStatuses", mock.Anything, []int64{3, 1, 2}).Return(map[int64]string{1: "available", 2: "maintenance", 3: "busy"}, nil)
+
+	suite.hold()
+
+	plan, err := suite.usecase.Assign(context.Background(), requests, 3000, "dispatcher")
+	suite.NoError(err)
+	suite.Equal([]model.Assignment{{RequestID: "a", VehicleID: 1, Distance: 100, Reservation: held(1)}}, plan.Assignments)
+	suite.Equal([]string{"b"}, plan.Unassigned)
+}
+
+func (suite *DispatchTestSuite) TestAssign_WhenAVehicleHasNoStatus_ShouldCountItAsAvailable() {
+	requests := []model.RideRequest{{ID: "a"}}
+	suite.locations.On("FindVehicleLocationsBatch", mock.Anything, mock.Anything).Return([]model.NearbyLocations{
+		{Locations: []model.Location{{VehicleID: 4, Distance: 40}}, Total: 1},
+	}, nil)
+	// vehicle 4 was imported with its location only, so it shows up in nearby searches
+	suite.vehicles.On("FindVehicleStatuses", mock.Anything, []int64{4}).Return(map[int64]string{}, nil)
+
+	suite.hold()
+
+	plan, err := suite.usecase.Assign(context.Background(), requests, 3000, "dispatcher")
+	suite.NoError(err)
+	suite.Equal([]model.Assignment{{RequestID: "a", VehicleID: 4, Distance: 40, Reservation: held(4)}}, plan.Assignments)
+}
+
+func (suite *DispatchTestSuite) TestAssign_WhenVehiclesAreScarce_ShouldServeAsManyRequestsAsPossible() {
+	// "a" is closest to the only vehicle "b" can reach, so serving "b" means sending "a" further away
+	requests := []model.RideRequest{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	suite.locations.On("FindVehicleLocationsBatch", mock.Anything, mock.Anything).Return([]model.NearbyLocations{
+		{Locations: []model.Location{{VehicleID: 1, Distance: 10}, {VehicleID: 2, Distance: 900}}, Total: 2},
+		{Locations: []model.Location{{VehicleID: 1, Distance: 800}}, Total: 1},
+		{},
+	}, nil)
+	suite.vehicles.On("FindVehicleStatuses", mock.Anything, []int64{1, 2}).Return(map[int64]string{1: "available", 2: "available"}, nil)
+
+	suite.hold()
+
+	plan, err := suite.usecase.Assign(context.Background(), requests, 3000, "dispatcher")
+	suite.NoError(err)
+	suite.Equal([]model.Assignment{
+		{RequestID: "a", VehicleID: 2, Distance: 900, Reservation: held(2)},
+		{RequestID: "b", VehicleID: 1, Distance: 800, Reservation: held(1)},
+	}, plan.Assignments)
+	suite.Equal([]string{"c"}, plan.Unassigned)
+	suite.Equal(1700.0, plan.TotalDistance)
+}
+
+func (suite *DispatchTestSuite) TestAssign_WhenRepoReturnsError_ShouldReturnError() {
+	err := errors.New("some repo error")
+	suite.locations.On("FindVehicleLocationsBatch", mock.Anything, mock.Anything).Return(nil, err)
+
+	_, actualErr := suite.usecase.Assign(context.Background(), []model.RideRequest{{ID: "a"}}, 3000, "dispatcher")
+	suite.EqualError(actualErr, errors.Wrapf(err, "failed to find the candidate vehicles").Error())
+	suite.vehicles.AssertNotCalled(suite.T(), "FindVehicleStatuses", mock.Anything, mock.Anything)
+}
+
+func (suite *DispatchTestSuite) TestAssign_WhenVehicleIsHeldInTheMeantime_ShouldLeaveItsRequestUnassigned() {
+	requests := []model.RideRequest{{ID: "a"}, {ID: "b"}}
+	suite.locations.On("FindVehicleLocationsBatch", mock.Anything, mock.Anything).Return([]model.NearbyLocations{
+		{Locations: []model.Location{{VehicleID: 1, Distance: 100}}, Total: 1},
+		{Locations: []model.Location{{VehicleID: 2, Distance: 200}}, Total: 1},
+	}, nil)
+	suite.vehicles.On("FindVehicleStatuses", mock.Anything, []int64{1, 2}).Return(map[int64]string{1: "available", 2: "available"}, nil)
+	suite.reservations.On("Create", mock.Anything, int64(1), "dispatcher", 2*time.Minute, 3).Return(model.Reservation{}, repository.ErrVehicleUnavailable)
+	suite.reservations.On("Create", mock.Anything, int64(2), "dispatcher", 2*time.Minute, 3).Return(held(2), nil)
+
+	plan, err := suite.usecase.Assign(context.Background(), requests, 3000, "dispatcher")
+	suite.NoError(err)
+	suite.Equal([]model.Assignment{{RequestID: "b", VehicleID: 2, Distance: 200, Reservation: held(2)}}, plan.Assignments)
+	suite.Equal([]string{"a"}, plan.Unassigned)
+	suite.Equal(200.0, plan.TotalDistance)
+}
+
+func (suite *DispatchTestSuite) TestAssign_WhenTheHolderHoldsTooManyVehicles_ShouldLeaveTheRestUnassigned() {
+	requests := []model.RideRequest{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	suite.locations.On("FindVehicleLocationsBatch", mock.Anything, mock.Anything).Return([]model.NearbyLocations{
+		{Locations: []model.Location{{VehicleID: 1, Distance: 100}}, Total: 1},
+		{Locations: []model.Location{{VehicleID: 2, Distance: 200}}, Total: 1},
+		{Locations: []model.Location{{VehicleID: 3, Distance: 300}}, Total: 1},
+	}, nil)
+	suite.vehicles.On("FindVehicleStatuses", mock.Anything, []int64{1, 2, 3}).Return(map[int64]string{1: "available", 2: "available", 3: "available"}, nil)
+	suite.reservations.On("Create", mock.Anything, int64(1), "dispatcher", 2*time.Minute, 3).Return(held(1), nil)
+	suite.reservations.On("Create", mock.Anything, int64(2), "dispatcher", 2*time.Minute, 3).Return(model.Reservation{}, repository.ErrTooManyHolds)
+
+	plan, err := suite.usecase.Assign(context.Background(), requests, 3000, "dispatcher")
+	suite.NoError(err)
+	suite.Equal([]model.Assignment{{RequestID: "a", VehicleID: 1, Distance: 100, Reservation: held(1)}}, plan.Assignments)
+	suite.Equal([]string{"b", "c"}, plan.Unassigned)
+	suite.reservations.AssertNotCalled(suite.T(), "Create", mock.Anything, int64(3), mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *DispatchTestSuite) TestAssign_WhenHoldingFails_ShouldReleaseTheHoldsAlreadyPlaced() {
+	requests := []model.RideRequest{{ID: "a"}, {ID: "b"}}
+	suite.locations.On("FindVehicleLocationsBatch", mock.Anything, mock.Anything).Return([]model.NearbyLocations{
+		{Locations: []model.Location{{VehicleID: 1, Distance: 100}}, Total: 1},
+		{Locations: []model.Location{{VehicleID: 2, Distance: 200}}, Total: 1},
+	}, nil)
+	suite.vehicles.On("FindVehicleStatuses", mock.Anything, []int64{1, 2}).Return(map[int64]string{1: "available", 2: "available"}, nil)
+	suite.reservations.On("Create", mock.Anything, int64(1), "dispatcher", 2*time.Minute, 3).Return(held(1), nil)
+	suite.reservations.On("Create", mock.Anything, int64(2), "dispatcher", 2*time.Minute, 3).Return(model.Reservation{}, errors.New("connection reset"))
+	suite.reservations.On("Transition", mock.Anything, int64(101), "dispatcher", 1, model.ReservationStatusCancelled).Return(model.Reservation{}, nil)
+
+	_, err := suite.usecase.Assign(context.Background(), requests, 3000, "dispatcher")
+	suite.EqualError(err, "failed to hold vehicle 2: connection reset")
+	suite.reservations.AssertExpectations(suite.T())
+}
+
+func TestDispatch(t *testing.T) {
+	suite.Run(t, new(DispatchTestSuite))
+}
@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"context"
+	"sort"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/tracing"
+
+	"github.com/pkg/errors"
+)
+
+// Snapper moves the pings of a single vehicle, oldest first, onto the road network and returns a Snap per ping
+type Snapper interface {
+	Match(ctx context.Context, pings []model.Ping) []model.Snap
+}
+
+// IngestUsecase stores the positions vehicles report
+type IngestUsecase interface {
+	Ingest(ctx context.Context, pings []model.Ping) (model.IngestResult, error)
+}
+
+type ingestUsecase struct {
+	logger            logger.Logger
+	ingestRepository  repository.IngestRepository
+	anomalyRepository repository.AnomalyRepository
+	invalidator       Invalidator
+	checks            AnomalyChecks
+	snapper           Snapper
+	serveSnapped      bool
+	keepHistory       bool
+}
+
+// NewIngestUsecase is a constructor for ingestUsecase. invalidator may be nil when nearby results aren't cached, and
+// snapper may be nil, which stores pings as they are; serveSnapped picks whether searches use the snapped or the raw
+// coordinates of a vehicle, and keepHistory whether every accepted ping is added to the location history as well
+func NewIngestUsecase(logger logger.Logger, ingestRepository repository.IngestRepository, anomalyRepository repository.AnomalyRepository, invalidator Invalidator,
+	checks AnomalyChecks, snapper Snapper, serveSnapped, keepHistory bool) IngestUsecase {
+	return &ingestUsecase{
+		logger:            logger,
+		ingestRepository:  ingestRepository,
+		anomalyRepository: anomalyRepository,
+		invalidator:       invalidator,
+		checks:            checks,
+		snapper:           snapper,
+		serveSnapped:      serveSnapped,
+		keepHistory:       keepHistory,
+	}
+}
+
+// Ingest stores the latest position of every vehicle in a batch of pings. Each ping is checked against the one
+// before it first, and the pings of a vehicle that pass are snapped together as a trajectory, so earlier pings still
+// help to place the latest one. Once positions are stored, the cached nearby results around where the vehicles were
+// and are now are dropped, so that they don't show vehicles where they were
+func (i ingestUsecase) Ingest(ctx context.Context, pings []model.Ping) (model.IngestResult, error) {
+	ctx, span := tracer.Start(ctx, "ingestUsecase.Ingest")
+	defer span.End()
+	span.SetAttributes(tracing.BatchSizeKey.Int(len(pings)))
+
+	grouped := trajectories(pings)
+	vehicleIDs := make([]int64, len(grouped))
+	for j, trajectory := range grouped {
+		vehicleIDs[j] = trajectory[0].VehicleID
+	}
+	tracks, err := i.ingestRepository.FindTracks(ctx, vehicleIDs)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to find the last positions of %d vehicles", len(vehicleIDs))
+		tracing.RecordError(span, err)
+		return model.IngestResult{}, err
+	}
+
+	result := model.IngestResult{Received: len(pings)}
+	positions := make([]model.Position, 0, len(grouped))
+	var history []model.Position
+	var anomalies []model.Anomaly
+	for _, trajectory := range grouped {
+		track, ok := tracks[trajectory[0].VehicleID]
+		if !ok {
+			track = model.Track{VehicleID: trajectory[0].VehicleID}
+		}
+		trajectory, found := i.checks.check(track, trajectory)
+		anomalies = append(anomalies, found...)
+		if len(trajectory) == 0 {
+			continue
+		}
+		snaps := make([]model.Snap, len(trajectory))
+		if i.snapper != nil {
+			snaps = i.snapper.Match(ctx, trajectory)
+		}
+		position := i.position(trajectory[len(trajectory)-1], snaps[len(snaps)-1])
+		if position.Snap.Found {
+			result.Snapped++
+		}
+		positions = append(positions, position)
+		if i.keepHistory {
+			for j, ping := range trajectory {
+				history = append(history, i.position(ping, snaps[j]))
+			}
+		}
+	}
+
+	for _, anomaly := range anomalies {
+		if anomaly.Action == model.AnomalyActionRejected {
+			result.Rejected++
+		} else {
+			result.Flagged++
+		}
+	}
+	i.recordAnomalies(ctx, anomalies)
+
+	stored, err := i.ingestRepository.SavePositions(ctx, positions)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to store the positions of %d vehicles", len(positions))
+		tracing.RecordError(span, err)
+		return model.IngestResult{}, err
+	}
+	result.Stored = stored
+	if stored > 0 {
+		i.invalidate(ctx, positions)
+	}
+	if !i.keepHistory {
+		return result, nil
+	}
+	// the history is added after the latest positions, so a request retried because storing them failed doesn't add its pings twice
+	if err = i.ingestRepository.SaveHistory(ctx, history); err != nil {
+		err = errors.Wrapf(err, "failed to add %d pings to the location history", len(history))
+		tracing.RecordError(span, err)
+		return model.IngestResult{}, err
+	}
+	return result, nil
+}
+
+// invalidate drops the cached nearby results that list the vehicles of positions where they were, and those around
+// where they are now
+func (i ingestUsecase) invalidate(ctx context.Context, positions []model.Position) {
+	vehicleIDs := make([]int64, len(positions))
+	locations := make([]model.Location, len(positions))
+	for j, position := range positions {
+		vehicleIDs[j] = position.VehicleID
+		locations[j] = model.Location{VehicleID: position.VehicleID, Latitude: position.Latitude, Longitude: position.Longitude}
+	}
+	invalidate(ctx, i.logger, i.invalidator, vehicleIDs, locations)
+}
+
+// position is where ping puts its vehicle, on the road of snap when it was found and snapped coordinates are served
+func (i ingestUsecase) position(ping model.Ping, snap model.Snap) model.Position {
+	position := model.Position{
+		VehicleID:    ping.VehicleID,
+		Latitude:     ping.Latitude,
+		Longitude:    ping.Longitude,
+		RawLatitude:  ping.Latitude,
+		RawLongitude: ping.Longitude,
+		Snap:         snap,
+		RecordedAt:   ping.RecordedAt,
+	}
+	if snap.Found && i.serveSnapped {
+		position.Latitude, position.Longitude, position.Snapped = snap.Latitude, snap.Longitude, true
+	}
+	return position
+}
+
+// recordAnomalies stores anomalies for review. Failing to do so is logged rather than failing the ingest,
+// since the pings have been dealt with either way
+func (i ingestUsecase) recordAnomalies(ctx context.Context, anomalies []model.Anomaly) {
+	if len(anomalies) == 0 {
+		return
+	}
+	if err := i.anomalyRepository.SaveAnomalies(ctx, anomalies); err != nil {
+		i.logger.WithContext(ctx).Errorf("failed to record %d anomalies, err: %s", len(anomalies), err.Error())
+	}
+}
+
+// trajectories groups pings by vehicle, in the order vehicles first appear, and orders the pings of each by when they were recorded
+func trajectories(pings []model.Ping) [][]model.Ping {
+	index := make(map[int64]int)
+	var grouped [][]model.Ping
+	for _, ping := range pings {
+		i, ok := index[ping.VehicleID]
+		if !ok {
+			i = len(grouped)
+			index[ping.VehicleID] = i
+			grouped = append(grouped, nil)
+		}
+		grouped[i] = append(grouped[i], ping)
+	}
+	for _, trajectory := range grouped {
+		sort.SliceStable(trajectory, func(a, b int) bool { return trajectory[a].RecordedAt.Before(trajectory[b].RecordedAt) })
+	}
+	return grouped
+}
@@ -0,0 +1,260 @@
+package usecase_test
+
+import (
+	"context"
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	repositoryMock "find-nearby-backend/repository/mocks"
+	"find-nearby-backend/usecase"
+	usecaseMock "find-nearby-backend/usecase/mocks"
+
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// northSnapper moves every ping south of 1.5 onto a road running north along 103.95
+type northSnapper struct {
+	calls [][]model.Ping
+}
+
+func (s *northSnapper) Match(ctx context.Context, pings []model.Ping) []model.Snap {
+	s.calls = append(s.calls, pings)
+	snaps := make([]model.Snap, len(pings))
+	for i, ping := range pings {
+		if ping.Latitude < 1.5 {
+			snaps[i] = model.Snap{Found: true, Latitude: ping.Latitude, Longitude: 103.95, Distance: 11}
+		}
+	}
+	return snaps
+}
+
+type IngestTestSuite struct {
+	suite.Suite
+	log         logger.Logger
+	positions   *repositoryMock.IngestRepository
+	anomalies   *repositoryMock.AnomalyRepository
+	invalidator *usecaseMock.Invalidator
+	tracks      map[int64]model.Track
+	snapper     *northSnapper
+	start       time.Time
+}
+
+func (suite *IngestTestSuite) SetupTest() {
+	cfg := config.LoadConfig()
+	suite.log = logger.New(cfg.LogLevel(), cfg.LogFormat())
+	suite.positions = &repositoryMock.IngestRepository{}
+	suite.anomalies = &repositoryMock.AnomalyRepository{}
+	suite.invalidator = &usecaseMock.Invalidator{}
+	suite.invalidator.On("Invalidate", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.tracks = map[int64]model.Track{}
+	suite.positions.On("FindTracks", mock.Anything, mock.Anything).Return(func(context.Context, []int64) map[int64]model.Track { return suite.tracks }, nil)
+	suite.snapper = &northSnapper{}
+	suite.start = time.Date(2021, 10, 3, 8, 0, 0, 0, time.UTC)
+}
+
+func (suite *IngestTestSuite) TestIngest_ShouldSnapTrajectoriesAndStoreTheLatestPositions() {
+	pings := []model.Ping{
+		{VehicleID: 1, Latitude: 1.3002, Longitude: 103.9, RecordedAt: suite.start.Add(2 * time.Second)},
+		{VehicleID: 2, Latitude: 1.6, Longitude: 103.9, RecordedAt: suite.start},
+		{VehicleID: 1, Latitude: 1.3001, Longitude: 103.9, RecordedAt: suite.start.Add(time.Second)},
+	}
+	expected := []model.Position{
+		{VehicleID: 1, Latitude: 1.3002, Longitude: 103.95, Snapped: true, RawLatitude: 1.3002, RawLongitude: 103.9,
+			Snap: model.Snap{Found: true, Latitude: 1.3002, Longitude: 103.95, Distance: 11}, RecordedAt: suite.start.Add(2 * time.Second)},
+		{VehicleID: 2, Latitude: 1.6, Longitude: 103.9, RawLatitude: 1.6, RawLongitude: 103.9, RecordedAt: suite.start},
+	}
+	suite.positions.On("SavePositions", mock.Anything, expected).Return(2, nil)
+
+	result, err := suite.ingest(usecase.AnomalyChecks{}, suite.snapper, true).Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.Equal(model.IngestResult{Received: 3, Stored: 2, Snapped: 1}, result)
+	suite.Len(suite.snapper.calls, 2)
+	suite.Equal([]model.Ping{pings[2], pings[0]}, suite.snapper.calls[0])
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenServingRawCoordinates_ShouldStillKeepTheSnap() {
+	pings := []model.Ping{{VehicleID: 1, Latitude: 1.3, Longitude: 103.9, RecordedAt: suite.start}}
+	suite.positions.On("SavePositions", mock.Anything, mock.Anything).Return(1, nil)
+
+	result, err := suite.ingest(usecase.AnomalyChecks{}, suite.snapper, false).Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.Equal(model.IngestResult{Received: 1, Stored: 1, Snapped: 1}, result)
+	actual := suite.positions.Calls[1].Arguments.Get(1).([]model.Position)
+	suite.False(actual[0].Snapped)
+	suite.Equal(103.9, actual[0].Longitude)
+	suite.True(actual[0].Snap.Found)
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenSnappingIsOff_ShouldStoreRawCoordinates() {
+	pings := []model.Ping{{VehicleID: 1, Latitude: 1.3, Longitude: 103.9, RecordedAt: suite.start}}
+	expected := []model.Position{{VehicleID: 1, Latitude: 1.3, Longitude: 103.9, RawLatitude: 1.3, RawLongitude: 103.9, RecordedAt: suite.start}}
+	suite.positions.On("SavePositions", mock.Anything, expected).Return(0, nil)
+
+	result, err := suite.ingest(usecase.AnomalyChecks{}, nil, true).Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.Equal(model.IngestResult{Received: 1}, result)
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenRepositoryFails_ShouldReturnError() {
+	pings := []model.Ping{{VehicleID: 1, Latitude: 1.3, Longitude: 103.9, RecordedAt: suite.start}}
+	suite.positions.On("SavePositions", mock.Anything, mock.Anything).Return(0, errors.New("connection refused"))
+
+	_, err := suite.ingest(usecase.AnomalyChecks{}, nil, true).Ingest(context.Background(), pings)
+	suite.EqualError(err, "failed to store the positions of 1 vehicles: connection refused")
+	suite.invalidator.AssertNotCalled(suite.T(), "Invalidate", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenVehicleTeleports_ShouldRejectThePingAndRecordIt() {
+	recordedAt := suite.start.Add(-time.Minute)
+	suite.tracks[1] = model.Track{VehicleID: 1, Type: "scooter", Latitude: 1.3, Longitude: 103.9, RecordedAt: &recordedAt}
+	pings := []model.Ping{
+		// 500m in a minute is 30 km/h; the next ping is 50km off a second later
+		{VehicleID: 1, Latitude: 1.3045, Longitude: 103.9, RecordedAt: suite.start},
+		{VehicleID: 1, Latitude: 1.75, Longitude: 103.9, RecordedAt: suite.start.Add(time.Second)},
+	}
+	suite.positions.On("SavePositions", mock.Anything, mock.MatchedBy(func(positions []model.Position) bool {
+		return len(positions) == 1 && positions[0].Latitude == 1.3045
+	})).Return(1, nil)
+	suite.anomalies.On("SaveAnomalies", mock.Anything, mock.MatchedBy(func(anomalies []model.Anomaly) bool {
+		return len(anomalies) == 1 && anomalies[0].Kind == model.AnomalyKindSpeed && anomalies[0].Action == model.AnomalyActionRejected &&
+			*anomalies[0].MaxSpeed == 60 && *anomalies[0].Speed > 100000
+	})).Return(nil)
+
+	checks := usecase.AnomalyChecks{MaxSpeeds: map[string]float64{"scooter": 60}, MaxSpeed: 250}
+	result, err := suite.ingest(checks, nil, true).Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.Equal(model.IngestResult{Received: 2, Stored: 1, Rejected: 1}, result)
+	suite.anomalies.AssertExpectations(suite.T())
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenPingsAreBackDated_ShouldMeasureThemBackFromTheStoredFix() {
+	suite.tracks[1] = model.Track{VehicleID: 1, Type: "scooter", Latitude: 1.3, Longitude: 103.9, RecordedAt: &suite.start}
+	pings := []model.Ping{
+		// 50km off an hour before the stored fix is 50 km/h, but 50km off a minute before it is a teleport
+		{VehicleID: 1, Latitude: 1.75, Longitude: 103.9, RecordedAt: suite.start.Add(-time.Hour)},
+		{VehicleID: 1, Latitude: 1.75, Longitude: 103.9, RecordedAt: suite.start.Add(-time.Minute)},
+		// measured from the stored fix, not from the back-dated ping 50km off that was accepted
+		{VehicleID: 1, Latitude: 1.75, Longitude: 103.9, RecordedAt: suite.start.Add(time.Second)},
+	}
+	suite.positions.On("SavePositions", mock.Anything, mock.MatchedBy(func(positions []model.Position) bool {
+		return len(positions) == 1 && positions[0].RecordedAt.Equal(suite.start.Add(-time.Hour))
+	})).Return(0, nil)
+	suite.anomalies.On("SaveAnomalies", mock.Anything, mock.MatchedBy(func(anomalies []model.Anomaly) bool {
+		return len(anomalies) == 2 && anomalies[0].Kind == model.AnomalyKindSpeed && anomalies[1].Kind == model.AnomalyKindSpeed &&
+			anomalies[0].RecordedAt.Equal(suite.start.Add(-time.Minute)) && anomalies[1].RecordedAt.Equal(suite.start.Add(time.Second))
+	})).Return(nil)
+
+	checks := usecase.AnomalyChecks{MaxSpeeds: map[string]float64{"scooter": 60}}
+	result, err := suite.ingest(checks, nil, true).Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.Equal(model.IngestResult{Received: 3, Rejected: 2}, result)
+	suite.anomalies.AssertExpectations(suite.T())
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenFlagging_ShouldStoreAnomaliesButStillDropNullIsland() {
+	area, err := model.ParseArea([]byte(`{"type": "Polygon", "coordinates": [[[103.6, 1.2], [104.1, 1.2], [104.1, 1.5], [103.6, 1.5], [103.6, 1.2]]]}`))
+	suite.Require().NoError(err)
+	pings := []model.Ping{
+		{VehicleID: 1, Latitude: 0, Longitude: 0, RecordedAt: suite.start},
+		{VehicleID: 2, Latitude: 1.6, Longitude: 103.9, RecordedAt: suite.start},
+		{VehicleID: 3, Latitude: 1.3, Longitude: 103.9, RecordedAt: suite.start},
+	}
+	suite.positions.On("SavePositions", mock.Anything, mock.MatchedBy(func(positions []model.Position) bool {
+		return len(positions) == 2 && positions[0].VehicleID == 2 && positions[1].VehicleID == 3
+	})).Return(2, nil)
+	suite.anomalies.On("SaveAnomalies", mock.Anything, []model.Anomaly{
+		{VehicleID: 1, Kind: model.AnomalyKindNullIsland, Action: model.AnomalyActionRejected, RecordedAt: suite.start},
+		{VehicleID: 2, Kind: model.AnomalyKindOutOfArea, Action: model.AnomalyActionFlagged, Latitude: 1.6, Longitude: 103.9, RecordedAt: suite.start},
+	}).Return(nil)
+
+	result, err := suite.ingest(usecase.AnomalyChecks{ServiceArea: area, Flag: true}, nil, true).Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.Equal(model.IngestResult{Received: 3, Stored: 2, Rejected: 1, Flagged: 1}, result)
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenRecordingAnomaliesFails_ShouldStillStorePositions() {
+	pings := []model.Ping{
+		{VehicleID: 1, Latitude: 0, Longitude: 0, RecordedAt: suite.start},
+		{VehicleID: 2, Latitude: 1.3, Longitude: 103.9, RecordedAt: suite.start},
+	}
+	suite.anomalies.On("SaveAnomalies", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+	suite.positions.On("SavePositions", mock.Anything, mock.Anything).Return(1, nil)
+
+	result, err := suite.ingest(usecase.AnomalyChecks{}, nil, true).Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.Equal(model.IngestResult{Received: 2, Stored: 1, Rejected: 1}, result)
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenHistoryIsKept_ShouldAddEveryAcceptedPing() {
+	pings := []model.Ping{
+		{VehicleID: 1, Latitude: 1.3002, Longitude: 103.9, RecordedAt: suite.start.Add(2 * time.Second)},
+		{VehicleID: 1, Latitude: 0, Longitude: 0, RecordedAt: suite.start.Add(3 * time.Second)},
+		{VehicleID: 1, Latitude: 1.3001, Longitude: 103.9, RecordedAt: suite.start.Add(time.Second)},
+	}
+	suite.anomalies.On("SaveAnomalies", mock.Anything, mock.Anything).Return(nil)
+	suite.positions.On("SavePositions", mock.Anything, mock.Anything).Return(1, nil)
+	suite.positions.On("SaveHistory", mock.Anything, mock.Anything).Return(nil)
+
+	ingest := usecase.NewIngestUsecase(suite.log, suite.positions, suite.anomalies, suite.invalidator, usecase.AnomalyChecks{}, suite.snapper, true, true)
+	result, err := ingest.Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.Equal(model.IngestResult{Received: 3, Stored: 1, Snapped: 1, Rejected: 1}, result)
+
+	history := suite.positions.Calls[2].Arguments.Get(1).([]model.Position)
+	suite.Require().Len(history, 2)
+	suite.Equal(suite.start.Add(time.Second), history[0].RecordedAt)
+	suite.Equal(103.95, history[0].Longitude)
+	suite.Equal(103.9, history[0].RawLongitude)
+	suite.True(history[1].Snapped)
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenAddingToTheHistoryFails_ShouldReturnError() {
+	pings := []model.Ping{{VehicleID: 1, Latitude: 1.3, Longitude: 103.9, RecordedAt: suite.start}}
+	suite.positions.On("SavePositions", mock.Anything, mock.Anything).Return(1, nil)
+	suite.positions.On("SaveHistory", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+
+	_, err := usecase.NewIngestUsecase(suite.log, suite.positions, suite.anomalies, suite.invalidator, usecase.AnomalyChecks{}, nil, true, true).Ingest(context.Background(), pings)
+	suite.EqualError(err, "failed to add 1 pings to the location history: connection refused")
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenPositionsAreStored_ShouldInvalidateCachedResults() {
+	pings := []model.Ping{{VehicleID: 1, Latitude: 1.3, Longitude: 103.9, RecordedAt: suite.start}}
+	suite.positions.On("SavePositions", mock.Anything, mock.Anything).Return(1, nil).Once()
+	suite.positions.On("SavePositions", mock.Anything, mock.Anything).Return(0, nil).Once()
+	ingest := suite.ingest(usecase.AnomalyChecks{}, nil, true)
+
+	_, err := ingest.Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.invalidator.AssertNumberOfCalls(suite.T(), "Invalidate", 1)
+	suite.invalidator.AssertCalled(suite.T(), "Invalidate", mock.Anything, []int64{1}, []model.Location{{VehicleID: 1, Latitude: 1.3, Longitude: 103.9}})
+
+	// a retried request whose pings are all older than the stored positions changes nothing
+	_, err = ingest.Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.invalidator.AssertNumberOfCalls(suite.T(), "Invalidate", 1)
+}
+
+func (suite *IngestTestSuite) TestIngest_WhenInvalidationFails_ShouldStillSucceed() {
+	pings := []model.Ping{{VehicleID: 1, Latitude: 1.3, Longitude: 103.9, RecordedAt: suite.start}}
+	suite.positions.On("SavePositions", mock.Anything, mock.Anything).Return(1, nil)
+	suite.invalidator = &usecaseMock.Invalidator{}
+	suite.invalidator.On("Invalidate", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("redis is down"))
+
+	result, err := suite.ingest(usecase.AnomalyChecks{}, nil, true).Ingest(context.Background(), pings)
+	suite.NoError(err)
+	suite.Equal(model.IngestResult{Received: 1, Stored: 1}, result)
+	suite.invalidator.AssertExpectations(suite.T())
+}
+
+func (suite *IngestTestSuite) ingest(checks usecase.AnomalyChecks, snapper usecase.Snapper, serveSnapped bool) usecase.IngestUsecase {
+	return usecase.NewIngestUsecase(suite.log, suite.positions, suite.anomalies, suite.invalidator, checks, snapper, serveSnapped, false)
+}
+
+func TestIngestUsecase(t *testing.T) {
+	suite.Run(t, new(IngestTestSuite))
+}
@@ -1,31 +1,143 @@
 package usecase
 
 import (
+	"context"
+	"sort"
+
+	"find-nearby-backend/logger"
 	"find-nearby-backend/model"
 	"find-nearby-backend/repository"
+	"find-nearby-backend/tracing"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("find-nearby-backend/usecase")
+
 // LocationUsecase is responsible for any location-related business logic
 type LocationUsecase interface {
-	FindVehicleLocations(latitude, longitude float64, radius, limit int) ([]model.Location, error)
+	FindVehicleLocations(ctx context.Context, latitude, longitude float64, radius, limit int) (model.NearbyLocations, error)
+	FindVehicleLocationsBatch(ctx context.Context, queries []model.NearbyQuery) ([]model.NearbyLocations, error)
+	FindVehicleLocationsByETA(ctx context.Context, latitude, longitude float64, radius, limit int) (model.NearbyLocations, error)
+	FindVehicleLocationsAlongRoute(ctx context.Context, corridor model.Corridor) (model.NearbyLocations, error)
+}
+
+// Router finds drive times from vehicles to an origin over a road network
+type Router interface {
+	Routes(ctx context.Context, latitude, longitude float64, locations []model.Location) []model.Route
 }
 
+// ErrRoutingUnavailable is returned for ETA ranking when no road graph is loaded
+var ErrRoutingUnavailable = errors.New("ranking by eta is not available: no road graph is loaded")
+
 type locationUsecase struct {
+	logger             logger.Logger
 	locationRepository repository.LocationRepository
+	router             Router
+	candidateFactor    int
 }
 
-// NewLocationUsecase is a constructor for locationUsecase
-func NewLocationUsecase(locationRepository repository.LocationRepository) LocationUsecase {
-	return &locationUsecase{locationRepository: locationRepository}
+// NewLocationUsecase is a constructor for locationUsecase. router may be nil, which turns ETA ranking off;
+// candidateFactor is how many times the limit of straight-line candidates it re-ranks
+func NewLocationUsecase(logger logger.Logger, locationRepository repository.LocationRepository, router Router, candidateFactor int) LocationUsecase {
+	if candidateFactor < 1 {
+		candidateFactor = 1
+	}
+	return &locationUsecase{logger: logger, locationRepository: locationRepository, router: router, candidateFactor: candidateFactor}
 }
 
 // FindVehicleLocations finds nearby locations
-func (l locationUsecase) FindVehicleLocations(latitude, longitude float64, radius, limit int) ([]model.Location, error) {
-	locations, err := l.locationRepository.FindVehicleLocations(latitude, longitude, radius, limit)
+func (l locationUsecase) FindVehicleLocations(ctx context.Context, latitude, longitude float64, radius, limit int) (model.NearbyLocations, error) {
+	ctx, span := tracer.Start(ctx, "locationUsecase.FindVehicleLocations")
+	defer span.End()
+	span.SetAttributes(tracing.QueryAttributes(latitude, longitude, radius, limit)...)
+
+	l.logger.WithContext(ctx).Debugf("finding up to %d vehicle locations within %dm of (%f, %f)", limit, radius, latitude, longitude)
+	nearby, err := l.locationRepository.FindVehicleLocations(ctx, latitude, longitude, radius, limit)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to find the locations within the range")
+		tracing.RecordError(span, err)
+		return model.NearbyLocations{}, err
+	}
+	span.SetAttributes(tracing.ResultCountKey.Int(len(nearby.Locations)))
+	return nearby, nil
+}
+
+// FindVehicleLocationsBatch finds nearby locations for many origins at once; the results are in the order of the queries
+func (l locationUsecase) FindVehicleLocationsBatch(ctx context.Context, queries []model.NearbyQuery) ([]model.NearbyLocations, error) {
+	ctx, span := tracer.Start(ctx, "locationUsecase.FindVehicleLocationsBatch")
+	defer span.End()
+	span.SetAttributes(tracing.BatchSizeKey.Int(len(queries)))
+
+	l.logger.WithContext(ctx).Debugf("finding vehicle locations for %d origins", len(queries))
+	results, err := l.locationRepository.FindVehicleLocationsBatch(ctx, queries)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to find the locations within the range of %d origins", len(queries))
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// FindVehicleLocationsByETA finds nearby locations ranked by drive time to the origin instead of straight-line distance.
+// The nearest candidates by distance are routed over the road graph and the fastest limit of them returned;
+// candidates the roads don't connect to the origin come last, closest first
+func (l locationUsecase) FindVehicleLocationsByETA(ctx context.Context, latitude, longitude float64, radius, limit int) (model.NearbyLocations, error) {
+	ctx, span := tracer.Start(ctx, "locationUsecase.FindVehicleLocationsByETA")
+	defer span.End()
+	span.SetAttributes(tracing.QueryAttributes(latitude, longitude, radius, limit)...)
+
+	if l.router == nil {
+		tracing.RecordError(span, ErrRoutingUnavailable)
+		return model.NearbyLocations{}, ErrRoutingUnavailable
+	}
+	candidates := limit * l.candidateFactor
+	l.logger.WithContext(ctx).Debugf("ranking up to %d vehicle locations within %dm of (%f, %f) by eta", candidates, radius, latitude, longitude)
+	nearby, err := l.locationRepository.FindVehicleLocations(ctx, latitude, longitude, radius, candidates)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to find the locations within the range")
+		tracing.RecordError(span, err)
+		return model.NearbyLocations{}, err
+	}
+
+	_, routeSpan := tracer.Start(ctx, "locationUsecase.FindVehicleLocationsByETA.route")
+	routes := l.router.Routes(ctx, latitude, longitude, nearby.Locations)
+	routeSpan.End()
+	ranked := make([]model.Location, len(nearby.Locations))
+	for i, location := range nearby.Locations {
+		if routes[i].Found {
+			eta, routeDistance := routes[i].Duration, routes[i].Distance
+			location.ETA, location.RouteDistance = &eta, &routeDistance
+		}
+		ranked[i] = location
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if (ranked[i].ETA == nil) != (ranked[j].ETA == nil) {
+			return ranked[i].ETA != nil
+		}
+		return ranked[i].ETA != nil && *ranked[i].ETA < *ranked[j].ETA
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	span.SetAttributes(tracing.ResultCountKey.Int(len(ranked)))
+	return model.NearbyLocations{Locations: ranked, Total: nearby.Total}, nil
+}
+
+// FindVehicleLocationsAlongRoute finds the locations within the buffer of a route
+func (l locationUsecase) FindVehicleLocationsAlongRoute(ctx context.Context, corridor model.Corridor) (model.NearbyLocations, error) {
+	ctx, span := tracer.Start(ctx, "locationUsecase.FindVehicleLocationsAlongRoute")
+	defer span.End()
+	span.SetAttributes(tracing.RoutePointsKey.Int(len(corridor.Route)), tracing.BufferKey.Int(corridor.Buffer), tracing.LimitKey.Int(corridor.Limit))
+
+	l.logger.WithContext(ctx).Debugf("finding up to %d vehicle locations within %dm of a route of %d points", corridor.Limit, corridor.Buffer, len(corridor.Route))
+	nearby, err := l.locationRepository.FindVehicleLocationsAlongRoute(ctx, corridor)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to find the locations within the range")
+		err = errors.Wrapf(err, "failed to find the locations along the route")
+		tracing.RecordError(span, err)
+		return model.NearbyLocations{}, err
 	}
-	return locations, nil
+	span.SetAttributes(tracing.ResultCountKey.Int(len(nearby.Locations)))
+	return nearby, nil
 }
@@ -1,7 +1,9 @@
 package usecase_test
 
 import (
+	"context"
 	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
 	"find-nearby-backend/model"
 	locationMock "find-nearby-backend/repository/mocks"
 	"find-nearby-backend/usecase"
@@ -9,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -22,7 +25,7 @@ type LocationTestSuite struct {
 func (suite *LocationTestSuite) SetupTest() {
 	suite.cfg = config.LoadConfig()
 	suite.repository = &locationMock.LocationRepository{}
-	suite.usecase = usecase.NewLocationUsecase(suite.repository)
+	suite.usecase = usecase.NewLocationUsecase(logger.New(suite.cfg.LogLevel(), suite.cfg.LogFormat()), suite.repository, nil, 1)
 }
 
 func (suite *LocationTestSuite) TestFindVehicleLocations_WhenRepoReturnsNoError_ShouldReturnNoError() {
@@ -40,9 +43,9 @@ func (suite *LocationTestSuite) TestFindVehicleLocations_WhenRepoReturnsNoError_
 		Latitude:  46.4211,
 		Longitude: -76.6903,
 	}
-	expectedLocs := []model.Location{loc1, loc2}
-	suite.repository.On("FindVehicleLocations", latitude, longitude, radius, limit).Return(expectedLocs, nil)
-	actualLocs, err := suite.usecase.FindVehicleLocations(latitude, longitude, radius, limit)
+	expectedLocs := model.NearbyLocations{Locations: []model.Location{loc1, loc2}, Total: 5}
+	suite.repository.On("FindVehicleLocations", mock.Anything, latitude, longitude, radius, limit).Return(expectedLocs, nil)
+	actualLocs, err := suite.usecase.FindVehicleLocations(context.Background(), latitude, longitude, radius, limit)
 	suite.NoError(err)
 	suite.Equal(expectedLocs, actualLocs)
 	suite.repository.AssertExpectations(suite.T())
@@ -57,13 +60,87 @@ func (suite *LocationTestSuite) TestFindVehicleLocations_WhenRepoReturnsError_Sh
 	err := errors.New("some repo error")
 	expectedErr := errors.Wrapf(err, "failed to find the locations within the range")
 
-	suite.repository.On("FindVehicleLocations", latitude, longitude, radius, limit).Return(nil, err)
-	actualLocs, actualErr := suite.usecase.FindVehicleLocations(latitude, longitude, radius, limit)
+	suite.repository.On("FindVehicleLocations", mock.Anything, latitude, longitude, radius, limit).Return(model.NearbyLocations{}, err)
+	actualLocs, actualErr := suite.usecase.FindVehicleLocations(context.Background(), latitude, longitude, radius, limit)
 	suite.EqualError(actualErr, expectedErr.Error())
-	suite.Nil(actualLocs)
+	suite.Nil(actualLocs.Locations)
 	suite.repository.AssertExpectations(suite.T())
 }
 
+func (suite *LocationTestSuite) TestFindVehicleLocationsBatch_WhenRepoReturnsError_ShouldReturnError() {
+	queries := []model.NearbyQuery{{Latitude: 45.4211, Longitude: -75.6903, Radius: 10, Limit: 10}}
+	err := errors.New("some repo error")
+	expectedErr := errors.Wrapf(err, "failed to find the locations within the range of 1 origins")
+
+	suite.repository.On("FindVehicleLocationsBatch", mock.Anything, queries).Return(nil, err)
+	actual, actualErr := suite.usecase.FindVehicleLocationsBatch(context.Background(), queries)
+	suite.EqualError(actualErr, expectedErr.Error())
+	suite.Nil(actual)
+	suite.repository.AssertExpectations(suite.T())
+}
+
+func (suite *LocationTestSuite) TestFindVehicleLocationsByETA_ShouldRankCandidatesByDriveTime() {
+	router := routesByVehicle{
+		1: {Found: true, Duration: 600, Distance: 5000},
+		2: {Found: true, Duration: 120, Distance: 900},
+		4: {Found: true, Duration: 300, Distance: 2500},
+	}
+	usecase := usecase.NewLocationUsecase(logger.New(suite.cfg.LogLevel(), suite.cfg.LogFormat()), suite.repository, router, 2)
+	candidates := model.NearbyLocations{Locations: []model.Location{{VehicleID: 1, Distance: 100}, {VehicleID: 2, Distance: 200}, {VehicleID: 3, Distance: 300}, {VehicleID: 4, Distance: 400}}, Total: 9}
+	suite.repository.On("FindVehicleLocations", mock.Anything, 1.3, 103.9, 1000, 4).Return(candidates, nil)
+
+	actual, err := usecase.FindVehicleLocationsByETA(context.Background(), 1.3, 103.9, 1000, 2)
+	suite.NoError(err)
+	suite.Equal(9, actual.Total)
+	suite.Require().Len(actual.Locations, 2)
+	suite.Equal(int64(2), actual.Locations[0].VehicleID)
+	suite.Equal(120.0, *actual.Locations[0].ETA)
+	suite.Equal(900.0, *actual.Locations[0].RouteDistance)
+	suite.Equal(int64(4), actual.Locations[1].VehicleID)
+	suite.Nil(candidates.Locations[1].ETA)
+}
+
+func (suite *LocationTestSuite) TestFindVehicleLocationsByETA_WhenRouteIsNotFound_ShouldRankLast() {
+	router := routesByVehicle{2: {Found: true, Duration: 120, Distance: 900}}
+	usecase := usecase.NewLocationUsecase(logger.New(suite.cfg.LogLevel(), suite.cfg.LogFormat()), suite.repository, router, 1)
+	candidates := model.NearbyLocations{Locations: []model.Location{{VehicleID: 1, Distance: 100}, {VehicleID: 2, Distance: 200}, {VehicleID: 3, Distance: 300}}, Total: 3}
+	suite.repository.On("FindVehicleLocations", mock.Anything, 1.3, 103.9, 1000, 3).Return(candidates, nil)
+
+	actual, err := usecase.FindVehicleLocationsByETA(context.Background(), 1.3, 103.9, 1000, 3)
+	suite.NoError(err)
+	suite.Require().Len(actual.Locations, 3)
+	suite.Equal([]int64{2, 1, 3}, []int64{actual.Locations[0].VehicleID, actual.Locations[1].VehicleID, actual.Locations[2].VehicleID})
+	suite.Nil(actual.Locations[1].ETA)
+}
+
+func (suite *LocationTestSuite) TestFindVehicleLocationsByETA_WhenRouterIsMissing_ShouldReturnErrRoutingUnavailable() {
+	_, err := suite.usecase.FindVehicleLocationsByETA(context.Background(), 1.3, 103.9, 1000, 2)
+	suite.Equal(usecase.ErrRoutingUnavailable, err)
+	suite.repository.AssertNotCalled(suite.T(), "FindVehicleLocations", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *LocationTestSuite) TestFindVehicleLocationsAlongRoute_WhenRepoReturnsError_ShouldReturnError() {
+	corridor := model.Corridor{Route: [][]float64{{103.9, 1.3}, {103.91, 1.31}}, Buffer: 300, Limit: 10, Order: model.OrderByStart}
+	err := errors.New("some repo error")
+
+	suite.repository.On("FindVehicleLocationsAlongRoute", mock.Anything, corridor).Return(model.NearbyLocations{}, err)
+	actual, actualErr := suite.usecase.FindVehicleLocationsAlongRoute(context.Background(), corridor)
+	suite.EqualError(actualErr, "failed to find the locations along the route: some repo error")
+	suite.Nil(actual.Locations)
+	suite.repository.AssertExpectations(suite.T())
+}
+
+// routesByVehicle is a usecase.Router with a fixed route per vehicle
+type routesByVehicle map[int64]model.Route
+
+func (r routesByVehicle) Routes(ctx context.Context, latitude, longitude float64, locations []model.Location) []model.Route {
+	routes := make([]model.Route, len(locations))
+	for i, location := range locations {
+		routes[i] = r[location.VehicleID]
+	}
+	return routes
+}
+
 func TestUsecase(t *testing.T) {
 	suite.Run(t, new(LocationTestSuite))
 }
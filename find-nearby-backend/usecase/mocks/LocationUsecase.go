@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	model "find-nearby-backend/model"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,22 +15,85 @@ type LocationUsecase struct {
 	mock.Mock
 }
 
-// FindVehicleLocations provides a mock function with given fields: latitude, longitude, radius, limit
-func (_m *LocationUsecase) FindVehicleLocations(latitude float64, longitude float64, radius int, limit int) ([]model.Location, error) {
-	ret := _m.Called(latitude, longitude, radius, limit)
+// FindVehicleLocations provides a mock function with given fields: ctx, latitude, longitude, radius, limit
+func (_m *LocationUsecase) FindVehicleLocations(ctx context.Context, latitude float64, longitude float64, radius int, limit int) (model.NearbyLocations, error) {
+	ret := _m.Called(ctx, latitude, longitude, radius, limit)
 
-	var r0 []model.Location
-	if rf, ok := ret.Get(0).(func(float64, float64, int, int) []model.Location); ok {
-		r0 = rf(latitude, longitude, radius, limit)
+	var r0 model.NearbyLocations
+	if rf, ok := ret.Get(0).(func(context.Context, float64, float64, int, int) model.NearbyLocations); ok {
+		r0 = rf(ctx, latitude, longitude, radius, limit)
+	} else {
+		r0 = ret.Get(0).(model.NearbyLocations)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, float64, float64, int, int) error); ok {
+		r1 = rf(ctx, latitude, longitude, radius, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindVehicleLocationsAlongRoute provides a mock function with given fields: ctx, corridor
+func (_m *LocationUsecase) FindVehicleLocationsAlongRoute(ctx context.Context, corridor model.Corridor) (model.NearbyLocations, error) {
+	ret := _m.Called(ctx, corridor)
+
+	var r0 model.NearbyLocations
+	if rf, ok := ret.Get(0).(func(context.Context, model.Corridor) model.NearbyLocations); ok {
+		r0 = rf(ctx, corridor)
+	} else {
+		r0 = ret.Get(0).(model.NearbyLocations)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, model.Corridor) error); ok {
+		r1 = rf(ctx, corridor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindVehicleLocationsBatch provides a mock function with given fields: ctx, queries
+func (_m *LocationUsecase) FindVehicleLocationsBatch(ctx context.Context, queries []model.NearbyQuery) ([]model.NearbyLocations, error) {
+	ret := _m.Called(ctx, queries)
+
+	var r0 []model.NearbyLocations
+	if rf, ok := ret.Get(0).(func(context.Context, []model.NearbyQuery) []model.NearbyLocations); ok {
+		r0 = rf(ctx, queries)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.Location)
+			r0 = ret.Get(0).([]model.NearbyLocations)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(float64, float64, int, int) error); ok {
-		r1 = rf(latitude, longitude, radius, limit)
+	if rf, ok := ret.Get(1).(func(context.Context, []model.NearbyQuery) error); ok {
+		r1 = rf(ctx, queries)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindVehicleLocationsByETA provides a mock function with given fields: ctx, latitude, longitude, radius, limit
+func (_m *LocationUsecase) FindVehicleLocationsByETA(ctx context.Context, latitude float64, longitude float64, radius int, limit int) (model.NearbyLocations, error) {
+	ret := _m.Called(ctx, latitude, longitude, radius, limit)
+
+	var r0 model.NearbyLocations
+	if rf, ok := ret.Get(0).(func(context.Context, float64, float64, int, int) model.NearbyLocations); ok {
+		r0 = rf(ctx, latitude, longitude, radius, limit)
+	} else {
+		r0 = ret.Get(0).(model.NearbyLocations)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, float64, float64, int, int) error); ok {
+		r1 = rf(ctx, latitude, longitude, radius, limit)
 	} else {
 		r1 = ret.Error(1)
 	}
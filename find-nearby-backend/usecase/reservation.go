@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/tracing"
+
+	"github.com/pkg/errors"
+)
+
+// Invalidator drops cached nearby results. Write paths that change where vehicles are, or which of them show up
+// nearby, call it with the vehicles they changed and, for the vehicles that may show up somewhere new, their positions
+type Invalidator interface {
+	Invalidate(ctx context.Context, vehicleIDs []int64, positions []model.Location) error
+}
+
+// ReservationUsecase holds vehicles for users and moves the holds through their lifecycle
+type ReservationUsecase interface {
+	Reserve(ctx context.Context, vehicleID int64, holder string, hold time.Duration) (model.Reservation, error)
+	Get(ctx context.Context, id int64, holder string) (model.Reservation, error)
+	Cancel(ctx context.Context, id int64, holder string, version int) (model.Reservation, error)
+	Confirm(ctx context.Context, id int64, holder string, version int) (model.Reservation, error)
+	Complete(ctx context.Context, id int64, holder string, version int) (model.Reservation, error)
+	ExpireHolds(ctx context.Context) (int64, error)
+}
+
+type reservationUsecase struct {
+	logger                logger.Logger
+	reservationRepository repository.ReservationRepository
+	vehicleRepository     repository.VehicleRepository
+	invalidator           Invalidator
+	maxHolds              int
+}
+
+// NewReservationUsecase is a constructor for reservationUsecase. vehicleRepository finds where the vehicles handed
+// back are, for invalidator, which may be nil when nearby results aren't cached. maxHolds is how many vehicles a holder
+// can hold at a time
+func NewReservationUsecase(logger logger.Logger, reservationRepository repository.ReservationRepository, vehicleRepository repository.VehicleRepository,
+	invalidator Invalidator, maxHolds int) ReservationUsecase {
+	return &reservationUsecase{logger: logger, reservationRepository: reservationRepository, vehicleRepository: vehicleRepository, invalidator: invalidator, maxHolds: maxHolds}
+}
+
+// Reserve holds a vehicle for holder, leaving it out of nearby results until the hold ends
+func (r reservationUsecase) Reserve(ctx context.Context, vehicleID int64, holder string, hold time.Duration) (model.Reservation, error) {
+	ctx, span := tracer.Start(ctx, "reservationUsecase.Reserve")
+	defer span.End()
+
+	reservation, err := r.reservationRepository.Create(ctx, vehicleID, holder, hold, r.maxHolds)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to reserve vehicle %d", vehicleID)
+		tracing.RecordError(span, err)
+		return model.Reservation{}, err
+	}
+	r.invalidate(ctx, reservation.VehicleID, false)
+	return reservation, nil
+}
+
+// Get returns a reservation of holder. Reservations of other holders can't be read, any more than they can be changed
+func (r reservationUsecase) Get(ctx context.Context, id int64, holder string) (model.Reservation, error) {
+	ctx, span := tracer.Start(ctx, "reservationUsecase.Get")
+	defer span.End()
+
+	reservation, err := r.reservationRepository.Get(ctx, id)
+	if err == nil && reservation.Holder != holder {
+		err = repository.ErrNotReservationHolder
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "failed to get reservation %d", id)
+		tracing.RecordError(span, err)
+		return model.Reservation{}, err
+	}
+	return reservation, nil
+}
+
+// Cancel releases a hold, or a confirmed reservation, putting the vehicle back into nearby results
+func (r reservationUsecase) Cancel(ctx context.Context, id int64, holder string, version int) (model.Reservation, error) {
+	return r.transition(ctx, "reservationUsecase.Cancel", id, holder, version, model.ReservationStatusCancelled)
+}
+
+// Confirm turns a hold into a ride; the vehicle becomes busy
+func (r reservationUsecase) Confirm(ctx context.Context, id int64, holder string, version int) (model.Reservation, error) {
+	return r.transition(ctx, "reservationUsecase.Confirm", id, holder, version, model.ReservationStatusConfirmed)
+}
+
+// Complete ends the ride of a confirmed reservation; the vehicle becomes available again
+func (r reservationUsecase) Complete(ctx context.Context, id int64, holder string, version int) (model.Reservation, error) {
+	return r.transition(ctx, "reservationUsecase.Complete", id, holder, version, model.ReservationStatusCompleted)
+}
+
+// ExpireHolds expires the holds that have lapsed. Lapsed holds stop hiding their vehicles right away, and cached
+// nearby results catch up within the cache TTL; expiring them only settles their status
+func (r reservationUsecase) ExpireHolds(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "reservationUsecase.ExpireHolds")
+	defer span.End()
+
+	expired, err := r.reservationRepository.ExpireHolds(ctx)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to expire lapsed holds")
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	if expired > 0 {
+		r.logger.WithContext(ctx).Debugf("expired %d lapsed holds", expired)
+	}
+	return expired, nil
+}
+
+func (r reservationUsecase) transition(ctx context.Context, spanName string, id int64, holder string, version int, status string) (model.Reservation, error) {
+	ctx, span := tracer.Start(ctx, spanName)
+	defer span.End()
+
+	reservation, err := r.reservationRepository.Transition(ctx, id, holder, version, status)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to move reservation %d to %s", id, status)
+		tracing.RecordError(span, err)
+		return model.Reservation{}, err
+	}
+	r.invalidate(ctx, reservation.VehicleID, status != model.ReservationStatusConfirmed)
+	return reservation, nil
+}
+
+// invalidate drops the cached nearby results that a change to a vehicle made stale. A vehicle handed back shows up
+// again where it is, so that is looked up; failing to is only logged, and the results there catch up within the TTL
+func (r reservationUsecase) invalidate(ctx context.Context, vehicleID int64, handedBack bool) {
+	if r.invalidator == nil {
+		return
+	}
+	var positions []model.Location
+	if handedBack {
+		var err error
+		if positions, err = r.vehicleRepository.FindVehiclePositions(ctx, []int64{vehicleID}); err != nil {
+			r.logger.WithContext(ctx).Warnf("failed to find where vehicle %d is, err: %s", vehicleID, err.Error())
+		}
+	}
+	invalidate(ctx, r.logger, r.invalidator, []int64{vehicleID}, positions)
+}
+
+// invalidate drops cached nearby results, if there is an invalidator. A failure is only logged: the entries expire on
+// their own after the cache TTL
+func invalidate(ctx context.Context, logger logger.Logger, invalidator Invalidator, vehicleIDs []int64, positions []model.Location) {
+	if invalidator == nil {
+		return
+	}
+	if err := invalidator.Invalidate(ctx, vehicleIDs, positions); err != nil {
+		logger.WithContext(ctx).Warnf("failed to invalidate cached nearby results, err: %s", err.Error())
+	}
+}
@@ -0,0 +1,136 @@
+package usecase_test
+
+import (
+	"context"
+	"find-nearby-backend/config"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	repositoryMock "find-nearby-backend/repository/mocks"
+	"find-nearby-backend/usecase"
+
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type countingInvalidator struct {
+	calls      int
+	vehicleIDs []int64
+	positions  []model.Location
+	err        error
+}
+
+func (i *countingInvalidator) Invalidate(ctx context.Context, vehicleIDs []int64, positions []model.Location) error {
+	i.calls++
+	i.vehicleIDs = append(i.vehicleIDs, vehicleIDs...)
+	i.positions = append(i.positions, positions...)
+	return i.err
+}
+
+type ReservationTestSuite struct {
+	suite.Suite
+	usecase      usecase.ReservationUsecase
+	reservations *repositoryMock.ReservationRepository
+	vehicles     *repositoryMock.VehicleRepository
+	invalidator  *countingInvalidator
+}
+
+func (suite *ReservationTestSuite) SetupTest() {
+	cfg := config.LoadConfig()
+	suite.reservations = &repositoryMock.ReservationRepository{}
+	suite.vehicles = &repositoryMock.VehicleRepository{}
+	suite.invalidator = &countingInvalidator{}
+	suite.usecase = usecase.NewReservationUsecase(logger.New(cfg.LogLevel(), cfg.LogFormat()), suite.reservations, suite.vehicles, suite.invalidator, 3)
+}
+
+func (suite *ReservationTestSuite) TestReserve_ShouldInvalidateCachedResults() {
+	expected := model.Reservation{ID: 1, VehicleID: 7, Holder: "alice", Status: model.ReservationStatusHeld, Version: 1}
+	suite.reservations.On("Create", mock.Anything, int64(7), "alice", 5*time.Minute, 3).Return(expected, nil)
+
+	actual, err := suite.usecase.Reserve(context.Background(), 7, "alice", 5*time.Minute)
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+	suite.Equal(1, suite.invalidator.calls)
+	suite.Equal([]int64{7}, suite.invalidator.vehicleIDs)
+	suite.Empty(suite.invalidator.positions, "a held vehicle only drops out of the results that list it")
+	suite.vehicles.AssertNotCalled(suite.T(), "FindVehiclePositions", mock.Anything, mock.Anything)
+}
+
+func (suite *ReservationTestSuite) TestReserve_WhenVehicleIsUnavailable_ShouldKeepTheCause() {
+	suite.reservations.On("Create", mock.Anything, int64(7), "bob", 5*time.Minute, 3).Return(model.Reservation{}, repository.ErrVehicleUnavailable)
+
+	_, err := suite.usecase.Reserve(context.Background(), 7, "bob", 5*time.Minute)
+	suite.EqualError(err, "failed to reserve vehicle 7: vehicle is not available or is already held")
+	suite.Equal(repository.ErrVehicleUnavailable, errors.Cause(err))
+	suite.Equal(0, suite.invalidator.calls)
+}
+
+func (suite *ReservationTestSuite) TestGet_WhenCallerIsNotTheHolder_ShouldReturnErrNotReservationHolder() {
+	reservation := model.Reservation{ID: 1, VehicleID: 7, Holder: "alice", Status: model.ReservationStatusHeld, Version: 1}
+	suite.reservations.On("Get", mock.Anything, int64(1)).Return(reservation, nil)
+
+	actual, err := suite.usecase.Get(context.Background(), 1, "alice")
+	suite.NoError(err)
+	suite.Equal(reservation, actual)
+	_, err = suite.usecase.Get(context.Background(), 1, "bob")
+	suite.EqualError(err, "failed to get reservation 1: reservation is held by someone else")
+	suite.Equal(repository.ErrNotReservationHolder, errors.Cause(err))
+}
+
+func (suite *ReservationTestSuite) TestConfirm_WhenInvalidationFails_ShouldStillSucceed() {
+	expected := model.Reservation{ID: 1, Status: model.ReservationStatusConfirmed, Version: 2}
+	suite.reservations.On("Transition", mock.Anything, int64(1), "alice", 1, model.ReservationStatusConfirmed).Return(expected, nil)
+	suite.invalidator.err = errors.New("redis is down")
+
+	actual, err := suite.usecase.Confirm(context.Background(), 1, "alice", 1)
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+}
+
+func (suite *ReservationTestSuite) TestComplete_ShouldInvalidateCachedResultsWhereTheVehicleIs() {
+	expected := model.Reservation{ID: 1, VehicleID: 7, Status: model.ReservationStatusCompleted, Version: 3}
+	position := model.Location{VehicleID: 7, Latitude: 1.3, Longitude: 103.9}
+	suite.reservations.On("Transition", mock.Anything, int64(1), "alice", 2, model.ReservationStatusCompleted).Return(expected, nil)
+	suite.vehicles.On("FindVehiclePositions", mock.Anything, []int64{7}).Return([]model.Location{position}, nil)
+
+	actual, err := suite.usecase.Complete(context.Background(), 1, "alice", 2)
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+	suite.Equal(1, suite.invalidator.calls)
+	suite.Equal([]int64{7}, suite.invalidator.vehicleIDs)
+	suite.Equal([]model.Location{position}, suite.invalidator.positions)
+}
+
+func (suite *ReservationTestSuite) TestCancel_WhenThePositionCantBeFound_ShouldStillInvalidateByVehicle() {
+	expected := model.Reservation{ID: 1, VehicleID: 7, Status: model.ReservationStatusCancelled, Version: 2}
+	suite.reservations.On("Transition", mock.Anything, int64(1), "alice", 1, model.ReservationStatusCancelled).Return(expected, nil)
+	suite.vehicles.On("FindVehiclePositions", mock.Anything, []int64{7}).Return(nil, errors.New("connection reset"))
+
+	actual, err := suite.usecase.Cancel(context.Background(), 1, "alice", 1)
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+	suite.Equal([]int64{7}, suite.invalidator.vehicleIDs)
+	suite.Empty(suite.invalidator.positions)
+}
+
+func (suite *ReservationTestSuite) TestExpireHolds_ShouldLeaveCachedResultsToTheTTL() {
+	suite.reservations.On("ExpireHolds", mock.Anything).Return(int64(0), nil).Once()
+	suite.reservations.On("ExpireHolds", mock.Anything).Return(int64(3), nil).Once()
+
+	expired, err := suite.usecase.ExpireHolds(context.Background())
+	suite.NoError(err)
+	suite.Equal(int64(0), expired)
+
+	expired, err = suite.usecase.ExpireHolds(context.Background())
+	suite.NoError(err)
+	suite.Equal(int64(3), expired)
+	suite.Equal(0, suite.invalidator.calls, "lapsed holds show up again without being expired")
+}
+
+func TestReservation(t *testing.T) {
+	suite.Run(t, new(ReservationTestSuite))
+}
@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	"find-nearby-backend/repository"
+	"find-nearby-backend/tracing"
+
+	"github.com/pkg/errors"
+)
+
+// RetentionPolicy is how long the location history is kept. History older than DownsampleAfter is thinned out to the
+// first point per vehicle and DownsampleInterval, and history older than Horizon deleted, BatchSize rows at a time
+type RetentionPolicy struct {
+	DownsampleAfter    time.Duration
+	DownsampleInterval time.Duration
+	Horizon            time.Duration
+	BatchSize          int
+}
+
+// RetentionUsecase compacts the location history
+type RetentionUsecase interface {
+	Compact(ctx context.Context, now time.Time) (model.RetentionReport, error)
+}
+
+type retentionUsecase struct {
+	logger            logger.Logger
+	historyRepository repository.HistoryRepository
+	policy            RetentionPolicy
+}
+
+// NewRetentionUsecase is a constructor for retentionUsecase
+func NewRetentionUsecase(logger logger.Logger, historyRepository repository.HistoryRepository, policy RetentionPolicy) RetentionUsecase {
+	return &retentionUsecase{logger: logger, historyRepository: historyRepository, policy: policy}
+}
+
+// Compact deletes the history past the horizon, then downsamples what is older than DownsampleAfter. The downsampling
+// goes through the history an hour or so at a time, oldest first, so each statement only ranks a slice of it, and
+// starts from the watermark a previous compaction left for the interval, so history that was already thinned out
+// isn't ranked again. When it fails or ctx is done part way, the report counts what was removed until then
+func (r retentionUsecase) Compact(ctx context.Context, now time.Time) (model.RetentionReport, error) {
+	ctx, span := tracer.Start(ctx, "retentionUsecase.Compact")
+	defer span.End()
+
+	interval := r.policy.DownsampleInterval
+	report := model.RetentionReport{
+		DeletedBefore:     now.Add(-r.policy.Horizon),
+		DownsampledBefore: truncate(now.Add(-r.policy.DownsampleAfter), interval),
+	}
+	deleted, err := r.inBatches(ctx, func(ctx context.Context) (int, error) {
+		return r.historyRepository.DeleteHistory(ctx, report.DeletedBefore, r.policy.BatchSize)
+	})
+	report.Deleted = deleted
+	if err != nil {
+		err = errors.Wrapf(err, "failed to delete the history recorded before %s", report.DeletedBefore.Format(time.RFC3339))
+		tracing.RecordError(span, err)
+		return report, err
+	}
+
+	oldest, err := r.historyRepository.OldestHistory(ctx)
+	if err != nil {
+		err = errors.Wrap(err, "failed to find the oldest history")
+		tracing.RecordError(span, err)
+		return report, err
+	}
+	if oldest == nil {
+		return report, nil
+	}
+	watermark, err := r.historyRepository.Watermark(ctx, interval)
+	if err != nil {
+		err = errors.Wrap(err, "failed to find how far the history was downsampled")
+		tracing.RecordError(span, err)
+		return report, err
+	}
+	// slices are a whole number of intervals, so no interval is split between two of them
+	slice := interval * ((time.Hour + interval - 1) / interval)
+	from := truncate(*oldest, slice)
+	if watermark != nil && watermark.After(from) {
+		from = *watermark
+	}
+	for from.Before(report.DownsampledBefore) {
+		to := truncate(from, slice).Add(slice)
+		if to.After(report.DownsampledBefore) {
+			to = report.DownsampledBefore
+		}
+		downsampled, err := r.inBatches(ctx, func(ctx context.Context) (int, error) {
+			return r.historyRepository.DownsampleHistory(ctx, from, to, interval, r.policy.BatchSize)
+		})
+		report.Downsampled += downsampled
+		if err != nil {
+			err = errors.Wrapf(err, "failed to downsample the history recorded from %s", from.Format(time.RFC3339))
+			tracing.RecordError(span, err)
+			return report, err
+		}
+		if err := r.historyRepository.SetWatermark(ctx, interval, to); err != nil {
+			err = errors.Wrapf(err, "failed to record that the history before %s was downsampled", to.Format(time.RFC3339))
+			tracing.RecordError(span, err)
+			return report, err
+		}
+		from = to
+	}
+	r.logger.WithContext(ctx).Infof("compacted the location history: deleted %d points recorded before %s and downsampled %d recorded before %s",
+		report.Deleted, report.DeletedBefore.Format(time.RFC3339), report.Downsampled, report.DownsampledBefore.Format(time.RFC3339))
+	return report, nil
+}
+
+// inBatches runs remove until it removes less than a full batch and returns how many rows it removed in all
+func (r retentionUsecase) inBatches(ctx context.Context, remove func(ctx context.Context) (int, error)) (int, error) {
+	total := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		removed, err := remove(ctx)
+		total += removed
+		if err != nil || removed < r.policy.BatchSize {
+			return total, err
+		}
+	}
+}
+
+// truncate rounds t down to a multiple of d since the Unix epoch, whatever the location of t
+func truncate(t time.Time, d time.Duration) time.Time {
+	return time.Unix(0, t.UnixNano()/int64(d)*int64(d)).In(t.Location())
+}
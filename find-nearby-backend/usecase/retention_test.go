@@ -0,0 +1,74 @@
+package usecase_test
+
+import (
+	"context"
+	"find-nearby-backend/logger"
+	"find-nearby-backend/model"
+	repositoryMock "find-nearby-backend/repository/mocks"
+	"find-nearby-backend/usecase"
+
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var retentionPolicy = usecase.RetentionPolicy{DownsampleAfter: 24 * time.Hour, DownsampleInterval: time.Minute, Horizon: 48 * time.Hour, BatchSize: 2}
+
+func TestCompact_ShouldDeleteInBatchesAndDownsampleHourByHour(t *testing.T) {
+	now := time.Date(2021, 10, 10, 12, 30, 30, 0, time.UTC)
+	deletedBefore := time.Date(2021, 10, 8, 12, 30, 30, 0, time.UTC)
+	oldest := time.Date(2021, 10, 9, 10, 15, 0, 0, time.UTC)
+	hour := func(h, m int) time.Time { return time.Date(2021, 10, 9, h, m, 0, 0, time.UTC) }
+
+	history := &repositoryMock.HistoryRepository{}
+	history.On("DeleteHistory", mock.Anything, deletedBefore, 2).Return(2, nil).Once()
+	history.On("DeleteHistory", mock.Anything, deletedBefore, 2).Return(1, nil).Once()
+	history.On("OldestHistory", mock.Anything).Return(&oldest, nil)
+	history.On("Watermark", mock.Anything, time.Minute).Return(nil, nil)
+	history.On("DownsampleHistory", mock.Anything, hour(10, 0), hour(11, 0), time.Minute, 2).Return(2, nil).Once()
+	history.On("DownsampleHistory", mock.Anything, hour(10, 0), hour(11, 0), time.Minute, 2).Return(0, nil).Once()
+	history.On("SetWatermark", mock.Anything, time.Minute, hour(11, 0)).Return(nil).Once()
+	history.On("DownsampleHistory", mock.Anything, hour(11, 0), hour(12, 0), time.Minute, 2).Return(1, nil).Once()
+	history.On("SetWatermark", mock.Anything, time.Minute, hour(12, 0)).Return(nil).Once()
+	history.On("DownsampleHistory", mock.Anything, hour(12, 0), hour(12, 30), time.Minute, 2).Return(0, nil).Once()
+	history.On("SetWatermark", mock.Anything, time.Minute, hour(12, 30)).Return(nil).Once()
+
+	report, err := usecase.NewRetentionUsecase(logger.New("debug", "plaintext"), history, retentionPolicy).Compact(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, model.RetentionReport{Deleted: 3, Downsampled: 3, DeletedBefore: deletedBefore, DownsampledBefore: hour(12, 30)}, report)
+	history.AssertExpectations(t)
+}
+
+func TestCompact_WhenThereIsAWatermark_ShouldStartDownsamplingFromIt(t *testing.T) {
+	now := time.Date(2021, 10, 10, 12, 30, 30, 0, time.UTC)
+	oldest := time.Date(2021, 10, 9, 10, 15, 0, 0, time.UTC)
+	watermark := time.Date(2021, 10, 9, 12, 0, 0, 0, time.UTC)
+	downsampledBefore := time.Date(2021, 10, 9, 12, 30, 0, 0, time.UTC)
+
+	history := &repositoryMock.HistoryRepository{}
+	history.On("DeleteHistory", mock.Anything, mock.Anything, 2).Return(0, nil).Once()
+	history.On("OldestHistory", mock.Anything).Return(&oldest, nil)
+	history.On("Watermark", mock.Anything, time.Minute).Return(&watermark, nil)
+	history.On("DownsampleHistory", mock.Anything, watermark, downsampledBefore, time.Minute, 2).Return(1, nil).Once()
+	history.On("SetWatermark", mock.Anything, time.Minute, downsampledBefore).Return(nil).Once()
+
+	report, err := usecase.NewRetentionUsecase(logger.New("debug", "plaintext"), history, retentionPolicy).Compact(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Downsampled)
+	history.AssertExpectations(t)
+}
+
+func TestCompact_WhenADeleteFails_ShouldReportWhatWasRemovedSoFar(t *testing.T) {
+	history := &repositoryMock.HistoryRepository{}
+	history.On("DeleteHistory", mock.Anything, mock.Anything, 2).Return(2, nil).Once()
+	history.On("DeleteHistory", mock.Anything, mock.Anything, 2).Return(0, errors.New("canceling statement due to lock timeout")).Once()
+
+	now := time.Date(2021, 10, 10, 12, 30, 30, 0, time.UTC)
+	report, err := usecase.NewRetentionUsecase(logger.New("debug", "plaintext"), history, retentionPolicy).Compact(context.Background(), now)
+	assert.EqualError(t, err, "failed to delete the history recorded before 2021-10-08T12:30:30Z: canceling statement due to lock timeout")
+	assert.Equal(t, 2, report.Deleted)
+	history.AssertNotCalled(t, "OldestHistory", mock.Anything)
+}